@@ -2,6 +2,7 @@ package cache
 
 import (
 	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
@@ -246,3 +247,144 @@ func RedisDelKey(key string, pool *redis.Pool) error {
 	_, err = conn.Do("DEL", key)
 	return err
 }
+
+// key不存在时才设置，返回是否设置成功
+func RedisSetStringNX(key, value string, ttl int, pool *redis.Pool) (bool, error) {
+	conn := pool.Get()
+	defer conn.Close()
+
+	if err := conn.Err(); err != nil {
+		return false, err
+	}
+
+	_, err := redis.String(conn.Do("SET", key, value, "EX", ttl, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// 读取并删除，用于只能使用一次的值，key不存在时返回redis.ErrNil
+func RedisTakeString(key string, pool *redis.Pool) (string, error) {
+	conn := pool.Get()
+	defer conn.Close()
+
+	if err := conn.Err(); err != nil {
+		return "", err
+	}
+
+	conn.Send("MULTI")
+	conn.Send("GET", key)
+	conn.Send("DEL", key)
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return "", err
+	}
+	return redis.String(values[0], nil)
+}
+
+// 计数加1并返回结果，第一次计数时设置过期时间，用于固定时间窗口的次数限制
+func RedisIncrCount(key string, ttl int, pool *redis.Pool) (int, error) {
+	conn := pool.Get()
+	defer conn.Close()
+
+	if err := conn.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := redis.Int(conn.Do("INCR", key))
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 && ttl > 0 {
+		if _, err := conn.Do("EXPIRE", key, ttl); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// 计数减1，用于退回RedisIncrCount预先占用的次数
+func RedisDecrCount(key string, pool *redis.Pool) error {
+	conn := pool.Get()
+	defer conn.Close()
+
+	if err := conn.Err(); err != nil {
+		return err
+	}
+
+	_, err := conn.Do("DECR", key)
+	return err
+}
+
+// 有序集合成员分数自增，ttl>0时设置过期时间
+func RedisZIncrBy(key, member string, incr float64, ttl int, pool *redis.Pool) error {
+	conn := pool.Get()
+	defer conn.Close()
+
+	if err := conn.Err(); err != nil {
+		return err
+	}
+
+	_, err := conn.Do("ZINCRBY", key, incr, member)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		_, err = conn.Do("EXPIRE", key, ttl)
+	}
+	return err
+}
+
+// 合并多个有序集合的分数，weights为每个集合的权重
+func RedisZUnionStore(dest string, keys []string, weights []float64, ttl int, pool *redis.Pool) error {
+	conn := pool.Get()
+	defer conn.Close()
+
+	if err := conn.Err(); err != nil {
+		return err
+	}
+
+	args := redis.Args{}.Add(dest, len(keys)).AddFlat(keys)
+	if len(weights) > 0 {
+		args = args.Add("WEIGHTS").AddFlat(weights)
+	}
+	if _, err := conn.Do("ZUNIONSTORE", args...); err != nil {
+		return err
+	}
+	if ttl > 0 {
+		_, err := conn.Do("EXPIRE", dest, ttl)
+		return err
+	}
+	return nil
+}
+
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// 按分数从高到低获取有序集合成员
+func RedisZRevRangeWithScores(key string, start, stop int, pool *redis.Pool) ([]ZMember, error) {
+	conn := pool.Get()
+	defer conn.Close()
+
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+
+	values, err := redis.Strings(conn.Do("ZREVRANGE", key, start, stop, "WITHSCORES"))
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]ZMember, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		score, err := strconv.ParseFloat(values[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, ZMember{Member: values[i], Score: score})
+	}
+	return members, nil
+}
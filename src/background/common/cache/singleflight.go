@@ -0,0 +1,51 @@
+package cache
+
+import "sync"
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+/*
+	同一个key同时只执行一次加载，其余调用等待并共享结果
+*/
+type FlightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall
+}
+
+func (g *FlightGroup) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := new(flightCall)
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	defer func() {
+		c.wg.Done()
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
+
+// key是否正在加载
+func (g *FlightGroup) InFlight(key string) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	_, ok := g.calls[key]
+	return ok
+}
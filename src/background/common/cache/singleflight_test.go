@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroupDo(t *testing.T) {
+	var g FlightGroup
+	var calls int32
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			v, err := g.Do("key", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(time.Millisecond * 100)
+				return "value", nil
+			})
+			if err != nil || v.(string) != "value" {
+				t.Errorf("unexpected result: %v %v", v, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected 1 call, got %d", n)
+	}
+	if g.InFlight("key") {
+		t.Error("key should not be in flight after Do returned")
+	}
+}
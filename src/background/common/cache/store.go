@@ -22,6 +22,7 @@ type Store struct {
 	mutex    *sync.Mutex
 	loading  map[string]bool
 	lazy     bool
+	flight   *FlightGroup
 }
 
 type valueWrapper struct {
@@ -33,6 +34,9 @@ type valueWrapper struct {
 
 type StoreLoadFunc func() (interface{}, error)
 
+// 加载函数同时返回值的有效期(秒)，小于等于0表示不缓存
+type StoreLoadTTLFunc func() (interface{}, int, error)
+
 func NewStore(redisAddr, redisPassword string, redisTTL, memTTL int, lazy bool) *Store {
 	s := new(Store)
 	s.pool = GetRedisPool(redisAddr, redisPassword)
@@ -42,6 +46,7 @@ func NewStore(redisAddr, redisPassword string, redisTTL, memTTL int, lazy bool)
 	s.mutex = new(sync.Mutex)
 	s.loading = make(map[string]bool)
 	s.lazy = lazy
+	s.flight = new(FlightGroup)
 	return s
 }
 
@@ -95,19 +100,55 @@ func fWraper(f StoreLoadFunc) (interface{}, error) {
 	return f()
 }
 
+// 加载标记的有效期(秒)，加载进程异常退出时标记自动失效
+const loadingTTL = 60
+
+/*
+	用SET NX抢占key的加载标记，返回是否抢到。
+	抢到的进程加载完成后删除标记
+*/
+func (s *Store) tryLoading(key string) (bool, error) {
+	return RedisSetStringNX(key+"_loading", "1", loadingTTL, s.pool)
+}
+
+func (s *Store) doneLoading(key string) {
+	RedisDelKey(key+"_loading", s.pool)
+}
+
+/*
+	等待其他进程加载完成并抢占加载标记，超过10秒返回错误。
+	等待过时返回true，此时key可能已经有新值
+*/
+func (s *Store) waitLoading(key string) (bool, error) {
+	n := time.Now()
+	waited := false
+	for {
+		ok, err := s.tryLoading(key)
+		if err != nil {
+			logger.Error(err)
+			return waited, err
+		}
+		if ok {
+			return waited, nil
+		}
+		waited = true
+		if time.Since(n) > time.Second*10 {
+			return waited, errors.New("fetch result for key " + key + " timeout")
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+}
+
 func (s *Store) lazyLoadRedis(key string, ttl int, f StoreLoadFunc) {
-	loadingKey := key + "_loading"
-	loading, err := RedisGetInt(loadingKey, s.pool)
-	if err != nil && err != redis.ErrNil {
+	ok, err := s.tryLoading(key)
+	if err != nil {
 		logger.Error(err)
 		return
 	}
-	if loading > 0 {
+	if !ok {
 		return
 	}
-
-	RedisSetInt(loadingKey, 1, 60, s.pool)
-	defer RedisDelKey(loadingKey, s.pool)
+	defer s.doneLoading(key)
 
 	o, err := fWraper(f)
 	if err != nil {
@@ -159,28 +200,23 @@ func (s *Store) lazyLoadMem(key string, ttl int, f StoreLoadFunc) {
 }
 
 func (s *Store) doCleanLoad(key string, obj interface{}, ttl int, f StoreLoadFunc) error {
-	loadingKey := key + "_loading"
+	waited, err := s.waitLoading(key)
+	if err != nil {
+		return err
+	}
+	defer s.doneLoading(key)
 
-	n := time.Now()
-	for {
-		loading, err := RedisGetInt(loadingKey, s.pool)
-		if err != nil && err != redis.ErrNil {
-			logger.Error(err)
-			return err
-		}
-		if loading > 0 {
-			if time.Now().Unix()-n.Unix() > 10 {
-				return errors.New("fetch result for key " + key + " timeout")
+	// 等待的其他进程已经加载完成
+	if waited {
+		if str, err := RedisGetString(key, s.pool); err == nil {
+			var val valueWrapper
+			if err := json.Unmarshal([]byte(str), &val); err == nil {
+				s.saveValue(key, val.Value, ttl, true, false)
+				return json.Unmarshal([]byte(val.Value), obj)
 			}
-			time.Sleep(time.Millisecond * 50)
-			continue
 		}
-		break
 	}
 
-	RedisSetInt(loadingKey, 1, 60, s.pool)
-	defer RedisDelKey(loadingKey, s.pool)
-
 	o, err := fWraper(f)
 	if err != nil {
 		logger.Error(err)
@@ -256,5 +292,113 @@ func (s *Store) GetJsonObject(key string, obj interface{}, f StoreLoadFunc) erro
 	return s.GetJsonObjectWithExpire(key, obj, 0, f)
 }
 
+/*
+	值的有效期由加载函数返回，用于自带失效时间的数据(如带auth_key的播放地址)。
+	距离过期不足refreshBefore秒时返回当前值并在后台刷新，已过期的值不再返回；
+	同一个key进程内只有一个加载，进程间通过redis的_loading标记(SET NX)去重
+*/
+func (s *Store) GetJsonObjectWithLoadTTL(key string, obj interface{}, refreshBefore int, f StoreLoadTTLFunc) error {
+	if val := s.getUnexpiredValue(key); val != nil {
+		if val.ExpiredAt.Before(time.Now().Add(refreshWindow(val, refreshBefore))) && !s.flight.InFlight(key) {
+			go func() {
+				if _, err := s.flight.Do(key, func() (interface{}, error) {
+					return s.loadWithTTL(key, f)
+				}); err != nil {
+					logger.Error(err)
+				}
+			}()
+		}
+		return json.Unmarshal([]byte(val.Value), obj)
+	}
+
+	data, err := s.flight.Do(key, func() (interface{}, error) {
+		return s.loadWithTTL(key, f)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data.(string)), obj)
+}
+
+/*
+	提前刷新的时间，不超过值有效期的1/4，
+	避免有效期比refreshBefore短时每次请求都刷新
+*/
+func refreshWindow(val *valueWrapper, refreshBefore int) time.Duration {
+	window := time.Second * time.Duration(refreshBefore)
+	if max := val.ExpiredAt.Sub(val.CreatedAt) / 4; window > max {
+		window = max
+	}
+	return window
+}
+
+// 依次从内存、redis中读取未过期的值
+func (s *Store) getUnexpiredValue(key string) *valueWrapper {
+	now := time.Now()
+	if v, ok := s.mem.Get(key); ok {
+		valp := v.(*valueWrapper)
+		if valp.ExpiredAt.After(now) {
+			return valp
+		}
+	}
+
+	str, err := RedisGetString(key, s.pool)
+	if err != nil {
+		if err != redis.ErrNil {
+			logger.Error(err)
+		}
+		return nil
+	}
+	var val valueWrapper
+	if err := json.Unmarshal([]byte(str), &val); err != nil {
+		logger.Error(err)
+		return nil
+	}
+	if !val.ExpiredAt.After(now) {
+		return nil
+	}
+	s.mem.Set(key, &val, val.ExpiredAt.Sub(now))
+	return &val
+}
+
+// 其他进程正在加载时等待其结果
+func (s *Store) loadWithTTL(key string, f StoreLoadTTLFunc) (string, error) {
+	waited, err := s.waitLoading(key)
+	if err != nil {
+		return "", err
+	}
+	defer s.doneLoading(key)
+
+	if waited {
+		if val := s.getUnexpiredValue(key); val != nil {
+			return val.Value, nil
+		}
+	}
+
+	var ttl int
+	o, err := fWraper(func() (interface{}, error) {
+		o, t, err := f()
+		ttl = t
+		return o, err
+	})
+	if err != nil {
+		logger.Error(err)
+		return "", err
+	}
+	if o == nil {
+		return "", errors.New("load nil value for key " + key)
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		logger.Error(err)
+		return "", err
+	}
+
+	if ttl > 0 {
+		s.saveValue(key, string(data), ttl, true, true)
+	}
+	return string(data), nil
+}
+
 func (s *Store) Delete(keyPattern string) {
 }
@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshWindow(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		ttl           time.Duration
+		refreshBefore int
+		want          time.Duration
+	}{
+		{time.Hour, 60, time.Minute},
+		{time.Minute * 2, 60, time.Second * 30},
+		{time.Second * 20, 60, time.Second * 5},
+		{time.Hour, 0, 0},
+	}
+	for _, c := range cases {
+		val := &valueWrapper{CreatedAt: now, ExpiredAt: now.Add(c.ttl)}
+		if got := refreshWindow(val, c.refreshBefore); got != c.want {
+			t.Errorf("ttl %v refreshBefore %d: got %v, want %v", c.ttl, c.refreshBefore, got, c.want)
+		}
+	}
+}
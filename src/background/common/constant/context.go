@@ -4,6 +4,7 @@ const (
 	ContextStorageRoot    = "contextstorageroot"
 	ContextDb             = "contextdb"
 	ContextUser           = "contextuser"
+	ContextUserTier       = "contextusertier"
 	ContextOsType         = "contextostype"
 	ContextAppVersion     = "contextappversion"
 	ContextAppKey         = "contextappkey"
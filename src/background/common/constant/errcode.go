@@ -39,6 +39,10 @@ const (
 	RegistrationDisabled   = 10028
 	UnAuthorizedResource   = 10029
 	IncorrectBundleId      = 10030
+	PlaybackNotSupported   = 10031
+	UpgradeRequired        = 10032
+	BeanNotEnough          = 10033
+	RedeemTierInvalid      = 10034
 
 	// ims side error, starts with 2000-
 	AdminNotExists        = 20001
@@ -62,6 +66,8 @@ const (
 	InvalidInput          = 21000
 	InvalidPlayurl        = 21001
 	PlayurlExists         = 21002
+	InvalidScript         = 21003
+	ScriptNotExists       = 21004
 	// common (both api + ims) error, starts with 3000-
 	WrongUsernamePassword = 30002
 )
@@ -167,10 +173,22 @@ func TranslateErrCode(code int, extra ...string) string {
 		msg = "资源未授权"
 	case IncorrectBundleId:
 		msg = "Bundle Id不正确"
+	case PlaybackNotSupported:
+		msg = "该频道不支持回看"
+	case UpgradeRequired:
+		msg = "会员等级不足，请升级会员"
+	case BeanNotEnough:
+		msg = "金豆不足"
+	case RedeemTierInvalid:
+		msg = "兑换的会员等级不高于当前等级"
 	case InvalidPlayurl:
 		msg = "无效的播放链接"
 	case PlayurlExists:
 		msg = "链接已存在"
+	case InvalidScript:
+		msg = "脚本无法加载"
+	case ScriptNotExists:
+		msg = "脚本不存在"
 	default:
 	}
 
@@ -115,6 +115,8 @@ const (
 	ContentProviderKuai     = 8
 	ContentProviderMigu     = 9
 	ContentProviderHanju    = 10
+	ContentProviderRRmeiju  = 14   // 11-13在播放地址脚本中已使用
+	ContentProviderDirectUrl = 20  // 直链片源，和System、Hanju一样不经过解析脚本，直接检测地址
 
 	ContentProviderCntv     = 30
 	ContentProviderHuashu   = 31
@@ -0,0 +1,74 @@
+package usertoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	服务之间传递已登录用户的短期token，由用户所在的服务签发，其他服务用相同的密钥校验。
+	密钥只保存在服务端配置中，不能和app签名的密钥相同。
+	格式为user_id.installation_id.expires.sign
+*/
+
+var (
+	ErrInvalidToken = errors.New("用户token无效")
+	ErrTokenExpired = errors.New("用户token已过期")
+)
+
+type User struct {
+	UserId         uint64
+	InstallationId uint64
+	Expires        int64
+}
+
+func sign(secret, data string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func Issue(secret string, userId, installationId uint64, ttl time.Duration, now time.Time) string {
+	data := fmt.Sprintf("%d.%d.%d", userId, installationId, now.Add(ttl).Unix())
+	return data + "." + sign(secret, data)
+}
+
+func Verify(secret, token string, now time.Time) (*User, error) {
+	if secret == "" {
+		return nil, ErrInvalidToken
+	}
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return nil, ErrInvalidToken
+	}
+	data := token[:i]
+	if !hmac.Equal([]byte(token[i+1:]), []byte(sign(secret, data))) {
+		return nil, ErrInvalidToken
+	}
+
+	fields := strings.Split(data, ".")
+	if len(fields) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var u User
+	var err error
+	if u.UserId, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if u.InstallationId, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if u.Expires, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() > u.Expires {
+		return nil, ErrTokenExpired
+	}
+	return &u, nil
+}
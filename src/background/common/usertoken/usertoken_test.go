@@ -0,0 +1,38 @@
+package usertoken
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserToken(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	token := Issue("secret", 12, 34, time.Minute*5, now)
+
+	u, err := Verify("secret", token, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.UserId != 12 || u.InstallationId != 34 || u.Expires != now.Add(time.Minute*5).Unix() {
+		t.Errorf("user %+v", u)
+	}
+
+	if _, err := Verify("secret", token, now.Add(time.Minute*6)); err != ErrTokenExpired {
+		t.Errorf("expired %v", err)
+	}
+	if _, err := Verify("other", token, now); err != ErrInvalidToken {
+		t.Errorf("other secret %v", err)
+	}
+	if _, err := Verify("", token, now); err != ErrInvalidToken {
+		t.Errorf("empty secret %v", err)
+	}
+	// 修改user_id后签名不匹配
+	if _, err := Verify("secret", "13"+token[2:], now); err != ErrInvalidToken {
+		t.Errorf("tampered %v", err)
+	}
+	for _, bad := range []string{"", "abc", "1.2.3", "a.b.c." + sign("secret", "a.b.c")} {
+		if _, err := Verify("secret", bad, now); err != ErrInvalidToken {
+			t.Errorf("%q: %v", bad, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"background/verification_code/challenge"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -21,6 +22,24 @@ type config struct {
 	CmsRoot       string `json:"cms_root"`
 	AreaData      string `json:"area_data"`
 
+	CaptchaRedisAddr     string `json:"captcha_redis_addr"` // 验证码服务的redis，不为空时发表评论需要验证码
+	CaptchaRedisPassword string `json:"captcha_redis_password"`
+
+	CaptchaTTL            int      `json:"captcha_ttl"` // 以下和验证码服务的配置相同，没有配置时使用默认值
+	CaptchaMaxAttempts    int      `json:"captcha_max_attempts"`
+	CaptchaIpMaxFailures  int      `json:"captcha_ip_max_failures"`
+	CaptchaIpWindow       int      `json:"captcha_ip_window"`
+	CaptchaTicketTTL      int      `json:"captcha_ticket_ttl"`
+	CaptchaTrustedProxies []string `json:"captcha_trusted_proxies"`
+	CaptchaModes          []string `json:"captcha_modes"` // 接受的验证码类型，和验证码服务中本服务consumer的配置一致
+
+	SlotCapacity int `json:"slot_capacity"` // 排班生成时段时的默认可预约人数
+
+	WxAppId       string `json:"wx_app_id"` // 小程序appid和secret，登录时用code换取open_id
+	WxAppSecret   string `json:"wx_app_secret"`
+	SessionSecret string `json:"session_secret"` // 签发登录token的密钥，为空时不能登录和预约
+	SessionTTL    int    `json:"session_ttl"`    // 登录token的有效期(秒)
+
 }
 
 var c config
@@ -38,6 +57,8 @@ func init() {
 	c.TmplRoot = "/root/Git/e94/src/background/doctor/tmpl/"
 	c.StaticRoot = "/root/data/storage/"
 	c.AreaData = "/root/bin/movie/config/area.data"
+	c.SlotCapacity = 20
+	c.SessionTTL = 7 * 24 * 3600
 }
 
 func LoadConfig(path string) error {
@@ -109,4 +130,48 @@ func GetCmsRoot() string {
 }
 func GetAreaData() string {
 	return c.AreaData
-}
\ No newline at end of file
+}
+
+func GetCaptchaRedisAddr() string {
+	return c.CaptchaRedisAddr
+}
+
+func GetCaptchaRedisPassword() string {
+	return c.CaptchaRedisPassword
+}
+
+func GetSlotCapacity() int {
+	return c.SlotCapacity
+}
+
+func GetWxAppId() string {
+	return c.WxAppId
+}
+
+func GetWxAppSecret() string {
+	return c.WxAppSecret
+}
+
+func GetSessionSecret() string {
+	return c.SessionSecret
+}
+
+func GetSessionTTL() int {
+	if c.SessionTTL <= 0 {
+		return 7 * 24 * 3600
+	}
+	return c.SessionTTL
+}
+
+// 验证码的次数限制，和验证码服务使用同一个redis时应配置相同的值
+func GetCaptchaConfig() challenge.Config {
+	return challenge.Config{
+		TTL:            c.CaptchaTTL,
+		MaxAttempts:    c.CaptchaMaxAttempts,
+		IpMaxFailures:  c.CaptchaIpMaxFailures,
+		IpWindow:       c.CaptchaIpWindow,
+		TicketTTL:      c.CaptchaTicketTTL,
+		TrustedProxies: c.CaptchaTrustedProxies,
+		Modes:          c.CaptchaModes,
+	}.WithDefaults()
+}
@@ -0,0 +1,450 @@
+package api
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/doctor/model"
+	"background/doctor/service"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+func periodIndex(period string) int {
+	for i, p := range model.SlotPeriods {
+		if p == period {
+			return i
+		}
+	}
+	return len(model.SlotPeriods)
+}
+
+// 预约的业务错误返回err_msg，其他错误返回500
+func bookingFailure(c *gin.Context, err error) {
+	switch err {
+	case service.ErrSlotNotFound, service.ErrSlotClosed, service.ErrSlotExpired, service.ErrSlotFull,
+		service.ErrAlreadyBooked, service.ErrBookingNotFound, service.ErrBookingState,
+		service.ErrBookingExpired, service.ErrBookingNotStart, service.ErrCapacityTooSmall:
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+	default:
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+}
+
+/*
+	按登录用户的open_id查找医生，查看就诊队列、标记就诊和修改时段时验证医生身份。
+	医生的open_id由binddoctor工具绑定
+*/
+func bookingDoctor(c *gin.Context, db *gorm.DB, user *model.User) (*model.Doctor, bool) {
+	if user.OpenId == "" {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": "医生不存在"})
+		return nil, false
+	}
+	var doctor model.Doctor
+	if err := db.Where("open_id = ?", user.OpenId).First(&doctor).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": "医生不存在"})
+		} else {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return &doctor, true
+}
+
+type ApiSlot struct {
+	Id         uint32 `json:"id"`
+	DoctorId   uint32 `json:"doctor_id"`
+	Date       string `json:"date"`
+	Period     string `json:"period"`
+	PeriodName string `json:"period_name"`
+	Capacity   int    `json:"capacity"`
+	Booked     int    `json:"booked"`
+	Remaining  int    `json:"remaining"`
+	Open       bool   `json:"open"`
+}
+
+func newApiSlot(slot *model.Slot) *ApiSlot {
+	remaining := slot.Capacity - slot.Booked
+	if remaining < 0 || !slot.Open {
+		remaining = 0
+	}
+	return &ApiSlot{
+		Id:         slot.Id,
+		DoctorId:   slot.DoctorId,
+		Date:       slot.Date,
+		Period:     slot.Period,
+		PeriodName: model.SlotPeriodName(slot.Period),
+		Capacity:   slot.Capacity,
+		Booked:     slot.Booked,
+		Remaining:  remaining,
+		Open:       slot.Open,
+	}
+}
+
+/*
+	医生今天以后可预约的时段，由排班生成(DutyAdd、DutyUpdate时同步，启动时补齐以前的排班)
+*/
+func SlotList(c *gin.Context) {
+	type param struct {
+		DoctorId uint32 `form:"doctor_id" json:"doctor_id"`
+		Limit    int    `form:"limit" json:"limit"` // 排班天数
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if p.DoctorId == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if p.Limit <= 0 || p.Limit > 60 {
+		p.Limit = 14
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	date := today()
+
+	var duties []model.Duty
+	if err := db.Order("date asc").Limit(p.Limit).Where("doctor_id = ? and date >= ?", p.DoctorId, date).Find(&duties).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if len(duties) == 0 {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": []*ApiSlot{}})
+		return
+	}
+	var slots []model.Slot
+	last := duties[len(duties)-1].Date
+	if err := db.Where("doctor_id = ? and date >= ? and date <= ? and open = ?", p.DoctorId, date, last, true).Find(&slots).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		if slots[i].Date != slots[j].Date {
+			return slots[i].Date < slots[j].Date
+		}
+		return periodIndex(slots[i].Period) < periodIndex(slots[j].Period)
+	})
+
+	apiSlots := make([]*ApiSlot, 0, len(slots))
+	for i := range slots {
+		apiSlots = append(apiSlots, newApiSlot(&slots[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": apiSlots})
+}
+
+/*
+	医生修改自己时段的可预约人数
+*/
+func SlotUpdate(c *gin.Context) {
+	type param struct {
+		SlotId   uint32 `form:"slot_id" json:"slot_id"`
+		Capacity int    `form:"capacity" json:"capacity"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if p.SlotId == 0 || p.Capacity < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	doctor, ok := bookingDoctor(c, db, sessionUser(c))
+	if !ok {
+		return
+	}
+	var count int
+	if err := db.Model(&model.Slot{}).Where("id = ? and doctor_id = ?", p.SlotId, doctor.Id).Count(&count).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if count == 0 {
+		bookingFailure(c, service.ErrSlotNotFound)
+		return
+	}
+
+	slot, err := service.SetCapacity(db, p.SlotId, p.Capacity)
+	if err != nil {
+		bookingFailure(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": newApiSlot(slot)})
+}
+
+func BookingAdd(c *gin.Context) {
+	type param struct {
+		SlotId uint32 `form:"slot_id" json:"slot_id"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if p.SlotId == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	user := sessionUser(c)
+	booking, err := service.Book(db, user.Id, p.SlotId, time.Now())
+	if err != nil {
+		bookingFailure(c, err)
+		return
+	}
+	logger.Info("[Booking] User ", user.Id, " booked slot ", p.SlotId, " number ", booking.Number)
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": booking})
+}
+
+func BookingCancel(c *gin.Context) {
+	type param struct {
+		BookingId uint32 `form:"booking_id" json:"booking_id"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if p.BookingId == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	user := sessionUser(c)
+	booking, err := service.CancelBooking(db, user.Id, p.BookingId, time.Now())
+	if err != nil {
+		bookingFailure(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": booking})
+}
+
+/*
+	用户的预约记录，按预约时间倒序
+*/
+func BookingList(c *gin.Context) {
+	type param struct {
+		Offset int    `form:"offset" json:"offset"`
+		Limit  int    `form:"limit" json:"limit"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if p.Limit <= 0 || p.Limit > 50 {
+		p.Limit = 20
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	user := sessionUser(c)
+
+	var bookings []model.Booking
+	if err := db.Order("id desc").Offset(p.Offset).Limit(p.Limit).Where("user_id = ?", user.Id).Find(&bookings).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var doctorIds []uint32
+	for _, b := range bookings {
+		doctorIds = append(doctorIds, b.DoctorId)
+	}
+	doctors := make(map[uint32]*model.Doctor)
+	if len(doctorIds) > 0 {
+		var list []model.Doctor
+		if err := db.Where("id in (?)", doctorIds).Find(&list).Error; err != nil {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		for i := range list {
+			doctors[list[i].Id] = &list[i]
+		}
+	}
+
+	type ApiBooking struct {
+		model.Booking
+		PeriodName string `json:"period_name"`
+		DoctorName string `json:"doctor_name"`
+		Hospital   string `json:"hospital"`
+		Department string `json:"department"`
+	}
+
+	apiBookings := make([]*ApiBooking, 0, len(bookings))
+	for _, b := range bookings {
+		apiBooking := &ApiBooking{Booking: b, PeriodName: model.SlotPeriodName(b.Period)}
+		if doctor, ok := doctors[b.DoctorId]; ok {
+			apiBooking.DoctorName = doctor.Name
+			apiBooking.Hospital = doctor.Hospital
+			apiBooking.Department = doctor.Department
+		}
+		apiBookings = append(apiBookings, apiBooking)
+	}
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": apiBookings})
+}
+
+/*
+	医生查看自己某天(默认今天)的就诊队列，按时段和排队号排序，不包含已取消的预约。
+	包含患者信息，医生由登录用户确定
+*/
+func BookingQueue(c *gin.Context) {
+	type param struct {
+		Date   string `form:"date" json:"date"`
+		Period string `form:"period" json:"period"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if p.Date == "" {
+		p.Date = today()
+	} else if _, err := time.Parse("2006-01-02", p.Date); err != nil {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": "日期格式错误"})
+		return
+	}
+	if p.Period != "" && model.SlotPeriodName(p.Period) == "" {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": "时段错误"})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	doctor, ok := bookingDoctor(c, db, sessionUser(c))
+	if !ok {
+		return
+	}
+	query := db.Where("doctor_id = ? and date = ? and state <> ?", doctor.Id, p.Date, model.BookingStateCancelled)
+	if p.Period != "" {
+		query = query.Where("period = ?", p.Period)
+	}
+	var bookings []model.Booking
+	if err := query.Find(&bookings).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(bookings, func(i, j int) bool {
+		if bookings[i].Period != bookings[j].Period {
+			return periodIndex(bookings[i].Period) < periodIndex(bookings[j].Period)
+		}
+		return bookings[i].Number < bookings[j].Number
+	})
+
+	var userIds []uint32
+	for _, b := range bookings {
+		userIds = append(userIds, b.UserId)
+	}
+	users := make(map[uint32]*model.User)
+	if len(userIds) > 0 {
+		var list []model.User
+		if err := db.Where("id in (?)", userIds).Find(&list).Error; err != nil {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		for i := range list {
+			users[list[i].Id] = &list[i]
+		}
+	}
+
+	type ApiQueueItem struct {
+		BookingId  uint32 `json:"booking_id"`
+		Period     string `json:"period"`
+		PeriodName string `json:"period_name"`
+		Number     int    `json:"number"`
+		State      int    `json:"state"`
+		UserId     uint32 `json:"user_id"`
+		Nick       string `json:"nick"`
+		Avtar      string `json:"avtar"`
+		Gender     string `json:"gender"`
+	}
+
+	queue := make([]*ApiQueueItem, 0, len(bookings))
+	for _, b := range bookings {
+		item := &ApiQueueItem{
+			BookingId:  b.Id,
+			Period:     b.Period,
+			PeriodName: model.SlotPeriodName(b.Period),
+			Number:     b.Number,
+			State:      b.State,
+			UserId:     b.UserId,
+		}
+		if user, ok := users[b.UserId]; ok {
+			item.Nick = user.Nick
+			item.Avtar = user.Avtar
+			item.Gender = user.Gender
+		}
+		queue = append(queue, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": queue})
+}
+
+/*
+	医生标记自己的就诊结果，state为4(已就诊)或3(爽约)
+*/
+func BookingMark(c *gin.Context) {
+	type param struct {
+		BookingId uint32 `form:"booking_id" json:"booking_id"`
+		State     int    `form:"state" json:"state"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if p.BookingId == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	doctor, ok := bookingDoctor(c, db, sessionUser(c))
+	if !ok {
+		return
+	}
+	booking, err := service.MarkBooking(db, doctor.Id, p.BookingId, p.State, today())
+	if err != nil {
+		bookingFailure(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": booking})
+}
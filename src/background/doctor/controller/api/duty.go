@@ -3,6 +3,8 @@ package api
 import (
 	"net/http"
 	"background/doctor/model"
+	"background/doctor/config"
+	"background/doctor/service"
 	"background/common/constant"
 	"background/common/logger"
 	"github.com/gin-gonic/gin"
@@ -137,6 +139,12 @@ func DutyAdd(c *gin.Context) {
 		return
 	}
 
+	if err = service.SyncSlots(db, &duty, config.GetSlotCapacity()) ; err != nil{
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": duty})
 }
 
@@ -187,5 +195,12 @@ func DutyUpdate(c *gin.Context) {
 		return
 	}
 
+	// 取消出诊的时段不能再预约，已有预约保留
+	if err = service.SyncSlots(db, &duty, config.GetSlotCapacity()) ; err != nil{
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": duty})
 }
\ No newline at end of file
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/common/usertoken"
+	"background/doctor/config"
+	"background/doctor/model"
+	"background/doctor/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	小程序登录：用wx.login的code换取open_id，用户不存在时创建，返回登录token。
+	预约、取消和医生的操作需要带上token，不再信任请求中的open_id
+*/
+func Login(c *gin.Context) {
+	type param struct {
+		Code string `form:"code" json:"code"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if p.Code == "" {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	secret := config.GetSessionSecret()
+	if secret == "" || config.GetWxAppId() == "" || config.GetWxAppSecret() == "" {
+		logger.Error("wx_app_id, wx_app_secret or session_secret is not configured")
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+
+	openId, err := service.Code2Session(config.GetWxAppId(), config.GetWxAppSecret(), p.Code)
+	if err != nil {
+		logger.Error(err)
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": "微信登录失败"})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var user model.User
+	if err = db.Where("open_id = ?", openId).First(&user).Error; err == gorm.ErrRecordNotFound {
+		user.OpenId = openId
+		err = db.Create(&user).Error
+	}
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ttl := time.Second * time.Duration(config.GetSessionTTL())
+	token := usertoken.Issue(secret, uint64(user.Id), 0, ttl, time.Now())
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{
+		"token":      token,
+		"expires_in": config.GetSessionTTL(),
+		"user":       user,
+	}})
+}
+
+/*
+	middleware for session，token由Login签发，放在Authorization header或token参数中。
+	验证通过后把用户放到context中
+*/
+func SessionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		if token == "" {
+			token, _ = c.GetQuery("token")
+		}
+		secret := config.GetSessionSecret()
+		if token == "" || secret == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"err_code": constant.Failure, "err_msg": "请先登录"})
+			return
+		}
+
+		session, err := usertoken.Verify(secret, token, time.Now())
+		if err != nil {
+			logger.Debug("Invalid session token ", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"err_code": constant.Failure, "err_msg": "请先登录"})
+			return
+		}
+
+		db := c.MustGet(constant.ContextDb).(*gorm.DB)
+		var user model.User
+		if err := db.Where("id = ?", session.UserId).First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"err_code": constant.Failure, "err_msg": "用户不存在"})
+			} else {
+				logger.Error(err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+			return
+		}
+		c.Set(constant.ContextUser, &user)
+	}
+}
+
+// 已登录的用户，由SessionHandler设置
+func sessionUser(c *gin.Context) *model.User {
+	return c.MustGet(constant.ContextUser).(*model.User)
+}
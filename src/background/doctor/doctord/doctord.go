@@ -9,11 +9,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"background/common/logger"
 	"log"
+	"time"
 	"background/doctor/model"
 	"background/doctor/config"
 	"background/common/constant"
 	"background/doctor/controller/api"
+	"background/doctor/service"
 	"background/common/middleware"
+	"background/common/cache"
+	"background/verification_code/challenge"
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -54,6 +58,17 @@ func main(){
 
 	model.InitModel(db)
 
+	// 以前添加的排班补上时段，之后由DutyAdd、DutyUpdate同步
+	if n, err := service.BackfillSlots(db, time.Now().Format("2006-01-02"), config.GetSlotCapacity()); err != nil {
+		logger.Error("Backfill slots failed ", err)
+	} else if n > 0 {
+		logger.Info("Backfill ", n, " slots")
+	}
+
+	if config.GetSessionSecret() == "" {
+		logger.Warn("session_secret is not configured, booking is disabled")
+	}
+
 	r := gin.New()
 
 	gin.SetMode(gin.DebugMode)
@@ -67,14 +82,32 @@ func main(){
 	r.Use(dbMiddleware)
 	{
 		r.GET("/user/add",api.AddUser)
+		r.GET("/user/login",api.Login)
 
 		r.GET("/comment/list",api.CommentList)
-		r.GET("/comment/add",api.CommentAdd)
+		if config.GetCaptchaRedisAddr() != "" {
+			captchaManager := challenge.NewManager(challenge.NewRedisStore(cache.GetRedisPool(config.GetCaptchaRedisAddr(), config.GetCaptchaRedisPassword())), config.GetCaptchaConfig())
+			r.GET("/comment/add", challenge.Require(captchaManager), api.CommentAdd)
+		} else {
+			r.GET("/comment/add",api.CommentAdd)
+		}
 
 		r.GET("/duty/list",api.DutyList)
 		r.GET("/duty/add",api.DutyAdd)
 		r.GET("/duty/update",api.DutyUpdate)
 
+		// 预约和医生的操作使用登录token确定用户
+		sessionMiddleware := api.SessionHandler()
+
+		r.GET("/slot/list",api.SlotList)
+		r.GET("/slot/update",sessionMiddleware,api.SlotUpdate)
+
+		r.GET("/booking/add",sessionMiddleware,api.BookingAdd)
+		r.GET("/booking/cancel",sessionMiddleware,api.BookingCancel)
+		r.GET("/booking/list",sessionMiddleware,api.BookingList)
+		r.GET("/booking/queue",sessionMiddleware,api.BookingQueue)
+		r.GET("/booking/mark",sessionMiddleware,api.BookingMark)
+
 	}
 
 	r.Run(":15000")
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type Booking struct {
+	Id       uint32 `gorm:"primary_key" json:"id"`
+	SlotId   uint32 `gorm:"index" json:"slot_id"`
+	DoctorId uint32 `gorm:"index:idx_booking_doctor_date" json:"doctor_id"`
+	UserId   uint32 `gorm:"index" json:"user_id"`
+	Date     string `gorm:"size:10;index:idx_booking_doctor_date" json:"date"`
+	Period   string `gorm:"size:10" json:"period"`
+	Number   int    `json:"number"` // 排队号，同一时段内从1开始
+	State    int    `json:"state"`
+
+	CreatedAt time.Time `json:"created_at"` // 创建时间，utc格式
+	UpdatedAt time.Time `json:"updated_at"` // 更新时间，utc格式
+}
+
+const (
+	BookingStateBooked    = 1 // 已预约
+	BookingStateCancelled = 2 // 用户取消
+	BookingStateNoShow    = 3 // 爽约
+	BookingStateFinished  = 4 // 已就诊
+)
+
+func (Booking) TableName() string {
+	return "booking"
+}
+
+func initBooking(db *gorm.DB) error {
+	var err error
+	if db.HasTable(&Booking{}) {
+		err = db.AutoMigrate(&Booking{}).Error
+	} else {
+		err = db.CreateTable(&Booking{}).Error
+	}
+	return err
+}
+
+func dropBooking(db *gorm.DB) {
+	db.DropTableIfExists(&Booking{})
+}
@@ -16,6 +16,7 @@ type Doctor struct {
 	Position     string         `json:"position"`    // 职位
 	Title        string         `json:"Title"`       // 职称
 	Nick         string         `json:"nick"`        // 昵称
+	OpenId       string         `gorm:"size:60;index" json:"-"` // 医生的微信open_id，查看就诊队列和标记就诊时验证，用tools/binddoctor绑定
 
 	CreatedAt    time.Time      `json:"created_at"`       // 创建时间，utc格式
 	UpdatedAt    time.Time      `json:"updated_at"`       // 更新时间，utc格式
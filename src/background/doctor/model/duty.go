@@ -18,6 +18,19 @@ type Duty struct {
 	UpdatedAt    time.Time      `json:"updated_at"`       // 更新时间，utc格式
 }
 
+// 排班中该时段是否出诊
+func (d *Duty) OnDuty(period string) bool {
+	switch period {
+	case SlotMorning:
+		return d.Morning
+	case SlotAfternoon:
+		return d.Afternoon
+	case SlotNight:
+		return d.Night
+	}
+	return false
+}
+
 func (Duty) TableName() string {
 	return "duty"
 }
@@ -31,6 +31,18 @@ func InitModel(db *gorm.DB) error {
 		logger.Fatal("Init db duty failed, ", err)
 		return err
 	}
+
+	err = initSlot(db)
+	if err != nil {
+		logger.Fatal("Init db slot failed, ", err)
+		return err
+	}
+
+	err = initBooking(db)
+	if err != nil {
+		logger.Fatal("Init db booking failed, ", err)
+		return err
+	}
 	return err
 }
 
@@ -40,6 +52,8 @@ func rebuildModel(db *gorm.DB) {
 	dropComment(db)
 	dropDoctor(db)
 	dropDuty(db)
+	dropSlot(db)
+	dropBooking(db)
 
 	InitModel(db)
 }
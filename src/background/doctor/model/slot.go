@@ -0,0 +1,81 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	可预约的时段，由排班(Duty)生成，每个医生每天每个时段一条。
+	排班取消时Open为false，已有的预约保留
+*/
+type Slot struct {
+	Id       uint32 `gorm:"primary_key" json:"id"`
+	DoctorId uint32 `gorm:"unique_index:idx_slot_doctor_date_period" json:"doctor_id"`
+	Date     string `gorm:"size:10;unique_index:idx_slot_doctor_date_period" json:"date"`
+	Period   string `gorm:"size:10;unique_index:idx_slot_doctor_date_period" json:"period"`
+	Capacity int    `json:"capacity"` // 可预约人数
+	Booked   int    `json:"booked"`   // 当前有效预约数，取消后减少
+	Issued   int    `json:"-"`        // 已发出的排队号，只增不减
+	Open     bool   `json:"open"`
+
+	CreatedAt time.Time `json:"created_at"` // 创建时间，utc格式
+	UpdatedAt time.Time `json:"updated_at"` // 更新时间，utc格式
+}
+
+const (
+	SlotMorning   = "morning"
+	SlotAfternoon = "afternoon"
+	SlotNight     = "night"
+)
+
+var SlotPeriods = []string{SlotMorning, SlotAfternoon, SlotNight}
+
+func SlotPeriodName(period string) string {
+	switch period {
+	case SlotMorning:
+		return "上午"
+	case SlotAfternoon:
+		return "下午"
+	case SlotNight:
+		return "晚上"
+	}
+	return ""
+}
+
+// 各时段的结束时间(点)，结束后不能再预约或取消
+var slotPeriodEndHours = map[string]int{
+	SlotMorning:   12,
+	SlotAfternoon: 18,
+	SlotNight:     22,
+}
+
+/*
+	时段的结束时间，按服务器所在时区
+*/
+func SlotEnd(date, period string) (time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return day, err
+	}
+	return day.Add(time.Hour * time.Duration(slotPeriodEndHours[period])), nil
+}
+
+func (Slot) TableName() string {
+	return "slot"
+}
+
+func initSlot(db *gorm.DB) error {
+	var err error
+	if db.HasTable(&Slot{}) {
+		err = db.AutoMigrate(&Slot{}).Error
+	} else {
+		err = db.CreateTable(&Slot{}).Error
+	}
+	return err
+}
+
+func dropSlot(db *gorm.DB) {
+	db.DropTableIfExists(&Slot{})
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"background/doctor/model"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrSlotNotFound     = errors.New("预约时段不存在")
+	ErrSlotClosed       = errors.New("医生该时段不出诊")
+	ErrSlotExpired      = errors.New("该时段已结束，不能预约")
+	ErrSlotFull         = errors.New("该时段已约满")
+	ErrAlreadyBooked    = errors.New("您已预约该时段")
+	ErrBookingNotFound  = errors.New("预约不存在")
+	ErrBookingState     = errors.New("预约状态不允许该操作")
+	ErrBookingExpired   = errors.New("就诊时段已结束，不能取消")
+	ErrBookingNotStart  = errors.New("还未到就诊日期")
+	ErrCapacityTooSmall = errors.New("容量不能小于已预约人数")
+)
+
+// 行锁，同一时段的预约按顺序执行
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	return tx.Set("gorm:query_option", "FOR UPDATE")
+}
+
+/*
+	按排班生成时段：出诊的时段不存在时按capacity创建，
+	已有时段按排班打开或关闭，容量和已有预约不变
+*/
+func SyncSlots(db *gorm.DB, duty *model.Duty, capacity int) error {
+	for _, period := range model.SlotPeriods {
+		open := duty.OnDuty(period)
+		var slot model.Slot
+		err := db.Where("doctor_id = ? and date = ? and period = ?", duty.DoctorId, duty.Date, period).First(&slot).Error
+		if err == gorm.ErrRecordNotFound {
+			if !open {
+				continue
+			}
+			slot = model.Slot{DoctorId: duty.DoctorId, Date: duty.Date, Period: period, Capacity: capacity, Open: true}
+			if err = db.Create(&slot).Error; err != nil {
+				// 并发创建时唯一索引冲突，已由其他请求创建
+				var count int
+				if db.Model(&model.Slot{}).Where("doctor_id = ? and date = ? and period = ?", duty.DoctorId, duty.Date, period).Count(&count); count == 0 {
+					return err
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if slot.Open != open {
+			if err = db.Model(&slot).UpdateColumn("open", open).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+	为today以后已有的排班补上缺少的出诊时段，启动时执行一次。
+	之前添加的排班没有时段，之后排班变化时由SyncSlots生成
+*/
+func BackfillSlots(db *gorm.DB, today string, capacity int) (int, error) {
+	var duties []model.Duty
+	if err := db.Where("date >= ?", today).Find(&duties).Error; err != nil {
+		return 0, err
+	}
+	var slots []model.Slot
+	if err := db.Where("date >= ?", today).Find(&slots).Error; err != nil {
+		return 0, err
+	}
+	type slotKey struct {
+		doctorId     uint32
+		date, period string
+	}
+	exists := make(map[slotKey]bool, len(slots))
+	for _, slot := range slots {
+		exists[slotKey{slot.DoctorId, slot.Date, slot.Period}] = true
+	}
+
+	created := 0
+	for _, duty := range duties {
+		for _, period := range model.SlotPeriods {
+			if !duty.OnDuty(period) || exists[slotKey{duty.DoctorId, duty.Date, period}] {
+				continue
+			}
+			slot := model.Slot{DoctorId: duty.DoctorId, Date: duty.Date, Period: period, Capacity: capacity, Open: true}
+			if err := db.Create(&slot).Error; err != nil {
+				return created, err
+			}
+			created++
+		}
+	}
+	return created, nil
+}
+
+// 时段结束后不能再预约或取消
+func slotEnded(date, period string, now time.Time) (bool, error) {
+	end, err := model.SlotEnd(date, period)
+	if err != nil {
+		return false, err
+	}
+	return !now.Before(end), nil
+}
+
+// 锁住时段后检查是否可以预约
+func checkBookable(slot *model.Slot, now time.Time) error {
+	if !slot.Open {
+		return ErrSlotClosed
+	}
+	if ended, err := slotEnded(slot.Date, slot.Period, now); err != nil {
+		return err
+	} else if ended {
+		return ErrSlotExpired
+	}
+	if slot.Booked >= slot.Capacity {
+		return ErrSlotFull
+	}
+	return nil
+}
+
+// 锁住预约后检查是否可以取消
+func checkCancelable(booking *model.Booking, now time.Time) error {
+	if booking.State != model.BookingStateBooked {
+		return ErrBookingState
+	}
+	if ended, err := slotEnded(booking.Date, booking.Period, now); err != nil {
+		return err
+	} else if ended {
+		return ErrBookingExpired
+	}
+	return nil
+}
+
+/*
+	修改时段容量，不能小于当前有效预约数
+*/
+func SetCapacity(db *gorm.DB, slotId uint32, capacity int) (*model.Slot, error) {
+	result := db.Model(&model.Slot{}).Where("id = ? and booked <= ?", slotId, capacity).UpdateColumn("capacity", capacity)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	var slot model.Slot
+	if err := db.Where("id = ?", slotId).First(&slot).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrSlotNotFound
+		}
+		return nil, err
+	}
+	if result.RowsAffected == 0 && slot.Capacity != capacity {
+		return nil, ErrCapacityTooSmall
+	}
+	return &slot, nil
+}
+
+/*
+	预约时段。在事务中锁住时段后检查容量和重复预约，并发预约不会超过容量，
+	排队号按预约顺序分配，取消后不重用。时段结束后(如当天上午12点以后的上午时段)不能预约
+*/
+func Book(db *gorm.DB, userId, slotId uint32, now time.Time) (*model.Booking, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	booking, err := book(tx, userId, slotId, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+func book(tx *gorm.DB, userId, slotId uint32, now time.Time) (*model.Booking, error) {
+	var slot model.Slot
+	if err := forUpdate(tx).Where("id = ?", slotId).First(&slot).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrSlotNotFound
+		}
+		return nil, err
+	}
+	if err := checkBookable(&slot, now); err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := tx.Model(&model.Booking{}).Where("slot_id = ? and user_id = ? and state = ?", slotId, userId, model.BookingStateBooked).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyBooked
+	}
+
+	if err := tx.Model(&slot).UpdateColumns(map[string]interface{}{
+		"booked": gorm.Expr("booked + 1"),
+		"issued": gorm.Expr("issued + 1"),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	booking := &model.Booking{
+		SlotId:   slot.Id,
+		DoctorId: slot.DoctorId,
+		UserId:   userId,
+		Date:     slot.Date,
+		Period:   slot.Period,
+		Number:   slot.Issued + 1,
+		State:    model.BookingStateBooked,
+	}
+	if err := tx.Create(booking).Error; err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+/*
+	用户取消预约，释放时段的名额。就诊时段结束后不能取消
+*/
+func CancelBooking(db *gorm.DB, userId, bookingId uint32, now time.Time) (*model.Booking, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	booking, err := cancelBooking(tx, userId, bookingId, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+func cancelBooking(tx *gorm.DB, userId, bookingId uint32, now time.Time) (*model.Booking, error) {
+	var booking model.Booking
+	if err := forUpdate(tx).Where("id = ? and user_id = ?", bookingId, userId).First(&booking).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrBookingNotFound
+		}
+		return nil, err
+	}
+	if err := checkCancelable(&booking, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&booking).UpdateColumn("state", model.BookingStateCancelled).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&model.Slot{}).Where("id = ? and booked > 0", booking.SlotId).UpdateColumn("booked", gorm.Expr("booked - 1")).Error; err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+/*
+	医生标记就诊结果(已就诊或爽约)，两者之间可以更正，已取消的预约不能标记。
+	名额不释放
+*/
+func MarkBooking(db *gorm.DB, doctorId, bookingId uint32, state int, today string) (*model.Booking, error) {
+	if state != model.BookingStateFinished && state != model.BookingStateNoShow {
+		return nil, ErrBookingState
+	}
+
+	var booking model.Booking
+	if err := db.Where("id = ? and doctor_id = ?", bookingId, doctorId).First(&booking).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrBookingNotFound
+		}
+		return nil, err
+	}
+	if booking.Date > today {
+		return nil, ErrBookingNotStart
+	}
+	if booking.State == state {
+		return &booking, nil
+	}
+
+	result := db.Model(&booking).Where("state in (?)", []int{model.BookingStateBooked, model.BookingStateFinished, model.BookingStateNoShow}).UpdateColumn("state", state)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrBookingState
+	}
+	return &booking, nil
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"background/doctor/model"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+func at(date, clock string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestCheckBookable(t *testing.T) {
+	slot := model.Slot{Date: "2020-01-02", Period: model.SlotMorning, Capacity: 2, Booked: 1, Open: true}
+	if err := checkBookable(&slot, at("2020-01-02", "11:59")); err != nil {
+		t.Errorf("before end %v", err)
+	}
+	if err := checkBookable(&slot, at("2020-01-02", "12:00")); err != ErrSlotExpired {
+		t.Errorf("at end %v", err)
+	}
+
+	full := slot
+	full.Booked = 2
+	if err := checkBookable(&full, at("2020-01-01", "09:00")); err != ErrSlotFull {
+		t.Errorf("full %v", err)
+	}
+	// 容量改小后已预约数可能超过容量
+	full.Capacity = 1
+	if err := checkBookable(&full, at("2020-01-01", "09:00")); err != ErrSlotFull {
+		t.Errorf("over capacity %v", err)
+	}
+	closed := slot
+	closed.Open = false
+	if err := checkBookable(&closed, at("2020-01-01", "09:00")); err != ErrSlotClosed {
+		t.Errorf("closed %v", err)
+	}
+}
+
+func TestCheckCancelable(t *testing.T) {
+	booking := model.Booking{Date: "2020-01-02", Period: model.SlotAfternoon, State: model.BookingStateBooked}
+	if err := checkCancelable(&booking, at("2020-01-02", "17:59")); err != nil {
+		t.Errorf("before end %v", err)
+	}
+	for _, now := range []time.Time{at("2020-01-02", "18:00"), at("2020-01-03", "09:00")} {
+		if err := checkCancelable(&booking, now); err != ErrBookingExpired {
+			t.Errorf("after end %v: %v", now, err)
+		}
+	}
+	for _, state := range []int{model.BookingStateCancelled, model.BookingStateNoShow, model.BookingStateFinished} {
+		b := booking
+		b.State = state
+		if err := checkCancelable(&b, at("2020-01-01", "09:00")); err != ErrBookingState {
+			t.Errorf("state %d: %v", state, err)
+		}
+	}
+}
+
+func TestParseCode2Session(t *testing.T) {
+	if openId, err := parseCode2Session([]byte(`{"openid":"o123","session_key":"k"}`)); err != nil || openId != "o123" {
+		t.Errorf("openid %q %v", openId, err)
+	}
+	for _, data := range []string{`{"errcode":40029,"errmsg":"invalid code"}`, `{}`, `<html>`} {
+		if _, err := parseCode2Session([]byte(data)); err == nil {
+			t.Errorf("%s: no error", data)
+		}
+	}
+}
+
+/*
+	以下测试需要mysql，DOCTOR_TEST_DB为测试库的连接串，例如
+	DOCTOR_TEST_DB='root:pass@tcp(127.0.0.1:3306)/doctor_test?charset=utf8&parseTime=True&loc=Local'
+*/
+func testDB(t *testing.T) *gorm.DB {
+	source := os.Getenv("DOCTOR_TEST_DB")
+	if source == "" {
+		t.Skip("DOCTOR_TEST_DB is not set")
+	}
+	db, err := gorm.Open("mysql", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	if err := model.InitModel(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// 明天的时段，测试结束后删除时段和预约
+func testSlot(t *testing.T, db *gorm.DB, capacity int) *model.Slot {
+	slot := &model.Slot{
+		DoctorId: uint32(time.Now().UnixNano() % 1000000000),
+		Date:     time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+		Period:   model.SlotMorning,
+		Capacity: capacity,
+		Open:     true,
+	}
+	if err := db.Create(slot).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("slot_id = ?", slot.Id).Delete(&model.Booking{})
+		db.Delete(slot)
+	})
+	return slot
+}
+
+func TestBookConcurrent(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	slot := testSlot(t, db, 3)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var numbers []int
+	full := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(userId uint32) {
+			defer wg.Done()
+			booking, err := Book(db, userId, slot.Id, time.Now())
+			mu.Lock()
+			defer mu.Unlock()
+			if err == ErrSlotFull {
+				full++
+			} else if err != nil {
+				t.Errorf("user %d: %v", userId, err)
+			} else {
+				numbers = append(numbers, booking.Number)
+			}
+		}(uint32(i + 1))
+	}
+	wg.Wait()
+
+	if len(numbers) != 3 || full != 17 {
+		t.Fatalf("booked %v, full %d", numbers, full)
+	}
+	seen := make(map[int]bool)
+	for _, n := range numbers {
+		if n < 1 || n > 3 || seen[n] {
+			t.Errorf("numbers %v", numbers)
+		}
+		seen[n] = true
+	}
+	var saved model.Slot
+	db.Where("id = ?", slot.Id).First(&saved)
+	if saved.Booked != 3 || saved.Issued != 3 {
+		t.Errorf("slot booked %d, issued %d", saved.Booked, saved.Issued)
+	}
+}
+
+func TestCancelAfterPeriodEnd(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	slot := testSlot(t, db, 3)
+
+	booking, err := Book(db, 1, slot.Id, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, _ := model.SlotEnd(slot.Date, slot.Period)
+	if _, err := CancelBooking(db, 1, booking.Id, end); err != ErrBookingExpired {
+		t.Errorf("cancel after end %v", err)
+	}
+	// 其他用户不能取消
+	if _, err := CancelBooking(db, 2, booking.Id, time.Now()); err != ErrBookingNotFound {
+		t.Errorf("cancel by other user %v", err)
+	}
+	var saved model.Slot
+	db.Where("id = ?", slot.Id).First(&saved)
+	if saved.Booked != 1 {
+		t.Errorf("slot booked %d after failed cancel", saved.Booked)
+	}
+
+	if _, err := CancelBooking(db, 1, booking.Id, time.Now()); err != nil {
+		t.Errorf("cancel %v", err)
+	}
+	db.Where("id = ?", slot.Id).First(&saved)
+	if saved.Booked != 0 {
+		t.Errorf("slot booked %d after cancel", saved.Booked)
+	}
+}
+
+func TestSetCapacityBelowBooked(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	slot := testSlot(t, db, 3)
+
+	for userId := uint32(1); userId <= 2; userId++ {
+		if _, err := Book(db, userId, slot.Id, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := SetCapacity(db, slot.Id, 1); err != ErrCapacityTooSmall {
+		t.Errorf("capacity below booked %v", err)
+	}
+	if saved, err := SetCapacity(db, slot.Id, 2); err != nil || saved.Capacity != 2 {
+		t.Errorf("capacity equal to booked %v %v", saved, err)
+	}
+	if _, err := Book(db, 3, slot.Id, time.Now()); err != ErrSlotFull {
+		t.Errorf("book after shrink %v", err)
+	}
+	if _, err := SetCapacity(db, 0, 5); err != ErrSlotNotFound {
+		t.Errorf("missing slot %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const WX_CODE2SESSION_URL = "https://api.weixin.qq.com/sns/jscode2session"
+
+var wxClient = &http.Client{Timeout: time.Second * 10}
+
+/*
+	小程序登录，用wx.login得到的code换取open_id。
+	返回 {"openid":"...","session_key":"...","unionid":"..."} 或 {"errcode":40029,"errmsg":"invalid code"}
+*/
+func Code2Session(appId, appSecret, code string) (string, error) {
+	values := url.Values{}
+	values.Add("appid", appId)
+	values.Add("secret", appSecret)
+	values.Add("js_code", code)
+	values.Add("grant_type", "authorization_code")
+
+	resp, err := wxClient.Get(WX_CODE2SESSION_URL + "?" + values.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("jscode2session: bad response status %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return parseCode2Session(data)
+}
+
+func parseCode2Session(data []byte) (string, error) {
+	var result struct {
+		OpenId  string `json:"openid"`
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", err
+	}
+	if result.ErrCode != 0 {
+		return "", fmt.Errorf("jscode2session: %d %s", result.ErrCode, result.ErrMsg)
+	}
+	if result.OpenId == "" {
+		return "", errors.New("jscode2session: empty openid")
+	}
+	return result.OpenId, nil
+}
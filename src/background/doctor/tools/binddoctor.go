@@ -0,0 +1,78 @@
+package main
+
+import (
+	"background/common/logger"
+	"background/doctor/config"
+	"background/doctor/model"
+	"flag"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	绑定医生的微信open_id，之后医生登录小程序可以查看就诊队列、标记就诊和修改时段。
+	医生先登录一次小程序，登录返回的用户id即为-user
+	go run binddoctor.go -doctor 12 -user 345
+	-user 0 解除绑定
+*/
+func main() {
+	configPath := flag.String("conf", "../config/config.json", "Config file path")
+	doctorId := flag.Uint("doctor", 0, "Doctor id")
+	userId := flag.Uint("user", 0, "User id of the doctor's wechat account, 0 to unbind")
+	flag.Parse()
+
+	err := config.LoadConfig(*configPath)
+	if err != nil {
+		logger.Error("Config Failed!!!!", err)
+		return
+	}
+
+	logger.SetLevel(config.GetLoggerLevel())
+
+	db, err := gorm.Open(config.GetDBName(), config.GetDBSource())
+	if err != nil {
+		logger.Fatal("Open db Failed!!!!", err)
+		return
+	}
+
+	db.LogMode(false)
+
+	model.InitModel(db)
+
+	var doctor model.Doctor
+	if err := db.Where("id = ?", *doctorId).First(&doctor).Error; err != nil {
+		logger.Error("Doctor ", *doctorId, " ", err)
+		return
+	}
+
+	openId := ""
+	if *userId != 0 {
+		var user model.User
+		if err := db.Where("id = ?", *userId).First(&user).Error; err != nil {
+			logger.Error("User ", *userId, " ", err)
+			return
+		}
+		if user.OpenId == "" {
+			logger.Error("User ", *userId, " has no open_id")
+			return
+		}
+		// 一个微信账号只能绑定一个医生
+		var count int
+		if err := db.Model(&model.Doctor{}).Where("open_id = ? and id <> ?", user.OpenId, doctor.Id).Count(&count).Error; err != nil {
+			logger.Error(err)
+			return
+		}
+		if count > 0 {
+			logger.Error("User ", *userId, " is bound to another doctor")
+			return
+		}
+		openId = user.OpenId
+	}
+
+	if err := db.Model(&doctor).UpdateColumn("open_id", openId).Error; err != nil {
+		logger.Error(err)
+		return
+	}
+	logger.Info("医生:", doctor.Id, " ", doctor.Name, " 绑定用户:", *userId)
+}
@@ -15,7 +15,10 @@ import (
 	aapi "background/newmovie/controller/api"
 	ccms "background/newmovie/controller/cms"
 	"background/common/cache"
+	"background/verification_code/challenge"
 	"background/newmovie/service"
+	"background/newmovie/service/script"
+	"background/newmovie/service/search"
 
 	"background/common/middleware"
 	cmid "background/newmovie/middleware"
@@ -25,6 +28,8 @@ import (
 )
 
 func main(){
+	// 执行解析脚本的子进程从这里进入
+	script.RunSandbox()
 	logger.SetLevel(config.GetLoggerLevel())
 
 	//check version
@@ -78,12 +83,22 @@ func main(){
 
 	dbMiddleware := middleware.GetDbPrepareHandler(config.GetDBName(), config.GetDBSource(), config.IsOrmLogEnabled())
 	appVerifyMiddleware := cmid.AppVerifyHandler(model.AppTypeApp)
+	userMiddleware := cmid.UserHandler()
 	//signatureMiddleware := middleware.SignatureVerifyHandler(false) // config.IsProductionEnv())
 
 	service.InitCache(cacheRedisAddr, cacheRedisPwd)
+	service.InitSearchLog(db, cacheRedisAddr, cacheRedisPwd)
 
 	service.SetArea(config.GetAreaData())
 
+	go script.WatchScripts(db)
+
+	if err := search.Rebuild(db); err != nil {
+		logger.Fatal(err)
+		return
+	}
+	go search.Watch(db)
+
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 	r.OPTIONS("*f", func(c *gin.Context) {})
@@ -94,50 +109,86 @@ func main(){
 	cms.Use(dbMiddleware,appVerifyMiddleware)
 	{
 		cms.POST("/install",aapi.InstallationHandler)
+		cms.POST("/device/bind", aapi.DeviceBindHandler)
 		cms.GET("/upgrade",aapi.UpgradeHandler)
 		cms.GET("/activity",aapi.ActivityHandler)
 
-		cms.GET("/video/list", aapi.VideoListHandler)
-		cms.GET("/video", aapi.VideoDetailHandler)
+		cms.GET("/video/list", userMiddleware, aapi.VideoListHandler)
+		cms.GET("/video", userMiddleware, aapi.VideoDetailHandler)
 		//cms.GET("/video/search", aapi.VideoSearchHandler)
 
 		//cms.GET("/video/topsearch", aapi.VideoTopSearchHandler)
 
 		cms.GET("/recommend", aapi.RecommendHandler)
 
-		cms.GET("/page", aapi.PageHandler)
+		cms.GET("/page", userMiddleware, aapi.PageHandler)
 
 		cms.GET("/opinion", aapi.OpinionHandler)
 
 		cms.POST("/digg", aapi.DiggHandler)
 		cms.GET("/digglist", aapi.DiggListHandler)
 
-		cms.GET("/guess", aapi.GuessListHandler)
+		cms.GET("/guess", userMiddleware, aapi.GuessListHandler)
 
 		cms.POST("/user/stream/add", aapi.UserStreamAddHandler)
 		cms.POST("/user/stream/update", aapi.UserStreamUpdateHandler)
 		cms.POST("/user/stream/delete", aapi.UserStreamDeleteHandler)
 		cms.GET("/user/stream/list", aapi.UserStreamListHandler)
 		cms.POST("/user/want", aapi.UserWantHandler)
+		cms.GET("/user/token", userMiddleware, aapi.UserTokenHandler)
 
-		cms.GET("/stream/list", aapi.StreamListHandler)
-		cms.GET("/stream", aapi.StreamDetailHandler)
+		cms.GET("/stream/list", userMiddleware, aapi.StreamListHandler)
+		cms.GET("/stream", userMiddleware, aapi.StreamDetailHandler)
+		cms.GET("/stream/epg", aapi.StreamEpgHandler)
+		cms.GET("/stream/playback", userMiddleware, aapi.StreamPlaybackHandler)
 
 		cms.GET("/web", aapi.WebVideoHandler)
 
-		cms.GET("/search", aapi.SearchHandler)
+		cms.GET("/search", userMiddleware, aapi.SearchHandler)
+		cms.POST("/search/click", aapi.SearchClickHandler)
 		cms.GET("/topsearch", aapi.TopSearchHandler)
 
 		cms.GET("/notification", aapi.NotifcationHandler)
+
+		cms.GET("/bean/balance", userMiddleware, aapi.BeanBalanceHandler)
+		cms.GET("/bean/history", userMiddleware, aapi.BeanHistoryHandler)
+		cms.POST("/bean/checkin", userMiddleware, aapi.BeanCheckinHandler)
+		cms.GET("/bean/redeem/options", aapi.BeanRedeemOptionsHandler)
+		cms.POST("/bean/redeem", userMiddleware, aapi.BeanRedeemHandler)
 	}
 
-	cms.Use(dbMiddleware)
+	login := r.Group("cms")
+	login.Use(dbMiddleware)
 	{
 		r.GET("/login", ccms.AdminLoginHandler)
-		cms.POST("/admin/login", ccms.AdminLoginHandler)
+		if config.IsAdminLoginCaptchaEnabled() {
+			captchaManager := challenge.NewManager(challenge.NewRedisStore(cache.GetRedisPool(cacheRedisAddr, cacheRedisPwd)), config.GetCaptchaConfig())
+			login.POST("/admin/login", challenge.Require(captchaManager), ccms.AdminLoginHandler)
+		} else {
+			login.POST("/admin/login", ccms.AdminLoginHandler)
+		}
+	}
+
+	// 后台接口，需要登录后的admin token
+	admin := r.Group("cms")
+	admin.Use(dbMiddleware, cmid.AdminHandler())
+	{
+		admin.POST("/video/save", ccms.MovieSaveHandler)
+		admin.POST("/script/save", ccms.ScriptSettingSaveHandler)
+
+		admin.POST("/resolver/script/save", ccms.ResolverScriptSaveHandler)
+		admin.GET("/resolver/script/list", ccms.ResolverScriptListHandler)
+		admin.POST("/resolver/script/dryrun", ccms.ResolverScriptDryRunHandler)
+		admin.POST("/resolver/script/promote", ccms.ResolverScriptPromoteHandler)
+
+		admin.GET("/search/zero/list", ccms.SearchZeroResultListHandler)
+		admin.POST("/search/zero/handle", ccms.SearchZeroResultHandleHandler)
+
+		admin.POST("/tier/save", ccms.TierSaveHandler)
 
-		cms.POST("/video/save", ccms.MovieSaveHandler)
-		cms.POST("/script/save", ccms.ScriptSettingSaveHandler)
+		admin.POST("/bean/grant", ccms.BeanGrantHandler)
+		admin.POST("/bean/refund", ccms.BeanRefundHandler)
+		admin.GET("/bean/ledger/list", ccms.BeanLedgerListHandler)
 	}
 
 	r.Static("/html", "/root/Git/e94/src/background/newmovie/html/")
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"background/verification_code/challenge"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -21,6 +22,59 @@ type config struct {
 	CmsRoot       string `json:"cms_root"`
 	AreaData      string `json:"area_data"`
 
+	ProbeConcurrency int `json:"probe_concurrency"` // 每个provider同时探测的链接数
+	ProbeTimeout     int `json:"probe_timeout"`     // 单个链接探测超时，单位秒
+
+	ScriptPoolSize       int `json:"script_pool_size"`       // 每个解析脚本预热的js虚拟机数量
+	ScriptCpuTime        int `json:"script_cpu_time"`        // 单次解析脚本执行时间上限，单位毫秒
+	ScriptMemoryLimit    int `json:"script_memory_limit"`    // 执行解析脚本的子进程内存上限，单位MB
+	ScriptReloadInterval int `json:"script_reload_interval"` // 检查数据库脚本更新的间隔，单位秒
+
+	EpgSources      []string `json:"epg_sources"`       // XMLTV节目单，本地文件路径或http地址
+	EpgSyncInterval int      `json:"epg_sync_interval"` // 节目单同步间隔，单位分钟
+
+	TopSearchSize int `json:"top_search_size"` // 根据搜索统计生成的热搜数量
+
+	BeanRegisterGift   uint32             `json:"bean_register_gift"`   // 新用户赠送的金豆
+	BeanCheckinRewards []uint32           `json:"bean_checkin_rewards"` // 连续签到第n天的奖励，超过长度后循环
+	BeanRedeemOptions  []BeanRedeemOption `json:"bean_redeem_options"`  // 金豆兑换会员的选项
+
+	AdminLoginCaptcha bool `json:"admin_login_captcha"` // 后台登录需要验证码，验证码服务需要使用同一个redis
+
+	CaptchaTTL            int      `json:"captcha_ttl"` // 以下和验证码服务的配置相同，没有配置时使用默认值
+	CaptchaMaxAttempts    int      `json:"captcha_max_attempts"`
+	CaptchaIpMaxFailures  int      `json:"captcha_ip_max_failures"`
+	CaptchaIpWindow       int      `json:"captcha_ip_window"`
+	CaptchaTicketTTL      int      `json:"captcha_ticket_ttl"`
+	CaptchaTrustedProxies []string `json:"captcha_trusted_proxies"`
+	CaptchaModes          []string `json:"captcha_modes"` // 接受的验证码类型，和验证码服务中本服务consumer的配置一致
+
+	UserTokenSecret string `json:"user_token_secret"` // 给其他服务(如扫码配对)的用户token密钥，为空时不签发
+	UserTokenTTL    int    `json:"user_token_ttl"`    // 用户token有效期，单位秒
+
+	AdminTokenSecret string `json:"admin_token_secret"` // 后台登录token的密钥，为空时后台接口都不能访问
+	AdminTokenTTL    int    `json:"admin_token_ttl"`    // 后台登录有效期，单位秒
+
+	BindSecret string `json:"bind_secret"`  // 校验扫码配对的bind_sign，和配对服务的bind_secret相同，为空时不接受绑定
+	BindMaxAge int    `json:"bind_max_age"` // 配对确认后多久内可以提交绑定，单位秒
+
+}
+
+// 用bean个金豆兑换days天的tier等级会员，tier参见model.UserOrdinary等
+type BeanRedeemOption struct {
+	Id   uint32 `json:"id"`
+	Tier uint32 `json:"tier"`
+	Days uint32 `json:"days"`
+	Bean uint32 `json:"bean"`
+}
+
+var defaultBeanCheckinRewards = []uint32{10, 10, 15, 15, 20, 20, 50}
+
+var defaultBeanRedeemOptions = []BeanRedeemOption{
+	{Id: 1, Tier: 1, Days: 7, Bean: 300},
+	{Id: 2, Tier: 1, Days: 30, Bean: 1000},
+	{Id: 3, Tier: 2, Days: 7, Bean: 600},
+	{Id: 4, Tier: 2, Days: 30, Bean: 2000},
 }
 
 var c config
@@ -38,6 +92,20 @@ func init() {
 	c.TmplRoot = "/root/Git/e94/src/background/newmovie/tmpl/"
 	c.StaticRoot = "/root/data/storage/"
 	c.AreaData = "/root/bin/movie/config/area.data"
+	c.ProbeConcurrency = 5
+	c.ProbeTimeout = 20
+	c.ScriptPoolSize = 4
+	c.ScriptCpuTime = 2000
+	c.ScriptMemoryLimit = 128
+	c.ScriptReloadInterval = 30
+	c.EpgSyncInterval = 60
+	c.TopSearchSize = 20
+	c.BeanRegisterGift = 100
+	c.BeanCheckinRewards = defaultBeanCheckinRewards
+	c.BeanRedeemOptions = defaultBeanRedeemOptions
+	c.UserTokenTTL = 300
+	c.AdminTokenTTL = 8 * 3600
+	c.BindMaxAge = 600
 }
 
 func LoadConfig(path string) error {
@@ -109,4 +177,132 @@ func GetCmsRoot() string {
 }
 func GetAreaData() string {
 	return c.AreaData
-}
\ No newline at end of file
+}
+
+func GetProbeConcurrency() int {
+	if c.ProbeConcurrency <= 0 {
+		return 5
+	}
+	return c.ProbeConcurrency
+}
+
+func GetProbeTimeout() int {
+	if c.ProbeTimeout <= 0 {
+		return 20
+	}
+	return c.ProbeTimeout
+}
+
+func GetScriptPoolSize() int {
+	if c.ScriptPoolSize <= 0 {
+		return 4
+	}
+	return c.ScriptPoolSize
+}
+
+func GetScriptCpuTime() int {
+	if c.ScriptCpuTime <= 0 {
+		return 2000
+	}
+	return c.ScriptCpuTime
+}
+
+func GetScriptMemoryLimit() int {
+	if c.ScriptMemoryLimit <= 0 {
+		return 128
+	}
+	return c.ScriptMemoryLimit
+}
+
+func GetScriptReloadInterval() int {
+	if c.ScriptReloadInterval <= 0 {
+		return 30
+	}
+	return c.ScriptReloadInterval
+}
+
+func GetEpgSources() []string {
+	return c.EpgSources
+}
+
+func GetEpgSyncInterval() int {
+	if c.EpgSyncInterval <= 0 {
+		return 60
+	}
+	return c.EpgSyncInterval
+}
+
+func GetTopSearchSize() int {
+	if c.TopSearchSize <= 0 {
+		return 20
+	}
+	return c.TopSearchSize
+}
+
+func GetBeanRegisterGift() uint32 {
+	return c.BeanRegisterGift
+}
+
+func GetBeanCheckinRewards() []uint32 {
+	if len(c.BeanCheckinRewards) == 0 {
+		return defaultBeanCheckinRewards
+	}
+	return c.BeanCheckinRewards
+}
+
+func GetBeanRedeemOptions() []BeanRedeemOption {
+	if len(c.BeanRedeemOptions) == 0 {
+		return defaultBeanRedeemOptions
+	}
+	return c.BeanRedeemOptions
+}
+
+func IsAdminLoginCaptchaEnabled() bool {
+	return c.AdminLoginCaptcha
+}
+
+func GetUserTokenSecret() string {
+	return c.UserTokenSecret
+}
+
+func GetUserTokenTTL() int {
+	if c.UserTokenTTL <= 0 {
+		return 300
+	}
+	return c.UserTokenTTL
+}
+
+func GetAdminTokenSecret() string {
+	return c.AdminTokenSecret
+}
+
+func GetAdminTokenTTL() int {
+	if c.AdminTokenTTL <= 0 {
+		return 8 * 3600
+	}
+	return c.AdminTokenTTL
+}
+
+func GetBindSecret() string {
+	return c.BindSecret
+}
+
+func GetBindMaxAge() int {
+	if c.BindMaxAge <= 0 {
+		return 600
+	}
+	return c.BindMaxAge
+}
+
+// 验证码的次数限制，和验证码服务使用同一个redis时应配置相同的值
+func GetCaptchaConfig() challenge.Config {
+	return challenge.Config{
+		TTL:            c.CaptchaTTL,
+		MaxAttempts:    c.CaptchaMaxAttempts,
+		IpMaxFailures:  c.CaptchaIpMaxFailures,
+		IpWindow:       c.CaptchaIpWindow,
+		TicketTTL:      c.CaptchaTicketTTL,
+		TrustedProxies: c.CaptchaTrustedProxies,
+		Modes:          c.CaptchaModes,
+	}.WithDefaults()
+}
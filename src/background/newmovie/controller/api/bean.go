@@ -0,0 +1,207 @@
+package api
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/config"
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// 未注册的设备没有金豆账户
+func getBeanUser(c *gin.Context) (*model.User, bool) {
+	user := c.MustGet(constant.ContextUser).(*model.User)
+	if user.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.UserNotExists, "err_msg": constant.TranslateErrCode(constant.UserNotExists)})
+		return nil, false
+	}
+	return user, true
+}
+
+/*
+	GET /cms/bean/balance
+	金豆余额、签到状态和生效中的兑换会员
+*/
+func BeanBalanceHandler(c *gin.Context) {
+	user, ok := getBeanUser(c)
+	if !ok {
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	upgrades, err := service.GetActiveTierUpgrades(db, user.Id)
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	type ApiUpgrade struct {
+		Tier     uint32 `json:"tier"`
+		StartAt  int64  `json:"start_at"`
+		ExpireAt int64  `json:"expire_at"`
+	}
+	type ApiBalance struct {
+		Bean        uint32        `json:"bean"`
+		Tier        uint32        `json:"tier"` // 当前生效的会员等级
+		CheckinDays uint32        `json:"checkin_days"`
+		CheckedIn   bool          `json:"checked_in"` // 今天是否已签到
+		Upgrades    []*ApiUpgrade `json:"upgrades"`
+	}
+
+	var balance ApiBalance
+	balance.Bean = user.Bean
+	balance.Tier = getUserTier(c)
+	balance.CheckinDays = user.CheckinDays
+	now := time.Now()
+	balance.CheckedIn = user.LastCheckin.Year() == now.Year() && user.LastCheckin.YearDay() == now.YearDay()
+	for _, upgrade := range upgrades {
+		balance.Upgrades = append(balance.Upgrades, &ApiUpgrade{upgrade.Tier, upgrade.StartAt.Unix(), upgrade.ExpireAt.Unix()})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": balance})
+}
+
+/*
+	GET /cms/bean/history
+	金豆流水，按时间倒序
+*/
+func BeanHistoryHandler(c *gin.Context) {
+	type param struct {
+		Limit  int `form:"limit" binding:"required"`
+		Offset int `form:"offset" binding:"exists"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	user, ok := getBeanUser(c)
+	if !ok {
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var ledgers []model.BeanLedger
+	if err := db.Where("user_id = ?", user.Id).Order("id desc").Offset(p.Offset).Limit(p.Limit).Find(&ledgers).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	type ApiLedger struct {
+		Id        uint32 `json:"id"`
+		Type      uint8  `json:"type"`
+		Amount    int32  `json:"amount"`
+		Balance   uint32 `json:"balance"`
+		Remark    string `json:"remark"`
+		CreatedAt int64  `json:"created_at"`
+	}
+
+	var apiLedgers []*ApiLedger
+	for _, ledger := range ledgers {
+		apiLedgers = append(apiLedgers, &ApiLedger{ledger.Id, ledger.Type, ledger.Amount, ledger.Balance, ledger.Remark, ledger.CreatedAt.Unix()})
+	}
+
+	var hasMore bool = true
+	if len(apiLedgers) < p.Limit {
+		hasMore = false
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": apiLedgers, "has_more": hasMore})
+}
+
+/*
+	POST /cms/bean/checkin
+	每日签到，重复签到返回当天的签到记录
+*/
+func BeanCheckinHandler(c *gin.Context) {
+	user, ok := getBeanUser(c)
+	if !ok {
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	ledger, created, err := service.Checkin(db, user.Id)
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{"amount": ledger.Amount, "balance": ledger.Balance, "remark": ledger.Remark, "created": created}})
+}
+
+/*
+	GET /cms/bean/redeem/options
+	金豆兑换会员的选项
+*/
+func BeanRedeemOptionsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": config.GetBeanRedeemOptions()})
+}
+
+/*
+	POST /cms/bean/redeem
+	用金豆兑换限时会员，key由客户端为每次兑换生成，重复提交只扣一次
+*/
+func BeanRedeemHandler(c *gin.Context) {
+	type param struct {
+		OptionId uint32 `form:"option_id" json:"option_id" binding:"required"`
+		Key      string `form:"key" json:"key" binding:"required"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+	if len(p.Key) > 32 {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidInput, "err_msg": constant.TranslateErrCode(constant.InvalidInput)})
+		return
+	}
+
+	user, ok := getBeanUser(c)
+	if !ok {
+		return
+	}
+
+	var option *config.BeanRedeemOption
+	options := config.GetBeanRedeemOptions()
+	for i := range options {
+		if options[i].Id == p.OptionId {
+			option = &options[i]
+			break
+		}
+	}
+	if option == nil {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidInput, "err_msg": constant.TranslateErrCode(constant.InvalidInput)})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	upgrade, err := service.RedeemTier(db, user.Id, option, p.Key)
+	if err == service.ErrBeanNotEnough {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.BeanNotEnough, "err_msg": constant.TranslateErrCode(constant.BeanNotEnough)})
+		return
+	} else if err == service.ErrBeanRedeemInvalid {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.RedeemTierInvalid, "err_msg": constant.TranslateErrCode(constant.RedeemTierInvalid)})
+		return
+	} else if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{"tier": upgrade.Tier, "start_at": upgrade.StartAt.Unix(), "expire_at": upgrade.ExpireAt.Unix()}})
+}
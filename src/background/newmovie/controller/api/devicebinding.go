@@ -0,0 +1,84 @@
+package api
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/config"
+	"background/newmovie/model"
+	"background/qrcode/pairing"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	POST /cms/device/bind
+	机顶盒提交扫码配对的结果，bind_sign由配对服务用bind_secret签发，
+	校验通过后保存机顶盒绑定的手机和用户。比已保存的绑定旧的结果忽略
+*/
+func DeviceBindHandler(c *gin.Context) {
+	type param struct {
+		DeviceId       string `form:"device_id" json:"device_id" binding:"required"`
+		InstallationId uint64 `form:"installation_id" json:"installation_id" binding:"required"`
+		UserId         uint64 `form:"user_id" json:"user_id"`
+		BoundAt        int64  `form:"bound_at" json:"bound_at" binding:"required"`
+		BindSign       string `form:"bind_sign" json:"bind_sign" binding:"required"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if config.GetBindSecret() == "" {
+		logger.Error("bind_secret is not configured")
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+
+	err := pairing.VerifyBindSign(config.GetBindSecret(), p.DeviceId, p.InstallationId, p.UserId, p.BoundAt, p.BindSign, config.GetBindMaxAge(), time.Now())
+	if err != nil {
+		logger.Warn("[DeviceBind] Device ", p.DeviceId, " ", err)
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	tx := db.Begin()
+	var binding model.DeviceBinding
+	err = tx.Set("gorm:query_option", "FOR UPDATE").Where("device_id = ?", p.DeviceId).First(&binding).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		tx.Rollback()
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err == nil && binding.BoundAt > p.BoundAt {
+		tx.Rollback()
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": binding})
+		return
+	}
+
+	binding.DeviceId = p.DeviceId
+	binding.InstallationId = p.InstallationId
+	binding.UserId = p.UserId
+	binding.BoundAt = p.BoundAt
+	if err := tx.Save(&binding).Error; err != nil {
+		tx.Rollback()
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("[DeviceBind] Device ", p.DeviceId, " bound to installation ", p.InstallationId, " user ", p.UserId)
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": binding})
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"background/common/constant"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 当前用户生效的会员等级(包含兑换会员)，由middleware.UserHandler设置
+func getUserTier(c *gin.Context) uint32 {
+	return c.MustGet(constant.ContextUserTier).(uint32)
+}
+
+// 会员等级不足，返回所需的等级供客户端引导升级
+func responseUpgradeRequired(c *gin.Context, requiredTier uint32) {
+	c.JSON(http.StatusOK, gin.H{
+		"err_code": constant.UpgradeRequired,
+		"err_msg":  constant.TranslateErrCode(constant.UpgradeRequired),
+		"data":     gin.H{"required_tier": requiredTier, "tier": getUserTier(c)},
+	})
+}
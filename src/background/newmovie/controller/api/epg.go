@@ -0,0 +1,88 @@
+package api
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	apimodel "background/newmovie/controller/api/model"
+	"background/newmovie/model"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	GET /cms/stream/epg
+	频道某一天的节目单，date格式为2018-01-02，默认为当天
+*/
+func StreamEpgHandler(c *gin.Context) {
+	type param struct {
+		Id   uint32 `form:"id" binding:"required"`
+		Date string `form:"date"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if p.Date != "" {
+		var err error
+		if day, err = time.ParseInLocation("2006-01-02", p.Date, time.Local); err != nil {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var programmes []model.EpgProgramme
+	if err := db.Where("stream_id = ? and start_at >= ? and start_at < ?", p.Id, day, day.AddDate(0, 0, 1)).Order("start_at asc").Find(&programmes).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	type ApiProgramme struct {
+		*apimodel.EpgProgramme
+		IsPlaying bool `json:"is_playing"`
+	}
+
+	apiProgrammes := make([]*ApiProgramme, 0, len(programmes))
+	for _, programme := range programmes {
+		var apiProgramme ApiProgramme
+		apiProgramme.EpgProgramme = apimodel.EpgProgrammeFromDb(programme)
+		apiProgramme.IsPlaying = !programme.StartAt.After(now) && programme.EndAt.After(now)
+		apiProgrammes = append(apiProgrammes, &apiProgramme)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": apiProgrammes})
+}
+
+/*
+	查询频道当前播放和下一个节目，key为stream id
+*/
+func getNowNextProgrammes(db *gorm.DB, streamIds []uint32) (map[uint32][]*apimodel.EpgProgramme, error) {
+	result := make(map[uint32][]*apimodel.EpgProgramme)
+	if len(streamIds) == 0 {
+		return result, nil
+	}
+
+	now := time.Now()
+	var programmes []model.EpgProgramme
+	if err := db.Where("stream_id in (?) and end_at > ? and start_at < ?", streamIds, now, now.Add(time.Hour*12)).Order("start_at asc").Find(&programmes).Error; err != nil {
+		return nil, err
+	}
+	for _, programme := range programmes {
+		if len(result[programme.StreamId]) < 2 {
+			result[programme.StreamId] = append(result[programme.StreamId], apimodel.EpgProgrammeFromDb(programme))
+		}
+	}
+	return result, nil
+}
@@ -23,6 +23,7 @@ func GuessListHandler(c *gin.Context) {
 	}
 
 	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	tier := getUserTier(c)
 
 	var stream model.Stream
 	if err := db.Where("id = ?",p.StreamId).First(&stream).Error ; err != nil{
@@ -43,7 +44,7 @@ func GuessListHandler(c *gin.Context) {
 	}
 
 	var streams []model.Stream
-	if err := db.Order("rand()").Limit(6).Where("id <> ? and disable = 0 and on_line = 1 and title like ?",p.StreamId,"%" + areaTitle + "%").Find(&streams).Error ; err != nil{
+	if err := db.Order("rand()").Limit(6).Where("id <> ? and disable = 0 and on_line = 1 and title like ?",p.StreamId,"%" + areaTitle + "%").Where(service.StreamEntitledCondition,tier,tier).Find(&streams).Error ; err != nil{
 		logger.Error(err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
@@ -51,7 +52,7 @@ func GuessListHandler(c *gin.Context) {
 	
 	var streams1 []model.Stream
 	if len(streams) < 6{
-		if err := db.Order("rand()").Limit(6).Where("id <> ? and disable = 0 and on_line = 1 and category = ?",p.StreamId,stream.Category).Find(&streams1).Error ; err != nil{
+		if err := db.Order("rand()").Limit(6).Where("id <> ? and disable = 0 and on_line = 1 and category = ?",p.StreamId,stream.Category).Where(service.StreamEntitledCondition,tier,tier).Find(&streams1).Error ; err != nil{
 			logger.Error(err)
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
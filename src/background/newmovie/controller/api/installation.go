@@ -19,6 +19,7 @@ import (
 	"io"
 	"strings"
 	"background/newmovie/config"
+	"background/newmovie/service"
 )
 
 /*
@@ -104,17 +105,15 @@ func InstallationHandler(c *gin.Context) {
 		}
 		now := time.Now()
 
-		lastDay := fmt.Sprintf("%04d-%02d-%02d",user.LastUseAt.Year(),user.LastUseAt.Month(),user.LastUseAt.Day())
-
-		nowDay := fmt.Sprintf("%04d-%02d-%02d",now.Year(),now.Month(),now.Day())
-
-		if nowDay != lastDay{
-			user.Bean += 10
+		// 金豆由签到流水更新，这里只更新使用记录，避免覆盖余额
+		if err = db.Model(&user).UpdateColumns(map[string]interface{}{"last_use_at": now, "last_use_ip": c.ClientIP()}).Error; err != nil {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
 		}
-		user.LastUseAt = now
-		user.LastUseIp = c.ClientIP()
 
-		if err = db.Save(&user).Error; err != nil {
+		// 每天第一次使用自动签到
+		if _, _, err = service.Checkin(db, user.Id); err != nil {
 			logger.Error(err)
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
@@ -133,7 +132,6 @@ func InstallationHandler(c *gin.Context) {
 		user.Avatar = "http://www.ezhantao.com:16882/res/avatar/avatar.png"
 		user.CheckinDays = 0
 		user.Laravel = model.UserOrdinary
-		user.Bean = 100
 		now := time.Now()
 		user.LastUseAt = now
 		user.LastUseIp = c.ClientIP()
@@ -144,6 +142,21 @@ func InstallationHandler(c *gin.Context) {
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
+
+		if gift := config.GetBeanRegisterGift(); gift > 0 {
+			change := service.BeanChange{
+				UserId:         user.Id,
+				Type:           model.BeanLedgerTypeRegister,
+				Amount:         int32(gift),
+				IdempotencyKey: fmt.Sprintf("register_%d", user.Id),
+				Remark:         "新用户赠送",
+			}
+			if _, _, err = service.ChangeBean(db, &change); err != nil {
+				logger.Error(err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+		}
 		//if err := db.Model(&comment).UpdateColumn("op_count", gorm.Expr("op_count + ?", 1)).Error; err != nil {
 		//	logger.Error(err)
 		//	c.AbortWithStatus(http.StatusInternalServerError)
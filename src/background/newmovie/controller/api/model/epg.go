@@ -0,0 +1,21 @@
+package apimodel
+
+import (
+	"background/newmovie/model"
+)
+
+type EpgProgramme struct {
+	Id        uint32 `json:"id"`
+	Title     string `json:"title"`
+	StartTime int64  `json:"start_time"` // unix时间戳，秒
+	EndTime   int64  `json:"end_time"`
+}
+
+func EpgProgrammeFromDb(src model.EpgProgramme) *EpgProgramme {
+	dst := EpgProgramme{}
+	dst.Id = src.Id
+	dst.Title = src.Title
+	dst.StartTime = src.StartAt.Unix()
+	dst.EndTime = src.EndAt.Unix()
+	return &dst
+}
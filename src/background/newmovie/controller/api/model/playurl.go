@@ -12,6 +12,7 @@ type PlayUrl struct {
 	Url            string  `json:"url"`
 	Quality        uint8   `json:"quality"`
 	IsPlay         bool    `json:"is_play"`
+	IsSupportBack  bool    `json:"is_support_back"`
 }
 
 func PlayUrlFromDb(src model.PlayUrl) *PlayUrl {
@@ -20,6 +21,7 @@ func PlayUrlFromDb(src model.PlayUrl) *PlayUrl {
 	dst.Provider = src.Provider
 	dst.Url = src.Url
 	dst.Quality = src.Quality
+	dst.IsSupportBack = src.IsSupportBack
 	if src.OnLine{
 		dst.IsPlay = true
 	}else{
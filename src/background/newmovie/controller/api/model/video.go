@@ -60,7 +60,7 @@ func VideoFromDb(src model.Video,db *gorm.DB) *Video {
 		pUrl.Provider = playUrl.Provider
 		pUrl.IsPlay = true
 		if playUrl.OnLine{
-			pUrl.Url = service.GetRealUrl(playUrl.Provider,playUrl.Url)
+			pUrl.Url = service.GetCachedRealUrl(playUrl.Provider,playUrl.Url)
 		}else{
 			pUrl.Url = playUrl.Url
 			pUrl.IsPlay = false
@@ -3,6 +3,7 @@ package api
 import (
 	"net/http"
 	"background/newmovie/model"
+	"background/newmovie/service"
 	"background/common/constant"
 	"background/common/logger"
 	"github.com/gin-gonic/gin"
@@ -14,23 +15,28 @@ func PageHandler(c *gin.Context) {
 	var err error
 
 	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	tier := getUserTier(c)
 
 	var resourceGroups []*model.ResourceGroup
-	if err = db.Order("sort desc").Where("on_line = ? and type = ?",constant.MediaStatusOnLine,constant.MediaTypeStream).Find(&resourceGroups).Error ; err != nil{
+	if err = db.Order("sort desc").Where("on_line = ? and type = ? and tier <= ?",constant.MediaStatusOnLine,constant.MediaTypeStream,tier).Find(&resourceGroups).Error ; err != nil{
 		logger.Error("query resource_group err!!!,",err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
+	if len(resourceGroups) == 0 {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{}, "count": 0, "has_more": false})
+		return
+	}
 
 	var streams []*model.Stream
-	if err = db.Order("stream.sort asc").Limit(12).Joins("inner join stream_group where stream.id = stream_group.stream_id and stream_group.resource_group_id = ?",resourceGroups[0].Id).Find(&streams).Error ; err != nil{
+	if err = db.Order("stream.sort asc").Limit(12).Joins("inner join stream_group where stream.id = stream_group.stream_id and stream_group.resource_group_id = ? and " + service.StreamEntitledCondition,resourceGroups[0].Id,tier,tier).Find(&streams).Error ; err != nil{
 		logger.Error(err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
 	var count uint32
-	if err = db.Model(&model.Stream{}).Joins("inner join stream_group where stream.id = stream_group.stream_id and stream_group.resource_group_id = ?",resourceGroups[0].Id).Count(&count).Error; err != nil {
+	if err = db.Model(&model.Stream{}).Joins("inner join stream_group where stream.id = stream_group.stream_id and stream_group.resource_group_id = ? and " + service.StreamEntitledCondition,resourceGroups[0].Id,tier,tier).Count(&count).Error; err != nil {
 		logger.Error(err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
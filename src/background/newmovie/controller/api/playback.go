@@ -0,0 +1,127 @@
+package api
+
+import (
+	"background/common/aes1"
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const playbackMaxDays = 7 // 最多回看7天内的节目，与节目单保留时间一致
+
+/*
+	GET /cms/stream/playback
+	直播回看地址，programme_id与start_time/end_time二选一，时间为unix时间戳，单位秒
+*/
+func StreamPlaybackHandler(c *gin.Context) {
+	type param struct {
+		Id          uint32 `form:"id" binding:"required"`
+		ProgrammeId uint32 `form:"programme_id"`
+		StartTime   int64  `form:"start_time"`
+		EndTime     int64  `form:"end_time"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var stream model.Stream
+	if err := db.Where("id = ? and disable = 0 and on_line = ?", p.Id, constant.MediaStatusOnLine).First(&stream).Error; err != nil {
+		logger.Error("query stream err!!!,", err)
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	tiers, err := service.GetStreamRequiredTiers(db, []uint32{stream.Id})
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if tiers[stream.Id] > getUserTier(c) {
+		responseUpgradeRequired(c, tiers[stream.Id])
+		return
+	}
+
+	var title string
+	start := time.Unix(p.StartTime, 0)
+	end := time.Unix(p.EndTime, 0)
+	if p.ProgrammeId != 0 {
+		var programme model.EpgProgramme
+		if err := db.Where("id = ? and stream_id = ?", p.ProgrammeId, p.Id).First(&programme).Error; err != nil {
+			logger.Error("query epg programme err!!!,", err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		title = programme.Title
+		start = programme.StartAt
+		end = programme.EndAt
+	}
+
+	now := time.Now()
+	if !end.After(start) || !start.Before(now) || start.Before(now.AddDate(0, 0, -playbackMaxDays)) {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidInput, "err_msg": constant.TranslateErrCode(constant.InvalidInput)})
+		return
+	}
+	// 正在播出的节目回看到当前时间
+	if end.After(now) {
+		end = now
+	}
+
+	if title == "" && stream.HasEpg {
+		var programme model.EpgProgramme
+		if err := db.Where("stream_id = ? and start_at <= ? and end_at > ?", p.Id, start, start).First(&programme).Error; err == nil {
+			title = programme.Title
+		}
+	}
+
+	var playUrls []model.PlayUrl
+	if err := db.Order("ready asc").Where("content_type = ? and content_id = ? and on_line = 1 and is_support_back = 1", constant.MediaTypeStream, p.Id).Find(&playUrls).Error; err != nil {
+		logger.Error("query play_url err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	type ApiPlayback struct {
+		PlayUrlId uint32 `json:"play_url_id"`
+		Provider  uint32 `json:"provider"`
+		Url       string `json:"url"`
+		StartTime int64  `json:"start_time"`
+		EndTime   int64  `json:"end_time"`
+	}
+
+	// 按起播时间依次尝试，返回第一个解析成功的链接
+	for _, playUrl := range playUrls {
+		result, err := service.GetCachedPlaybackUrl(playUrl.Provider, playUrl.Url, stream.Category, title, start, end)
+		if err != nil {
+			logger.Error("resolve playback url failed, play_url: ", playUrl.Id, " ", err)
+			continue
+		}
+
+		var playback ApiPlayback
+		playback.PlayUrlId = playUrl.Id
+		playback.Provider = playUrl.Provider
+		playback.StartTime = start.Unix()
+		playback.EndTime = end.Unix()
+		if playback.Url, err = aes1.Encrypt([]byte(result.Url())); err != nil {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": playback})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.PlaybackNotSupported, "err_msg": constant.TranslateErrCode(constant.PlaybackNotSupported)})
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/jinzhu/gorm"
 	apimodel "background/newmovie/controller/api/model"
 	"background/common/util"
+	"background/newmovie/service/search"
+	"time"
 )
 
 func StreamListHandler(c *gin.Context) {
@@ -30,16 +32,42 @@ func StreamListHandler(c *gin.Context) {
 	var err error
 
 	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	tier := getUserTier(c)
 
+	var group model.ResourceGroup
+	if err = db.Where("id = ?", p.ResourceGroupId).First(&group).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if group.Tier > tier {
+		responseUpgradeRequired(c, group.Tier)
+		return
+	}
+
+	// 组内所需等级更高的频道不返回
 	var streams []*model.Stream
-	if err = db.Order("stream.sort asc").Offset(p.Offset).Limit(p.Limit).Joins("inner join stream_group where stream.id = stream_group.stream_id and stream_group.resource_group_id = ? and stream.on_line = 1 and stream.disable = 0", p.ResourceGroupId).Find(&streams).Error; err != nil {
+	if err = db.Order("stream.sort asc").Offset(p.Offset).Limit(p.Limit).Joins("inner join stream_group where stream.id = stream_group.stream_id and stream_group.resource_group_id = ? and stream.on_line = 1 and stream.disable = 0 and " + service.StreamEntitledCondition, p.ResourceGroupId, tier, tier).Find(&streams).Error; err != nil {
 		logger.Error(err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
 	var count uint32
-	if err = db.Model(&model.Stream{}).Joins("inner join stream_group where stream.on_line = 1 and disable = 0 and stream.id = stream_group.stream_id and stream_group.resource_group_id = ?", p.ResourceGroupId).Count(&count).Error; err != nil {
+	if err = db.Model(&model.Stream{}).Joins("inner join stream_group where stream.on_line = 1 and disable = 0 and stream.id = stream_group.stream_id and stream_group.resource_group_id = ? and " + service.StreamEntitledCondition, p.ResourceGroupId, tier, tier).Count(&count).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var streamIds []uint32
+	for _, stream := range streams {
+		if stream.HasEpg {
+			streamIds = append(streamIds, stream.Id)
+		}
+	}
+	nowNext, err := getNowNextProgrammes(db, streamIds)
+	if err != nil {
 		logger.Error(err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
@@ -49,6 +77,8 @@ func StreamListHandler(c *gin.Context) {
 		Id    uint32 `json:"id"`
 		Title string `json:"title"`
 		Thumb string `json:"thumb"`
+		Now   *apimodel.EpgProgramme `json:"now"`  // 当前节目，没有节目单时为null
+		Next  *apimodel.EpgProgramme `json:"next"` // 下一个节目
 	}
 
 	var apiStreams []*ApiStream
@@ -57,6 +87,16 @@ func StreamListHandler(c *gin.Context) {
 		apiStream.Id = stream.Id
 		apiStream.Title = stream.Title
 		apiStream.Thumb = stream.Thumb
+		if programmes := nowNext[stream.Id]; len(programmes) > 0 {
+			// 节目间有空档时第一个节目还未开始，作为next
+			if programmes[0].StartTime <= time.Now().Unix() {
+				apiStream.Now = programmes[0]
+				programmes = programmes[1:]
+			}
+			if len(programmes) > 0 {
+				apiStream.Next = programmes[0]
+			}
+		}
 		apiStreams = append(apiStreams, &apiStream)
 	}
 
@@ -89,6 +129,18 @@ func StreamDetailHandler(c *gin.Context) {
 	if err = db.Where("id = ? and disable = 0 and on_line = ?", p.Id, constant.MediaStatusOnLine).First(&stream).Error; err != nil {
 		logger.Error("query video err!!!,", err)
 		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	tiers, err := service.GetStreamRequiredTiers(db, []uint32{stream.Id})
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if tiers[stream.Id] > getUserTier(c) {
+		responseUpgradeRequired(c, tiers[stream.Id])
+		return
 	}
 
 	type ApiStream struct {
@@ -146,10 +198,45 @@ func SearchHandler(c *gin.Context) {
 
 	db := c.MustGet(constant.ContextDb).(*gorm.DB)
 
-	var streams []model.Stream
-	if err = db.Offset(p.Offset).Limit(p.Limit).Order("sort asc").Where("title like ? and disable = 0 and on_line = ?", "%" + p.Title + "%", constant.MediaStatusOnLine).Find(&streams).Error; err != nil {
-		logger.Error("query video err!!!,", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+	hits, count := search.Search(p.Title, getUserTier(c), p.Offset, p.Limit)
+	// 翻页不重复记录
+	if p.Offset == 0 {
+		service.LogSearch(c.MustGet(constant.ContextInstallationId).(uint64), p.Title, count)
+	}
+
+	var streamIds, videoIds []uint32
+	for _, hit := range hits {
+		if hit.ContentType == constant.MediaTypeStream {
+			streamIds = append(streamIds, hit.Id)
+		} else {
+			videoIds = append(videoIds, hit.Id)
+		}
+	}
+
+	streams := make(map[uint32]model.Stream)
+	if len(streamIds) > 0 {
+		var list []model.Stream
+		if err = db.Where("id in (?)", streamIds).Find(&list).Error; err != nil {
+			logger.Error("query stream err!!!,", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		for _, stream := range list {
+			streams[stream.Id] = stream
+		}
+	}
+
+	videos := make(map[uint32]model.Video)
+	if len(videoIds) > 0 {
+		var list []model.Video
+		if err = db.Where("id in (?)", videoIds).Find(&list).Error; err != nil {
+			logger.Error("query video err!!!,", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		for _, video := range list {
+			videos[video.Id] = video
+		}
 	}
 	//title,score,area,description,actors,directors,thumb,pageUrl,publishDate
 	type ApiStream struct {
@@ -167,23 +254,40 @@ func SearchHandler(c *gin.Context) {
 		Provider    uint32                `json:"provider"`
 	}
 
+	// 按相关度顺序输出，视频的content_type沿用原接口的MediaTypeEpisode
 	var apiModels []*ApiStream
-	for _, stream := range streams {
+	for _, hit := range hits {
 		var apiStream ApiStream
-		apiStream.Id = stream.Id
-		apiStream.Thumb = stream.Thumb
-		apiStream.Title = stream.Title
-		apiStream.ContentType = constant.MediaTypeStream
+		if hit.ContentType == constant.MediaTypeStream {
+			stream, ok := streams[hit.Id]
+			if !ok {
+				continue
+			}
+			apiStream.Id = stream.Id
+			apiStream.Thumb = stream.Thumb
+			apiStream.Title = stream.Title
+			apiStream.ContentType = constant.MediaTypeStream
+		} else {
+			video, ok := videos[hit.Id]
+			if !ok {
+				continue
+			}
+			apiStream.Id = video.Id
+			apiStream.Thumb = video.ThumbY
+			apiStream.Title = video.Title
+			apiStream.ContentType = constant.MediaTypeEpisode
+			apiStream.Actors = video.Actors
+			apiStream.Country = video.Country
+			apiStream.Directors = video.Directors
+			apiStream.PublishDate = video.PublishDate
+			apiStream.Score = fmt.Sprint(video.Score)
+			apiStream.Description = video.Description
+			apiStream.Provider = constant.ContentProviderSystem
+		}
 		apiModels = append(apiModels, &apiStream)
 	}
 
-	var count int
-	if err = db.Model(&model.Stream{}).Where("title like ? and disable = 0 and on_line = ?", "%" + p.Title + "%", constant.MediaStatusOnLine).Count(&count).Error; err != nil {
-		logger.Error(err)
-		c.AbortWithStatus(http.StatusInternalServerError)
-		return
-	}
-
+	// 本地没有结果时从第三方网站查找
 	if count == 0{
 		if p.Offset == 0{
 			var youkuVideo ApiStream
@@ -284,28 +388,6 @@ func SearchHandler(c *gin.Context) {
 				apiModels = append(apiModels, &youkuVideo)
 			}
 		}
-
-		var videos []model.Video
-		if err = db.Offset(p.Offset).Limit(p.Limit - count).Order("sort asc").Where("title like ? and on_line = ?", "%" + p.Title + "%", constant.MediaStatusOnLine).Find(&videos).Error; err != nil {
-			logger.Error("query video err!!!,", err)
-			c.AbortWithStatus(http.StatusInternalServerError)
-		}
-
-		for _, video := range videos {
-			var apiStream ApiStream
-			apiStream.Id = video.Id
-			apiStream.Thumb = video.ThumbY
-			apiStream.Title = video.Title
-			apiStream.ContentType = constant.MediaTypeEpisode
-			apiStream.Actors = video.Actors
-			apiStream.Country = video.Country
-			apiStream.Directors = video.Directors
-			apiStream.PublishDate = video.PublishDate
-			apiStream.Score = fmt.Sprint(video.Score)
-			apiStream.Description = video.Description
-			apiStream.Provider = constant.ContentProviderSystem
-			apiModels = append(apiModels, &apiStream)
-		}
 	}
 
 
@@ -317,6 +399,29 @@ func SearchHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": apiModels, "has_more":hasMore, "count":count})
 }
 
+/*
+	POST /cms/search/click
+	记录用户点击的搜索结果
+*/
+func SearchClickHandler(c *gin.Context) {
+	type param struct {
+		Title       string `form:"title" binding:"required"`
+		ContentType uint32 `form:"content_type" binding:"required"`
+		Id          uint32 `form:"id" binding:"required"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	installationId := c.MustGet(constant.ContextInstallationId).(uint64)
+	service.LogSearchClick(installationId, p.Title, p.ContentType, p.Id)
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success})
+}
+
 func TopSearchHandler(c *gin.Context) {
 
 	type param struct {
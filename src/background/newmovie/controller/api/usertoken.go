@@ -0,0 +1,38 @@
+package api
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/common/usertoken"
+	"background/newmovie/config"
+	"background/newmovie/model"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	GET /cms/user/token
+	签发短期用户token，app调用其他服务(如扫码配对)时提交，其他服务不信任请求中的user_id
+*/
+func UserTokenHandler(c *gin.Context) {
+	user := c.MustGet(constant.ContextUser).(*model.User)
+	if user.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.UserNotExists, "err_msg": constant.TranslateErrCode(constant.UserNotExists)})
+		return
+	}
+	secret := config.GetUserTokenSecret()
+	if secret == "" {
+		logger.Error("user_token_secret is not configured")
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+
+	ttl := time.Second * time.Duration(config.GetUserTokenTTL())
+	token := usertoken.Issue(secret, uint64(user.Id), user.InstallationId, ttl, time.Now())
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{
+		"user_token": token,
+		"expires_in": config.GetUserTokenTTL(),
+	}})
+}
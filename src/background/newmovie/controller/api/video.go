@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	apimodel "background/newmovie/controller/api/model"
+	"background/newmovie/service"
 )
 
 
@@ -73,9 +74,10 @@ func VideoListHandler(c *gin.Context) {
 	var err error
 
 	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	tier := getUserTier(c)
 
 	var videos []*model.Video
-	if err = db.Order("publish_date desc").Offset(p.Offset).Where("on_line = ?",constant.MediaStatusOnLine).Limit(p.Limit).Find(&videos).Error ; err != nil{
+	if err = db.Order("publish_date desc").Offset(p.Offset).Where("on_line = ?",constant.MediaStatusOnLine).Where(service.VideoEntitledCondition, tier, tier).Limit(p.Limit).Find(&videos).Error ; err != nil{
 		logger.Error("query movie err!!!,",err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 	}
@@ -86,7 +88,7 @@ func VideoListHandler(c *gin.Context) {
 	}
 
 	var count uint32
-	if err = db.Model(&model.Video{}).Where("on_line = ?",constant.MediaStatusOnLine).Count(&count).Error; err != nil {
+	if err = db.Model(&model.Video{}).Where("on_line = ?",constant.MediaStatusOnLine).Where(service.VideoEntitledCondition, tier, tier).Count(&count).Error; err != nil {
 		logger.Error(err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
@@ -134,6 +136,18 @@ func VideoDetailHandler(c *gin.Context) {
 	if err = db.Where("id = ? and on_line = ?",p.Id,constant.MediaStatusOnLine).Find(&video).Error ; err != nil{
 		logger.Error("query video err!!!,",err)
 		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	tiers, err := service.GetVideoRequiredTiers(db, []uint32{video.Id})
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if tiers[video.Id] > getUserTier(c) {
+		responseUpgradeRequired(c, tiers[video.Id])
+		return
 	}
 
 	var apiVideo *apimodel.Video
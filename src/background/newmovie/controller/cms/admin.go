@@ -7,8 +7,11 @@ import (
 	"background/common/constant"
 	"background/newmovie/model"
 	"background/common/util"
+	"background/common/usertoken"
+	"background/newmovie/config"
 	"errors"
 	"net/http"
+	"time"
 )
 
 /*
@@ -25,6 +28,7 @@ func AdminLoginHandler(c *gin.Context) {
 	type adminInfo struct {
 		Id       uint32 `json:"id"`
 		Username string `json:"username"`
+		Token    string `json:"token"` // 调用后台接口时放在Authorization header中
 	}
 
 	if c.Params == nil{
@@ -70,5 +74,10 @@ func AdminLoginHandler(c *gin.Context) {
 	var info adminInfo
 	info.Id = dbAdmin.Id
 	info.Username = dbAdmin.Username
+	if secret := config.GetAdminTokenSecret(); secret != "" {
+		info.Token = usertoken.Issue(secret, uint64(dbAdmin.Id), 0, time.Second*time.Duration(config.GetAdminTokenTTL()), time.Now())
+	} else {
+		logger.Warn("没有配置admin_token_secret，后台接口不能访问")
+	}
 	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": info})
 }
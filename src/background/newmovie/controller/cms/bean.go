@@ -0,0 +1,143 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	POST /cms/bean/grant
+	后台发放金豆，amount为负数时扣除。key用于防止重复提交
+*/
+func BeanGrantHandler(c *gin.Context) {
+	type param struct {
+		UserId uint32 `form:"user_id" json:"user_id" binding:"required"`
+		Amount int32  `form:"amount" json:"amount" binding:"required"`
+		Key    string `form:"key" json:"key" binding:"required"`
+		Remark string `form:"remark" json:"remark" binding:"required"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if len(p.Key) > 32 || len(p.Remark) > 255 {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidInput, "err_msg": constant.TranslateErrCode(constant.InvalidInput)})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	change := service.BeanChange{
+		UserId:         p.UserId,
+		Type:           model.BeanLedgerTypeAdminGrant,
+		Amount:         p.Amount,
+		IdempotencyKey: fmt.Sprintf("grant_%s", p.Key),
+		Remark:         p.Remark,
+	}
+	ledger, _, err := service.ChangeBean(db, &change)
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.UserNotExists, "err_msg": constant.TranslateErrCode(constant.UserNotExists)})
+		return
+	} else if err == service.ErrBeanNotEnough {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.BeanNotEnough, "err_msg": constant.TranslateErrCode(constant.BeanNotEnough)})
+		return
+	} else if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": ledger})
+}
+
+/*
+	POST /cms/bean/refund
+	兑换会员退款，ledger_id为兑换扣除金豆的流水
+*/
+func BeanRefundHandler(c *gin.Context) {
+	type param struct {
+		LedgerId uint32 `form:"ledger_id" json:"ledger_id" binding:"required"`
+		Remark   string `form:"remark" json:"remark"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if len(p.Remark) > 255 {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidInput, "err_msg": constant.TranslateErrCode(constant.InvalidInput)})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	ledger, err := service.RefundTierPurchase(db, p.LedgerId, p.Remark)
+	if err == gorm.ErrRecordNotFound || err == service.ErrBeanLedgerNotRefundable {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidInput, "err_msg": constant.TranslateErrCode(constant.InvalidInput)})
+		return
+	} else if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": ledger})
+}
+
+/*
+	GET /cms/bean/ledger/list
+	金豆流水查询，user_id和type为空时不过滤
+*/
+func BeanLedgerListHandler(c *gin.Context) {
+	type param struct {
+		UserId uint32 `form:"user_id"`
+		Type   uint8  `form:"type"`
+		Limit  int    `form:"limit" binding:"required"`
+		Offset int    `form:"offset"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	query := db.Model(model.BeanLedger{})
+	if p.UserId != 0 {
+		query = query.Where("user_id = ?", p.UserId)
+	}
+	if p.Type != 0 {
+		query = query.Where("type = ?", p.Type)
+	}
+
+	var count uint32
+	if err := query.Count(&count).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var ledgers []model.BeanLedger
+	if err := query.Order("id desc").Offset(p.Offset).Limit(p.Limit).Find(&ledgers).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": ledgers, "count": count})
+}
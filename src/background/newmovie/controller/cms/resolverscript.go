@@ -0,0 +1,212 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/model"
+	"background/newmovie/service/resolver"
+	"background/newmovie/service/script"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const scriptDryRunTimeout = time.Second * 30
+
+/*
+	POST /cms/resolver/script/save
+	上传解析脚本新版本，保存为草稿
+*/
+func ResolverScriptSaveHandler(c *gin.Context) {
+	type param struct {
+		Provider uint32 `form:"provider" json:"provider" binding:"required"`
+		FuncName string `form:"func_name" json:"func_name" binding:"required"`
+		Content  string `form:"content" json:"content" binding:"required"`
+		Remark   string `form:"remark" json:"remark"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if err := script.CompileScript(p.Provider, p.Content, p.FuncName); err != nil {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidScript, "err_msg": constant.TranslateErrCode(constant.InvalidScript, err.Error())})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	// 锁住provider的最新版本，并发保存时版本号依次递增，(provider, version)唯一索引兜底
+	tx := db.Begin()
+	var last model.ResolverScript
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("provider = ?", p.Provider).Order("version desc").First(&last).Error; err != nil && err != gorm.ErrRecordNotFound {
+		tx.Rollback()
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var s model.ResolverScript
+	s.Provider = p.Provider
+	s.Version = last.Version + 1
+	s.FuncName = p.FuncName
+	s.Content = p.Content
+	s.Remark = p.Remark
+	s.Status = model.ResolverScriptStatusDraft
+	if err := tx.Create(&s).Error; err != nil {
+		tx.Rollback()
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": s})
+}
+
+/*
+	GET /cms/resolver/script/list
+	provider的脚本版本列表，不返回脚本内容
+*/
+func ResolverScriptListHandler(c *gin.Context) {
+	type param struct {
+		Provider uint32 `form:"provider" binding:"required"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	var scripts []model.ResolverScript
+	if err := db.Select("id, provider, version, func_name, status, remark, created_at, updated_at").Where("provider = ?", p.Provider).Order("version desc").Find(&scripts).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": scripts})
+}
+
+/*
+	POST /cms/resolver/script/dryrun
+	用指定版本的脚本试解析url，并与当前生效版本的结果对比
+*/
+func ResolverScriptDryRunHandler(c *gin.Context) {
+	type param struct {
+		Id          uint32 `form:"id" json:"id" binding:"required"`
+		Url         string `form:"url" json:"url" binding:"required"` // 网页地址，直播为频道名
+		ContentType uint32 `form:"content_type" json:"content_type"`
+		Quality     uint8  `form:"quality" json:"quality"`
+		TvType      string `form:"tv_type" json:"tv_type"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if p.ContentType == 0 {
+		p.ContentType = constant.MediaTypeEpisode
+	}
+	if p.Quality == 0 {
+		p.Quality = constant.VideoQuality576p
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	var s model.ResolverScript
+	if err := db.Where("id = ?", p.Id).First(&s).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusOK, gin.H{"err_code": constant.ScriptNotExists, "err_msg": constant.TranslateErrCode(constant.ScriptNotExists)})
+			return
+		}
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scriptDryRunTimeout)
+	defer cancel()
+	req := resolver.Request{Provider: s.Provider, Url: p.Url, Quality: p.Quality, ContentType: p.ContentType, TvType: p.TvType}
+	result, err := script.DryRun(ctx, &s, &req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidScript, "err_msg": constant.TranslateErrCode(constant.InvalidScript, err.Error())})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": result})
+}
+
+/*
+	POST /cms/resolver/script/promote
+	将指定版本设为生效版本，原生效版本改为已替换，本进程立即重新加载，其他进程定时加载
+*/
+func ResolverScriptPromoteHandler(c *gin.Context) {
+	type param struct {
+		Id uint32 `form:"id" json:"id" binding:"required"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	var s model.ResolverScript
+	if err := db.Where("id = ?", p.Id).First(&s).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusOK, gin.H{"err_code": constant.ScriptNotExists, "err_msg": constant.TranslateErrCode(constant.ScriptNotExists)})
+			return
+		}
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if err := script.CompileScript(s.Provider, s.Content, s.FuncName); err != nil {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidScript, "err_msg": constant.TranslateErrCode(constant.InvalidScript, err.Error())})
+		return
+	}
+
+	tx := db.Begin()
+	if err := tx.Model(model.ResolverScript{}).Where("provider = ? and status = ? and id <> ?", s.Provider, model.ResolverScriptStatusActive, s.Id).
+		Update("status", model.ResolverScriptStatusRetired).Error; err != nil {
+		tx.Rollback()
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Model(&s).Update("status", model.ResolverScriptStatusActive).Error; err != nil {
+		tx.Rollback()
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if err := script.LoadScripts(db); err != nil {
+		logger.Error(err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": s})
+}
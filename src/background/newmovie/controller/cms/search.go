@@ -0,0 +1,77 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	GET /cms/search/zero/list
+	没有搜索结果的查询，按搜索次数排序，status默认为待处理
+*/
+func SearchZeroResultListHandler(c *gin.Context) {
+	type param struct {
+		Status uint8 `form:"status"`
+		Limit  int   `form:"limit" binding:"required"`
+		Offset int   `form:"offset"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if p.Status == 0 {
+		p.Status = model.SearchZeroResultPending
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var reports []model.SearchZeroResult
+	if err := db.Where("status = ?", p.Status).Order("count desc").Offset(p.Offset).Limit(p.Limit).Find(&reports).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var count uint32
+	if err := db.Model(model.SearchZeroResult{}).Where("status = ?", p.Status).Count(&count).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": reports, "count": count})
+}
+
+/*
+	POST /cms/search/zero/handle
+	标记为已处理
+*/
+func SearchZeroResultHandleHandler(c *gin.Context) {
+	type param struct {
+		Id uint32 `form:"id" json:"id" binding:"required"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	if err := db.Model(model.SearchZeroResult{}).Where("id = ?", p.Id).Update("status", model.SearchZeroResultHandled).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success})
+}
@@ -0,0 +1,78 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/model"
+	"background/newmovie/service/search"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	POST /cms/tier/save
+	设置资源组、频道或视频观看所需的会员等级
+	content_type : 18 资源组 4 频道 1 视频
+	tier         : 参见model.UserOrdinary等
+*/
+func TierSaveHandler(c *gin.Context) {
+	type param struct {
+		ContentType uint32 `form:"content_type" json:"content_type" binding:"required"`
+		Id          uint32 `form:"id" json:"id" binding:"required"`
+		Tier        uint32 `form:"tier" json:"tier"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if p.Tier > model.UserDiamonds {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidInput, "err_msg": constant.TranslateErrCode(constant.InvalidInput)})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var value interface{}
+	switch p.ContentType {
+	case constant.MediaTypeResourceGroup:
+		value = model.ResourceGroup{}
+	case constant.MediaTypeStream:
+		value = model.Stream{}
+	case constant.MediaTypeVideo:
+		value = model.Video{}
+	default:
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.InvalidInput, "err_msg": constant.TranslateErrCode(constant.InvalidInput)})
+		return
+	}
+
+	if err := db.Model(value).Where("id = ?", p.Id).Update("tier", p.Tier).Error; err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// 更新搜索索引中的等级，资源组影响组内所有内容，直接重建
+	var err error
+	switch p.ContentType {
+	case constant.MediaTypeResourceGroup:
+		go func() {
+			if err := search.Rebuild(db); err != nil {
+				logger.Error(err)
+			}
+		}()
+	case constant.MediaTypeStream:
+		err = search.IndexStream(db, p.Id)
+	case constant.MediaTypeVideo:
+		err = search.IndexVideo(db, p.Id)
+	}
+	if err != nil {
+		logger.Error(err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success})
+}
@@ -7,6 +7,7 @@ import (
 	"background/common/constant"
 	"background/newmovie/model"
 	"background/common/util"
+	"background/newmovie/service/search"
 	"net/http"
 	"time"
 )
@@ -106,5 +107,9 @@ func MovieSaveHandler(c *gin.Context) {
 		}
 	}
 
+	if err := search.IndexVideo(db, video.Id); err != nil {
+		logger.Error(err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": video})
 }
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/common/usertoken"
+	"background/newmovie/config"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	middleware for admin, 后台接口使用。
+	token由AdminLoginHandler签发，放在Authorization header或token参数中，
+	格式和用户token相同，user_id为admin id，使用单独的admin_token_secret
+*/
+func AdminHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		if token == "" {
+			token, _ = c.GetQuery("token")
+		}
+		secret := config.GetAdminTokenSecret()
+		if token == "" || secret == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"err_code": constant.AdminNotLogin, "err_msg": constant.TranslateErrCode(constant.AdminNotLogin)})
+			return
+		}
+
+		admin, err := usertoken.Verify(secret, token, time.Now())
+		if err != nil {
+			logger.Debug("Invalid admin token ", err)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"err_code": constant.AdminNotLogin, "err_msg": constant.TranslateErrCode(constant.AdminNotLogin)})
+			return
+		}
+		c.Set(constant.ContextAdminId, uint32(admin.UserId))
+	}
+}
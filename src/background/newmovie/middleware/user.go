@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	middleware for user, 需要在AppVerifyHandler之后使用，只用于需要用户的接口。
+	根据installation_id查询用户保存到context，未注册的设备作为普通用户；
+	包含金豆兑换的限时会员的等级单独保存，不修改user.Laravel
+*/
+func UserHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := c.MustGet(constant.ContextDb).(*gorm.DB)
+		installationId := c.MustGet(constant.ContextInstallationId).(uint64)
+
+		var user model.User
+		if installationId != 0 {
+			if err := db.Where("installation_id = ?", installationId).First(&user).Error; err != nil && err != gorm.ErrRecordNotFound {
+				logger.Error(err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+		}
+		if user.Id == 0 {
+			user.InstallationId = installationId
+			user.Laravel = model.UserOrdinary
+		}
+
+		tier, err := service.GetUserTier(db, &user)
+		if err != nil {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.Set(constant.ContextUser, &user)
+		c.Set(constant.ContextUserTier, tier)
+	}
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	金豆流水，只增不改，user.bean为流水amount的累计
+*/
+type BeanLedger struct {
+	Id             uint32    `gorm:"primary_key" json:"id"`
+	UserId         uint32    `gorm:"index" json:"user_id"`
+	Type           uint8     `json:"type"`    // 参见BeanLedgerType*
+	Amount         int32     `json:"amount"`  // 正数为收入，负数为支出
+	Balance        uint32    `json:"balance"` // 变动后的余额
+	IdempotencyKey string    `gorm:"size:64;unique_index" json:"idempotency_key"`
+	RefId          uint32    `json:"ref_id"` // 退款对应的流水id
+	Remark         string    `gorm:"size:255" json:"remark"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+const (
+	BeanLedgerTypeCheckin      = 1 // 每日签到
+	BeanLedgerTypeTierPurchase = 2 // 兑换会员
+	BeanLedgerTypeAdminGrant   = 3 // 后台发放或扣除
+	BeanLedgerTypeRefund       = 4 // 兑换退款
+	BeanLedgerTypeRegister     = 5 // 新用户赠送
+	BeanLedgerTypeOpening      = 6 // 期初余额，有流水之前user.bean中已有的金豆
+)
+
+func (BeanLedger) TableName() string {
+	return "bean_ledger"
+}
+
+func initBeanLedger(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&BeanLedger{}) {
+		err = db.AutoMigrate(&BeanLedger{}).Error
+	} else {
+		err = db.CreateTable(&BeanLedger{}).Error
+	}
+	if err != nil {
+		return err
+	}
+	return initOpeningBalance(db)
+}
+
+/*
+	user.bean与流水合计不一致的用户补一条期初余额流水，使流水合计等于余额。
+	期初流水的时间取该用户最早的流水时间，每个用户只补一次
+*/
+func initOpeningBalance(db *gorm.DB) error {
+	return db.Exec("insert into bean_ledger (user_id, type, amount, balance, idempotency_key, remark, created_at) "+
+		"select u.id, ?, cast(u.bean as signed) - ifnull(s.total, 0), cast(u.bean as signed) - ifnull(s.total, 0), concat('opening:', u.id), ?, ifnull(s.first, now()) "+
+		"from user u left join (select user_id, sum(amount) total, min(created_at) first from bean_ledger group by user_id) s on s.user_id = u.id "+
+		"where u.bean <> ifnull(s.total, 0) and not exists (select 1 from bean_ledger o where o.idempotency_key = concat('opening:', u.id))",
+		BeanLedgerTypeOpening, "期初余额").Error
+}
+
+func dropBeanLedger(db *gorm.DB) {
+	db.DropTableIfExists(&BeanLedger{})
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	机顶盒扫码配对的结果，由机顶盒提交配对服务签发的bind_sign，校验后保存。
+	每台机顶盒只绑定最近一次配对的手机和用户
+*/
+type DeviceBinding struct {
+	Id             uint32    `gorm:"primary_key" json:"id"`
+	DeviceId       string    `gorm:"size:64;unique_index" json:"device_id"`
+	InstallationId uint64    `gorm:"index" json:"installation_id"` // 扫码手机的installation_id
+	UserId         uint64    `gorm:"index" json:"user_id"`
+	BoundAt        int64     `json:"bound_at"` // 配对确认的时间，unix秒
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (DeviceBinding) TableName() string {
+	return "device_binding"
+}
+
+func initDeviceBinding(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&DeviceBinding{}) {
+		err = db.AutoMigrate(&DeviceBinding{}).Error
+	} else {
+		err = db.CreateTable(&DeviceBinding{}).Error
+	}
+	return err
+}
+
+func dropDeviceBinding(db *gorm.DB) {
+	db.DropTableIfExists(&DeviceBinding{})
+}
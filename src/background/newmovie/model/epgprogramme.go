@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 直播频道节目单，由taskd从XMLTV导入
+type EpgProgramme struct {
+	Id          uint32    `gorm:"primary_key" json:"id"`
+	StreamId    uint32    `gorm:"index:idx_stream_start" json:"stream_id"`
+	Title       string    `gorm:"size:255" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	StartAt     time.Time `gorm:"index:idx_stream_start" json:"start_at"`
+	EndAt       time.Time `json:"end_at"`
+	CreatedAt   time.Time `json:"created_at"` // 创建时间，utc格式
+}
+
+func (EpgProgramme) TableName() string {
+	return "epg_programme"
+}
+
+func initEpgProgramme(db *gorm.DB) error {
+	var err error
+	if db.HasTable(&EpgProgramme{}) {
+		err = db.AutoMigrate(&EpgProgramme{}).Error
+	} else {
+		err = db.CreateTable(&EpgProgramme{}).Error
+	}
+	return err
+}
+
+func dropEpgProgramme(db *gorm.DB) {
+	db.DropTableIfExists(&EpgProgramme{})
+}
@@ -22,6 +22,7 @@ type PlayUrl struct {
 	Quality     uint8          `json:"quality"`
 	Sort        uint32         `json:"sort"`
 	Ready       int64          `json:"rdady"`   //起播时间
+	IsSupportBack bool         `json:"is_support_back"` // 直播是否支持回看，由taskd根据解析结果更新
 
 	CreatedAt   time.Time      `json:"created_at"` // 创建时间，utc格式
 	UpdatedAt   time.Time      `json:"updated_at"` // 更新时间，utc格式
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 播放地址探测记录，用于排查链接下线原因
+type PlayUrlProbe struct {
+	Id         uint32    `gorm:"primary_key" json:"id"`
+	PlayUrlId  uint32    `gorm:"index" json:"play_url_id"`
+	Provider   uint32    `gorm:"index" json:"provider"`
+	Url        string    `gorm:"size:1024" json:"url"` // 实际探测的地址
+	Format     string    `gorm:"size:16" json:"format"`
+	Success    bool      `json:"success"`
+	StatusCode int       `json:"status_code"`
+	Reason     string    `gorm:"size:255" json:"reason"`
+	Latency    int64     `json:"latency"`    // 首个请求响应时间，毫秒
+	Ready      int64     `json:"ready"`      // 起播时间，毫秒
+	Bitrate    uint32    `json:"bitrate"`    // 媒体码率 bps
+	Throughput uint32    `json:"throughput"` // 下载速度 bps
+	SegmentUrl string    `gorm:"size:1024" json:"segment_url"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"` // 创建时间，utc格式
+}
+
+func (PlayUrlProbe) TableName() string {
+	return "play_url_probe"
+}
+
+func initPlayUrlProbe(db *gorm.DB) error {
+	var err error
+	if db.HasTable(&PlayUrlProbe{}) {
+		err = db.AutoMigrate(&PlayUrlProbe{}).Error
+	} else {
+		err = db.CreateTable(&PlayUrlProbe{}).Error
+	}
+	return err
+}
+
+func dropPlayUrlProbe(db *gorm.DB) {
+	db.DropTableIfExists(&PlayUrlProbe{})
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	ResolverScriptStatusDraft   = 1 // 草稿，只能试运行
+	ResolverScriptStatusActive  = 2 // 线上使用的版本，每个provider只有一个
+	ResolverScriptStatusRetired = 3 // 被新版本替换
+)
+
+// 服务端解析真实播放地址的js脚本，按provider分版本保存
+type ResolverScript struct {
+	Id        uint32    `gorm:"primary_key" json:"id"`
+	Provider  uint32    `gorm:"unique_index:idx_provider_version" json:"provider"`
+	Version   uint32    `gorm:"unique_index:idx_provider_version" json:"version"`
+	FuncName  string    `gorm:"size:64" json:"func_name"` // 脚本入口函数
+	Content   string    `gorm:"type:longtext" json:"content"`
+	Status    uint8     `json:"status"`
+	Remark    string    `gorm:"size:255" json:"remark"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ResolverScript) TableName() string {
+	return "resolver_script"
+}
+
+func initResolverScript(db *gorm.DB) error {
+	var err error
+	if db.HasTable(&ResolverScript{}) {
+		err = db.AutoMigrate(&ResolverScript{}).Error
+	} else {
+		err = db.CreateTable(&ResolverScript{}).Error
+	}
+	return err
+}
+
+func dropResolverScript(db *gorm.DB) {
+	db.DropTableIfExists(&ResolverScript{})
+}
@@ -14,6 +14,7 @@ type ResourceGroup struct {
 	Sort            uint32           `json:"sort"`
 	Count           uint32           `json:"count"`
 	OnLine          bool             `json:"on_line"`
+	Tier            uint32           `gorm:"not null;default:0" json:"tier"` // 观看所需会员等级，对组内频道和视频都生效，参见UserOrdinary等
 	Videos          []*Video         `gorm:"many2many:video_group" json:"videos"`
 	Streams         []*Stream        `gorm:"many2many:stream_group" json:"streams"`
 	CreatedAt       time.Time        `json:"created_at"`
@@ -39,6 +40,10 @@ func initResourceGroup(db *gorm.DB) error {
 
 	if db.HasTable(&ResourceGroup{}) {
 		err = db.AutoMigrate(&ResourceGroup{}).Error
+		if err == nil {
+			// 增加tier之前的数据
+			err = db.Exec("update resource_group set tier = 0 where tier is null").Error
+		}
 	} else {
 		err = db.CreateTable(&ResourceGroup{}).Error
 	}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 搜索记录，ClickId为用户在搜索结果中点击的内容
+type SearchLog struct {
+	Id               uint32    `gorm:"primary_key" json:"id"`
+	Query            string    `gorm:"size:64;index" json:"query"`
+	InstallationId   uint64    `gorm:"index" json:"installation_id"`
+	ResultCount      int       `json:"result_count"`
+	ClickContentType uint32    `json:"click_content_type"`
+	ClickId          uint32    `json:"click_id"`
+	CreatedAt        time.Time `gorm:"index" json:"created_at"` // 创建时间，utc格式
+}
+
+func (SearchLog) TableName() string {
+	return "search_log"
+}
+
+func initSearchLog(db *gorm.DB) error {
+	var err error
+	if db.HasTable(&SearchLog{}) {
+		err = db.AutoMigrate(&SearchLog{}).Error
+	} else {
+		err = db.CreateTable(&SearchLog{}).Error
+	}
+	return err
+}
+
+func dropSearchLog(db *gorm.DB) {
+	db.DropTableIfExists(&SearchLog{})
+}
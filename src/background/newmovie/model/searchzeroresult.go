@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	SearchZeroResultPending = 1 // 待编辑处理
+	SearchZeroResultHandled = 2 // 已补充内容或忽略
+)
+
+// 没有搜索结果的查询，由taskd汇总，供编辑补充内容
+type SearchZeroResult struct {
+	Id             uint32    `gorm:"primary_key" json:"id"`
+	Query          string    `gorm:"size:64;unique_index" json:"query"`
+	Count          uint32    `json:"count"` // 最近7天搜索次数
+	Status         uint8     `json:"status"`
+	LastSearchedAt time.Time `json:"last_searched_at"`
+	CreatedAt      time.Time `json:"created_at"` // 创建时间，utc格式
+	UpdatedAt      time.Time `json:"updated_at"` // 更新时间，utc格式
+}
+
+func (SearchZeroResult) TableName() string {
+	return "search_zero_result"
+}
+
+func initSearchZeroResult(db *gorm.DB) error {
+	var err error
+	if db.HasTable(&SearchZeroResult{}) {
+		err = db.AutoMigrate(&SearchZeroResult{}).Error
+	} else {
+		err = db.CreateTable(&SearchZeroResult{}).Error
+	}
+	return err
+}
+
+func dropSearchZeroResult(db *gorm.DB) {
+	db.DropTableIfExists(&SearchZeroResult{})
+}
@@ -36,7 +36,9 @@ type Stream struct {
 	OnLine         bool             `json:"on_line"`
 	Disable        bool             `json:"disable"`
 	HasEpg         bool             `json:"has_epg"`
+	EpgAlias       string           `gorm:"size:255" json:"epg_alias"` // epg中的频道名，多个用逗号分隔
 	Category       string           `json:"category"`
+	Tier           uint32           `gorm:"not null;default:0" json:"tier"` // 观看所需会员等级，参见UserOrdinary等
 	EpgSyncedAt    *time.Time       `json:"epg_synced_at"`
 	CreatedAt      time.Time        `json:"created_at"`       // 创建时间，utc格式
 	UpdatedAt      time.Time        `json:"updated_at"`       // 更新时间，utc格式
@@ -54,6 +56,10 @@ func initStream(db *gorm.DB) error {
 	var err error
 	if db.HasTable(&Stream{}) {
 		err = db.AutoMigrate(&Stream{}).Error
+		if err == nil {
+			// 增加tier之前的数据
+			err = db.Exec("update stream set tier = 0 where tier is null").Error
+		}
 	} else {
 		err = db.CreateTable(&Stream{}).Error
 		if err == nil {
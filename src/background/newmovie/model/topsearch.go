@@ -10,6 +10,7 @@ type TopSearch struct {
 	Title           string           `gorm:"size:255" json:"title" translated:"true"`
 	Sort            uint32           `json:"sort"`
 	ContentType     uint8            `json:"content_type"`
+	Auto            bool             `gorm:"not null;default:0" json:"auto"` // taskd根据搜索统计生成，重新计算时替换
 	CreatedAt       time.Time        `json:"created_at"`
 	UpdatedAt       time.Time        `json:"updated_at"`
 }
@@ -23,6 +24,10 @@ func initTopSearch(db *gorm.DB) error {
 
 	if db.HasTable(&TopSearch{}) {
 		err = db.AutoMigrate(&TopSearch{}).Error
+		if err == nil {
+			// 增加auto之前编辑维护的热搜
+			err = db.Exec("update top_search set auto = 0 where auto is null").Error
+		}
 	} else {
 		err = db.CreateTable(&TopSearch{}).Error
 	}
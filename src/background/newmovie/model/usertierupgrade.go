@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	用金豆兑换的限时会员，有效期内用户等级取user.laravel和tier中较高的
+*/
+type UserTierUpgrade struct {
+	Id        uint32    `gorm:"primary_key" json:"id"`
+	UserId    uint32    `gorm:"index" json:"user_id"`
+	Tier      uint32    `json:"tier"`      // 参见UserOrdinary等
+	LedgerId  uint32    `json:"ledger_id"` // 兑换扣除金豆的流水id
+	StartAt   time.Time `json:"start_at"`
+	ExpireAt  time.Time `json:"expire_at"` // 退款时设置为退款时间
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (UserTierUpgrade) TableName() string {
+	return "user_tier_upgrade"
+}
+
+func initUserTierUpgrade(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&UserTierUpgrade{}) {
+		err = db.AutoMigrate(&UserTierUpgrade{}).Error
+	} else {
+		err = db.CreateTable(&UserTierUpgrade{}).Error
+	}
+	return err
+}
+
+func dropUserTierUpgrade(db *gorm.DB) {
+	db.DropTableIfExists(&UserTierUpgrade{})
+}
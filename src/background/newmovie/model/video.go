@@ -22,6 +22,7 @@ type Video struct {
 	PublishDate    string           `json:"publish_date"`
 	Category       string           `json:"category"`
 	OnLine         bool             `json:"on_line"`
+	Status         uint8            `json:"status"`           // 播放状态，参见VideoStatus*
 	Year           uint32           `json:"year"`
 	Language       string           `gorm:"size:60" json:"language"`
 	Country        string           `gorm:"size:20" json:"country"`
@@ -29,11 +30,18 @@ type Video struct {
 	Actors         string           `gorm:"size:255" json:"actors"`
 	Writer         string           `gorm:"size:255" json:"writer"`
 	Tags           string           `gorm:"size:255" json:"tags"`
+	Tier           uint32           `gorm:"not null;default:0" json:"tier"` // 观看所需会员等级，参见UserOrdinary等
 
 	CreatedAt      time.Time        `json:"created_at"`       // 创建时间，utc格式
 	UpdatedAt      time.Time        `json:"updated_at"`       // 更新时间，utc格式
 }
 
+const (
+	VideoStatusUnknown    = 0 // 未检测
+	VideoStatusPlayable   = 2 // 至少一个播放地址可播
+	VideoStatusUnPlayable = 3 // 所有播放地址都不可播
+)
+
 func (Video) TableName() string {
 	return "video"
 }
@@ -42,6 +50,10 @@ func initVideo(db *gorm.DB) error {
 	var err error
 	if db.HasTable(&Video{}) {
 		err = db.AutoMigrate(&Video{}).Error
+		if err == nil {
+			// 增加tier之前的数据
+			err = db.Exec("update video set tier = 0 where tier is null").Error
+		}
 	} else {
 		err = db.CreateTable(&Video{}).Error
 	}
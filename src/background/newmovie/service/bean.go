@@ -0,0 +1,291 @@
+package service
+
+import (
+	"background/newmovie/config"
+	"background/newmovie/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrBeanNotEnough           = errors.New("bean not enough")
+	ErrBeanLedgerNotRefundable = errors.New("bean ledger is not refundable")
+	ErrBeanRedeemInvalid       = errors.New("redeem tier is not higher than user tier")
+)
+
+/*
+	一次金豆变动，同一个IdempotencyKey只会生效一次
+*/
+type BeanChange struct {
+	UserId         uint32
+	Type           uint8 // 参见model.BeanLedgerType*
+	Amount         int32
+	IdempotencyKey string
+	RefId          uint32
+	Remark         string
+}
+
+/*
+	在事务中执行f，f返回错误时回滚
+*/
+func withTransaction(db *gorm.DB, f func(tx *gorm.DB) error) error {
+	tx := db.Begin()
+	if err := tx.Error; err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// 锁定用户行，同一用户的金豆变动串行执行
+func lockUser(tx *gorm.DB, userId uint32) (*model.User, error) {
+	var user model.User
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", userId).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 查询已生效的流水，不存在时返回nil
+func findBeanLedger(tx *gorm.DB, key string) (*model.BeanLedger, error) {
+	var ledger model.BeanLedger
+	if err := tx.Where("idempotency_key = ?", key).First(&ledger).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ledger, nil
+}
+
+// 调用前需要已经锁定user
+func applyBeanChange(tx *gorm.DB, user *model.User, change *BeanChange) (*model.BeanLedger, error) {
+	balance := int64(user.Bean) + int64(change.Amount)
+	if balance < 0 {
+		return nil, ErrBeanNotEnough
+	}
+
+	if err := tx.Model(user).UpdateColumn("bean", balance).Error; err != nil {
+		return nil, err
+	}
+	user.Bean = uint32(balance)
+
+	var ledger model.BeanLedger
+	ledger.UserId = user.Id
+	ledger.Type = change.Type
+	ledger.Amount = change.Amount
+	ledger.Balance = user.Bean
+	ledger.IdempotencyKey = change.IdempotencyKey
+	ledger.RefId = change.RefId
+	ledger.Remark = change.Remark
+	if err := tx.Create(&ledger).Error; err != nil {
+		return nil, err
+	}
+	return &ledger, nil
+}
+
+/*
+	变更金豆余额并写入流水，IdempotencyKey已存在时返回原流水，created为false
+*/
+func ChangeBean(db *gorm.DB, change *BeanChange) (ledger *model.BeanLedger, created bool, err error) {
+	err = withTransaction(db, func(tx *gorm.DB) error {
+		user, err := lockUser(tx, change.UserId)
+		if err != nil {
+			return err
+		}
+		if ledger, err = findBeanLedger(tx, change.IdempotencyKey); err != nil || ledger != nil {
+			return err
+		}
+		ledger, err = applyBeanChange(tx, user, change)
+		created = err == nil
+		return err
+	})
+	return
+}
+
+// 连续签到第days天的奖励
+func checkinReward(days uint32) int32 {
+	rewards := config.GetBeanCheckinRewards()
+	return int32(rewards[(days-1)%uint32(len(rewards))])
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+/*
+	每日签到，昨天签到过连续天数加1，否则从1开始。
+	当天已签到时返回当天的流水，created为false
+*/
+func Checkin(db *gorm.DB, userId uint32) (ledger *model.BeanLedger, created bool, err error) {
+	now := time.Now()
+	key := fmt.Sprintf("checkin_%d_%s", userId, now.Format("20060102"))
+
+	err = withTransaction(db, func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userId)
+		if err != nil {
+			return err
+		}
+		if ledger, err = findBeanLedger(tx, key); err != nil || ledger != nil {
+			return err
+		}
+
+		days := uint32(1)
+		if sameDay(user.LastCheckin, now.AddDate(0, 0, -1)) {
+			days = user.CheckinDays + 1
+		}
+		change := BeanChange{
+			UserId:         userId,
+			Type:           model.BeanLedgerTypeCheckin,
+			Amount:         checkinReward(days),
+			IdempotencyKey: key,
+			Remark:         fmt.Sprintf("连续签到%d天", days),
+		}
+		if ledger, err = applyBeanChange(tx, user, &change); err != nil {
+			return err
+		}
+		if err = tx.Model(user).UpdateColumns(map[string]interface{}{"checkin_days": days, "last_checkin": now}).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return
+}
+
+/*
+	用金豆兑换限时会员，同等级未过期的会员顺延。
+	key由客户端生成，重复提交时返回第一次兑换的结果
+*/
+func RedeemTier(db *gorm.DB, userId uint32, option *config.BeanRedeemOption, key string) (*model.UserTierUpgrade, error) {
+	var upgrade model.UserTierUpgrade
+	err := withTransaction(db, func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userId)
+		if err != nil {
+			return err
+		}
+		ledgerKey := fmt.Sprintf("redeem_%d_%s", userId, key)
+		ledger, err := findBeanLedger(tx, ledgerKey)
+		if err != nil {
+			return err
+		}
+		if ledger != nil {
+			return tx.Where("ledger_id = ?", ledger.Id).First(&upgrade).Error
+		}
+
+		if option.Tier <= user.Laravel {
+			return ErrBeanRedeemInvalid
+		}
+		change := BeanChange{
+			UserId:         userId,
+			Type:           model.BeanLedgerTypeTierPurchase,
+			Amount:         -int32(option.Bean),
+			IdempotencyKey: ledgerKey,
+			Remark:         fmt.Sprintf("兑换%d天会员，等级%d", option.Days, option.Tier),
+		}
+		if ledger, err = applyBeanChange(tx, user, &change); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		upgrade.StartAt = now
+		var last model.UserTierUpgrade
+		err = tx.Where("user_id = ? and tier = ? and expire_at > ?", userId, option.Tier, now).Order("expire_at desc").First(&last).Error
+		if err == nil {
+			upgrade.StartAt = last.ExpireAt
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+		upgrade.UserId = userId
+		upgrade.Tier = option.Tier
+		upgrade.LedgerId = ledger.Id
+		upgrade.ExpireAt = upgrade.StartAt.AddDate(0, 0, int(option.Days))
+		return tx.Create(&upgrade).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &upgrade, nil
+}
+
+/*
+	兑换会员退款，退回扣除的金豆并立即结束对应的会员，每笔兑换只能退一次
+*/
+func RefundTierPurchase(db *gorm.DB, ledgerId uint32, remark string) (*model.BeanLedger, error) {
+	var refund *model.BeanLedger
+	err := withTransaction(db, func(tx *gorm.DB) error {
+		var purchase model.BeanLedger
+		if err := tx.Where("id = ?", ledgerId).First(&purchase).Error; err != nil {
+			return err
+		}
+		if purchase.Type != model.BeanLedgerTypeTierPurchase {
+			return ErrBeanLedgerNotRefundable
+		}
+
+		user, err := lockUser(tx, purchase.UserId)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("refund_%d", purchase.Id)
+		if refund, err = findBeanLedger(tx, key); err != nil || refund != nil {
+			return err
+		}
+
+		change := BeanChange{
+			UserId:         purchase.UserId,
+			Type:           model.BeanLedgerTypeRefund,
+			Amount:         -purchase.Amount,
+			IdempotencyKey: key,
+			RefId:          purchase.Id,
+			Remark:         remark,
+		}
+		if refund, err = applyBeanChange(tx, user, &change); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(model.UserTierUpgrade{}).Where("ledger_id = ? and expire_at > ?", purchase.Id, now).
+			Updates(map[string]interface{}{"expire_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// 未过期的兑换会员，按到期时间排序
+func GetActiveTierUpgrades(db *gorm.DB, userId uint32) ([]model.UserTierUpgrade, error) {
+	var upgrades []model.UserTierUpgrade
+	if err := db.Where("user_id = ? and expire_at > ?", userId, time.Now()).Order("expire_at asc").Find(&upgrades).Error; err != nil {
+		return nil, err
+	}
+	return upgrades, nil
+}
+
+/*
+	用户当前的会员等级，取user.laravel和生效中的兑换会员中较高的
+*/
+func GetUserTier(db *gorm.DB, user *model.User) (uint32, error) {
+	tier := user.Laravel
+	if user.Id == 0 {
+		return tier, nil
+	}
+
+	now := time.Now()
+	var upgrades []model.UserTierUpgrade
+	if err := db.Where("user_id = ? and start_at <= ? and expire_at > ?", user.Id, now, now).Find(&upgrades).Error; err != nil {
+		return 0, err
+	}
+	for _, upgrade := range upgrades {
+		if upgrade.Tier > tier {
+			tier = upgrade.Tier
+		}
+	}
+	return tier, nil
+}
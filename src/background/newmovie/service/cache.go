@@ -6,9 +6,11 @@ import (
 )
 
 var cacheStore *cache.Store
+var playUrlCacheStore *cache.Store // 真实播放地址，有效期由地址本身决定
 
 func InitCache(redisAddr, redisPassword string) {
 	cacheStore = cache.NewStore(redisAddr, redisPassword, 60, 10, true)
+	playUrlCacheStore = cache.NewStore(redisAddr, redisPassword, 60, 60, false)
 }
 
 func GetCacheKey(entity string, appId, versionId, contentType, contentId uint32, args ...interface{}) string {
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	会员等级权限：资源组、频道、视频都可以设置观看所需的会员等级(参见model.UserOrdinary等)，
+	频道和视频实际所需等级取自身和所属资源组中最高的一个
+*/
+
+// 列表查询条件，参数为用户等级，需要传两次
+const (
+	StreamEntitledCondition = "stream.tier <= ? and not exists(select 1 from stream_group g inner join resource_group r on g.resource_group_id = r.id where g.stream_id = stream.id and r.tier > ?)"
+	VideoEntitledCondition  = "video.tier <= ? and not exists(select 1 from video_group g inner join resource_group r on g.resource_group_id = r.id where g.video_id = video.id and r.tier > ?)"
+)
+
+// 频道实际所需会员等级，key为频道id
+func GetStreamRequiredTiers(db *gorm.DB, ids []uint32) (map[uint32]uint32, error) {
+	return getRequiredTiers(db, "stream", "stream_group", "stream_id", ids)
+}
+
+func GetVideoRequiredTiers(db *gorm.DB, ids []uint32) (map[uint32]uint32, error) {
+	return getRequiredTiers(db, "video", "video_group", "video_id", ids)
+}
+
+func getRequiredTiers(db *gorm.DB, table, groupTable, column string, ids []uint32) (map[uint32]uint32, error) {
+	tiers := make(map[uint32]uint32)
+	if len(ids) == 0 {
+		return tiers, nil
+	}
+
+	var rows []struct {
+		Id   uint32
+		Tier uint32
+	}
+	sql := "select a.id, greatest(a.tier, coalesce(max(r.tier), 0)) as tier from " + table + " a" +
+		" left join " + groupTable + " g on g." + column + " = a.id" +
+		" left join resource_group r on r.id = g.resource_group_id" +
+		" where a.id in (?) group by a.id"
+	if err := db.Raw(sql, ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tiers[row.Id] = row.Tier
+	}
+	return tiers, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const xmltvTimeLayout = "20060102150405 -0700"
+
+type XmltvChannel struct {
+	Id           string   `xml:"id,attr"`
+	DisplayNames []string `xml:"display-name"`
+}
+
+type XmltvProgramme struct {
+	Channel     string    `xml:"channel,attr"`
+	Start       string    `xml:"start,attr"`
+	Stop        string    `xml:"stop,attr"`
+	Title       string    `xml:"title"`
+	Description string    `xml:"desc"`
+	StartAt     time.Time `xml:"-"`
+	EndAt       time.Time `xml:"-"`
+}
+
+type Xmltv struct {
+	Channels   []XmltvChannel   `xml:"channel"`
+	Programmes []XmltvProgramme `xml:"programme"`
+}
+
+/*
+	读取XMLTV节目单，source为本地文件或http地址，.gz结尾的按gzip解压
+*/
+func LoadXmltv(source string) (*Xmltv, error) {
+	var r io.Reader
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := http.Client{Timeout: time.Minute * 5}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("get %s failed [%s]", source, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if strings.HasSuffix(strings.ToLower(source), ".gz") {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	return ParseXmltv(r)
+}
+
+// 解析XMLTV，丢弃时间格式错误的节目
+func ParseXmltv(r io.Reader) (*Xmltv, error) {
+	var tv Xmltv
+	if err := xml.NewDecoder(r).Decode(&tv); err != nil {
+		return nil, err
+	}
+
+	programmes := tv.Programmes[:0]
+	for _, p := range tv.Programmes {
+		var err error
+		if p.StartAt, err = parseXmltvTime(p.Start); err != nil {
+			continue
+		}
+		if p.EndAt, err = parseXmltvTime(p.Stop); err != nil || !p.EndAt.After(p.StartAt) {
+			continue
+		}
+		p.Title = strings.TrimSpace(p.Title)
+		p.Description = strings.TrimSpace(p.Description)
+		programmes = append(programmes, p)
+	}
+	tv.Programmes = programmes
+	return &tv, nil
+}
+
+// XMLTV时间格式为 20180101120000 +0800，时区可省略，省略时按本地时间
+func parseXmltvTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 14 {
+		return time.Time{}, errors.New("invalid xmltv time " + s)
+	}
+	if len(s) == 14 {
+		return time.ParseInLocation("20060102150405", s, time.Local)
+	}
+	return time.Parse(xmltvTimeLayout, s)
+}
+
+/*
+	频道名归一化，用于匹配Stream标题和别名：
+	忽略大小写、空格、横线以及"高清"、"HD"等后缀
+*/
+func NormalizeChannelName(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	name = strings.NewReplacer(" ", "", "-", "", "_", "", "＋", "+").Replace(name)
+	for _, suffix := range []string{"高清", "HD", "频道", "综合"} {
+		if len(name) > len(suffix) {
+			name = strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testXmltv = `<?xml version="1.0" encoding="UTF-8"?>
+<tv>
+  <channel id="cctv1"><display-name>CCTV-1 综合</display-name><display-name>CCTV1</display-name></channel>
+  <channel id="hunan"><display-name>湖南卫视</display-name></channel>
+  <programme channel="cctv1" start="20180101120000 +0800" stop="20180101123000 +0800">
+    <title> 新闻30分 </title><desc>午间新闻</desc>
+  </programme>
+  <programme channel="cctv1" start="20180101123000" stop="20180101130000"><title>今日说法</title></programme>
+  <programme channel="hunan" start="bad" stop="20180101130000 +0800"><title>时间错误</title></programme>
+  <programme channel="hunan" start="20180101130000 +0800" stop="20180101120000 +0800"><title>结束早于开始</title></programme>
+</tv>`
+
+func TestParseXmltv(t *testing.T) {
+	tv, err := ParseXmltv(strings.NewReader(testXmltv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tv.Channels) != 2 || len(tv.Channels[0].DisplayNames) != 2 {
+		t.Fatalf("channels %+v", tv.Channels)
+	}
+	if len(tv.Programmes) != 2 {
+		t.Fatalf("programmes %+v", tv.Programmes)
+	}
+
+	p := tv.Programmes[0]
+	if p.Title != "新闻30分" || p.Description != "午间新闻" {
+		t.Errorf("title %q desc %q", p.Title, p.Description)
+	}
+	want := time.Date(2018, 1, 1, 4, 0, 0, 0, time.UTC)
+	if !p.StartAt.Equal(want) || !p.EndAt.Equal(want.Add(time.Minute*30)) {
+		t.Errorf("time %v - %v", p.StartAt, p.EndAt)
+	}
+	// 省略时区按本地时间
+	if local := time.Date(2018, 1, 1, 12, 30, 0, 0, time.Local); !tv.Programmes[1].StartAt.Equal(local) {
+		t.Errorf("local time %v", tv.Programmes[1].StartAt)
+	}
+
+	if _, err := ParseXmltv(strings.NewReader("<tv>")); err == nil {
+		t.Error("truncated xml parsed")
+	}
+}
+
+func TestNormalizeChannelName(t *testing.T) {
+	cases := map[string]string{
+		"CCTV-1 综合": "CCTV1",
+		"cctv1":     "CCTV1",
+		"湖南卫视高清":    "湖南卫视",
+		"湖南卫视 HD":   "湖南卫视",
+		"CCTV-5＋":   "CCTV5+",
+		"HD":        "HD",
+		" 北京卫视频道 ":  "北京卫视",
+	}
+	for name, want := range cases {
+		if got := NormalizeChannelName(name); got != want {
+			t.Errorf("NormalizeChannelName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type M3u8Variant struct {
+	Url        string
+	Bandwidth  uint32
+	Resolution string
+}
+
+type M3u8Segment struct {
+	Url      string
+	Duration float64 // 秒
+}
+
+type M3u8Playlist struct {
+	IsMaster       bool
+	Variants       []M3u8Variant // master playlist中的码率列表
+	Segments       []M3u8Segment // media playlist中的分片
+	TargetDuration float64
+	EndList        bool // 有#EXT-X-ENDLIST为点播，否则为直播
+}
+
+/*
+	解析m3u8内容，base为playlist自身地址，用于将相对地址转换为绝对地址
+*/
+func ParseM3u8(base string, data string) (*M3u8Playlist, error) {
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var playlist M3u8Playlist
+	first := true
+	var variant *M3u8Variant
+	var duration float64
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if first {
+			first = false
+			if !strings.HasPrefix(line, "#EXTM3U") {
+				return nil, errors.New("invalid m3u8, #EXTM3U not found")
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			playlist.IsMaster = true
+			variant = &M3u8Variant{}
+			attrs := parseM3u8Attributes(line[len("#EXT-X-STREAM-INF:"):])
+			if bw, err := strconv.ParseUint(attrs["BANDWIDTH"], 10, 32); err == nil {
+				variant.Bandwidth = uint32(bw)
+			}
+			variant.Resolution = attrs["RESOLUTION"]
+		} else if strings.HasPrefix(line, "#EXTINF:") {
+			value := line[len("#EXTINF:"):]
+			if i := strings.Index(value, ","); i >= 0 {
+				value = value[:i]
+			}
+			duration, _ = strconv.ParseFloat(value, 64)
+		} else if strings.HasPrefix(line, "#EXT-X-TARGETDURATION:") {
+			playlist.TargetDuration, _ = strconv.ParseFloat(line[len("#EXT-X-TARGETDURATION:"):], 64)
+		} else if strings.HasPrefix(line, "#EXT-X-ENDLIST") {
+			playlist.EndList = true
+		} else if strings.HasPrefix(line, "#") {
+			continue
+		} else {
+			ref, err := url.Parse(line)
+			if err != nil {
+				continue
+			}
+			absUrl := baseUrl.ResolveReference(ref).String()
+			if variant != nil {
+				variant.Url = absUrl
+				playlist.Variants = append(playlist.Variants, *variant)
+				variant = nil
+			} else {
+				playlist.Segments = append(playlist.Segments, M3u8Segment{Url: absUrl, Duration: duration})
+				duration = 0
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if first {
+		return nil, errors.New("empty m3u8")
+	}
+
+	return &playlist, nil
+}
+
+// 解析 KEY=VALUE,KEY="VALUE" 形式的属性列表
+func parseM3u8Attributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for len(s) > 0 {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, "\"") {
+			end := strings.Index(s[1:], "\"")
+			if end < 0 {
+				value = s[1:]
+				s = ""
+			} else {
+				value = s[1 : end+1]
+				s = s[end+2:]
+			}
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				value = s
+				s = ""
+			} else {
+				value = s[:end]
+				s = s[end:]
+			}
+		}
+		attrs[key] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+	return attrs
+}
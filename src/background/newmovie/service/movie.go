@@ -10,7 +10,20 @@ import (
 	"github.com/robertkrimen/otto"
 	"fmt"
 	"background/common/constant"
-	"background/newmovie/service/script"
+	"background/newmovie/service/resolver"
+	_ "background/newmovie/service/script" // 注册各provider解析器
+	"context"
+	"time"
+	"errors"
+)
+
+var ErrPlaybackNotSupported = errors.New("playback not supported")
+
+const (
+	playUrlRefreshBefore = 60       // 距离失效不足60秒时后台刷新
+	playUrlExpireMargin  = 30       // 提前30秒视为失效，留出客户端起播时间
+	playUrlDefaultTTL    = 300      // 地址中没有失效时间(如vkey)时缓存5分钟
+	playUrlMaxTTL        = 3600 * 2
 )
 type OtherPlayUrl struct{
 	Provider     uint32
@@ -23,26 +36,143 @@ type OtherPlayUrl struct{
 }
 
 func GetRealUrl(provider uint32, url string)(string){
+	result, err := ResolveRealUrl(context.Background(), provider, url)
+	if err != nil{
+		logger.Error(err)
+		return ""
+	}
 
-	if provider == constant.ContentProviderSystem || provider == constant.ContentProviderYouKu || provider == constant.ContentProviderMgtv{
-		return url
+	logger.Debug(result.Url())
+	return result.Url()
+}
+
+/*
+	解析点播真实播放地址，provider对应的解析器在service/script中注册
+*/
+func ResolveRealUrl(ctx context.Context, provider uint32, url string)(*resolver.Result, error){
+	req := resolver.Request{
+		Provider: provider,
+		Url: url,
+		Quality: constant.VideoQuality576p,
+		ContentType: constant.MediaTypeEpisode,
 	}
+	return resolver.Resolve(ctx, &req)
+}
 
-	var realUrl string
-	if provider == constant.ContentProviderIqiyi{
-		realUrl = script.GetIqiyiRealPlayUrl(url)
-	}else if provider == constant.ContentProviderMigu{
-		realUrl = script.GetMiguRealPlayUrl(constant.MediaTypeEpisode,url)
-	}else if provider == constant.ContentProviderSohu{
-		realUrl = script.GetSohuRealPlayUrl(url)
+
+/*
+	带缓存的点播真实地址解析，同一provider+网页地址在失效前只解析一次
+*/
+func GetCachedRealUrl(provider uint32, url string)(string){
+	if playUrlCacheStore == nil{
+		return GetRealUrl(provider, url)
 	}
 
-	logger.Debug(realUrl)
-	return realUrl
+	key := GetCacheKey("real_play_url", 0, 0, constant.MediaTypeEpisode, 0, "_provider_", provider, "_url_", util.Md5Hash(url))
+	var result resolver.Result
+	err := playUrlCacheStore.GetJsonObjectWithLoadTTL(key, &result, playUrlRefreshBefore, func() (interface{}, int, error) {
+		// 后台刷新不能随请求结束而取消，不使用请求的context
+		r, err := ResolveRealUrl(context.Background(), provider, url)
+		if err != nil{
+			return nil, 0, err
+		}
+		return r, playUrlTTL(r.Url(), r.ExpiredAt), nil
+	})
+	if err != nil{
+		logger.Error(err)
+		return ""
+	}
+	return result.Url()
 }
 
+/*
+	解析直播频道地址，start不为零值时解析该时间段的回看地址
+*/
+func ResolveStreamUrl(ctx context.Context, provider uint32, channel, tvType, backTitle string, start, end time.Time)(*resolver.Result, error){
+	req := resolver.Request{
+		Provider: provider,
+		Url: channel,
+		Quality: constant.VideoQuality576p,
+		ContentType: constant.MediaTypeStream,
+		TvType: tvType,
+		BackTitle: backTitle,
+		StartTime: start,
+		EndTime: end,
+	}
+	return resolver.Resolve(ctx, &req)
+}
+
+/*
+	带缓存的回看地址解析，同一频道同一时间段在失效前只解析一次
+*/
+func GetCachedPlaybackUrl(provider uint32, channel, tvType, backTitle string, start, end time.Time)(*resolver.Result, error){
+	resolve := func() (*resolver.Result, error) {
+		r, err := ResolveStreamUrl(context.Background(), provider, channel, tvType, backTitle, start, end)
+		if err != nil{
+			return nil, err
+		}
+		if !r.IsSupportBack{
+			return nil, ErrPlaybackNotSupported
+		}
+		return r, nil
+	}
+	if playUrlCacheStore == nil{
+		return resolve()
+	}
+
+	key := GetCacheKey("playback_url", 0, 0, constant.MediaTypeStream, 0, "_provider_", provider, "_url_", util.Md5Hash(channel), "_start_", start.Unix(), "_end_", end.Unix())
+	var result resolver.Result
+	err := playUrlCacheStore.GetJsonObjectWithLoadTTL(key, &result, playUrlRefreshBefore, func() (interface{}, int, error) {
+		r, err := resolve()
+		if err != nil{
+			return nil, 0, err
+		}
+		return r, playUrlTTL(r.Url(), r.ExpiredAt), nil
+	})
+	if err != nil{
+		return nil, err
+	}
+	return &result, nil
+}
 
+/*
+	带缓存的直播源解析，key为provider+频道/网页地址+清晰度
+*/
 func GetStreamSourceUrl(v OtherPlayUrl,jsCode string)(string){
+	if v.Provider == 0 || playUrlCacheStore == nil {
+		return resolveStreamSourceUrl(v, jsCode)
+	}
+
+	key := GetCacheKey("stream_source_url", 0, 0, v.ContentType, 0, "_provider_", v.Provider, "_quality_", v.Quality, "_url_", util.Md5Hash(v.Channel + v.Url))
+	var realUrl string
+	err := playUrlCacheStore.GetJsonObjectWithLoadTTL(key, &realUrl, playUrlRefreshBefore, func() (interface{}, int, error) {
+		url := resolveStreamSourceUrl(v, jsCode)
+		return url, playUrlTTL(url, resolver.ExpiryFromUrl(url)), nil
+	})
+	if err != nil{
+		logger.Error(err)
+		return ""
+	}
+	return realUrl
+}
+
+// 根据地址失效时间计算缓存时间，解析失败不缓存
+func playUrlTTL(url string, expiredAt time.Time)(int){
+	if url == ""{
+		return 0
+	}
+	if expiredAt.IsZero(){
+		return playUrlDefaultTTL
+	}
+
+	ttl := int(time.Until(expiredAt).Seconds()) - playUrlExpireMargin
+	if ttl > playUrlMaxTTL{
+		ttl = playUrlMaxTTL
+	}
+	return ttl
+}
+
+func resolveStreamSourceUrl(v OtherPlayUrl,jsCode string)(string){
 	if v.Provider == 0 {
 		return v.Url
 	}
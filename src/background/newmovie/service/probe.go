@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ProbeFormatUnknown = "unknown"
+	ProbeFormatHls     = "hls"
+	ProbeFormatFlv     = "flv"
+	ProbeFormatMp4     = "mp4"
+	ProbeFormatPage    = "page"
+
+	probeMaxPlaylistSize = 1024 * 1024     // m3u8最大读取1M
+	probeMaxSegmentSize  = 8 * 1024 * 1024 // 分片最大读取8M
+	probeStreamReadSize  = 512 * 1024      // flv/mp4读取512K用于测速
+	probeMinBitrate      = 64 * 1000       // 低于64kbps认为流异常
+	probeStreamReadTime  = time.Second * 3 // flv为持续推流，最多读取3秒
+)
+
+type ProbeResult struct {
+	Url        string
+	Format     string
+	Success    bool
+	StatusCode int
+	Reason     string // 失败原因
+	Latency    int64  // 首个请求响应时间，毫秒
+	Ready      int64  // 起播时间(收到首个媒体数据)，毫秒
+	Bitrate    uint32 // 媒体码率 bps
+	Throughput uint32 // 下载速度 bps
+	SegmentUrl string
+}
+
+type Prober struct {
+	client  *http.Client
+	headers map[string]string
+}
+
+func NewProber(timeout time.Duration) *Prober {
+	p := new(Prober)
+	p.client = &http.Client{Timeout: timeout}
+	p.headers = map[string]string{
+		"User-Agent": "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/63.0.3239.132 Safari/537.36",
+	}
+	return p
+}
+
+/*
+	探测播放地址是否可播，支持HLS(m3u8)、HTTP-FLV、MP4
+*/
+func (p *Prober) Probe(playUrl string) *ProbeResult {
+	result := &ProbeResult{Url: playUrl, Format: ProbeFormatUnknown}
+	if !strings.HasPrefix(playUrl, "http://") && !strings.HasPrefix(playUrl, "https://") {
+		result.Reason = "unsupported scheme"
+		return result
+	}
+
+	start := time.Now()
+	resp, err := p.get(playUrl)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.Latency = millisecondsSince(start)
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		result.Reason = fmt.Sprintf("bad response status [%s]", resp.Status)
+		return result
+	}
+
+	result.Format = detectProbeFormat(playUrl, resp.Header.Get("Content-Type"))
+	switch result.Format {
+	case ProbeFormatHls:
+		err = p.probeHls(resp, start, result)
+	case ProbeFormatFlv:
+		err = p.probeStream(resp, start, result, checkFlvHeader)
+	case ProbeFormatMp4:
+		err = p.probeStream(resp, start, result, checkMp4Header)
+	default:
+		err = errors.New("unknown stream format")
+	}
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+
+	result.Success = true
+	return result
+}
+
+/*
+	优酷、芒果等由客户端解析网页播放，只检查网页可以正常访问
+*/
+func (p *Prober) ProbePage(pageUrl string) *ProbeResult {
+	result := &ProbeResult{Url: pageUrl, Format: ProbeFormatPage}
+
+	start := time.Now()
+	resp, err := p.get(pageUrl)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.Latency = millisecondsSince(start)
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		result.Reason = fmt.Sprintf("bad response status [%s]", resp.Status)
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+func (p *Prober) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	return p.client.Do(req)
+}
+
+func (p *Prober) probeHls(resp *http.Response, start time.Time, result *ProbeResult) error {
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, probeMaxPlaylistSize))
+	if err != nil {
+		return err
+	}
+
+	playlist, err := ParseM3u8(resp.Request.URL.String(), string(body))
+	if err != nil {
+		return err
+	}
+
+	// master playlist选择码率最低的一路，与播放器起播策略一致
+	if playlist.IsMaster {
+		if len(playlist.Variants) == 0 {
+			return errors.New("master playlist has no variant")
+		}
+		variant := playlist.Variants[0]
+		for _, v := range playlist.Variants {
+			if v.Bandwidth > 0 && (variant.Bandwidth == 0 || v.Bandwidth < variant.Bandwidth) {
+				variant = v
+			}
+		}
+
+		mediaResp, err := p.get(variant.Url)
+		if err != nil {
+			return err
+		}
+		defer mediaResp.Body.Close()
+		if mediaResp.StatusCode != http.StatusOK {
+			return fmt.Errorf("bad media playlist status [%s]", mediaResp.Status)
+		}
+		body, err = ioutil.ReadAll(io.LimitReader(mediaResp.Body, probeMaxPlaylistSize))
+		if err != nil {
+			return err
+		}
+		playlist, err = ParseM3u8(mediaResp.Request.URL.String(), string(body))
+		if err != nil {
+			return err
+		}
+		if playlist.IsMaster {
+			return errors.New("nested master playlist")
+		}
+	}
+
+	if len(playlist.Segments) == 0 {
+		return errors.New("media playlist has no segment")
+	}
+
+	// 直播从倒数第三个分片起播，点播从第一个分片起播
+	segment := playlist.Segments[0]
+	if !playlist.EndList && len(playlist.Segments) > 3 {
+		segment = playlist.Segments[len(playlist.Segments)-3]
+	}
+	result.SegmentUrl = segment.Url
+
+	segStart := time.Now()
+	segResp, err := p.get(segment.Url)
+	if err != nil {
+		return err
+	}
+	defer segResp.Body.Close()
+	if segResp.StatusCode != http.StatusOK && segResp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("bad segment status [%s]", segResp.Status)
+	}
+
+	var buf [1]byte
+	if _, err := io.ReadFull(segResp.Body, buf[:]); err != nil {
+		return errors.New("empty segment")
+	}
+	result.Ready = millisecondsSince(start)
+
+	n, err := io.Copy(ioutil.Discard, io.LimitReader(segResp.Body, probeMaxSegmentSize))
+	if err != nil {
+		return err
+	}
+	size := n + 1
+	elapsed := time.Since(segStart).Seconds()
+	if elapsed > 0 {
+		result.Throughput = uint32(float64(size*8) / elapsed)
+	}
+
+	duration := segment.Duration
+	if duration <= 0 {
+		duration = playlist.TargetDuration
+	}
+	if duration > 0 {
+		result.Bitrate = uint32(float64(size*8) / duration)
+		if result.Bitrate < probeMinBitrate {
+			return fmt.Errorf("segment bitrate too low: %d bps", result.Bitrate)
+		}
+		// 下载速度低于码率则无法流畅播放
+		if result.Throughput < result.Bitrate {
+			return fmt.Errorf("segment download too slow: %d bps < %d bps", result.Throughput, result.Bitrate)
+		}
+	}
+	return nil
+}
+
+func (p *Prober) probeStream(resp *http.Response, start time.Time, result *ProbeResult, check func([]byte) bool) error {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(resp.Body, header); err != nil {
+		return errors.New("stream too short")
+	}
+	result.Ready = millisecondsSince(start)
+	if !check(header) {
+		return fmt.Errorf("invalid %s header", result.Format)
+	}
+
+	readStart := time.Now()
+	deadline := readStart.Add(probeStreamReadTime)
+	var size int64 = int64(len(header))
+	buf := make([]byte, 32*1024)
+	for size < probeStreamReadSize && time.Now().Before(deadline) {
+		n, err := resp.Body.Read(buf)
+		size += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	elapsed := time.Since(readStart).Seconds()
+	if elapsed > 0 {
+		result.Throughput = uint32(float64(size*8) / elapsed)
+	}
+	if size < probeStreamReadSize && result.Throughput < probeMinBitrate {
+		return fmt.Errorf("stream download too slow: %d bps", result.Throughput)
+	}
+	return nil
+}
+
+func detectProbeFormat(url, contentType string) string {
+	path := strings.ToLower(url)
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	contentType = strings.ToLower(contentType)
+
+	if strings.HasSuffix(path, ".m3u8") || strings.Contains(contentType, "mpegurl") {
+		return ProbeFormatHls
+	}
+	if strings.HasSuffix(path, ".flv") || strings.Contains(contentType, "flv") {
+		return ProbeFormatFlv
+	}
+	if strings.HasSuffix(path, ".mp4") || strings.Contains(contentType, "mp4") {
+		return ProbeFormatMp4
+	}
+	return ProbeFormatUnknown
+}
+
+func checkFlvHeader(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("FLV"))
+}
+
+// mp4第一个box一般为ftyp，部分文件以moov/free/mdat开头
+func checkMp4Header(header []byte) bool {
+	if len(header) < 8 {
+		return false
+	}
+	switch string(header[4:8]) {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide":
+		return true
+	}
+	return false
+}
+
+func millisecondsSince(t time.Time) int64 {
+	return int64(time.Since(t) / time.Millisecond)
+}
@@ -0,0 +1,60 @@
+package resolver
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	从播放地址的防盗链参数中解析失效时间，无法解析时返回零值
+	auth_key: 阿里云/腾讯云A型鉴权 {timestamp}-{rand}-{uid}-{md5}
+	txTime:   腾讯云直播 十六进制时间戳
+	wsTime:   网宿 十进制或十六进制时间戳
+	expires/e: 通用过期时间戳
+*/
+func ExpiryFromUrl(rawUrl string) time.Time {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return time.Time{}
+	}
+	query := u.Query()
+
+	if v := query.Get("auth_key"); v != "" {
+		if i := strings.Index(v, "-"); i > 0 {
+			if ts, err := strconv.ParseInt(v[:i], 10, 64); err == nil {
+				return unixTime(ts)
+			}
+		}
+	}
+	if v := query.Get("txTime"); v != "" {
+		if ts, err := strconv.ParseInt(v, 16, 64); err == nil {
+			return unixTime(ts)
+		}
+	}
+	if v := query.Get("wsTime"); v != "" {
+		if ts, err := strconv.ParseInt(v, 10, 64); err == nil && len(v) == 10 {
+			return unixTime(ts)
+		}
+		if ts, err := strconv.ParseInt(v, 16, 64); err == nil {
+			return unixTime(ts)
+		}
+	}
+	for _, key := range []string{"expires", "Expires", "e"} {
+		if v := query.Get(key); v != "" {
+			if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return unixTime(ts)
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// 兼容秒和毫秒时间戳
+func unixTime(ts int64) time.Time {
+	if ts > 1e12 {
+		return time.Unix(0, ts*int64(time.Millisecond))
+	}
+	return time.Unix(ts, 0)
+}
@@ -0,0 +1,129 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const DefaultTimeout = time.Second * 15
+
+var (
+	ErrNotRegistered = errors.New("resolver not registered")
+	ErrNoUrl         = errors.New("no play url resolved")
+)
+
+type Request struct {
+	Provider    uint32
+	Url         string // 网页地址，直播为频道标识
+	Quality     uint8  // refer to constant/typ.go VideoQuality*
+	ContentType uint32 // refer to constant/typ.go MediaType*
+	TvType      string // 央视 卫视 地方
+
+	// 直播回看，StartTime为零值时解析直播地址
+	BackTitle string // 回看节目名称
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+type Result struct {
+	Urls          []string          `json:"urls"`            // 长度为1时是完整地址，否则为分段地址
+	ExpiredAt     time.Time         `json:"expired_at"`      // 地址失效时间，零值表示未知
+	Headers       map[string]string `json:"headers"`         // 播放时需携带的请求头，如Referer、User-Agent
+	IsSupportBack bool              `json:"is_support_back"` // 是否支持回看
+}
+
+func (r *Result) Url() string {
+	if r == nil || len(r.Urls) == 0 {
+		return ""
+	}
+	return r.Urls[0]
+}
+
+type Resolver interface {
+	Resolve(ctx context.Context, req *Request) (*Result, error)
+}
+
+type ResolverFunc func(ctx context.Context, req *Request) (*Result, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, req *Request) (*Result, error) {
+	return f(ctx, req)
+}
+
+type entry struct {
+	resolver Resolver
+	timeout  time.Duration
+}
+
+var (
+	mutex    sync.RWMutex
+	registry = make(map[uint32]*entry)
+)
+
+/*
+	注册provider对应的解析器，timeout<=0时使用DefaultTimeout
+*/
+func Register(provider uint32, r Resolver, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	registry[provider] = &entry{resolver: r, timeout: timeout}
+}
+
+func IsRegistered(provider uint32) bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	_, ok := registry[provider]
+	return ok
+}
+
+/*
+	调用provider对应的解析器，超过解析器的超时时间或ctx取消时立即返回。
+	返回时取消传给解析器的ctx，解析器的网络请求和脚本子进程随之结束
+*/
+func Resolve(ctx context.Context, req *Request) (*Result, error) {
+	mutex.RLock()
+	e, ok := registry[req.Provider]
+	mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%v: provider %d", ErrNotRegistered, req.Provider)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	type response struct {
+		result *Result
+		err    error
+	}
+	ch := make(chan response, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ch <- response{err: fmt.Errorf("resolver panic: %v", r)}
+			}
+		}()
+		result, err := e.resolver.Resolve(ctx, req)
+		ch <- response{result: result, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case resp := <-ch:
+		if resp.err != nil {
+			return nil, resp.err
+		}
+		if resp.result == nil || len(resp.result.Urls) == 0 || resp.result.Urls[0] == "" {
+			return nil, ErrNoUrl
+		}
+		if resp.result.ExpiredAt.IsZero() {
+			resp.result.ExpiredAt = ExpiryFromUrl(resp.result.Urls[0])
+		}
+		return resp.result, nil
+	}
+}
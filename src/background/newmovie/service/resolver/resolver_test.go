@@ -0,0 +1,42 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResolveTimeoutCancelsResolver(t *testing.T) {
+	cancelled := make(chan struct{})
+	Register(9001, ResolverFunc(func(ctx context.Context, req *Request) (*Result, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}), time.Millisecond*50)
+
+	if _, err := Resolve(context.Background(), &Request{Provider: 9001}); err != context.DeadlineExceeded {
+		t.Fatalf("err %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("resolver ctx not cancelled after timeout")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	Register(9002, ResolverFunc(func(ctx context.Context, req *Request) (*Result, error) {
+		return &Result{Urls: []string{req.Url}}, nil
+	}), 0)
+
+	result, err := Resolve(context.Background(), &Request{Provider: 9002, Url: "http://a/1.m3u8"})
+	if err != nil || result.Url() != "http://a/1.m3u8" {
+		t.Fatalf("result %v, err %v", result, err)
+	}
+	if _, err := Resolve(context.Background(), &Request{Provider: 9002}); err != ErrNoUrl {
+		t.Errorf("empty url err %v", err)
+	}
+	if _, err := Resolve(context.Background(), &Request{Provider: 9003}); err == nil {
+		t.Error("unregistered provider resolved")
+	}
+}
@@ -4,34 +4,46 @@ package script
 
 import (
 	"background/common/logger"
+	"background/newmovie/service/resolver"
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strings"
 )
 func GetHanjuRealPlayUrl(url string)(string){
+	result, err := ResolveHanju(context.Background(), &resolver.Request{Url: url})
+	if err != nil{
+		logger.Error(err)
+		return ""
+	}
+	return result.Url()
+}
 
-	requ, err := http.NewRequest("GET", url,nil)
+func ResolveHanju(ctx context.Context, req *resolver.Request)(*resolver.Result, error){
+	requ, err := http.NewRequest("GET", req.Url,nil)
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := http.DefaultClient.Do(requ)
+	resp, err := http.DefaultClient.Do(requ.WithContext(ctx))
 	if err != nil {
-		logger.Error(err)
-		return ""
+		return nil, err
 	}
+	defer resp.Body.Close()
 
 	recv,err := ioutil.ReadAll(resp.Body)
 	if err != nil{
-		logger.Error(err)
-		return ""
+		return nil, err
 	}
 	//var vid='https://www2.yuboyun.com/hls/2018/07/08/nVMgCubW/playlist.m3u8';
 
 	data := string(recv)
 	start := strings.Index(data,"var vid='")
 	end := strings.Index(data,".m3u8");
-	if start == -1 || end == -1{
-		return ""
+	if start == -1 || end == -1 || end < start{
+		return nil, errors.New("hanju play url not found")
 	}
 
-	return data[start+9:end+5]
+	return &resolver.Result{Urls: []string{data[start+9:end+5]}, Headers: map[string]string{"Referer": req.Url}}, nil
 }
-
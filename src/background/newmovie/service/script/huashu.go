@@ -2,17 +2,29 @@ package script
 
 import (
 	"background/common/logger"
+	"background/newmovie/model"
+	"background/newmovie/service/resolver"
+	"bytes"
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
-	"bytes"
-	"background/newmovie/model"
+	"strings"
 )
 
 func GetHuashuRealPlayUrl(playUrl model.PlayUrl)(string){
-	//jsCode := GetHuashuJsCode()
+	result, err := ResolveHuashu(context.Background(), &resolver.Request{Url: playUrl.Url, Quality: playUrl.Quality, ContentType: 4})
+	if err != nil{
+		logger.Error(err)
+		return ""
+	}
+	return result.Url()
+}
 
+// 华数直播，req.Url为频道code
+func ResolveHuashu(ctx context.Context, req *resolver.Request)(*resolver.Result, error){
 	var qualityCode string
-	if playUrl.Quality == 4{
+	if req.Quality == 4{
 		qualityCode = "1000996"
 	}else{
 		qualityCode = "1000995"
@@ -23,7 +35,7 @@ func GetHuashuRealPlayUrl(playUrl model.PlayUrl)(string){
 		"\t<body>\n" +
 		"\t\t<contents>\n" +
 		"\t\t\t<content>\n" +
-		"\t\t\t\t<code>" + playUrl.Url + "</code>\n" +
+		"\t\t\t\t<code>" + req.Url + "</code>\n" +
 		"\t\t\t\t<site-code>1000889</site-code>\n" +
 		"\t\t\t\t<items-index>-1</items-index>\n" +
 		"\t\t\t\t<folder-code>" + qualityCode + "</folder-code>\n" +
@@ -33,26 +45,35 @@ func GetHuashuRealPlayUrl(playUrl model.PlayUrl)(string){
 		"\t</body>\n" +
 		"</message>"
 
-	logger.Debug(huaShuSrc)
 	requ, err := http.NewRequest("POST", "http://101.71.69.172:8080/wasu_catalog/catalog", bytes.NewBuffer([]byte(huaShuSrc)))
+	if err != nil {
+		return nil, err
+	}
 	requ.Header.Add("Content-Type", "application/xml")
 
-	resp, err := http.DefaultClient.Do(requ)
+	resp, err := http.DefaultClient.Do(requ.WithContext(ctx))
 	if err != nil {
-		logger.Debug("Proxy failed!")
-		return ""
+		return nil, err
 	}
+	defer resp.Body.Close()
 
 	recv,err := ioutil.ReadAll(resp.Body)
 	if err != nil{
-		logger.Error(err)
+		return nil, err
 	}
 
 	data := string(recv)
+	start := strings.Index(data,"<playUrl>")
+	end := strings.Index(data,"</playUrl>")
+	if start == -1 || end == -1 || end < start{
+		return nil, errors.New("huashu play url not found")
+	}
+	playUrl := data[start+len("<playUrl>"):end]
+	playUrl = strings.Replace(playUrl,"<![CDATA[","",-1)
+	playUrl = strings.Replace(playUrl,"]]>","",-1)
+	playUrl = strings.TrimSpace(playUrl)
 
-
-
-	return data
+	return &resolver.Result{Urls: []string{playUrl}}, nil
 }
 
 //func GetHuashuJsCode()(string){
@@ -0,0 +1,200 @@
+package script
+
+import (
+	"background/common/logger"
+	"background/newmovie/config"
+	"background/newmovie/model"
+	"background/newmovie/service/resolver"
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	poolMutex    sync.RWMutex
+	activePools  = make(map[uint32]*vmPool) // 数据库中生效的脚本
+	builtinPools = make(map[uint32]*vmPool) // 代码内置脚本，数据库没有生效版本时使用
+)
+
+// 取provider当前使用的虚拟机池，优先使用数据库中生效的版本
+func getPool(provider uint32) (*vmPool, error) {
+	poolMutex.RLock()
+	p, ok := activePools[provider]
+	if !ok {
+		p, ok = builtinPools[provider]
+	}
+	poolMutex.RUnlock()
+	if ok {
+		return p, nil
+	}
+
+	jp, ok := jsProviders[provider]
+	if !ok {
+		return nil, fmt.Errorf("no script for provider %d", provider)
+	}
+	p, err := newVmPool(provider, 0, jp.code(), jp.funcName, config.GetScriptPoolSize())
+	if err != nil {
+		return nil, err
+	}
+
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+	if exist, ok := builtinPools[provider]; ok {
+		return exist, nil
+	}
+	builtinPools[provider] = p
+	return p, nil
+}
+
+/*
+	检查脚本能否加载以及入口函数是否存在，只有通过js解析的provider可以上传脚本
+*/
+func CompileScript(provider uint32, jsCode, funcName string) error {
+	if !IsJsProvider(provider) {
+		return fmt.Errorf("provider %d is not resolved by script", provider)
+	}
+	_, err := newVmPool(provider, 0, jsCode, funcName, 0)
+	return err
+}
+
+/*
+	加载数据库中生效的脚本，版本没有变化的provider不重新加载，
+	新版本加载失败时继续使用原来的版本
+*/
+func LoadScripts(db *gorm.DB) error {
+	var scripts []model.ResolverScript
+	if err := db.Where("status = ?", model.ResolverScriptStatusActive).Find(&scripts).Error; err != nil {
+		return err
+	}
+
+	poolMutex.RLock()
+	old := activePools
+	poolMutex.RUnlock()
+
+	pools := make(map[uint32]*vmPool)
+	for _, s := range scripts {
+		p, ok := old[s.Provider]
+		if ok && p.version == s.Version {
+			pools[s.Provider] = p
+			continue
+		}
+
+		np, err := newVmPool(s.Provider, s.Version, s.Content, s.FuncName, config.GetScriptPoolSize())
+		if err != nil {
+			logger.Error("load resolver script failed, provider: ", s.Provider, " version: ", s.Version, " ", err)
+			if ok {
+				pools[s.Provider] = p
+			}
+			continue
+		}
+		logger.Info("load resolver script, provider: ", s.Provider, " version: ", s.Version)
+		pools[s.Provider] = np
+	}
+
+	poolMutex.Lock()
+	activePools = pools
+	poolMutex.Unlock()
+
+	for provider, p := range old {
+		if pools[provider] != p {
+			p.close()
+		}
+	}
+	return nil
+}
+
+// 定时检查脚本更新，cmsd和taskd启动时调用
+func WatchScripts(db *gorm.DB) {
+	for {
+		if err := LoadScripts(db); err != nil {
+			logger.Error(err)
+		}
+		time.Sleep(time.Second * time.Duration(config.GetScriptReloadInterval()))
+	}
+}
+
+type DryRunOutput struct {
+	Version       uint32   `json:"version"` // 0为代码内置脚本
+	Urls          []string `json:"urls"`
+	IsSupportBack bool     `json:"is_support_back"`
+	Error         string   `json:"error"`
+	Elapsed       int64    `json:"elapsed"` // 毫秒
+}
+
+type DryRunResult struct {
+	Candidate *DryRunOutput `json:"candidate"`
+	Active    *DryRunOutput `json:"active"`
+	Same      bool          `json:"same"`
+	Diff      []string      `json:"diff"`
+}
+
+/*
+	用候选脚本和当前生效的脚本分别解析同一个地址，对比输出。
+	播放地址中的鉴权参数每次都不同，只比较scheme、host和path
+*/
+func DryRun(ctx context.Context, candidate *model.ResolverScript, req *resolver.Request) (*DryRunResult, error) {
+	pool, err := newVmPool(candidate.Provider, candidate.Version, candidate.Content, candidate.FuncName, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.close()
+
+	var result DryRunResult
+	result.Candidate = dryRunPool(ctx, pool, req)
+	if active, err := getPool(candidate.Provider); err == nil {
+		result.Active = dryRunPool(ctx, active, req)
+	}
+	result.Diff = diffDryRun(result.Active, result.Candidate)
+	result.Same = len(result.Diff) == 0
+	return &result, nil
+}
+
+func dryRunPool(ctx context.Context, pool *vmPool, req *resolver.Request) *DryRunOutput {
+	out := &DryRunOutput{Version: pool.version}
+	start := time.Now()
+	res, err := runJsResolver(ctx, pool, newJsRequest(pool.provider, req))
+	out.Elapsed = int64(time.Since(start) / time.Millisecond)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Urls = res.Urls
+	out.IsSupportBack = res.IsSupportBack
+	return out
+}
+
+func diffDryRun(active, candidate *DryRunOutput) []string {
+	var diff []string
+	if active == nil {
+		return append(diff, "no active script")
+	}
+	if active.Error != candidate.Error {
+		diff = append(diff, fmt.Sprintf("error: %q -> %q", active.Error, candidate.Error))
+	}
+	if active.IsSupportBack != candidate.IsSupportBack {
+		diff = append(diff, fmt.Sprintf("is_support_back: %v -> %v", active.IsSupportBack, candidate.IsSupportBack))
+	}
+	if len(active.Urls) != len(candidate.Urls) {
+		diff = append(diff, fmt.Sprintf("urls: %d -> %d", len(active.Urls), len(candidate.Urls)))
+		return diff
+	}
+	for i := range active.Urls {
+		a, b := urlWithoutQuery(active.Urls[i]), urlWithoutQuery(candidate.Urls[i])
+		if a != b {
+			diff = append(diff, fmt.Sprintf("urls[%d]: %s -> %s", i, a, b))
+		}
+	}
+	return diff
+}
+
+func urlWithoutQuery(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
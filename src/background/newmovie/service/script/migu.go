@@ -1,134 +1,22 @@
 package script
 
 import (
+	"background/common/constant"
 	"background/common/logger"
-	"github.com/robertkrimen/otto"
-	"encoding/json"
-	"io/ioutil"
-	"net/http"
+	"background/newmovie/service/resolver"
+	"context"
 )
 func GetMiguRealPlayUrl(content_type uint32,url string)(string){
-	jsCode := GetMiguJsCode()
-
-	type CallBackDatas struct{
-		FetchUrlNew   string      `gorm:"fetch_url_new" json:"fetch_url_new"`
-		VId           string      `gorm:"vId" json:"vId"`
-	}
-
-	type ReqParam struct{
-		Times             uint32    `gorm:"times" json:"times"`
-		Quality           uint8     `gorm:"quality" json:"quality"`
-		ContentType       uint32    `gorm:"content_type" json:"content_type"`
-		HtmlData          string    `gorm:"html_data" json:"html_data"`
-		Url               string    `gorm:"url" json:"url"`
-		CallBackData      CallBackDatas  `gorm:"call_back_data" json:"call_back_data"`
-	}
-
-	type Headers struct{
-		UserAgent           string    `gorm:"user_agent" json:"user_agent"`
-		Referer             string    `gorm:"referer" json:"referer"`
-		ContentType         string    `gorm:"content_type" json:"content_type"`
-		UserId              string    `gorm:"userId" json:"userId"`
-		UserToken           string    `gorm:"userToken" json:"userToken"`
-		SdkceId             string    `gorm:"SDKCEId" json:"SDKCEId"`
-		ClientId            string    `gorm:"clientId" json:"clientId"`
-	}
-
-	type Fetch struct{
-		Url           string    `gorm:"url" json:"url"`
-		Method        string    `gorm:"method" json:"method"`
-		Header        Headers   `gorm:"header" json:"header"`
-		Body          string    `gorm:"body" json:"body"`
-	}
-
-	type ResParam struct{
-		Done              bool      `gorm:"done" json:"done"`
-		FetchUrl          Fetch     `gorm:"fetch_url" json:"fetch_url"`
-		Urls              []string  `gorm:"urls" json:"urls"`
-		CallBackData      CallBackDatas  `gorm:"call_back_data" json:"call_back_data"`
-	}
-
-	first := true
-	var reqParam ReqParam
-	var resParam ResParam
-	var times uint32 = 0
-
-	var result string
-	for first || !resParam.Done {
-		times = times + 1
-		reqParam.Times = times
-		reqParam.Quality = 3
-		reqParam.ContentType = content_type
-		reqParam.Url = url
-
-		if len(resParam.FetchUrl.Url) > 0{
-			requ, err := http.NewRequest("GET", resParam.FetchUrl.Url,nil)
-
-			if len(resParam.FetchUrl.Header.UserAgent) > 0{
-				requ.Header.Add("User-Agent", resParam.FetchUrl.Header.UserAgent)
-			}
-			if len(resParam.FetchUrl.Header.Referer) > 0{
-				requ.Header.Add("Referer", resParam.FetchUrl.Header.Referer)
-			}
-			if len(resParam.FetchUrl.Header.ContentType) > 0{
-				requ.Header.Add("Content-Type", resParam.FetchUrl.Header.ContentType)
-			}
-			if len(resParam.FetchUrl.Header.UserId) > 0{
-				requ.Header.Add("userId", resParam.FetchUrl.Header.UserId)
-			}
-			if len(resParam.FetchUrl.Header.ContentType) > 0{
-				requ.Header.Add("userToken", resParam.FetchUrl.Header.UserToken)
-			}
-			if len(resParam.FetchUrl.Header.ContentType) > 0{
-				requ.Header.Add("SDKCEId", resParam.FetchUrl.Header.SdkceId)
-			}
-			if len(resParam.FetchUrl.Header.ClientId) > 0{
-				requ.Header.Add("clientId", resParam.FetchUrl.Header.ClientId)
-			}
-
-			resp, err := http.DefaultClient.Do(requ)
-			if err != nil {
-				logger.Debug("err")
-				return ""
-			}
-
-			recv,err := ioutil.ReadAll(resp.Body)
-			if err != nil{
-				logger.Error(err)
-			}
-			//logger.Debug(string(recv))
-			reqParam.HtmlData = string(recv)
-
-			//logger.Debug("html_data:",reqParam.HtmlData)
-
-		}
-
-		b, _ := json.Marshal(reqParam)
-		//logger.Debug(string(b))
-
-		vm := otto.New()
-		vm.Run(jsCode)
-		data ,err := vm.Call("GetMiguRealPlayUrl",nil,string(b))
-		if err != nil{
-			logger.Error(err)
-			return ""
-		}
-		result = data.String()
-		//logger.Debug(result)
-
-		if err := json.Unmarshal([]byte(result), &resParam); err != nil {
-			logger.Error(err)
-			return ""
-		}
-
-		reqParam.CallBackData = resParam.CallBackData
-		first = false
+	result, err := ResolveMigu(context.Background(), &resolver.Request{Url: url, Quality: 3, ContentType: content_type})
+	if err != nil{
+		logger.Error(err)
+		return ""
 	}
+	return result.Url()
+}
 
-	if len(resParam.Urls) > 0{
-		return resParam.Urls[0]
-	}
-	return ""
+func ResolveMigu(ctx context.Context, req *resolver.Request)(*resolver.Result, error){
+	return runProviderScript(ctx, constant.ContentProviderMigu, req)
 }
 
 func GetMiguJsCode()(string){
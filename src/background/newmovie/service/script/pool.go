@@ -0,0 +1,88 @@
+package script
+
+import (
+	"background/newmovie/config"
+	"context"
+)
+
+/*
+	同一版本脚本的子进程池。子进程启动后只加载脚本不执行入口函数，
+	每次解析取用一个，执行后结束，避免脚本全局变量在请求间串用
+*/
+type vmPool struct {
+	provider uint32
+	version  uint32 // 0为代码内置脚本
+	funcName string
+	code     string
+	vms      chan *sandbox
+}
+
+func newVmPool(provider, version uint32, jsCode, funcName string, size int) (*vmPool, error) {
+	p := new(vmPool)
+	p.provider = provider
+	p.version = version
+	p.funcName = funcName
+	p.code = jsCode
+	p.vms = make(chan *sandbox, size)
+
+	// 第一个子进程检查脚本能否加载
+	vm, err := p.start(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		vm.close()
+		return p, nil
+	}
+	p.vms <- vm
+	for i := 1; i < cap(p.vms); i++ {
+		go p.refill()
+	}
+	return p, nil
+}
+
+func (p *vmPool) start(ctx context.Context) (*sandbox, error) {
+	return startSandbox(ctx, p.code, p.funcName, config.GetScriptCpuTime(), config.GetScriptMemoryLimit())
+}
+
+// 池中没有空闲的子进程时启动一个，ctx取消时停止等待
+func (p *vmPool) get(ctx context.Context) (*sandbox, error) {
+	var vm *sandbox
+	select {
+	case vm = <-p.vms:
+	default:
+		var err error
+		if vm, err = p.start(ctx); err != nil {
+			return nil, err
+		}
+	}
+	go p.refill()
+	return vm, nil
+}
+
+func (p *vmPool) refill() {
+	if len(p.vms) == cap(p.vms) {
+		return
+	}
+	vm, err := p.start(context.Background())
+	if err != nil {
+		return
+	}
+	select {
+	case p.vms <- vm:
+	default:
+		vm.close()
+	}
+}
+
+// 版本被替换后结束空闲的子进程
+func (p *vmPool) close() {
+	for {
+		select {
+		case vm := <-p.vms:
+			vm.close()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package script
+
+import (
+	"background/common/constant"
+	"background/newmovie/service/resolver"
+	"context"
+	"time"
+)
+
+type jsProvider struct {
+	code     func() string // 代码内置脚本
+	funcName string
+	channel  bool // 直播频道名通过channel字段传给脚本
+}
+
+// 通过js脚本解析的provider，可以在cms中上传新版本替换内置脚本
+var jsProviders = map[uint32]jsProvider{
+	constant.ContentProviderCntv:  {GetCntvJsCode, "GetCntvRealPlayUrl", true},
+	constant.ContentProviderIqiyi: {GetiqiyiJsCode, "GetIqiyiRealPlayUrl", false},
+	constant.ContentProviderMigu:  {GetMiguJsCode, "GetMiguRealPlayUrl", false},
+	constant.ContentProviderSohu:  {GetSohuJsCode, "GetSohuRealPlayUrl", false},
+}
+
+// provider是否通过js脚本解析
+func IsJsProvider(provider uint32) bool {
+	_, ok := jsProviders[provider]
+	return ok
+}
+
+func newJsRequest(provider uint32, req *resolver.Request) *jsRequest {
+	jsReq := jsRequest{Quality: req.Quality, ContentType: req.ContentType, TvType: req.TvType}
+	if jp, ok := jsProviders[provider]; ok && jp.channel {
+		jsReq.Channel = req.Url
+	} else {
+		jsReq.Url = req.Url
+	}
+	if !req.StartTime.IsZero() {
+		jsReq.BackTitle = req.BackTitle
+		jsReq.StartTime = req.StartTime.UnixNano() / int64(time.Millisecond)
+		jsReq.EndTime = req.EndTime.UnixNano() / int64(time.Millisecond)
+	}
+	return &jsReq
+}
+
+// 优酷、芒果等由客户端解析网页，直接返回原地址
+func resolvePageUrl(ctx context.Context, req *resolver.Request) (*resolver.Result, error) {
+	return &resolver.Result{Urls: []string{req.Url}}, nil
+}
+
+func init() {
+	resolver.Register(constant.ContentProviderSystem, resolver.ResolverFunc(resolvePageUrl), time.Second)
+	resolver.Register(constant.ContentProviderYouKu, resolver.ResolverFunc(resolvePageUrl), time.Second)
+	resolver.Register(constant.ContentProviderMgtv, resolver.ResolverFunc(resolvePageUrl), time.Second)
+
+	resolver.Register(constant.ContentProviderIqiyi, resolver.ResolverFunc(ResolveIqiyi), time.Second*15)
+	resolver.Register(constant.ContentProviderSohu, resolver.ResolverFunc(ResolveSohu), time.Second*15)
+	resolver.Register(constant.ContentProviderMigu, resolver.ResolverFunc(ResolveMigu), time.Second*15)
+	resolver.Register(constant.ContentProviderCntv, resolver.ResolverFunc(ResolveCntv), time.Second*15)
+	resolver.Register(constant.ContentProviderHanju, resolver.ResolverFunc(ResolveHanju), time.Second*10)
+	resolver.Register(constant.ContentProviderHuashu, resolver.ResolverFunc(ResolveHuashu), time.Second*10)
+	resolver.Register(constant.ContentProviderRRmeiju, resolver.ResolverFunc(ResolveRRmeiju), time.Second*20)
+}
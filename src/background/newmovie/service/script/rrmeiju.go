@@ -3,6 +3,9 @@ package script
 
 import (
 	"background/common/logger"
+	"background/newmovie/service/resolver"
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -11,13 +14,25 @@ import (
 	"net/url"
 )
 func GetRRmeijuRealPlayUrl(apiurl string)(string){
-
-	query, err := goquery.NewDocument(apiurl)
-	if err != nil {
+	result, err := ResolveRRmeiju(context.Background(), &resolver.Request{Url: apiurl})
+	if err != nil{
 		logger.Debug(apiurl)
 		logger.Error(err)
 		return ""
 	}
+	return result.Url()
+}
+
+func ResolveRRmeiju(ctx context.Context, req *resolver.Request)(*resolver.Result, error){
+	page, err := getWithContext(ctx, req.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
 
 	base := query.Find("script")
 	html := ""
@@ -27,43 +42,51 @@ func GetRRmeijuRealPlayUrl(apiurl string)(string){
 		}
 	})
 	if html == ""{
-		return ""
+		return nil, errors.New("rrmeiju player_data not found")
 	}
 
-	//logger.Debug(html)
 	html = strings.Replace(html,"var player_data=","",-1)
-	//logger.Debug(html)
 
 	apiUrl1 := gjson.Get(html, "url")
-
 	if !apiUrl1.Exists() {
-		return ""
+		return nil, errors.New("rrmeiju player url not found")
 	}
 
 	u, err := url.Parse(apiUrl1.String())
-
-	url1 := u.Host + u.Path
-	requ, err := http.NewRequest("GET", url1,nil)
-
-	resp, err := http.DefaultClient.Do(requ)
 	if err != nil {
-		logger.Error(err)
-		return ""
+		return nil, err
 	}
 
-	recv,err := ioutil.ReadAll(resp.Body)
-	if err != nil{
-		logger.Error(err)
-		return ""
+	data, err := getWithContext(ctx, "http://" + u.Host + u.Path)
+	if err != nil {
+		return nil, err
 	}
 
-	data := string(recv)
 	redictUrl := GetRRmeijuValue("var redirecturl",data)
 	mainStr := GetRRmeijuValue("var main",data)
 	//mp4 := GetRRmeijuValue("mp4",data)
 	realUrl := redictUrl + mainStr
 
-	return realUrl
+	return &resolver.Result{Urls: []string{realUrl}, Headers: map[string]string{"Referer": req.Url}}, nil
+}
+
+func getWithContext(ctx context.Context, url string)(string, error){
+	requ, err := http.NewRequest("GET", url,nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(requ.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	recv,err := ioutil.ReadAll(resp.Body)
+	if err != nil{
+		return "", err
+	}
+	return string(recv), nil
 }
 
 func GetRRmeijuValue(label ,pageinfo string)(string){
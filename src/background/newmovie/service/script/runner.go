@@ -0,0 +1,189 @@
+package script
+
+import (
+	"background/newmovie/service/resolver"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const maxScriptRounds = 10 // 防止脚本返回done=false死循环
+
+/*
+	* times        : 第几次调用脚本，从1开始
+	* quality      : 1 流畅 2 标清 3 高清 4 720P 5 1080P
+	* content_type : 4 直播 2 点播
+	* html_data    : 上一次fetch_url返回的网页内容
+	* call_back_data : 上一次脚本返回的call_back_data，原样回传
+	* back_title、start_time、end_time : 回看节目名称及时间段
+*/
+type jsRequest struct {
+	Times        uint32          `json:"times"`
+	Quality      uint8           `json:"quality"`
+	ContentType  uint32          `json:"content_type"`
+	HtmlData     string          `json:"html_data"`
+	Url          string          `json:"url,omitempty"`
+	Channel      string          `json:"channel,omitempty"`
+	TvType       string          `json:"tv_type,omitempty"`
+	BackTitle    string          `json:"back_title,omitempty"`
+	StartTime    int64           `json:"start_time,omitempty"` // 回看开始时间，毫秒
+	EndTime      int64           `json:"end_time,omitempty"`
+	CallBackData json.RawMessage `json:"call_back_data"`
+}
+
+type jsHeaders struct {
+	UserAgent   string `json:"user_agent"`
+	Referer     string `json:"referer"`
+	ContentType string `json:"content_type"`
+	UserId      string `json:"userId"`
+	UserToken   string `json:"userToken"`
+	SdkceId     string `json:"SDKCEId"`
+	ClientId    string `json:"clientId"`
+}
+
+type jsFetch struct {
+	Url    string    `json:"url"`
+	Method string    `json:"method"`
+	Header jsHeaders `json:"header"`
+	Body   string    `json:"body"`
+}
+
+/*
+	* done     : 为true表示调用结束，false表示需要请求fetch_url后再次调用
+	* urls     : done为true时返回，真实播放地址
+	* is_support_back : 是否支持回看,0不支持，1支持
+*/
+type jsResponse struct {
+	Done          bool            `json:"done"`
+	FetchUrl      jsFetch         `json:"fetch_url"`
+	Urls          []string        `json:"urls"`
+	IsSupportBack uint32          `json:"is_support_back"`
+	Error         string          `json:"error"`
+	CallBackData  json.RawMessage `json:"call_back_data"`
+}
+
+// 使用provider当前生效的脚本解析
+func runProviderScript(ctx context.Context, provider uint32, req *resolver.Request) (*resolver.Result, error) {
+	pool, err := getPool(provider)
+	if err != nil {
+		return nil, err
+	}
+	return runJsResolver(ctx, pool, newJsRequest(provider, req))
+}
+
+/*
+	循环执行解析脚本的入口函数，直到脚本返回done=true。
+	在一个子进程中执行，多轮调用共享ScriptCpuTime的执行时间，fetch网页的时间不计算在内
+*/
+func runJsResolver(ctx context.Context, pool *vmPool, req *jsRequest) (*resolver.Result, error) {
+	vm, err := pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer vm.close()
+
+	if len(req.CallBackData) == 0 {
+		req.CallBackData = json.RawMessage("{}")
+	}
+
+	var res jsResponse
+	var lastHeader jsHeaders
+	for req.Times = 1; req.Times <= maxScriptRounds; req.Times++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(res.FetchUrl.Url) > 0 {
+			data, err := fetchForScript(ctx, &res.FetchUrl)
+			if err != nil {
+				return nil, err
+			}
+			req.HtmlData = data
+			lastHeader = res.FetchUrl.Header
+		}
+
+		b, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		value, err := vm.call(ctx, string(b))
+		if err != nil {
+			return nil, err
+		}
+
+		res = jsResponse{}
+		if err := json.Unmarshal([]byte(value), &res); err != nil {
+			return nil, err
+		}
+		if len(res.CallBackData) > 0 {
+			req.CallBackData = res.CallBackData
+		}
+
+		if res.Done {
+			if res.Error != "" {
+				return nil, errors.New(res.Error)
+			}
+			result := &resolver.Result{Urls: res.Urls, IsSupportBack: res.IsSupportBack == 1}
+			result.Headers = make(map[string]string)
+			if lastHeader.UserAgent != "" {
+				result.Headers["User-Agent"] = lastHeader.UserAgent
+			}
+			if lastHeader.Referer != "" {
+				result.Headers["Referer"] = lastHeader.Referer
+			}
+			return result, nil
+		}
+	}
+	return nil, errors.New(pool.funcName + " exceeded max rounds")
+}
+
+func fetchForScript(ctx context.Context, fetch *jsFetch) (string, error) {
+	method := strings.ToUpper(fetch.Method)
+	if method != "POST" {
+		method = "GET"
+	}
+
+	var body *bytes.Buffer
+	if method == "POST" {
+		body = bytes.NewBufferString(fetch.Body)
+	} else {
+		body = &bytes.Buffer{}
+	}
+
+	requ, err := http.NewRequest(method, fetch.Url, body)
+	if err != nil {
+		return "", err
+	}
+	requ = requ.WithContext(ctx)
+
+	headers := map[string]string{
+		"User-Agent":   fetch.Header.UserAgent,
+		"Referer":      fetch.Header.Referer,
+		"Content-Type": fetch.Header.ContentType,
+		"userId":       fetch.Header.UserId,
+		"userToken":    fetch.Header.UserToken,
+		"SDKCEId":      fetch.Header.SdkceId,
+		"clientId":     fetch.Header.ClientId,
+	}
+	for k, v := range headers {
+		if len(v) > 0 {
+			requ.Header.Add(k, v)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(requ)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	recv, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(recv), nil
+}
@@ -0,0 +1,274 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+/*
+	解析脚本在子进程中执行，子进程是当前程序本身，通过环境变量区分。
+	子进程用rlimit限制内存(RLIMIT_DATA)和cpu时间(RLIMIT_CPU)，超限时被系统终止，
+	不影响主进程和其他脚本。请求和结果通过fd 3、4传递，脚本的console输出不会混入
+*/
+
+const sandboxEnv = "NEWMOVIE_SCRIPT_SANDBOX"
+
+var (
+	errScriptTimeout    = errors.New("script cpu time exceeded")
+	errScriptMemory     = errors.New("script memory limit exceeded")
+	errSandboxDisabled  = errors.New("script sandbox not enabled, call script.RunSandbox in main")
+	sandboxEnabled      bool
+	sandboxStartTimeout = time.Second * 5
+)
+
+type sandboxInit struct {
+	Code     string `json:"code"`
+	FuncName string `json:"func_name"`
+	CpuTime  int    `json:"cpu_time"` // 毫秒，加载和多次调用共享
+	Memory   int    `json:"memory"`   // MB
+}
+
+type sandboxReply struct {
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+/*
+	在main开始时调用。当前进程是脚本子进程时执行脚本后退出，否则允许启动子进程
+*/
+func RunSandbox() {
+	if os.Getenv(sandboxEnv) == "" {
+		sandboxEnabled = true
+		return
+	}
+	os.Exit(serveSandbox(os.NewFile(3, "request"), os.NewFile(4, "reply")))
+}
+
+func serveSandbox(in, out *os.File) int {
+	decoder := json.NewDecoder(in)
+	encoder := json.NewEncoder(out)
+
+	var init sandboxInit
+	if err := decoder.Decode(&init); err != nil {
+		return 1
+	}
+	setLimit(syscall.RLIMIT_DATA, uint64(init.Memory)*1024*1024)
+	// cpu时间按秒计，包含进程启动，超过时收到SIGXCPU
+	setLimit(syscall.RLIMIT_CPU, uint64(init.CpuTime/1000+2))
+
+	vm := otto.New()
+	budget := time.Millisecond * time.Duration(init.CpuTime)
+	err := runWithLimits(vm, &budget, func() error {
+		if _, err := vm.Run(init.Code); err != nil {
+			return err
+		}
+		fn, err := vm.Get(init.FuncName)
+		if err != nil {
+			return err
+		}
+		if !fn.IsFunction() {
+			return errors.New("function " + init.FuncName + " not defined in script")
+		}
+		return nil
+	})
+	if err != nil {
+		encoder.Encode(&sandboxReply{Error: err.Error()})
+		return 0
+	}
+	if encoder.Encode(&sandboxReply{}) != nil {
+		return 1
+	}
+
+	for {
+		var arg string
+		if err := decoder.Decode(&arg); err != nil {
+			return 0
+		}
+		var reply sandboxReply
+		if err := runWithLimits(vm, &budget, func() error {
+			value, err := vm.Call(init.FuncName, nil, arg)
+			if err != nil {
+				return err
+			}
+			reply.Value = value.String()
+			return nil
+		}); err != nil {
+			reply.Error = err.Error()
+		}
+		if encoder.Encode(&reply) != nil {
+			return 1
+		}
+	}
+}
+
+func setLimit(resource int, limit uint64) {
+	syscall.Setrlimit(resource, &syscall.Rlimit{Cur: limit, Max: limit})
+}
+
+/*
+	执行f并限制时间，超时通过Interrupt中断虚拟机。
+	budget为剩余的执行时间，多次调用共享同一个budget。脚本引起的其他panic作为错误返回
+*/
+func runWithLimits(vm *otto.Otto, budget *time.Duration, f func() error) (err error) {
+	if *budget <= 0 {
+		return errScriptTimeout
+	}
+
+	vm.Interrupt = make(chan func(), 1)
+	done := make(chan struct{})
+	start := time.Now()
+	defer func() {
+		close(done)
+		*budget -= time.Since(start)
+		if caught := recover(); caught != nil {
+			if caught == errScriptTimeout {
+				err = errScriptTimeout
+				return
+			}
+			err = fmt.Errorf("script panic: %v", caught)
+		}
+	}()
+
+	go func() {
+		timer := time.NewTimer(*budget)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+			vm.Interrupt <- func() { panic(errScriptTimeout) }
+		}
+	}()
+
+	return f()
+}
+
+// 主进程中的一个脚本子进程，只用于一次解析
+type sandbox struct {
+	cmd     *exec.Cmd
+	request *os.File
+	reply   *os.File
+	decoder *json.Decoder
+	encoder *json.Encoder
+	budget  time.Duration // 等待子进程的时间上限，比子进程内的限制多留启动时间
+}
+
+/*
+	启动子进程并加载脚本，脚本加载失败或入口函数不存在时返回错误。
+	ctx取消时结束子进程，不再等待加载
+*/
+func startSandbox(ctx context.Context, jsCode, funcName string, cpuTime, memory int) (*sandbox, error) {
+	if !sandboxEnabled {
+		return nil, errSandboxDisabled
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	requestRead, requestWrite, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	replyRead, replyWrite, err := os.Pipe()
+	if err != nil {
+		requestRead.Close()
+		requestWrite.Close()
+		return nil, err
+	}
+
+	s := new(sandbox)
+	s.cmd = exec.Command(exe)
+	s.cmd.Env = append(os.Environ(), sandboxEnv+"=1")
+	s.cmd.ExtraFiles = []*os.File{requestRead, replyWrite}
+	s.request = requestWrite
+	s.reply = replyRead
+	s.decoder = json.NewDecoder(replyRead)
+	s.encoder = json.NewEncoder(requestWrite)
+	s.budget = time.Millisecond*time.Duration(cpuTime) + sandboxStartTimeout
+
+	err = s.cmd.Start()
+	requestRead.Close()
+	replyWrite.Close()
+	if err != nil {
+		s.request.Close()
+		s.reply.Close()
+		return nil, err
+	}
+
+	if _, err := s.roundTrip(ctx, &sandboxInit{Code: jsCode, FuncName: funcName, CpuTime: cpuTime, Memory: memory}); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// 调用脚本入口函数，arg为json字符串
+func (s *sandbox) call(ctx context.Context, arg string) (string, error) {
+	return s.roundTrip(ctx, arg)
+}
+
+func (s *sandbox) roundTrip(ctx context.Context, request interface{}) (string, error) {
+	if err := s.encoder.Encode(request); err != nil {
+		return "", s.exitError(err)
+	}
+
+	replies := make(chan error, 1)
+	var reply sandboxReply
+	go func() {
+		replies <- s.decoder.Decode(&reply)
+	}()
+
+	start := time.Now()
+	timer := time.NewTimer(s.budget)
+	defer timer.Stop()
+	select {
+	case err := <-replies:
+		s.budget -= time.Since(start)
+		if err != nil {
+			return "", s.exitError(err)
+		}
+	case <-timer.C:
+		s.close()
+		return "", errScriptTimeout
+	case <-ctx.Done():
+		s.close()
+		return "", ctx.Err()
+	}
+	if reply.Error != "" {
+		if reply.Error == errScriptTimeout.Error() {
+			return "", errScriptTimeout
+		}
+		return "", errors.New(reply.Error)
+	}
+	return reply.Value, nil
+}
+
+// 子进程异常退出时按退出信号判断原因
+func (s *sandbox) exitError(err error) error {
+	s.close()
+	if status, ok := s.cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGXCPU {
+		return errScriptTimeout
+	}
+	// 内存超限时go运行时无法分配内存，以非0状态退出
+	if !s.cmd.ProcessState.Success() {
+		return errScriptMemory
+	}
+	return err
+}
+
+func (s *sandbox) close() {
+	if s.cmd.ProcessState != nil {
+		return
+	}
+	s.request.Close()
+	s.cmd.Process.Kill()
+	s.cmd.Wait()
+	s.reply.Close()
+}
@@ -0,0 +1,53 @@
+package script
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// 测试二进制同时作为脚本子进程
+func TestMain(m *testing.M) {
+	RunSandbox()
+	os.Exit(m.Run())
+}
+
+func runTestScript(t *testing.T, code string) error {
+	p, err := newVmPool(1, 1, code, "F", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runJsResolver(context.Background(), p, &jsRequest{})
+	return err
+}
+
+func TestSandbox(t *testing.T) {
+	// 每次解析使用新的子进程，全局变量不会串用
+	p, err := newVmPool(1, 1, `var n = 0; function F(s){ n++; return JSON.stringify({done:true, urls:["http://a/" + n]}); }`, "F", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.close()
+	for i := 0; i < 3; i++ {
+		res, err := runJsResolver(context.Background(), p, &jsRequest{})
+		if err != nil || len(res.Urls) != 1 || res.Urls[0] != "http://a/1" {
+			t.Fatalf("resolve %v %v", res, err)
+		}
+	}
+
+	if _, err := newVmPool(1, 1, `var x = 1;`, "F", 0); err == nil {
+		t.Error("missing function accepted")
+	}
+}
+
+func TestSandboxLimits(t *testing.T) {
+	if err := runTestScript(t, `function F(s){ while(true){} }`); err != errScriptTimeout {
+		t.Errorf("endless loop %v", err)
+	}
+	if err := runTestScript(t, `function F(s){ var a = "xxxxxxxxxxxxxxxx"; while(true){ a = a + a; } }`); err != errScriptMemory {
+		t.Errorf("string growth %v", err)
+	}
+	if err := runTestScript(t, `function F(s){ return new Array(400000000).join("ab"); }`); err != errScriptMemory {
+		t.Errorf("array join %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	weightTitle  = 10.0
+	weightPinyin = 8.0
+	weightPerson = 6.0 // 演员、导演
+	weightAlias  = 4.0 // 剧集标题
+
+	prefixFactor = 0.7 // 拼音、英文前缀匹配
+	fuzzyFactor  = 0.5 // 拼写错误的模糊匹配
+	bigramFactor = 2.0 // 相邻两字同时命中
+
+	exactTitleBonus  = 100.0
+	prefixTitleBonus = 30.0
+
+	maxPrefixTerms = 200 // 前缀匹配最多展开的词数
+)
+
+type DocKey struct {
+	ContentType uint32
+	Id          uint32
+}
+
+type Document struct {
+	ContentType uint32 // refer to constant/typ.go MediaType*
+	Id          uint32
+	Title       string
+	Aliases     []string // 剧集标题等
+	Actors      string
+	Directors   string
+	Score       float64 // 评分，相关度相同时评分高的在前
+	Sort        uint32
+	Tier        uint32 // 观看所需会员等级，已合并所属资源组的等级
+}
+
+type Hit struct {
+	ContentType uint32
+	Id          uint32
+	Score       float64
+}
+
+type indexedDoc struct {
+	doc     *Document
+	terms   []string
+	title   string // 去掉分隔符的标题
+	pinyins []string
+}
+
+/*
+	内存倒排索引，汉字按单字和两字切分，字母数字支持前缀和模糊匹配
+*/
+type Index struct {
+	mutex      sync.RWMutex
+	docs       map[DocKey]*indexedDoc
+	postings   map[string]map[DocKey]float64
+	vocab      []string // 排序后的字母数字词，用于前缀和模糊匹配
+	vocabDirty bool
+}
+
+func NewIndex() *Index {
+	idx := new(Index)
+	idx.docs = make(map[DocKey]*indexedDoc)
+	idx.postings = make(map[string]map[DocKey]float64)
+	return idx
+}
+
+func (idx *Index) Len() int {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	return len(idx.docs)
+}
+
+// 添加或更新文档
+func (idx *Index) Add(doc *Document) {
+	key := DocKey{doc.ContentType, doc.Id}
+
+	terms := make(map[string]float64)
+	addTerms := func(list []string, weight float64) {
+		for _, t := range list {
+			if terms[t] < weight {
+				terms[t] = weight
+			}
+		}
+	}
+	addTerms(textTerms(doc.Title), weightTitle)
+	pinyins := pinyinTerms(doc.Title)
+	addTerms(pinyins, weightPinyin)
+	addTerms(textTerms(doc.Actors), weightPerson)
+	addTerms(textTerms(doc.Directors), weightPerson)
+	for _, alias := range doc.Aliases {
+		addTerms(textTerms(alias), weightAlias)
+	}
+
+	d := &indexedDoc{doc: doc, title: compact(doc.Title)}
+	if len(pinyins) >= 2 {
+		d.pinyins = pinyins[:2]
+	}
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	idx.remove(key)
+	for t, w := range terms {
+		docs, ok := idx.postings[t]
+		if !ok {
+			docs = make(map[DocKey]float64)
+			idx.postings[t] = docs
+			if isAscii(t) {
+				idx.vocabDirty = true
+			}
+		}
+		docs[key] = w
+		d.terms = append(d.terms, t)
+	}
+	idx.docs[key] = d
+}
+
+// 已索引的contentType类型文档的id
+func (idx *Index) Ids(contentType uint32) []uint32 {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	var ids []uint32
+	for k := range idx.docs {
+		if k.ContentType == contentType {
+			ids = append(ids, k.Id)
+		}
+	}
+	return ids
+}
+
+func (idx *Index) Remove(contentType, id uint32) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	idx.remove(DocKey{contentType, id})
+}
+
+func (idx *Index) remove(key DocKey) {
+	d, ok := idx.docs[key]
+	if !ok {
+		return
+	}
+	for _, t := range d.terms {
+		docs := idx.postings[t]
+		delete(docs, key)
+		if len(docs) == 0 {
+			delete(idx.postings, t)
+			if isAscii(t) {
+				idx.vocabDirty = true
+			}
+		}
+	}
+	delete(idx.docs, key)
+}
+
+func (idx *Index) ensureVocab() {
+	idx.mutex.RLock()
+	dirty := idx.vocabDirty
+	idx.mutex.RUnlock()
+	if !dirty {
+		return
+	}
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	if !idx.vocabDirty {
+		return
+	}
+	vocab := make([]string, 0, len(idx.vocab))
+	for t := range idx.postings {
+		if isAscii(t) {
+			vocab = append(vocab, t)
+		}
+	}
+	sort.Strings(vocab)
+	idx.vocab = vocab
+	idx.vocabDirty = false
+}
+
+/*
+	搜索并按相关度排序，返回offset开始的limit条结果和总数，所需会员等级高于tier的不返回。
+	查询中的汉字和字母数字词允许少量未命中(maxTypos)，字母数字另外支持前缀和编辑距离匹配
+*/
+func (idx *Index) Search(query string, tier uint32, offset, limit int) ([]Hit, int) {
+	coverage, bigrams := queryTokens(query)
+	if len(coverage) == 0 {
+		return nil, 0
+	}
+	idx.ensureVocab()
+
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	matched := make(map[DocKey]int)
+	scores := make(map[DocKey]float64)
+	for _, t := range coverage {
+		best := make(map[DocKey]float64)
+		merge := func(docs map[DocKey]float64, factor float64) {
+			for k, w := range docs {
+				if best[k] < w*factor {
+					best[k] = w * factor
+				}
+			}
+		}
+
+		merge(idx.postings[t.term], 1)
+		if t.ascii {
+			for _, term := range idx.prefixTerms(t.term) {
+				merge(idx.postings[term], prefixFactor)
+			}
+			if len(best) == 0 {
+				for _, term := range idx.fuzzyTerms(t.term) {
+					merge(idx.postings[term], fuzzyFactor)
+				}
+			}
+		}
+
+		for k, w := range best {
+			matched[k]++
+			scores[k] += w
+		}
+	}
+	for _, b := range bigrams {
+		for k, w := range idx.postings[b] {
+			if _, ok := scores[k]; ok {
+				scores[k] += w * bigramFactor
+			}
+		}
+	}
+
+	need := len(coverage) - maxTypos(len(coverage))
+	q := compact(query)
+	var hits []Hit
+	for k, n := range matched {
+		if n < need {
+			continue
+		}
+		d := idx.docs[k]
+		if d.doc.Tier > tier {
+			continue
+		}
+		score := scores[k]
+		if d.title == q || containsString(d.pinyins, q) {
+			score += exactTitleBonus
+		} else if strings.HasPrefix(d.title, q) {
+			score += prefixTitleBonus
+		}
+		score += d.doc.Score
+		hits = append(hits, Hit{ContentType: k.ContentType, Id: k.Id, Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		a := idx.docs[DocKey{hits[i].ContentType, hits[i].Id}].doc
+		b := idx.docs[DocKey{hits[j].ContentType, hits[j].Id}].doc
+		if a.Sort != b.Sort {
+			return a.Sort < b.Sort
+		}
+		return a.Id > b.Id
+	})
+
+	total := len(hits)
+	if offset >= total {
+		return nil, total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return hits[offset:end], total
+}
+
+// 以prefix开头的词，不包括prefix本身
+func (idx *Index) prefixTerms(prefix string) []string {
+	var terms []string
+	i := sort.SearchStrings(idx.vocab, prefix)
+	for ; i < len(idx.vocab) && len(terms) < maxPrefixTerms; i++ {
+		t := idx.vocab[i]
+		if !strings.HasPrefix(t, prefix) {
+			break
+		}
+		if t != prefix {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+func (idx *Index) fuzzyTerms(term string) []string {
+	d := maxEditDistance(term)
+	if d == 0 {
+		return nil
+	}
+	var terms []string
+	for _, t := range idx.vocab {
+		diff := len(t) - len(term)
+		if diff > d || diff < -d {
+			continue
+		}
+		if editDistance(t, term) <= d {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+func isAscii(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"testing"
+)
+
+func testIndex() *Index {
+	idx := NewIndex()
+	idx.Add(&Document{ContentType: 1, Id: 1, Title: "湖南卫视", Sort: 2})
+	idx.Add(&Document{ContentType: 1, Id: 2, Title: "湖南都市", Sort: 1})
+	idx.Add(&Document{ContentType: 2, Id: 3, Title: "卫视新闻联播", Actors: "张三"})
+	idx.Add(&Document{ContentType: 2, Id: 4, Title: "Breaking Bad", Aliases: []string{"绝命毒师 第1集"}, Tier: 2})
+	return idx
+}
+
+func hitIds(hits []Hit) []uint32 {
+	var ids []uint32
+	for _, hit := range hits {
+		ids = append(ids, hit.Id)
+	}
+	return ids
+}
+
+func TestSearchRanking(t *testing.T) {
+	idx := testIndex()
+
+	// 标题完全相同的排在最前，其次是标题前缀
+	hits, total := idx.Search("湖南卫视", 0, 0, 10)
+	if total == 0 || hits[0].Id != 1 {
+		t.Fatalf("exact title %v", hitIds(hits))
+	}
+	hits, _ = idx.Search("卫视", 0, 0, 10)
+	if len(hits) != 2 || hits[0].Id != 3 {
+		t.Errorf("title prefix %v", hitIds(hits))
+	}
+	// 相关度相同时按sort
+	hits, _ = idx.Search("湖南", 0, 0, 10)
+	if len(hits) != 2 || hits[0].Id != 2 || hits[1].Id != 1 {
+		t.Errorf("sort order %v", hitIds(hits))
+	}
+	hits, _ = idx.Search("张三", 0, 0, 10)
+	if len(hits) != 1 || hits[0].Id != 3 {
+		t.Errorf("actor %v", hitIds(hits))
+	}
+}
+
+func TestSearchPinyin(t *testing.T) {
+	idx := testIndex()
+	for _, q := range []string{"hunanweishi", "hnws", "weishi", "huna"} {
+		hits, _ := idx.Search(q, 0, 0, 10)
+		found := false
+		for _, hit := range hits {
+			found = found || hit.Id == 1
+		}
+		if !found {
+			t.Errorf("%s: %v", q, hitIds(hits))
+		}
+	}
+	hits, _ := idx.Search("hnws", 0, 0, 10)
+	if len(hits) == 0 || hits[0].Id != 1 {
+		t.Errorf("initials exact %v", hitIds(hits))
+	}
+}
+
+func TestSearchFuzzy(t *testing.T) {
+	idx := testIndex()
+	hits, _ := idx.Search("breking", 2, 0, 10)
+	if len(hits) != 1 || hits[0].Id != 4 {
+		t.Errorf("typo %v", hitIds(hits))
+	}
+	// 短词不做模糊匹配
+	if hits, _ := idx.Search("bak", 2, 0, 10); len(hits) != 0 {
+		t.Errorf("short typo %v", hitIds(hits))
+	}
+	// 剧集标题
+	if hits, _ := idx.Search("绝命毒师", 2, 0, 10); len(hits) != 1 {
+		t.Errorf("alias %v", hitIds(hits))
+	}
+}
+
+func TestSearchTierAndPaging(t *testing.T) {
+	idx := testIndex()
+	if hits, _ := idx.Search("breaking", 1, 0, 10); len(hits) != 0 {
+		t.Errorf("tier filtered %v", hitIds(hits))
+	}
+	hits, total := idx.Search("湖南", 0, 1, 10)
+	if total != 2 || len(hits) != 1 || hits[0].Id != 1 {
+		t.Errorf("offset %v total %d", hitIds(hits), total)
+	}
+	if hits, total := idx.Search("湖南", 0, 5, 10); hits != nil || total != 2 {
+		t.Errorf("offset past end %v total %d", hitIds(hits), total)
+	}
+}
+
+func TestIndexRemoveAndUpdate(t *testing.T) {
+	idx := testIndex()
+	idx.Remove(1, 1)
+	if hits, _ := idx.Search("hunanweishi", 0, 0, 10); len(hits) != 0 {
+		t.Errorf("removed doc found %v", hitIds(hits))
+	}
+	if ids := idx.Ids(1); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("ids %v", ids)
+	}
+
+	// 更新后旧标题的词不再命中
+	idx.Add(&Document{ContentType: 2, Id: 3, Title: "东方卫视"})
+	if hits, _ := idx.Search("新闻", 0, 0, 10); len(hits) != 0 {
+		t.Errorf("old title found %v", hitIds(hits))
+	}
+	if hits, _ := idx.Search("dongfang", 0, 0, 10); len(hits) != 1 {
+		t.Errorf("new title %v", hitIds(hits))
+	}
+	if idx.Len() != 3 {
+		t.Errorf("len %d", idx.Len())
+	}
+}
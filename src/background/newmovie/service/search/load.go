@@ -0,0 +1,263 @@
+package search
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	syncInterval    = time.Second * 30 // 增量同步间隔，taskd等其他进程导入的数据由此同步
+	rebuildInterval = time.Hour        // 全量重建间隔，同步资源组会员等级的修改
+)
+
+var (
+	indexMutex   sync.RWMutex
+	defaultIndex = NewIndex()
+	lastSync     time.Time
+)
+
+func getIndex() *Index {
+	indexMutex.RLock()
+	defer indexMutex.RUnlock()
+	return defaultIndex
+}
+
+func Search(query string, tier uint32, offset, limit int) ([]Hit, int) {
+	return getIndex().Search(query, tier, offset, limit)
+}
+
+/*
+	从数据库全量重建索引，只索引上线的视频和频道，剧集标题并入所属视频。
+	资源组的会员等级修改不会更新频道和视频，在全量重建时同步
+*/
+func Rebuild(db *gorm.DB) error {
+	start := time.Now()
+	idx := NewIndex()
+
+	var videos []model.Video
+	if err := db.Where("on_line = ?", constant.MediaStatusOnLine).Find(&videos).Error; err != nil {
+		return err
+	}
+	var episodes []model.Episode
+	if err := db.Select("video_id, title").Find(&episodes).Error; err != nil {
+		return err
+	}
+	aliases := make(map[uint32][]string)
+	for _, episode := range episodes {
+		aliases[episode.VideoId] = append(aliases[episode.VideoId], episode.Title)
+	}
+	var videoIds []uint32
+	for _, video := range videos {
+		videoIds = append(videoIds, video.Id)
+	}
+	videoTiers, err := service.GetVideoRequiredTiers(db, videoIds)
+	if err != nil {
+		return err
+	}
+	for i := range videos {
+		idx.Add(videoDocument(&videos[i], aliases[videos[i].Id], videoTiers[videos[i].Id]))
+	}
+
+	var streams []model.Stream
+	if err := db.Where("on_line = ? and disable = 0", constant.MediaStatusOnLine).Find(&streams).Error; err != nil {
+		return err
+	}
+	var streamIds []uint32
+	for _, stream := range streams {
+		streamIds = append(streamIds, stream.Id)
+	}
+	streamTiers, err := service.GetStreamRequiredTiers(db, streamIds)
+	if err != nil {
+		return err
+	}
+	for i := range streams {
+		idx.Add(streamDocument(&streams[i], streamTiers[streams[i].Id]))
+	}
+
+	indexMutex.Lock()
+	defaultIndex = idx
+	lastSync = start
+	indexMutex.Unlock()
+
+	logger.Info("search index rebuilt, videos: ", len(videos), " streams: ", len(streams), " cost: ", time.Since(start))
+	return nil
+}
+
+// 重新索引一个视频，下线或已删除的从索引中移除
+func IndexVideo(db *gorm.DB, id uint32) error {
+	var video model.Video
+	if err := db.Where("id = ?", id).First(&video).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			getIndex().Remove(constant.MediaTypeVideo, id)
+			return nil
+		}
+		return err
+	}
+	if !video.OnLine {
+		getIndex().Remove(constant.MediaTypeVideo, id)
+		return nil
+	}
+
+	var titles []string
+	if err := db.Model(model.Episode{}).Where("video_id = ?", id).Pluck("title", &titles).Error; err != nil {
+		return err
+	}
+	tiers, err := service.GetVideoRequiredTiers(db, []uint32{id})
+	if err != nil {
+		return err
+	}
+	getIndex().Add(videoDocument(&video, titles, tiers[id]))
+	return nil
+}
+
+func IndexStream(db *gorm.DB, id uint32) error {
+	var stream model.Stream
+	if err := db.Where("id = ?", id).First(&stream).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			getIndex().Remove(constant.MediaTypeStream, id)
+			return nil
+		}
+		return err
+	}
+	if !stream.OnLine || stream.Disable {
+		getIndex().Remove(constant.MediaTypeStream, id)
+		return nil
+	}
+	tiers, err := service.GetStreamRequiredTiers(db, []uint32{id})
+	if err != nil {
+		return err
+	}
+	getIndex().Add(streamDocument(&stream, tiers[id]))
+	return nil
+}
+
+/*
+	移除数据库中已删除的视频和频道。直接删除的记录没有updated_at，比较id发现
+*/
+func removeDeleted(db *gorm.DB) error {
+	tables := map[uint32]interface{}{
+		constant.MediaTypeVideo:  model.Video{},
+		constant.MediaTypeStream: model.Stream{},
+	}
+	idx := getIndex()
+	for contentType, table := range tables {
+		var ids []uint32
+		if err := db.Model(table).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		exists := make(map[uint32]bool, len(ids))
+		for _, id := range ids {
+			exists[id] = true
+		}
+		for _, id := range idx.Ids(contentType) {
+			if !exists[id] {
+				idx.Remove(contentType, id)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+	同步上次同步后更新过的视频、剧集和频道，并移除已删除的
+*/
+func syncUpdated(db *gorm.DB) error {
+	indexMutex.RLock()
+	since := lastSync
+	indexMutex.RUnlock()
+	start := time.Now()
+
+	if err := removeDeleted(db); err != nil {
+		return err
+	}
+
+	var videoIds []uint32
+	if err := db.Model(model.Video{}).Where("updated_at >= ?", since).Pluck("id", &videoIds).Error; err != nil {
+		return err
+	}
+	var episodeVideoIds []uint32
+	if err := db.Model(model.Episode{}).Where("updated_at >= ?", since).Pluck("distinct video_id", &episodeVideoIds).Error; err != nil {
+		return err
+	}
+	var streamIds []uint32
+	if err := db.Model(model.Stream{}).Where("updated_at >= ?", since).Pluck("id", &streamIds).Error; err != nil {
+		return err
+	}
+
+	for _, id := range append(videoIds, episodeVideoIds...) {
+		if err := IndexVideo(db, id); err != nil {
+			return err
+		}
+	}
+	for _, id := range streamIds {
+		if err := IndexStream(db, id); err != nil {
+			return err
+		}
+	}
+
+	indexMutex.Lock()
+	lastSync = start
+	indexMutex.Unlock()
+	return nil
+}
+
+// 定时增量同步和全量重建，cmsd启动时调用
+func Watch(db *gorm.DB) {
+	lastRebuild := time.Now()
+	for {
+		time.Sleep(syncInterval)
+
+		var err error
+		if time.Since(lastRebuild) > rebuildInterval {
+			err = Rebuild(db)
+			lastRebuild = time.Now()
+		} else {
+			err = syncUpdated(db)
+		}
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+func videoDocument(video *model.Video, episodeTitles []string, tier uint32) *Document {
+	doc := Document{
+		ContentType: constant.MediaTypeVideo,
+		Id:          video.Id,
+		Title:       video.Title,
+		Actors:      video.Actors,
+		Directors:   video.Directors,
+		Score:       video.Score,
+		Sort:        video.Sort,
+		Tier:        tier,
+	}
+	for _, title := range episodeTitles {
+		if title != "" && title != video.Title {
+			doc.Aliases = append(doc.Aliases, title)
+		}
+	}
+	return &doc
+}
+
+func streamDocument(stream *model.Stream, tier uint32) *Document {
+	doc := Document{
+		ContentType: constant.MediaTypeStream,
+		Id:          stream.Id,
+		Title:       stream.Title,
+		Sort:        stream.Sort,
+		Tier:        tier,
+	}
+	for _, alias := range strings.Split(stream.EpgAlias, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			doc.Aliases = append(doc.Aliases, alias)
+		}
+	}
+	return &doc
+}
@@ -0,0 +1,214 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+var pinyinArgs = pinyin.NewArgs()
+
+// 连续的汉字或字母数字为一段，其他字符作为分隔
+type segment struct {
+	text  []rune
+	isHan bool
+}
+
+/*
+	统一大小写，全角转半角
+*/
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		} else if r == 0x3000 {
+			r = ' '
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// 去掉分隔字符后的文本，用于整体比较标题
+func compact(s string) string {
+	var b strings.Builder
+	for _, seg := range segments(s) {
+		b.WriteString(string(seg.text))
+	}
+	return b.String()
+}
+
+func segments(s string) []segment {
+	var segs []segment
+	var cur []rune
+	curHan := false
+	flush := func() {
+		if len(cur) > 0 {
+			segs = append(segs, segment{text: cur, isHan: curHan})
+			cur = nil
+		}
+	}
+	for _, r := range normalize(s) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if !curHan {
+				flush()
+			}
+			curHan = true
+			cur = append(cur, r)
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if curHan {
+				flush()
+			}
+			curHan = false
+			cur = append(cur, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return segs
+}
+
+/*
+	索引词：汉字单字和相邻两字，字母数字整词
+*/
+func textTerms(s string) []string {
+	var terms []string
+	for _, seg := range segments(s) {
+		if !seg.isHan {
+			terms = append(terms, string(seg.text))
+			continue
+		}
+		for i := range seg.text {
+			terms = append(terms, string(seg.text[i]))
+			if i+1 < len(seg.text) {
+				terms = append(terms, string(seg.text[i:i+2]))
+			}
+		}
+	}
+	return terms
+}
+
+/*
+	拼音索引词：整个标题的全拼和首字母("hunanweishi"、"hnws")，
+	每个字的拼音及相邻两字的拼音，用于匹配"weishi"这类部分输入
+*/
+func pinyinTerms(s string) []string {
+	var full, initials strings.Builder
+	var syllables []string
+	for _, seg := range segments(s) {
+		if !seg.isHan {
+			full.WriteString(string(seg.text))
+			initials.WriteString(string(seg.text))
+			continue
+		}
+		for _, r := range seg.text {
+			p := pinyin.SinglePinyin(r, pinyinArgs)
+			if len(p) == 0 || p[0] == "" {
+				continue
+			}
+			full.WriteString(p[0])
+			initials.WriteString(p[0][:1])
+			syllables = append(syllables, p[0])
+		}
+	}
+	if len(syllables) == 0 {
+		return nil
+	}
+
+	terms := []string{full.String(), initials.String()}
+	for i := range syllables {
+		terms = append(terms, syllables[i])
+		if i+1 < len(syllables) {
+			terms = append(terms, syllables[i]+syllables[i+1])
+		}
+	}
+	return terms
+}
+
+type queryToken struct {
+	term  string
+	ascii bool // 字母数字可以前缀、模糊匹配拼音
+}
+
+/*
+	查询词拆分：coverage为判断是否命中的词(汉字单字、字母数字整词)，
+	bigrams为相邻两字，只用于提高相关度
+*/
+func queryTokens(q string) (coverage []queryToken, bigrams []string) {
+	for _, seg := range segments(q) {
+		if !seg.isHan {
+			coverage = append(coverage, queryToken{term: string(seg.text), ascii: true})
+			continue
+		}
+		for i := range seg.text {
+			coverage = append(coverage, queryToken{term: string(seg.text[i])})
+			if i+1 < len(seg.text) {
+				bigrams = append(bigrams, string(seg.text[i:i+2]))
+			}
+		}
+	}
+	return coverage, bigrams
+}
+
+// 允许的错字数，越长的查询容错越多
+func maxTypos(n int) int {
+	switch {
+	case n <= 2:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// 字母数字的编辑距离上限
+func maxEditDistance(term string) int {
+	switch {
+	case len(term) < 4:
+		return 0
+	case len(term) < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// 编辑距离，相邻字母交换算一次编辑
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
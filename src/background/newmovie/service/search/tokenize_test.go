@@ -0,0 +1,69 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	if got := normalize("ＣＣＴＶ－１　Hello"); got != "cctv-1 hello" {
+		t.Errorf("normalize %q", got)
+	}
+	if got := compact("CCTV-1 综合"); got != "cctv1综合" {
+		t.Errorf("compact %q", got)
+	}
+}
+
+func TestTextTerms(t *testing.T) {
+	got := textTerms("湖南卫视 HD")
+	want := []string{"湖", "湖南", "南", "南卫", "卫", "卫视", "视", "hd"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("textTerms %v", got)
+	}
+	// 汉字和字母数字相邻时分为两段
+	got = textTerms("cctv5体育")
+	want = []string{"cctv5", "体", "体育", "育"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mixed textTerms %v", got)
+	}
+}
+
+func TestPinyinTerms(t *testing.T) {
+	got := pinyinTerms("湖南卫视")
+	want := []string{"hunanweishi", "hnws", "hu", "hunan", "nan", "nanwei", "wei", "weishi", "shi"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pinyinTerms %v", got)
+	}
+	if got := pinyinTerms("CCTV"); got != nil {
+		t.Errorf("no han pinyinTerms %v", got)
+	}
+}
+
+func TestQueryTokens(t *testing.T) {
+	coverage, bigrams := queryTokens("卫视 hd")
+	want := []queryToken{{term: "卫"}, {term: "视"}, {term: "hd", ascii: true}}
+	if !reflect.DeepEqual(coverage, want) {
+		t.Errorf("coverage %v", coverage)
+	}
+	if !reflect.DeepEqual(bigrams, []string{"卫视"}) {
+		t.Errorf("bigrams %v", bigrams)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"weishi", "weishi", 0},
+		{"weishi", "weisi", 1},
+		{"weishi", "wiesh", 2},
+		{"hunan", "hnuan", 1}, // 相邻交换
+		{"", "abc", 3},
+	}
+	for _, c := range cases {
+		if got := editDistance(c.a, c.b); got != c.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
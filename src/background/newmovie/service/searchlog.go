@@ -0,0 +1,179 @@
+package service
+
+import (
+	"background/common/cache"
+	"background/common/logger"
+	"background/newmovie/model"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	searchHourKeyPrefix     = "search_hour_"      // 有结果的搜索次数，按小时
+	searchDayKeyPrefix      = "search_day_"       // 有结果的搜索次数，按天
+	searchZeroDayKeyPrefix  = "search_zero_day_"  // 没有结果的搜索次数，按天
+	searchClickDayKeyPrefix = "search_click_day_" // 搜索结果点击次数，按天
+
+	searchHourTTL = 3600 * 49
+	searchDayTTL  = 86400 * 8
+
+	searchLogQueueSize = 4096
+	searchQueryMaxLen  = 64
+)
+
+type searchLogEntry struct {
+	log   model.SearchLog
+	click bool
+}
+
+var searchPool *redis.Pool
+var searchLogQueue chan *searchLogEntry
+
+// taskd只读取统计，不记录搜索
+func InitSearchStats(redisAddr, redisPassword string) {
+	searchPool = cache.GetRedisPool(redisAddr, redisPassword)
+}
+
+func InitSearchLog(db *gorm.DB, redisAddr, redisPassword string) {
+	InitSearchStats(redisAddr, redisPassword)
+	searchLogQueue = make(chan *searchLogEntry, searchLogQueueSize)
+	go searchLogWorker(db)
+}
+
+// 去掉首尾空白并统一大小写，超长的截断
+func NormalizeSearchQuery(query string) string {
+	r := []rune(strings.ToLower(strings.TrimSpace(query)))
+	if len(r) > searchQueryMaxLen {
+		r = r[:searchQueryMaxLen]
+	}
+	return string(r)
+}
+
+/*
+	记录一次搜索，异步写入数据库和redis，队列满时丢弃，不影响搜索接口
+*/
+func LogSearch(installationId uint64, query string, resultCount int) {
+	var e searchLogEntry
+	e.log.InstallationId = installationId
+	e.log.Query = NormalizeSearchQuery(query)
+	e.log.ResultCount = resultCount
+	e.log.CreatedAt = time.Now()
+	enqueueSearchLog(&e)
+}
+
+// 记录搜索结果点击，更新该设备最近一次相同查询的记录
+func LogSearchClick(installationId uint64, query string, contentType, id uint32) {
+	var e searchLogEntry
+	e.click = true
+	e.log.InstallationId = installationId
+	e.log.Query = NormalizeSearchQuery(query)
+	e.log.ClickContentType = contentType
+	e.log.ClickId = id
+	e.log.CreatedAt = time.Now()
+	enqueueSearchLog(&e)
+}
+
+func enqueueSearchLog(e *searchLogEntry) {
+	if searchLogQueue == nil || e.log.Query == "" {
+		return
+	}
+	select {
+	case searchLogQueue <- e:
+	default:
+		logger.Warn("search log queue full, drop query: ", e.log.Query)
+	}
+}
+
+func searchLogWorker(db *gorm.DB) {
+	for e := range searchLogQueue {
+		if e.click {
+			saveSearchClick(db, e)
+			continue
+		}
+
+		if err := db.Create(&e.log).Error; err != nil {
+			logger.Error(err)
+		}
+		if err := incrSearchWindows(e.log.Query, e.log.ResultCount, e.log.CreatedAt); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+func saveSearchClick(db *gorm.DB, e *searchLogEntry) {
+	var log model.SearchLog
+	err := db.Where("installation_id = ? and query = ?", e.log.InstallationId, e.log.Query).Order("id desc").First(&log).Error
+	if err == nil {
+		err = db.Model(&log).Updates(map[string]interface{}{"click_content_type": e.log.ClickContentType, "click_id": e.log.ClickId}).Error
+	} else if err == gorm.ErrRecordNotFound {
+		err = db.Create(&e.log).Error
+	}
+	if err != nil {
+		logger.Error(err)
+	}
+
+	member := fmt.Sprintf("%d_%d", e.log.ClickContentType, e.log.ClickId)
+	if err := cache.RedisZIncrBy(searchClickDayKeyPrefix+dayBucket(e.log.CreatedAt), member, 1, searchDayTTL, searchPool); err != nil {
+		logger.Error(err)
+	}
+}
+
+func incrSearchWindows(query string, resultCount int, t time.Time) error {
+	if resultCount == 0 {
+		return cache.RedisZIncrBy(searchZeroDayKeyPrefix+dayBucket(t), query, 1, searchDayTTL, searchPool)
+	}
+	if err := cache.RedisZIncrBy(searchHourKeyPrefix+hourBucket(t), query, 1, searchHourTTL, searchPool); err != nil {
+		return err
+	}
+	return cache.RedisZIncrBy(searchDayKeyPrefix+dayBucket(t), query, 1, searchDayTTL, searchPool)
+}
+
+func hourBucket(t time.Time) string {
+	return t.Format("2006010215")
+}
+
+func dayBucket(t time.Time) string {
+	return t.Format("20060102")
+}
+
+/*
+	热门搜索：最近24小时的搜索次数加上最近7天的日均次数，只统计有结果的查询
+*/
+func GetHotSearches(limit int) ([]cache.ZMember, error) {
+	now := time.Now()
+	var keys []string
+	var weights []float64
+	for i := 0; i < 24; i++ {
+		keys = append(keys, searchHourKeyPrefix+hourBucket(now.Add(-time.Hour*time.Duration(i))))
+		weights = append(weights, 1)
+	}
+	for i := 0; i < 7; i++ {
+		keys = append(keys, searchDayKeyPrefix+dayBucket(now.AddDate(0, 0, -i)))
+		weights = append(weights, 1.0/7)
+	}
+
+	dest := "search_hot_" + hourBucket(now)
+	if err := cache.RedisZUnionStore(dest, keys, weights, 600, searchPool); err != nil {
+		return nil, err
+	}
+	return cache.RedisZRevRangeWithScores(dest, 0, limit-1, searchPool)
+}
+
+// 最近days天没有结果的查询及次数
+func GetZeroResultSearches(days, limit int) ([]cache.ZMember, error) {
+	now := time.Now()
+	var keys []string
+	for i := 0; i < days; i++ {
+		keys = append(keys, searchZeroDayKeyPrefix+dayBucket(now.AddDate(0, 0, -i)))
+	}
+
+	dest := "search_zero_" + hourBucket(now)
+	if err := cache.RedisZUnionStore(dest, keys, nil, 600, searchPool); err != nil {
+		return nil, err
+	}
+	return cache.RedisZRevRangeWithScores(dest, 0, limit-1, searchPool)
+}
@@ -22,86 +22,68 @@ func CheckOtherPlayUrl(db *gorm.DB){
 	CheckOthersPlayUrlByProvider(constant.ContentProviderYouKu,db)
 	CheckOthersPlayUrlByProvider(constant.ContentProviderIqiyi,db)
 	CheckOthersPlayUrlByProvider(constant.ContentProviderMgtv,db)
+	updateVideoStatus(db)
 }
 
+/*
+	第三方链接需先解析真实地址，解析成本较高，每个provider抽取一个链接探测，结果作用于该provider全部链接
+*/
 func CheckOthersPlayUrlByProvider(provider uint32,db *gorm.DB){
 	var playUrl model.PlayUrl
 	if err := db.Where("provider = ?",provider).First(&playUrl).Error ; err != nil{
 		logger.Error(err)
 		return
 	}
-	jsCode := GetJsCode()
-	realUrl := service.GetRealUrl(playUrl.Provider,playUrl.Url,jsCode)
-	if isCanPlay(realUrl){
-		if err := db.Exec("update play_url set on_line = 1 where provider = ?",provider).Error ; err != nil{
-			logger.Error(err)
-			return
-		}
-		if err := db.Exec("update video a inner join episode b on a.id = b.video_id inner join play_url c on b.id = c.content_id and c.content_type = 2 set a.status = 2").Error ; err != nil{
-			logger.Error(err)
-			return
-		}
-	}else{
-		if err := db.Exec("update play_url set on_line = 0 where provider = ?",provider).Error ; err != nil{
-			logger.Error(err)
-			return
-		}
-		if err := db.Exec("update video a inner join episode b on a.id = b.video_id inner join play_url c on b.id = c.content_id and c.content_type = 2 set a.status = 3").Error ; err != nil{
-			logger.Error(err)
-			return
-		}
+	realUrl := service.GetRealUrl(playUrl.Provider,playUrl.Url)
+	result := &service.ProbeResult{Url: realUrl, Format: service.ProbeFormatUnknown, Reason: "resolve real url failed"}
+	if realUrl == playUrl.Url{
+		result = newProber().ProbePage(realUrl)
+	}else if realUrl != ""{
+		result = newProber().Probe(realUrl)
+	}
+	saveProbeHistory(db,&playUrl,result)
+
+	if err := db.Exec("update play_url set on_line = ? where provider = ?",result.Success,provider).Error ; err != nil{
+		logger.Error(err)
+		return
 	}
 }
 
+/*
+	探测直链播放地址，更新在线状态及起播时间
+*/
 func CheckSystemPlayUrl(db *gorm.DB){
 	var err error
-	var videos []model.Video
-	if err = db.Find(&videos).Error ; err != nil{
+	var playUrls []*model.PlayUrl
+	if err = db.Where("content_type = ? and provider in (?)",constant.MediaTypeEpisode,[]uint32{constant.ContentProviderSystem,constant.ContentProviderHanju,constant.ContentProviderDirectUrl}).Find(&playUrls).Error ; err != nil{
 		logger.Error(err)
 		return
 	}
 
-	for _ , video := range videos{
-		var episodes []model.Episode
-		if err = db.Where("video_id = ?",video.Id).Find(&episodes).Error ; err != nil{
-			logger.Error(err)
-			return
-		}
-		video.Status = 3
-		for _ , episode := range episodes{
-			var playUrls []model.PlayUrl
-			if err = db.Where("content_type = 2 and content_id = ?",episode.Id).Find(&playUrls).Error ; err != nil{
-				logger.Error(err)
-				return
-			}
+	var jobs []*probeJob
+	for _, playUrl := range playUrls{
+		jobs = append(jobs,&probeJob{playUrl: playUrl, url: playUrl.Url})
+	}
+	probeJobs(jobs)
+
+	for _, job := range jobs{
+		saveProbeHistory(db,job.playUrl,job.result)
 
-			for _, playUrl := range playUrls{
-				if playUrl.Provider == 0 || playUrl.Provider == 10 || playUrl.Provider == 20{
-					if isCanPlay(playUrl.Url){
-						playUrl.OnLine = true
-						video.Status = 2
-					}else{
-						playUrl.OnLine = false
-					}
-				}
-				if err = db.Save(&playUrl).Error ; err != nil{
-					logger.Error(err)
-					return
-				}
+		job.playUrl.OnLine = job.result.Success
+		if job.result.Success{
+			job.playUrl.Ready = job.result.Ready
+			if job.result.Bitrate > 0{
+				job.playUrl.Bitrate = job.result.Bitrate
 			}
 		}
-
-		if err = db.Save(&video).Error ; err != nil{
+		if err = db.Model(job.playUrl).Updates(map[string]interface{}{"on_line": job.playUrl.OnLine, "ready": job.playUrl.Ready, "bitrate": job.playUrl.Bitrate}).Error ; err != nil{
 			logger.Error(err)
 			return
 		}
-
 	}
-}
-
 
-func isCanPlay(url string)(bool){
-	return true
+	updateVideoStatus(db)
+	cleanProbeHistory(db)
 }
 
 
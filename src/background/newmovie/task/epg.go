@@ -0,0 +1,142 @@
+package task
+
+import (
+	"background/common/logger"
+	"background/newmovie/config"
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const epgKeepDays = 7
+
+/*
+	从配置的XMLTV源同步节目单。XMLTV频道的id和显示名称与Stream的标题、epg_alias匹配，
+	匹配成功的频道覆盖节目单时间范围内的旧数据，并更新has_epg和epg_synced_at
+*/
+func SyncEpg(db *gorm.DB) {
+	sources := config.GetEpgSources()
+	if len(sources) == 0 {
+		return
+	}
+
+	var streams []model.Stream
+	if err := db.Select("id, title, epg_alias").Find(&streams).Error; err != nil {
+		logger.Error(err)
+		return
+	}
+	names := epgChannelNames(streams)
+
+	for _, source := range sources {
+		tv, err := service.LoadXmltv(source)
+		if err != nil {
+			logger.Error("load xmltv ", source, " failed, ", err)
+			continue
+		}
+
+		channels, programmes := matchXmltv(names, tv)
+		for streamId, list := range programmes {
+			saveEpgProgrammes(db, streamId, list)
+		}
+		logger.Info("sync epg ", source, ", channels: ", len(tv.Channels), " matched: ", len(channels))
+	}
+
+	cleanEpgProgrammes(db)
+}
+
+// 归一化的频道标题和epg_alias对应的stream id
+func epgChannelNames(streams []model.Stream) map[string]uint32 {
+	names := make(map[string]uint32)
+	for _, stream := range streams {
+		names[service.NormalizeChannelName(stream.Title)] = stream.Id
+		for _, alias := range strings.Split(stream.EpgAlias, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				names[service.NormalizeChannelName(alias)] = stream.Id
+			}
+		}
+	}
+	return names
+}
+
+/*
+	按频道id和显示名称匹配Stream，返回XMLTV频道id对应的stream id和每个stream的节目
+*/
+func matchXmltv(names map[string]uint32, tv *service.Xmltv) (map[string]uint32, map[uint32][]service.XmltvProgramme) {
+	channels := make(map[string]uint32)
+	for _, ch := range tv.Channels {
+		for _, name := range append([]string{ch.Id}, ch.DisplayNames...) {
+			if id, ok := names[service.NormalizeChannelName(name)]; ok {
+				channels[ch.Id] = id
+				break
+			}
+		}
+	}
+
+	programmes := make(map[uint32][]service.XmltvProgramme)
+	for _, p := range tv.Programmes {
+		if id, ok := channels[p.Channel]; ok {
+			programmes[id] = append(programmes[id], p)
+		}
+	}
+	return channels, programmes
+}
+
+func saveEpgProgrammes(db *gorm.DB, streamId uint32, list []service.XmltvProgramme) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartAt.Before(list[j].StartAt)
+	})
+	from := list[0].StartAt
+	to := list[len(list)-1].EndAt
+
+	tx := db.Begin()
+	if err := tx.Where("stream_id = ? and start_at >= ? and start_at < ?", streamId, from, to).Delete(model.EpgProgramme{}).Error; err != nil {
+		tx.Rollback()
+		logger.Error(err)
+		return
+	}
+
+	var last time.Time
+	for _, p := range list {
+		// 同一时间开始的节目只保留一个
+		if p.StartAt.Equal(last) {
+			continue
+		}
+		last = p.StartAt
+
+		var programme model.EpgProgramme
+		programme.StreamId = streamId
+		programme.Title = p.Title
+		if r := []rune(p.Title); len(r) > 255 {
+			programme.Title = string(r[:255])
+		}
+		programme.Description = p.Description
+		programme.StartAt = p.StartAt
+		programme.EndAt = p.EndAt
+		if err := tx.Create(&programme).Error; err != nil {
+			tx.Rollback()
+			logger.Error(err)
+			return
+		}
+	}
+
+	now := time.Now()
+	if err := tx.Model(model.Stream{}).Where("id = ?", streamId).Updates(map[string]interface{}{"has_epg": true, "epg_synced_at": now}).Error; err != nil {
+		tx.Rollback()
+		logger.Error(err)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error(err)
+	}
+}
+
+func cleanEpgProgrammes(db *gorm.DB) {
+	before := time.Now().AddDate(0, 0, -epgKeepDays)
+	if err := db.Where("end_at < ?", before).Delete(model.EpgProgramme{}).Error; err != nil {
+		logger.Error(err)
+	}
+}
@@ -0,0 +1,43 @@
+package task
+
+import (
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"testing"
+)
+
+func TestMatchXmltv(t *testing.T) {
+	streams := []model.Stream{
+		{Id: 1, Title: "CCTV-1综合"},
+		{Id: 2, Title: "湖南卫视", EpgAlias: "芒果台, hunantv"},
+		{Id: 3, Title: "没有节目单"},
+	}
+	tv := &service.Xmltv{
+		Channels: []service.XmltvChannel{
+			{Id: "cctv1", DisplayNames: []string{"CCTV1"}},
+			{Id: "hunan", DisplayNames: []string{"芒果台"}},
+			{Id: "HunanTV"},
+			{Id: "other", DisplayNames: []string{"其他频道"}},
+		},
+		Programmes: []service.XmltvProgramme{
+			{Channel: "cctv1", Title: "新闻联播"},
+			{Channel: "hunan", Title: "快乐大本营"},
+			{Channel: "HunanTV", Title: "天天向上"},
+			{Channel: "other", Title: "不匹配"},
+		},
+	}
+
+	channels, programmes := matchXmltv(epgChannelNames(streams), tv)
+	want := map[string]uint32{"cctv1": 1, "hunan": 2, "HunanTV": 2}
+	if len(channels) != len(want) {
+		t.Fatalf("channels %v", channels)
+	}
+	for id, streamId := range want {
+		if channels[id] != streamId {
+			t.Errorf("channel %s matched %d, want %d", id, channels[id], streamId)
+		}
+	}
+	if len(programmes[1]) != 1 || len(programmes[2]) != 2 || len(programmes[3]) != 0 {
+		t.Errorf("programmes %v", programmes)
+	}
+}
@@ -0,0 +1,51 @@
+package task
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"background/newmovie/service/resolver"
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	解析直播链接，根据解析脚本返回的is_support_back更新链接是否支持回看
+*/
+func CheckStreamSupportBack(db *gorm.DB) {
+	var playUrls []*model.PlayUrl
+	if err := db.Where("content_type = ? and on_line = 1", constant.MediaTypeStream).Find(&playUrls).Error; err != nil {
+		logger.Error(err)
+		return
+	}
+
+	var streams []model.Stream
+	if err := db.Select("id, category").Find(&streams).Error; err != nil {
+		logger.Error(err)
+		return
+	}
+	categories := make(map[uint32]string)
+	for _, stream := range streams {
+		categories[stream.Id] = stream.Category
+	}
+
+	for _, playUrl := range playUrls {
+		if !resolver.IsRegistered(playUrl.Provider) {
+			continue
+		}
+		result, err := service.ResolveStreamUrl(context.Background(), playUrl.Provider, playUrl.Url, categories[playUrl.ContentId], "", time.Time{}, time.Time{})
+		if err != nil {
+			logger.Warn("resolve stream play_url failed, id: ", playUrl.Id, " ", err)
+			continue
+		}
+		if result.IsSupportBack == playUrl.IsSupportBack {
+			continue
+		}
+		if err := db.Model(playUrl).Update("is_support_back", result.IsSupportBack).Error; err != nil {
+			logger.Error(err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package task
+
+import (
+	"background/common/logger"
+	"background/newmovie/config"
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+)
+
+const probeHistoryKeepDays = 7
+
+type probeJob struct {
+	playUrl *model.PlayUrl
+	url     string // 实际探测地址，第三方链接为解析后的真实地址
+	result  *service.ProbeResult
+}
+
+func newProber() *service.Prober {
+	return service.NewProber(time.Second * time.Duration(config.GetProbeTimeout()))
+}
+
+/*
+	按provider分组探测，不同provider并行，同一provider最多同时探测ProbeConcurrency个链接，
+	避免同时请求过多被源站封禁
+*/
+func probeJobs(jobs []*probeJob) {
+	groups := make(map[uint32][]*probeJob)
+	for _, job := range jobs {
+		groups[job.playUrl.Provider] = append(groups[job.playUrl.Provider], job)
+	}
+
+	prober := newProber()
+	concurrency := config.GetProbeConcurrency()
+
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		sem := make(chan struct{}, concurrency)
+		for _, job := range group {
+			wg.Add(1)
+			go func(job *probeJob) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				job.result = prober.Probe(job.url)
+			}(job)
+		}
+	}
+	wg.Wait()
+}
+
+// 写入探测记录
+func saveProbeHistory(db *gorm.DB, playUrl *model.PlayUrl, result *service.ProbeResult) {
+	var probe model.PlayUrlProbe
+	probe.PlayUrlId = playUrl.Id
+	probe.Provider = playUrl.Provider
+	probe.Url = result.Url
+	probe.Format = result.Format
+	probe.Success = result.Success
+	probe.StatusCode = result.StatusCode
+	probe.Reason = result.Reason
+	if len(probe.Reason) > 255 {
+		// 在字符边界截断，避免截断多字节字符
+		n := 255
+		for n > 0 && !utf8.RuneStart(probe.Reason[n]) {
+			n--
+		}
+		probe.Reason = probe.Reason[:n]
+	}
+	probe.Latency = result.Latency
+	probe.Ready = result.Ready
+	probe.Bitrate = result.Bitrate
+	probe.Throughput = result.Throughput
+	probe.SegmentUrl = result.SegmentUrl
+	if err := db.Create(&probe).Error; err != nil {
+		logger.Error(err)
+	}
+
+	if !result.Success {
+		logger.Warn("probe play_url failed, id: ", playUrl.Id, " provider: ", playUrl.Provider, " url: ", result.Url, " reason: ", result.Reason)
+	}
+}
+
+func cleanProbeHistory(db *gorm.DB) {
+	before := time.Now().AddDate(0, 0, -probeHistoryKeepDays)
+	if err := db.Where("created_at < ?", before).Delete(model.PlayUrlProbe{}).Error; err != nil {
+		logger.Error(err)
+	}
+}
+
+// 根据点播链接在线状态更新video状态
+func updateVideoStatus(db *gorm.DB) {
+	if err := db.Exec("update video a set a.status = if(exists(select 1 from episode b inner join play_url c on b.id = c.content_id and c.content_type = 2 and c.on_line = 1 where b.video_id = a.id), ?, ?)",
+		model.VideoStatusPlayable, model.VideoStatusUnPlayable).Error; err != nil {
+		logger.Error(err)
+	}
+}
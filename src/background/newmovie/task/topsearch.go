@@ -0,0 +1,115 @@
+package task
+
+import (
+	"background/common/cache"
+	"background/common/constant"
+	"background/common/logger"
+	"background/newmovie/config"
+	"background/newmovie/model"
+	"background/newmovie/service"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	zeroResultDays  = 7
+	zeroResultLimit = 200
+)
+
+/*
+	根据搜索统计重新生成热搜，编辑手动维护的热搜保持原顺序排在前面
+*/
+func RecomputeTopSearch(db *gorm.DB) {
+	hot, err := service.GetHotSearches(config.GetTopSearchSize())
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	var manual []model.TopSearch
+	if err := db.Where("auto = ?", false).Find(&manual).Error; err != nil {
+		logger.Error(err)
+		return
+	}
+
+	tx := db.Begin()
+	if err := tx.Where("auto = ?", true).Delete(model.TopSearch{}).Error; err != nil {
+		tx.Rollback()
+		logger.Error(err)
+		return
+	}
+	for _, top := range autoTopSearches(manual, hot) {
+		top.ContentType = constant.MediaTypeEpisode
+		var count int
+		if err := tx.Model(model.Stream{}).Where("title = ?", top.Title).Count(&count).Error; err == nil && count > 0 {
+			top.ContentType = constant.MediaTypeStream
+		}
+		if err := tx.Create(top).Error; err != nil {
+			tx.Rollback()
+			logger.Error(err)
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error(err)
+	}
+}
+
+/*
+	按搜索统计生成的热搜，排在手动维护的热搜之后，和手动热搜重复的查询不再生成
+*/
+func autoTopSearches(manual []model.TopSearch, hot []cache.ZMember) []*model.TopSearch {
+	var sort uint32
+	exists := make(map[string]bool)
+	for _, top := range manual {
+		if top.Sort > sort {
+			sort = top.Sort
+		}
+		exists[service.NormalizeSearchQuery(top.Title)] = true
+	}
+
+	var result []*model.TopSearch
+	for _, member := range hot {
+		if exists[member.Member] {
+			continue
+		}
+		exists[member.Member] = true
+		sort++
+		result = append(result, &model.TopSearch{Title: member.Member, Sort: sort, Auto: true})
+	}
+	return result
+}
+
+/*
+	汇总最近7天没有结果的查询，写入search_zero_result供编辑在cms中查看
+*/
+func ReportZeroResultSearches(db *gorm.DB) {
+	zero, err := service.GetZeroResultSearches(zeroResultDays, zeroResultLimit)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	now := time.Now()
+	for _, member := range zero {
+		var report model.SearchZeroResult
+		err := db.Where("query = ?", member.Member).First(&report).Error
+		if err == gorm.ErrRecordNotFound {
+			report.Query = member.Member
+			report.Count = uint32(member.Score)
+			report.Status = model.SearchZeroResultPending
+			report.LastSearchedAt = now
+			err = db.Create(&report).Error
+		} else if err == nil {
+			updates := map[string]interface{}{"count": uint32(member.Score)}
+			if uint32(member.Score) > report.Count {
+				updates["last_searched_at"] = now
+			}
+			err = db.Model(&report).Updates(updates).Error
+		}
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+}
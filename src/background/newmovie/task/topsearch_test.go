@@ -0,0 +1,43 @@
+package task
+
+import (
+	"background/common/cache"
+	"background/newmovie/model"
+	"testing"
+)
+
+// 表中已有编辑维护的热搜(包括增加auto字段之前的数据)
+func TestAutoTopSearches(t *testing.T) {
+	manual := []model.TopSearch{
+		{Id: 1, Title: "琅琊榜", Sort: 1},
+		{Id: 2, Title: " CCTV1 ", Sort: 3},
+		{Id: 3, Title: "甄嬛传", Sort: 2},
+	}
+	hot := []cache.ZMember{
+		{Member: "cctv1", Score: 100},
+		{Member: "庆余年", Score: 80},
+		{Member: "琅琊榜", Score: 60},
+		{Member: "长安十二时辰", Score: 40},
+	}
+
+	result := autoTopSearches(manual, hot)
+	want := []struct {
+		title string
+		sort  uint32
+	}{{"庆余年", 4}, {"长安十二时辰", 5}}
+	if len(result) != len(want) {
+		t.Fatalf("got %d top searches, want %d", len(result), len(want))
+	}
+	for i, w := range want {
+		if result[i].Title != w.title || result[i].Sort != w.sort || !result[i].Auto {
+			t.Errorf("top search %d: %+v, want %s sort %d", i, result[i], w.title, w.sort)
+		}
+	}
+}
+
+func TestAutoTopSearchesEmptyTable(t *testing.T) {
+	result := autoTopSearches(nil, []cache.ZMember{{Member: "a"}, {Member: "b"}})
+	if len(result) != 2 || result[0].Sort != 1 || result[1].Sort != 2 {
+		t.Errorf("result %+v", result)
+	}
+}
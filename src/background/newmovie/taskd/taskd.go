@@ -4,6 +4,9 @@ import (
 	"background/newmovie/config"
 	"background/newmovie/model"
 	"background/newmovie/task"
+	"background/newmovie/service"
+	"background/newmovie/service/script"
+	"background/shortvideo/setting"
 	"background/common/logger"
 	"flag"
 	"log"
@@ -14,6 +17,8 @@ import (
 )
 
 func main() {
+	// 执行解析脚本的子进程从这里进入
+	script.RunSandbox()
 
 
 	configPath := flag.String("conf", "../config/config.json", "Config file path")
@@ -37,6 +42,19 @@ func main() {
 
 	model.InitModel(db)
 
+	cacheRedisAddr, cacheRedisPwd, err := setting.GetCacheRedis(db)
+	if err != nil {
+		logger.Fatal(err)
+		return
+	}
+	service.InitSearchStats(cacheRedisAddr, cacheRedisPwd)
+
+	// 探测和回看检查使用cms中发布的解析脚本版本
+	if err := script.LoadScripts(db); err != nil {
+		logger.Error(err)
+	}
+	go script.WatchScripts(db)
+
 	go func(){
 		for{
 			task.CheckSystemPlayUrl(db)
@@ -46,6 +64,24 @@ func main() {
 		}
 	}()
 
+	go func(){
+		for{
+			task.SyncEpg(db)
+			task.CheckStreamSupportBack(db)
+
+			time.Sleep(time.Minute * time.Duration(config.GetEpgSyncInterval()))
+		}
+	}()
+
+	go func(){
+		for{
+			task.RecomputeTopSearch(db)
+			task.ReportZeroResultSearches(db)
+
+			time.Sleep(time.Hour)
+		}
+	}()
+
 	for {
 		time.Sleep(time.Minute * 5)
 	}
@@ -10,6 +10,8 @@ import (
 )
 
 func main(){
+	// 执行解析脚本的子进程从这里进入
+	script.RunSandbox()
 	logger.SetLevel(config.GetLoggerLevel())
 	configPath := flag.String("conf", "../config/config.json", "Config file path")
 	err := config.LoadConfig(*configPath)
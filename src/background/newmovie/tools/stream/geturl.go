@@ -7,6 +7,8 @@ import (
 )
 
 func main(){
+	// 执行解析脚本的子进程从这里进入
+	script.RunSandbox()
 	logger.SetLevel(config.GetLoggerLevel())
 
 	realUrl := script.GetMiguRealPlayUrl(2,"http://www.miguvideo.com/wap/resource/pc/detail/miguplay.jsp?cid=617379229")
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+type config struct {
+	ProductionEnv bool   `json:"production_env"`
+	LoggerLevel   uint8  `json:"logger_level"`
+	ListenAddr    string `json:"listen_addr"`
+
+	SignSecret    string   `json:"sign_secret"`     // 带签名链接的密钥
+	SignHosts     []string `json:"sign_hosts"`      // 允许签名的链接域名，.开头匹配子域名，为空时不签名
+	SignMaxAge    int      `json:"sign_max_age"`    // 签名链接的有效期(秒)，0为不过期
+	LogoDir       string   `json:"logo_dir"`        // 按名称使用的logo图片目录
+	MaxSize       int      `json:"max_size"`        // 图片最大边长(像素)
+	MaxBatch      int      `json:"max_batch"`       // 批量生成的最大个数
+	MaxUploadSize int64    `json:"max_upload_size"` // 上传图片的最大字节数
+
+	RedisAddr       string `json:"redis_addr"` // 为空时配对会话保存在内存中，只能单实例部署
+	RedisPassword   string `json:"redis_password"`
+	PairTTL         int    `json:"pair_ttl"`          // 配对二维码有效期(秒)
+	PairPollTimeout int    `json:"pair_poll_timeout"` // 长轮询最长等待时间(秒)
+	PairSecret      string `json:"pair_secret"`       // 配对二维码token的密钥
+	BindSecret      string `json:"bind_secret"`       // 配对结果bind_sign的密钥，和校验绑定的服务共用
+	UserTokenSecret string `json:"user_token_secret"` // 校验手机提交的用户token，和签发token的服务共用
+	DeviceSecret    string `json:"device_secret"`     // 机顶盒密钥的根密钥，校验机顶盒创建会话的签名
+}
+
+var c config
+
+func init() {
+	c.ProductionEnv = false
+	c.LoggerLevel = 0
+	c.ListenAddr = ":6600"
+	c.LogoDir = "/root/Git/e94/src/background/qrcode/logo"
+	c.MaxSize = 2000
+	c.MaxBatch = 200
+	c.MaxUploadSize = 5 << 20
+	c.PairTTL = 180
+	c.PairPollTimeout = 25
+}
+
+func LoadConfig(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var ctmp config
+	err = json.Unmarshal(b, &ctmp)
+	if err != nil {
+		return err
+	}
+
+	c = ctmp
+	return nil
+}
+
+func IsProductionEnv() bool {
+	return c.ProductionEnv
+}
+
+func GetLoggerLevel() uint8 {
+	return c.LoggerLevel
+}
+
+func GetListenAddr() string {
+	return c.ListenAddr
+}
+
+func GetSignSecret() string {
+	return c.SignSecret
+}
+
+func GetSignHosts() []string {
+	return c.SignHosts
+}
+
+func GetSignMaxAge() int {
+	return c.SignMaxAge
+}
+
+func GetLogoDir() string {
+	return c.LogoDir
+}
+
+func GetMaxSize() int {
+	return c.MaxSize
+}
+
+func GetMaxBatch() int {
+	return c.MaxBatch
+}
+
+func GetMaxUploadSize() int64 {
+	return c.MaxUploadSize
+}
+
+func GetRedisAddr() string {
+	return c.RedisAddr
+}
+
+func GetRedisPassword() string {
+	return c.RedisPassword
+}
+
+func GetPairTTL() int {
+	return c.PairTTL
+}
+
+func GetPairPollTimeout() int {
+	return c.PairPollTimeout
+}
+
+func GetPairSecret() string {
+	return c.PairSecret
+}
+
+func GetBindSecret() string {
+	return c.BindSecret
+}
+
+func GetUserTokenSecret() string {
+	return c.UserTokenSecret
+}
+
+func GetDeviceSecret() string {
+	return c.DeviceSecret
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"background/common/constant"
+	"background/common/usertoken"
+	"background/qrcode/config"
+	"background/qrcode/logger"
+	"background/qrcode/pairing"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var pairManager *pairing.Manager
+
+func SetPairManager(m *pairing.Manager) {
+	pairManager = m
+}
+
+// 配对的业务错误返回err_msg，其他错误返回500
+func pairFailure(c *gin.Context, err error) {
+	switch err {
+	case pairing.ErrSessionNotFound, pairing.ErrInvalidToken, pairing.ErrTokenExpired,
+		pairing.ErrSessionDone, pairing.ErrScannedByOther, pairing.ErrInvalidPoll, pairing.ErrInvalidDevice:
+		failure(c, err)
+	default:
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+}
+
+/*
+	机顶盒创建配对会话，请求用机顶盒密钥签名(参见pairing.DeviceSign)，
+	签名的参数为device_id、operator_id和timestamp。
+	返回的qrcode为二维码内容，qrcode_url为本服务生成二维码图片的地址，
+	poll_token用于查询配对状态，不能显示在二维码中
+*/
+func CreatePairSessionHandler(c *gin.Context) {
+	type param struct {
+		DeviceId   string `form:"device_id" json:"device_id" binding:"required"`
+		OperatorId string `form:"operator_id" json:"operator_id"`
+		Timestamp  int64  `form:"timestamp" json:"timestamp" binding:"required"`
+		Sign       string `form:"sign" json:"sign" binding:"required"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error("Invalid pair session param ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if len(p.DeviceId) > 64 || len(p.OperatorId) > 64 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	params := map[string]string{
+		"device_id":   p.DeviceId,
+		"operator_id": p.OperatorId,
+		"timestamp":   strconv.FormatInt(p.Timestamp, 10),
+	}
+	if err := pairManager.VerifyDevice(params, p.Sign, time.Now()); err != nil {
+		logger.Warn("[PairSession] Device ", p.DeviceId, " sign verify failed ", err)
+		pairFailure(c, err)
+		return
+	}
+
+	session, payload, err := pairManager.Create(p.DeviceId, p.OperatorId)
+	if err != nil {
+		pairFailure(c, err)
+		return
+	}
+	content, _ := json.Marshal(payload)
+	logger.Info("[PairSession] Created ", session.Id, " for device ", p.DeviceId)
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{
+		"session_id": session.Id,
+		"poll_token": session.PollToken,
+		"qrcode":     string(content),
+		"qrcode_url": "/qrcode?msg=" + url.QueryEscape(string(content)),
+		"expires_in": config.GetPairTTL(),
+	}})
+}
+
+type PairPollParam struct {
+	SessionId string `form:"session_id" binding:"required"`
+	PollToken string `form:"poll_token" binding:"required"`
+}
+
+/*
+	机顶盒长轮询：状态和state参数不同时立即返回，否则最多等待pair_poll_timeout秒
+*/
+func PollPairSessionHandler(c *gin.Context) {
+	var p struct {
+		PairPollParam
+		State string `form:"state"`
+	}
+	if err := c.Bind(&p); err != nil {
+		logger.Error("Invalid pair poll param ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	timeout := time.Second * time.Duration(config.GetPairPollTimeout())
+	session, err := pairManager.Wait(p.SessionId, p.PollToken, p.State, timeout, c.Request.Context().Done())
+	if err != nil {
+		pairFailure(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": session})
+}
+
+/*
+	机顶盒通过SSE接收状态变化，每次变化发送state事件，配对结束或过期后关闭
+*/
+func PairSessionEventsHandler(c *gin.Context) {
+	var p PairPollParam
+	if err := c.Bind(&p); err != nil {
+		logger.Error("Invalid pair events param ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if _, err := pairManager.Get(p.SessionId, p.PollToken); err != nil {
+		pairFailure(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	timeout := time.Second * time.Duration(config.GetPairPollTimeout())
+	done := c.Request.Context().Done()
+	known := ""
+	c.Stream(func(w io.Writer) bool {
+		session, err := pairManager.Wait(p.SessionId, p.PollToken, known, timeout, done)
+		if err != nil {
+			c.SSEvent("error", gin.H{"err_msg": err.Error()})
+			return false
+		}
+		if c.Request.Context().Err() != nil {
+			return false
+		}
+		if session.State == known {
+			// 保持连接
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		}
+		known = session.State
+		c.SSEvent("state", session)
+		return !session.Done()
+	})
+}
+
+type PairTokenParam struct {
+	SessionId string `form:"session_id" json:"session_id" binding:"required"`
+	DeviceId  string `form:"device_id" json:"device_id" binding:"required"`
+	Expires   int64  `form:"expires" json:"expires" binding:"required"`
+	Token     string `form:"token" json:"token" binding:"required"`
+	UserToken string `form:"user_token" json:"user_token" binding:"required"` // app从用户服务获取的token
+}
+
+func (p *PairTokenParam) payload() *pairing.QrPayload {
+	return &pairing.QrPayload{SessionId: p.SessionId, DeviceId: p.DeviceId, Expires: p.Expires, Token: p.Token}
+}
+
+/*
+	手机的用户和installation_id只从用户服务签发的token中读取，不信任请求中的参数
+*/
+func pairUser(c *gin.Context, token string) (*usertoken.User, bool) {
+	user, err := usertoken.Verify(config.GetUserTokenSecret(), token, time.Now())
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+		return nil, false
+	}
+	if user.InstallationId == 0 {
+		logger.Error("Pair user token without installation_id, user ", user.UserId)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return nil, false
+	}
+	return user, true
+}
+
+/*
+	手机扫码后提交二维码内容，返回机顶盒信息用于确认
+*/
+func ScanPairHandler(c *gin.Context) {
+	var p PairTokenParam
+	if err := c.Bind(&p); err != nil {
+		logger.Error("Invalid pair scan param ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	user, ok := pairUser(c, p.UserToken)
+	if !ok {
+		return
+	}
+
+	session, err := pairManager.Scan(p.payload(), user.InstallationId)
+	if err != nil {
+		pairFailure(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{
+		"device_id":   session.DeviceId,
+		"operator_id": session.OperatorId,
+		"expires_at":  session.ExpiresAt,
+	}})
+}
+
+/*
+	手机确认配对，机顶盒绑定到token中的installation_id和用户
+*/
+func ConfirmPairHandler(c *gin.Context) {
+	var p PairTokenParam
+	if err := c.Bind(&p); err != nil {
+		logger.Error("Invalid pair confirm param ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	user, ok := pairUser(c, p.UserToken)
+	if !ok {
+		return
+	}
+
+	session, err := pairManager.Confirm(p.payload(), user.InstallationId, user.UserId)
+	if err != nil {
+		pairFailure(c, err)
+		return
+	}
+	logger.Info("[PairConfirm] Device ", session.DeviceId, " bound to installation ", user.InstallationId, " user ", user.UserId)
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success})
+}
+
+func CancelPairHandler(c *gin.Context) {
+	var p PairTokenParam
+	if err := c.Bind(&p); err != nil {
+		logger.Error("Invalid pair cancel param ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	user, ok := pairUser(c, p.UserToken)
+	if !ok {
+		return
+	}
+
+	if _, err := pairManager.Cancel(p.payload(), user.InstallationId); err != nil {
+		pairFailure(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success})
+}
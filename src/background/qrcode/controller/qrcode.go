@@ -1,45 +1,376 @@
 package controller
 
 import (
+	"archive/zip"
+	"background/common/constant"
+	"background/qrcode/config"
+	"background/qrcode/decode"
 	"background/qrcode/logger"
-	"background/qrcode/util"
-	"image/jpeg"
+	"background/qrcode/payload"
+	"background/qrcode/render"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	qrcode "github.com/skip2/go-qrcode"
 )
 
-// 根据运营商ID跟机顶盒ID返回二维码图片，其二维码内容为一段JSON串
+// 二维码内容，type为空时直接使用msg
+type PayloadParam struct {
+	Type    string `form:"type" json:"type"` // text、wifi、vcard、url
+	Message string `form:"msg" json:"msg"`
+
+	Ssid     string `form:"ssid" json:"ssid"`
+	Password string `form:"password" json:"password"`
+	Auth     string `form:"auth" json:"auth"`
+	Hidden   bool   `form:"hidden" json:"hidden"`
+
+	Name    string `form:"name" json:"name"`
+	Org     string `form:"org" json:"org"`
+	Title   string `form:"title" json:"title"`
+	Tel     string `form:"tel" json:"tel"`
+	Email   string `form:"email" json:"email"`
+	Url     string `form:"url" json:"url"`
+	Address string `form:"address" json:"address"`
+	Note    string `form:"note" json:"note"`
+
+	Sign bool `form:"sign" json:"sign"` // url类型是否加签名
+}
+
+func (p *PayloadParam) content() (string, error) {
+	switch p.Type {
+	case "", "text":
+		if p.Message == "" {
+			return "", payload.ErrMissingPayload
+		}
+		return p.Message, nil
+	case "wifi":
+		return payload.WiFi{Ssid: p.Ssid, Password: p.Password, Auth: p.Auth, Hidden: p.Hidden}.Encode()
+	case "vcard":
+		return payload.VCard{Name: p.Name, Org: p.Org, Title: p.Title, Tel: p.Tel, Email: p.Email,
+			Url: p.Url, Address: p.Address, Note: p.Note}.Encode()
+	case "url":
+		if !p.Sign {
+			if p.Url == "" {
+				return "", payload.ErrMissingPayload
+			}
+			return p.Url, nil
+		}
+		return payload.SignUrl(p.Url, config.GetSignSecret(), config.GetSignHosts(), time.Now())
+	}
+	return "", fmt.Errorf("不支持的二维码类型%s", p.Type)
+}
+
+// 图片样式，没有传的参数使用原来接口的默认值
+type StyleParam struct {
+	Size   int    `form:"size" json:"size"`
+	Level  string `form:"level" json:"level"`
+	Margin int    `form:"margin" json:"margin"`
+	Fg     string `form:"fg" json:"fg"`
+	Bg     string `form:"bg" json:"bg"`
+	Format string `form:"format" json:"format"`
+	Logo   string `form:"logo" json:"logo"` // logo_dir下的logo名称，上传的logo文件优先
+}
+
+func NewStyleParam() StyleParam {
+	return StyleParam{Size: 430, Level: "H", Margin: 4, Fg: "#000000", Bg: "#ffffff", Format: render.FormatJpeg}
+}
+
+func (p *StyleParam) options(c *gin.Context) (render.Options, error) {
+	opts := render.DefaultOptions()
+	var err error
+	if p.Size <= 0 || p.Size > config.GetMaxSize() {
+		return opts, fmt.Errorf("图片大小需要在1～%d之间", config.GetMaxSize())
+	}
+	opts.Size = p.Size
+	if p.Margin < 0 || p.Margin > 20 {
+		return opts, fmt.Errorf("边距需要在0～20之间")
+	}
+	opts.Margin = p.Margin
+	if opts.Level, err = render.ParseLevel(p.Level); err != nil {
+		return opts, err
+	}
+	if opts.Foreground, err = render.ParseColor(p.Fg); err != nil {
+		return opts, err
+	}
+	if opts.Background, err = render.ParseColor(p.Bg); err != nil {
+		return opts, err
+	}
+	if opts.Format, err = render.ParseFormat(p.Format); err != nil {
+		return opts, err
+	}
+	if opts.Logo, err = loadLogo(c, p.Logo); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+var logoNameRegexp = regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
+
+// 识别二维码的图片最大边长，手机拍摄的照片一般在这个范围内
+const decodeMaxSide = 4096
+
+var errImageTooLarge = errors.New("图片尺寸过大")
+
+/*
+	先读取图片头检查宽高，避免小文件声明很大的尺寸时解码占用大量内存
+*/
+func decodeImage(r io.ReadSeeker, maxSide int) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxSide || cfg.Height > maxSide {
+		return nil, errImageTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+/*
+	优先使用上传的logo文件，否则按名称读取logo_dir下的png
+*/
+func loadLogo(c *gin.Context, name string) (image.Image, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if header, err := c.FormFile("logo"); err == nil {
+			if header.Size > config.GetMaxUploadSize() {
+				return nil, fmt.Errorf("logo文件超过%d字节", config.GetMaxUploadSize())
+			}
+			file, err := header.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+			img, err := decodeImage(file, config.GetMaxSize())
+			if err == errImageTooLarge {
+				return nil, fmt.Errorf("logo图片宽高不能超过%d像素", config.GetMaxSize())
+			} else if err != nil {
+				return nil, fmt.Errorf("logo图片格式错误")
+			}
+			return img, nil
+		}
+	}
+	if name == "" {
+		return nil, nil
+	}
+	if !logoNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("logo名称错误")
+	}
+	file, err := os.Open(filepath.Join(config.GetLogoDir(), name+".png"))
+	if err != nil {
+		return nil, fmt.Errorf("logo %s不存在", name)
+	}
+	defer file.Close()
+	img, err := decodeImage(file, config.GetMaxSize())
+	if err != nil {
+		logger.Error(err)
+		return nil, fmt.Errorf("logo %s格式错误", name)
+	}
+	return img, nil
+}
+
+func failure(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+}
+
+/*
+	根据参数返回二维码图片，默认为430像素、最高纠错等级的jpeg。
+	size、level(L/M/Q/H)、margin、fg、bg(#RRGGBB)、format(png/jpeg/svg)控制样式，
+	type为wifi、vcard、url时按模板生成内容，POST时可以上传logo文件
+*/
 func IptvQrcodeHandler(c *gin.Context) {
 	type param struct {
-		Message           string `form:"msg" json:"msg"`
+		PayloadParam
+		StyleParam
 	}
 
-	var p param
+	// 限制上传logo的请求大小，Bind会读取整个multipart
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.GetMaxUploadSize())
+	p := param{StyleParam: NewStyleParam()}
 	if err := c.Bind(&p); err != nil {
-		logger.Error("Invalid device register param ", err)
+		logger.Error("Invalid qrcode param ", err)
 		c.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
 
 	logger.Debug(c.Request.URL)
 
-	var err error
-	var q *qrcode.QRCode
-	if q, err = qrcode.New(p.Message, qrcode.Highest); err != nil {
+	content, err := p.content()
+	if err != nil {
+		failure(c, err)
+		return
+	}
+	opts, err := p.options(c)
+	if err != nil {
+		failure(c, err)
+		return
+	}
+
+	data, err := render.Render(content, opts)
+	if err == render.ErrLowContrast {
+		failure(c, err)
+		return
+	} else if err != nil {
 		logger.Error(err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
+	logger.Info("[IptvQrcode] Resposned qrcode image with ", content)
 
-	buff := util.BuffReadWriter{}
-	if err = jpeg.Encode(&buff, q.Image(430), &jpeg.Options{jpeg.DefaultQuality}); err != nil {
+	c.Data(http.StatusOK, render.ContentType(opts.Format), data)
+}
+
+var fileNameRegexp = regexp.MustCompile(`[\\/:*?"<>|\s]+`)
+
+/*
+	批量生成，返回zip。请求为JSON，样式参数对所有二维码有效，
+	items中每一项为二维码内容参数，file_name为zip中的文件名(不含扩展名)
+*/
+func BatchQrcodeHandler(c *gin.Context) {
+	type item struct {
+		PayloadParam
+		FileName string `json:"file_name"`
+	}
+	type param struct {
+		StyleParam
+		Items []item `json:"items"`
+	}
+
+	p := param{StyleParam: NewStyleParam()}
+	if err := c.BindJSON(&p); err != nil {
+		logger.Error("Invalid qrcode batch param ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if len(p.Items) == 0 || len(p.Items) > config.GetMaxBatch() {
+		failure(c, fmt.Errorf("批量生成的个数需要在1～%d之间", config.GetMaxBatch()))
+		return
+	}
+	opts, err := p.options(c)
+	if err != nil {
+		failure(c, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	used := make(map[string]bool)
+	for i, item := range p.Items {
+		content, err := item.content()
+		if err != nil {
+			failure(c, fmt.Errorf("第%d个: %v", i+1, err))
+			return
+		}
+		data, err := render.Render(content, opts)
+		if err == render.ErrLowContrast {
+			failure(c, err)
+			return
+		} else if err != nil {
+			failure(c, fmt.Errorf("第%d个: %v", i+1, err))
+			return
+		}
+
+		name := strings.Trim(fileNameRegexp.ReplaceAllString(item.FileName, "_"), "._")
+		if name == "" {
+			name = fmt.Sprint(i + 1)
+		}
+		// 重名时加序号
+		for base, n := name, 2; used[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[name] = true
+
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name + render.Extension(opts.Format), Method: zip.Store, Modified: time.Now()})
+		if err == nil {
+			_, err = f.Write(data)
+		}
+		if err != nil {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := w.Close(); err != nil {
 		logger.Error(err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
-	logger.Info("[IptvQrcode] Resposned qrcode image with ", p.Message)
+	logger.Info("[BatchQrcode] Resposned ", len(p.Items), " qrcode images")
+
+	c.Header("Content-Disposition", `attachment; filename="qrcode.zip"`)
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+/*
+	识别上传图片(字段file)中的二维码
+*/
+func DecodeQrcodeHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.GetMaxUploadSize())
+	header, err := c.FormFile("file")
+	if err != nil {
+		failure(c, fmt.Errorf("缺少图片文件或文件超过%d字节", config.GetMaxUploadSize()))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	img, err := decodeImage(file, decodeMaxSide)
+	if err == errImageTooLarge {
+		failure(c, fmt.Errorf("图片宽高不能超过%d像素", decodeMaxSide))
+		return
+	} else if err != nil {
+		failure(c, fmt.Errorf("图片格式错误，支持png、jpeg、gif"))
+		return
+	}
+	text, err := decode.Decode(img)
+	if err != nil {
+		logger.Debug("[DecodeQrcode] ", err)
+		failure(c, decode.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{"text": text}})
+}
+
+/*
+	校验扫码打开的签名链接，供链接指向的服务调用，url为完整的链接
+*/
+func VerifyQrcodeUrlHandler(c *gin.Context) {
+	type param struct {
+		Url string `form:"url" binding:"required"`
+	}
+
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error("Invalid verify param ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	maxAge := time.Second * time.Duration(config.GetSignMaxAge())
+	if err := payload.VerifyUrl(p.Url, config.GetSignSecret(), config.GetSignHosts(), maxAge, time.Now()); err != nil {
+		failure(c, err)
+		return
+	}
 
-	c.Data(http.StatusOK, "image/jpeg", buff.Buff)
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success})
 }
@@ -0,0 +1,331 @@
+package decode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+var ErrNotFound = errors.New("没有找到二维码")
+
+/*
+	识别图片中的二维码，返回内容。
+	按三个定位图形做仿射变换取样，支持旋转，不支持明显透视变形的照片
+*/
+func Decode(img image.Image) (string, error) {
+	b := newBitmap(img)
+	finders := b.findFinders()
+	if len(finders) < 3 {
+		return "", ErrNotFound
+	}
+
+	var lastErr error = ErrNotFound
+	for _, t := range bestTriples(finders) {
+		tl, tr, bl := orient(t)
+		module := (tl.module + tr.module + bl.module) / 3
+		estimate := int(math.Floor((tl.distance(tr)+tl.distance(bl))/2/module+0.5)) + 7
+		// 估算的边长可能差一个版本
+		base := (estimate-17+2)/4*4 + 17
+		for _, size := range []int{base, base + 4, base - 4} {
+			if size < 21 || size > 177 {
+				continue
+			}
+			text, err := b.decodeGrid(b.sample(tl, tr, bl, size))
+			if err == nil {
+				return text, nil
+			}
+			lastErr = err
+		}
+	}
+	return "", lastErr
+}
+
+func (b *bitmap) decodeGrid(g *grid) (string, error) {
+	version := (g.size - 17) / 4
+	level, mask, err := g.formatInfo()
+	if err != nil {
+		return "", err
+	}
+	data, err := correctBlocks(g.codewords(version, mask), version, level)
+	if err != nil {
+		return "", err
+	}
+	return parseData(data, version)
+}
+
+// 二值化的图片，dark为true表示深色
+type bitmap struct {
+	width  int
+	height int
+	dark   []bool
+}
+
+/*
+	按灰度直方图用大津法求阈值
+*/
+func newBitmap(img image.Image) *bitmap {
+	bounds := img.Bounds()
+	b := &bitmap{width: bounds.Dx(), height: bounds.Dy()}
+	gray := make([]uint8, b.width*b.height)
+	var histogram [256]int
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			v := color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+			gray[y*b.width+x] = v
+			histogram[v]++
+		}
+	}
+
+	total := len(gray)
+	var sum float64
+	for i, n := range histogram {
+		sum += float64(i * n)
+	}
+	var sumBackground, best float64
+	var weightBackground int
+	threshold := 128
+	for i, n := range histogram {
+		weightBackground += n
+		if weightBackground == 0 {
+			continue
+		}
+		weightForeground := total - weightBackground
+		if weightForeground == 0 {
+			break
+		}
+		sumBackground += float64(i * n)
+		meanBackground := sumBackground / float64(weightBackground)
+		meanForeground := (sum - sumBackground) / float64(weightForeground)
+		between := float64(weightBackground) * float64(weightForeground) * (meanBackground - meanForeground) * (meanBackground - meanForeground)
+		if between > best {
+			best, threshold = between, i
+		}
+	}
+
+	b.dark = make([]bool, total)
+	for i, v := range gray {
+		b.dark[i] = int(v) <= threshold
+	}
+	return b
+}
+
+func (b *bitmap) get(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.width || y >= b.height {
+		return false
+	}
+	return b.dark[y*b.width+x]
+}
+
+type finder struct {
+	x, y   float64
+	module float64
+	count  int // 被多少行扫描到
+}
+
+func (f *finder) distance(o *finder) float64 {
+	return math.Hypot(f.x-o.x, f.y-o.y)
+}
+
+// 深浅深浅深的宽度是否符合1:1:3:1:1
+func finderRatio(runs [5]int) bool {
+	total := 0
+	for _, r := range runs {
+		if r == 0 {
+			return false
+		}
+		total += r
+	}
+	if total < 7 {
+		return false
+	}
+	module := float64(total) / 7
+	variance := module / 2
+	return math.Abs(module-float64(runs[0])) < variance &&
+		math.Abs(module-float64(runs[1])) < variance &&
+		math.Abs(3*module-float64(runs[2])) < 3*variance &&
+		math.Abs(module-float64(runs[3])) < variance &&
+		math.Abs(module-float64(runs[4])) < variance
+}
+
+/*
+	从(x, y)沿(dx, dy)两个方向统计游程，返回中心相对(x, y)像素左上角的偏移和总宽度，不符合比例时ok为false
+*/
+func (b *bitmap) crossCheck(x, y, dx, dy int) (center float64, total int, ok bool) {
+	if !b.get(x, y) {
+		return 0, 0, false
+	}
+	var runs [5]int
+	count := func(i, step int, dark bool) int {
+		n := 0
+		for b.inside(x+i*step*dx, y+i*step*dy) && b.get(x+i*step*dx, y+i*step*dy) == dark {
+			n++
+			i++
+		}
+		return n
+	}
+	back := count(0, -1, true)
+	runs[1] = count(back, -1, false)
+	runs[0] = count(back+runs[1], -1, true)
+	forward := count(1, 1, true)
+	runs[3] = count(1+forward, 1, false)
+	runs[4] = count(1+forward+runs[3], 1, true)
+	runs[2] = back + forward
+	if !finderRatio(runs) {
+		return 0, 0, false
+	}
+	for _, r := range runs {
+		total += r
+	}
+	// 中心深色游程的范围为[-(back-1), forward+1)
+	return float64(forward-back+2) / 2, total, true
+}
+
+func (b *bitmap) inside(x, y int) bool {
+	return x >= 0 && y >= 0 && x < b.width && y < b.height
+}
+
+/*
+	逐行扫描符合比例的游程，再纵向、横向交叉检查，合并相近的结果
+*/
+func (b *bitmap) findFinders() []*finder {
+	var finders []*finder
+	type run struct {
+		start  int
+		length int
+		dark   bool
+	}
+	for y := 0; y < b.height; y++ {
+		var runs []run
+		for x := 0; x < b.width; {
+			start, dark := x, b.get(x, y)
+			for x < b.width && b.get(x, y) == dark {
+				x++
+			}
+			runs = append(runs, run{start, x - start, dark})
+		}
+		for i := 0; i+4 < len(runs); i++ {
+			if !runs[i].dark {
+				continue
+			}
+			if finderRatio([5]int{runs[i].length, runs[i+1].length, runs[i+2].length, runs[i+3].length, runs[i+4].length}) {
+				b.addCandidate(&finders, runs[i+2].start+runs[i+2].length/2, y)
+			}
+		}
+	}
+	var result []*finder
+	for _, f := range finders {
+		if f.count >= 2 {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+func (b *bitmap) addCandidate(finders *[]*finder, x, y int) {
+	cy, vTotal, ok := b.crossCheck(x, y, 0, 1)
+	if !ok {
+		return
+	}
+	yy := y + int(math.Floor(cy))
+	cx, hTotal, ok := b.crossCheck(x, yy, 1, 0)
+	if !ok {
+		return
+	}
+	if math.Abs(float64(vTotal-hTotal)) > float64(hTotal)*0.4 {
+		return
+	}
+	f := &finder{x: float64(x) + cx, y: float64(y) + cy, module: float64(vTotal+hTotal) / 14, count: 1}
+	for _, o := range *finders {
+		if o.distance(f) < o.module*2 && math.Abs(o.module-f.module) < o.module*0.5 {
+			n := float64(o.count)
+			o.x = (o.x*n + f.x) / (n + 1)
+			o.y = (o.y*n + f.y) / (n + 1)
+			o.module = (o.module*n + f.module) / (n + 1)
+			o.count++
+			return
+		}
+	}
+	*finders = append(*finders, f)
+}
+
+/*
+	按模块大小相近、构成等腰直角三角形的程度给三个定位图形的组合打分，从好到差排序
+*/
+func bestTriples(finders []*finder) [][3]*finder {
+	sort.Slice(finders, func(i, j int) bool { return finders[i].count > finders[j].count })
+	if len(finders) > 8 {
+		finders = finders[:8]
+	}
+	type scored struct {
+		triple [3]*finder
+		score  float64
+	}
+	var list []scored
+	for i := 0; i < len(finders); i++ {
+		for j := i + 1; j < len(finders); j++ {
+			for k := j + 1; k < len(finders); k++ {
+				t := [3]*finder{finders[i], finders[j], finders[k]}
+				tl, tr, bl := orient(t)
+				a, c := tl.distance(tr), tl.distance(bl)
+				hyp := tr.distance(bl)
+				if a == 0 || c == 0 {
+					continue
+				}
+				mean := (tl.module + tr.module + bl.module) / 3
+				score := math.Abs(a-c)/math.Max(a, c) +
+					math.Abs(hyp-math.Hypot(a, c))/hyp +
+					(math.Abs(tl.module-mean)+math.Abs(tr.module-mean)+math.Abs(bl.module-mean))/mean
+				if score < 0.5 {
+					list = append(list, scored{t, score})
+				}
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].score < list[j].score })
+	var triples [][3]*finder
+	for _, s := range list {
+		triples = append(triples, s.triple)
+	}
+	return triples
+}
+
+/*
+	直角顶点为左上，按叉积区分右上和左下(图片y轴向下)
+*/
+func orient(t [3]*finder) (tl, tr, bl *finder) {
+	d01, d02, d12 := t[0].distance(t[1]), t[0].distance(t[2]), t[1].distance(t[2])
+	switch {
+	case d12 >= d01 && d12 >= d02:
+		tl, tr, bl = t[0], t[1], t[2]
+	case d02 >= d01 && d02 >= d12:
+		tl, tr, bl = t[1], t[0], t[2]
+	default:
+		tl, tr, bl = t[2], t[0], t[1]
+	}
+	if (tr.x-tl.x)*(bl.y-tl.y)-(tr.y-tl.y)*(bl.x-tl.x) < 0 {
+		tr, bl = bl, tr
+	}
+	return
+}
+
+/*
+	定位图形中心是模块(3, 3)，按仿射变换取每个模块中心的像素
+*/
+func (b *bitmap) sample(tl, tr, bl *finder, size int) *grid {
+	n := float64(size - 7)
+	ux, uy := (tr.x-tl.x)/n, (tr.y-tl.y)/n
+	vx, vy := (bl.x-tl.x)/n, (bl.y-tl.y)/n
+	g := &grid{size: size, modules: make([][]bool, size)}
+	for y := 0; y < size; y++ {
+		g.modules[y] = make([]bool, size)
+		for x := 0; x < size; x++ {
+			mx, my := float64(x-3), float64(y-3)
+			px := tl.x + mx*ux + my*vx
+			py := tl.y + mx*uy + my*vy
+			g.modules[y][x] = b.get(int(math.Floor(px)), int(math.Floor(py)))
+		}
+	}
+	return g
+}
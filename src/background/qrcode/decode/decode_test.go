@@ -0,0 +1,142 @@
+package decode
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"github.com/axgle/mahonia"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+func encode(t *testing.T, content string, level qrcode.RecoveryLevel, size int) image.Image {
+	q, err := qrcode.New(content, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q.Image(size)
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		content string
+		level   qrcode.RecoveryLevel
+		size    int
+	}{
+		{"12345678901234", qrcode.Medium, 200},
+		{"HELLO WORLD", qrcode.Low, 100},
+		{"http://example.com/pair?code=abc&sign=0123456789abcdef", qrcode.Highest, 430},
+		{"WIFI:T:WPA;S:家里的网络;P:p@ss;;", qrcode.High, 300},
+		{strings.Repeat("机顶盒二维码", 40), qrcode.Medium, -3},
+	}
+	for _, c := range cases {
+		text, err := Decode(encode(t, c.content, c.level, c.size))
+		if err != nil {
+			t.Errorf("%q: %v", c.content, err)
+			continue
+		}
+		if text != c.content {
+			t.Errorf("decoded %q, want %q", text, c.content)
+		}
+	}
+}
+
+// 旋转90度
+func rotate(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.Set(b.Max.Y-1-y, x, img.At(x, y))
+		}
+	}
+	return out
+}
+
+func TestDecodeRotated(t *testing.T) {
+	content := "rotated"
+	text, err := Decode(rotate(encode(t, content, qrcode.Medium, 256)))
+	if err != nil || text != content {
+		t.Errorf("decoded %q %v", text, err)
+	}
+}
+
+// 中间覆盖一块，需要纠错
+func TestDecodeWithErrors(t *testing.T) {
+	content := "http://example.com/logo"
+	img := encode(t, content, qrcode.Highest, 400)
+	m := image.NewRGBA(img.Bounds())
+	draw.Draw(m, m.Bounds(), img, image.ZP, draw.Src)
+	center := m.Bounds().Dx() / 2
+	draw.Draw(m, image.Rect(center-40, center-40, center+40, center+40), image.NewUniform(color.RGBA{200, 30, 30, 255}), image.ZP, draw.Src)
+
+	text, err := Decode(m)
+	if err != nil || text != content {
+		t.Errorf("decoded %q %v", text, err)
+	}
+}
+
+func TestDecodeNotFound(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	if _, err := Decode(img); err != ErrNotFound {
+		t.Errorf("err %v", err)
+	}
+}
+
+// 按位拼接数据码字，用于构造编码器不生成的模式
+type bitWriter struct {
+	data []byte
+	bits int
+}
+
+func (w *bitWriter) write(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if v>>uint(i)&1 != 0 {
+			w.data[w.bits/8] |= 0x80 >> uint(w.bits%8)
+		}
+		w.bits++
+	}
+}
+
+func TestParseDataHanzi(t *testing.T) {
+	// 中文汉字模式：1101、子集0001、字符数，GB2312编码减去A1A1或A6A1后按0x60合并为13位
+	var w bitWriter
+	w.write(13, 4)
+	w.write(1, 4)
+	w.write(2, 8)
+	for _, c := range []int{0xd6d0, 0xcec4} { // 中文
+		c -= 0xa6a1
+		w.write(c>>8*0x60+c&0xff, 13)
+	}
+	// 日文汉字模式：1000、字符数，Shift_JIS编码减去8140后按0xc0合并为13位
+	w.write(8, 4)
+	w.write(1, 8)
+	c := 0x935f - 0x8140 // 点
+	w.write(c>>8*0xc0+c&0xff, 13)
+	w.write(0, 4)
+
+	text, err := parseData(w.data, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 中文点
+	want := mahonia.NewDecoder("gbk").ConvertString("\xd6\xd0\xce\xc4") + mahonia.NewDecoder("sjis").ConvertString("\x93\x5f")
+	if text != want {
+		t.Errorf("text %q, want %q", text, want)
+	}
+
+	// 只支持GB2312子集
+	w = bitWriter{}
+	w.write(13, 4)
+	w.write(2, 4)
+	w.write(1, 8)
+	w.write(0, 13)
+	if _, err := parseData(w.data, 1); err == nil {
+		t.Error("unknown hanzi subset parsed")
+	}
+}
@@ -0,0 +1,428 @@
+package decode
+
+import (
+	"errors"
+	"math/bits"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/axgle/mahonia"
+)
+
+var (
+	errFormatInfo = errors.New("invalid format info")
+	errDataBits   = errors.New("invalid data bits")
+)
+
+// 格式信息中的纠错等级编码，下标为L、M、Q、H
+var ecLevelBits = [4]int{1, 0, 3, 2}
+
+// 模块矩阵，modules[y][x]为true表示深色
+type grid struct {
+	size    int
+	modules [][]bool
+}
+
+func (g *grid) get(x, y int) bool {
+	return g.modules[y][x]
+}
+
+func bch(value, poly int) int {
+	msb := bits.Len(uint(poly)) - 1
+	value <<= uint(msb)
+	for bits.Len(uint(value)) > msb {
+		value ^= poly << uint(bits.Len(uint(value))-msb-1)
+	}
+	return value
+}
+
+/*
+	读取两份格式信息，按汉明距离找最接近的合法值，返回纠错等级下标和掩码
+*/
+func (g *grid) formatInfo() (int, int, error) {
+	var first, second int
+	read := func(v *int, x, y int) {
+		*v <<= 1
+		if g.get(x, y) {
+			*v |= 1
+		}
+	}
+	for x := 0; x <= 5; x++ {
+		read(&first, x, 8)
+	}
+	read(&first, 7, 8)
+	read(&first, 8, 8)
+	read(&first, 8, 7)
+	for y := 5; y >= 0; y-- {
+		read(&first, 8, y)
+	}
+	for y := g.size - 1; y >= g.size-7; y-- {
+		read(&second, 8, y)
+	}
+	for x := g.size - 8; x < g.size; x++ {
+		read(&second, x, 8)
+	}
+
+	best, bestDistance := -1, 4
+	for data := 0; data < 32; data++ {
+		code := (data<<10 | bch(data, 0x537)) ^ 0x5412
+		for _, v := range []int{first, second} {
+			if d := bits.OnesCount(uint(code ^ v)); d < bestDistance {
+				best, bestDistance = data, d
+			}
+		}
+	}
+	if best < 0 {
+		return 0, 0, errFormatInfo
+	}
+	for level, b := range ecLevelBits {
+		if b == best>>3 {
+			return level, best & 7, nil
+		}
+	}
+	return 0, 0, errFormatInfo
+}
+
+func masked(mask, y, x int) bool {
+	switch mask {
+	case 0:
+		return (y+x)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (y+x)%3 == 0
+	case 4:
+		return (y/2+x/3)%2 == 0
+	case 5:
+		return y*x%2+y*x%3 == 0
+	case 6:
+		return (y*x%2+y*x%3)%2 == 0
+	}
+	return ((y+x)%2+y*x%3)%2 == 0
+}
+
+// 功能图形(定位、分隔符、格式、时序、校正、版本信息)所在的模块
+func functionPattern(version int) [][]bool {
+	size := version*4 + 17
+	pattern := make([][]bool, size)
+	for y := range pattern {
+		pattern[y] = make([]bool, size)
+	}
+	fill := func(left, top, width, height int) {
+		for y := top; y < top+height; y++ {
+			for x := left; x < left+width; x++ {
+				pattern[y][x] = true
+			}
+		}
+	}
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+	centers := alignmentPatternCenter[version]
+	for i, cy := range centers {
+		for j, cx := range centers {
+			last := len(centers) - 1
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			fill(cx-2, cy-2, 5, 5)
+		}
+	}
+	fill(6, 9, 1, size-17)
+	fill(9, 6, size-17, 1)
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+	return pattern
+}
+
+/*
+	去掉掩码后按之字形顺序读出所有码字
+*/
+func (g *grid) codewords(version, mask int) []byte {
+	pattern := functionPattern(version)
+	var result []byte
+	var current byte
+	count := 0
+	up := true
+	for x := g.size - 1; x > 0; x -= 2 {
+		if x == 6 {
+			x--
+		}
+		for i := 0; i < g.size; i++ {
+			y := i
+			if up {
+				y = g.size - 1 - i
+			}
+			for col := 0; col < 2; col++ {
+				if pattern[y][x-col] {
+					continue
+				}
+				current <<= 1
+				if g.get(x-col, y) != masked(mask, y, x-col) {
+					current |= 1
+				}
+				count++
+				if count == 8 {
+					result = append(result, current)
+					current, count = 0, 0
+				}
+			}
+		}
+		up = !up
+	}
+	return result
+}
+
+/*
+	按分块还原交错的码字并纠错，返回数据码字
+*/
+func correctBlocks(raw []byte, version, level int) ([]byte, error) {
+	type dataBlock struct {
+		codewords []byte
+		numData   int
+	}
+	var blocks []*dataBlock
+	var total int
+	for _, b := range ecBlocks[version][level] {
+		for i := 0; i < b.numBlocks; i++ {
+			blocks = append(blocks, &dataBlock{codewords: make([]byte, b.numCodewords), numData: b.numDataCodewords})
+			total += b.numCodewords
+		}
+	}
+	if len(raw) < total {
+		return nil, errDataBits
+	}
+	ecCount := len(blocks[0].codewords) - blocks[0].numData
+	maxData := blocks[len(blocks)-1].numData
+
+	offset := 0
+	for i := 0; i < maxData; i++ {
+		for _, b := range blocks {
+			if i < b.numData {
+				b.codewords[i] = raw[offset]
+				offset++
+			}
+		}
+	}
+	for i := 0; i < ecCount; i++ {
+		for _, b := range blocks {
+			b.codewords[b.numData+i] = raw[offset]
+			offset++
+		}
+	}
+
+	var data []byte
+	for _, b := range blocks {
+		if _, err := rsCorrect(b.codewords, ecCount); err != nil {
+			return nil, err
+		}
+		data = append(data, b.codewords[:b.numData]...)
+	}
+	return data, nil
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) available() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.available() {
+		return 0, errDataBits
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.data[r.pos/8]&(0x80>>uint(r.pos%8)) != 0 {
+			v |= 1
+		}
+		r.pos++
+	}
+	return v, nil
+}
+
+const alphanumericChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// 字符数的位数，按版本1～9、10～26、27～40
+func countBits(mode, version int) int {
+	i := 0
+	if version >= 27 {
+		i = 2
+	} else if version >= 10 {
+		i = 1
+	}
+	switch mode {
+	case 1:
+		return [3]int{10, 12, 14}[i]
+	case 2:
+		return [3]int{9, 11, 13}[i]
+	case 8, 13:
+		return [3]int{8, 10, 12}[i]
+	}
+	return [3]int{8, 16, 16}[i]
+}
+
+/*
+	解析数据码字，支持数字、字母数字、字节、日文汉字(Shift_JIS)和中文汉字(GB2312)模式，
+	字节模式不是UTF-8时按GBK解码
+*/
+func parseData(data []byte, version int) (string, error) {
+	r := &bitReader{data: data}
+	var result strings.Builder
+	var bytesMode []byte
+	flush := func() {
+		if len(bytesMode) == 0 {
+			return
+		}
+		if utf8.Valid(bytesMode) {
+			result.Write(bytesMode)
+		} else {
+			result.WriteString(mahonia.NewDecoder("gbk").ConvertString(string(bytesMode)))
+		}
+		bytesMode = nil
+	}
+
+	for r.available() >= 4 {
+		mode, _ := r.read(4)
+		if mode == 0 {
+			break
+		}
+		switch mode {
+		case 7: // ECI，忽略字符集声明
+			first, err := r.read(8)
+			if err != nil {
+				return "", err
+			}
+			if first&0x80 != 0 {
+				extra := 8
+				if first&0xc0 == 0xc0 {
+					extra = 16
+				}
+				if _, err := r.read(extra); err != nil {
+					return "", err
+				}
+			}
+			continue
+		case 3: // 结构链接
+			if _, err := r.read(16); err != nil {
+				return "", err
+			}
+			continue
+		case 5: // FNC1
+			continue
+		case 9:
+			if _, err := r.read(8); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		// 中文汉字模式在字符数前有4位子集，1为GB2312
+		if mode == 13 {
+			subset, err := r.read(4)
+			if err != nil || subset != 1 {
+				return "", errDataBits
+			}
+		}
+
+		count, err := r.read(countBits(mode, version))
+		if err != nil {
+			return "", err
+		}
+		switch mode {
+		case 1:
+			flush()
+			for ; count >= 3; count -= 3 {
+				v, err := r.read(10)
+				if err != nil || v >= 1000 {
+					return "", errDataBits
+				}
+				result.WriteString(string([]byte{byte('0' + v/100), byte('0' + v/10%10), byte('0' + v%10)}))
+			}
+			if count == 2 {
+				v, err := r.read(7)
+				if err != nil || v >= 100 {
+					return "", errDataBits
+				}
+				result.WriteString(string([]byte{byte('0' + v/10), byte('0' + v%10)}))
+			} else if count == 1 {
+				v, err := r.read(4)
+				if err != nil || v >= 10 {
+					return "", errDataBits
+				}
+				result.WriteByte(byte('0' + v))
+			}
+		case 2:
+			flush()
+			for ; count >= 2; count -= 2 {
+				v, err := r.read(11)
+				if err != nil || v >= 45*45 {
+					return "", errDataBits
+				}
+				result.WriteByte(alphanumericChars[v/45])
+				result.WriteByte(alphanumericChars[v%45])
+			}
+			if count == 1 {
+				v, err := r.read(6)
+				if err != nil || v >= 45 {
+					return "", errDataBits
+				}
+				result.WriteByte(alphanumericChars[v])
+			}
+		case 4:
+			for i := 0; i < count; i++ {
+				v, err := r.read(8)
+				if err != nil {
+					return "", err
+				}
+				bytesMode = append(bytesMode, byte(v))
+			}
+		case 8: // 日文汉字，Shift_JIS
+			flush()
+			var sjis []byte
+			for i := 0; i < count; i++ {
+				v, err := r.read(13)
+				if err != nil {
+					return "", err
+				}
+				assembled := (v/0xc0)<<8 | v%0xc0
+				if assembled < 0x1f00 {
+					assembled += 0x8140
+				} else {
+					assembled += 0xc140
+				}
+				sjis = append(sjis, byte(assembled>>8), byte(assembled))
+			}
+			result.WriteString(mahonia.NewDecoder("sjis").ConvertString(string(sjis)))
+		case 13:
+			flush()
+			var gb []byte
+			for i := 0; i < count; i++ {
+				v, err := r.read(13)
+				if err != nil {
+					return "", err
+				}
+				assembled := (v/0x60)<<8 | v%0x60
+				if assembled < 0xa00 {
+					assembled += 0xa1a1
+				} else {
+					assembled += 0xa6a1
+				}
+				gb = append(gb, byte(assembled>>8), byte(assembled))
+			}
+			result.WriteString(mahonia.NewDecoder("gbk").ConvertString(string(gb)))
+		default:
+			return "", errDataBits
+		}
+	}
+	flush()
+	return result.String(), nil
+}
@@ -0,0 +1,143 @@
+package decode
+
+import "errors"
+
+var errTooManyErrors = errors.New("too many errors")
+
+// GF(256)，本原多项式x^8+x^4+x^3+x^2+1
+var gfExp [512]byte
+var gfLog [256]int
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x >= 256 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+255-gfLog[b]]
+}
+
+// 多项式求值，系数低次在前
+func polyEval(p []byte, x byte) byte {
+	var y byte
+	for i := len(p) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ p[i]
+	}
+	return y
+}
+
+/*
+	Reed-Solomon纠错，block为一个块的全部码字(高次在前)，ecCount为纠错码字数，
+	就地修正错误，返回修正的个数
+*/
+func rsCorrect(block []byte, ecCount int) (int, error) {
+	n := len(block)
+	// 伴随式S_j = r(α^j)
+	syndromes := make([]byte, ecCount)
+	hasError := false
+	for j := 0; j < ecCount; j++ {
+		var s byte
+		for _, c := range block {
+			s = gfMul(s, gfExp[j]) ^ c
+		}
+		syndromes[j] = s
+		if s != 0 {
+			hasError = true
+		}
+	}
+	if !hasError {
+		return 0, nil
+	}
+
+	// Berlekamp-Massey求错误位置多项式
+	locator := []byte{1}
+	prev := []byte{1}
+	l, m := 0, 1
+	var b byte = 1
+	for k := 0; k < ecCount; k++ {
+		d := syndromes[k]
+		for i := 1; i <= l && i < len(locator); i++ {
+			d ^= gfMul(locator[i], syndromes[k-i])
+		}
+		if d == 0 {
+			m++
+			continue
+		}
+		next := make([]byte, len(locator))
+		copy(next, locator)
+		coef := gfDiv(d, b)
+		for i, p := range prev {
+			for len(next) <= i+m {
+				next = append(next, 0)
+			}
+			next[i+m] ^= gfMul(coef, p)
+		}
+		if 2*l <= k {
+			prev = locator
+			l = k + 1 - l
+			b = d
+			m = 1
+		} else {
+			m++
+		}
+		locator = next
+	}
+	if 2*l > ecCount {
+		return 0, errTooManyErrors
+	}
+
+	// Chien搜索：Λ(α^-p) = 0时x^p的系数有错
+	var powers []int
+	for p := 0; p < n; p++ {
+		if polyEval(locator, gfExp[(255-p)%255]) == 0 {
+			powers = append(powers, p)
+		}
+	}
+	if len(powers) != l {
+		return 0, errTooManyErrors
+	}
+
+	// Forney算法求错误值，伴随式从α^0开始，e = X·Ω(X^-1)/Λ'(X^-1)
+	omega := make([]byte, ecCount)
+	for i, s := range syndromes {
+		for j, c := range locator {
+			if i+j < ecCount {
+				omega[i+j] ^= gfMul(s, c)
+			}
+		}
+	}
+	derivative := make([]byte, len(locator))
+	for i := 1; i < len(locator); i += 2 {
+		derivative[i-1] = locator[i]
+	}
+	for _, p := range powers {
+		x := gfExp[p%255]
+		xInv := gfExp[(255-p)%255]
+		denominator := polyEval(derivative, xInv)
+		if denominator == 0 {
+			return 0, errTooManyErrors
+		}
+		block[n-1-p] ^= gfMul(x, gfDiv(polyEval(omega, xInv), denominator))
+	}
+	return len(powers), nil
+}
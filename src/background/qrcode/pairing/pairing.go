@@ -0,0 +1,488 @@
+package pairing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	机顶盒扫码配对：机顶盒创建会话并显示二维码，二维码内容为带签名的短期token，
+	手机扫码后确认，机顶盒通过长轮询或SSE得到绑定的设备和用户。
+	状态只能从等待扫码、已扫码变为已确认或已取消
+*/
+
+// 会话状态
+const (
+	StatePending   = "pending"   // 等待扫码
+	StateScanned   = "scanned"   // 手机已扫码，等待确认
+	StateConfirmed = "confirmed" // 已确认绑定
+	StateCancelled = "cancelled" // 手机取消
+	StateExpired   = "expired"
+)
+
+const (
+	sessionPrefix = "pair:session:"
+	scanPrefix    = "pair:scan:" // 扫码的installation_id，只能有一台手机
+	donePrefix    = "pair:done:" // 确认或取消后的会话，只有一次能写入成功，读取时优先使用
+	devicePrefix  = "pair:device_sign:" // 用过的机顶盒签名，同一个签名只能创建一次会话
+	// 会话过期后保留的时间，期间机顶盒仍能查询到expired状态
+	sessionKeep = 60
+	// 机顶盒请求的timestamp和服务器时间允许的误差(秒)
+	deviceSignWindow = 600
+)
+
+var (
+	ErrSessionNotFound = errors.New("配对会话不存在或已过期")
+	ErrInvalidToken    = errors.New("二维码无效")
+	ErrTokenExpired    = errors.New("二维码已过期，请刷新")
+	ErrSessionDone     = errors.New("配对已完成或已取消")
+	ErrScannedByOther  = errors.New("二维码已被其他设备扫描")
+	ErrInvalidPoll     = errors.New("poll_token错误")
+	ErrInvalidDevice   = errors.New("机顶盒签名错误")
+	ErrInvalidBindSign = errors.New("绑定签名无效")
+	ErrBindSignExpired = errors.New("绑定签名已过期")
+)
+
+type Session struct {
+	Id         string `json:"session_id"`
+	DeviceId   string `json:"device_id"`
+	OperatorId string `json:"operator_id"`
+	State      string `json:"state"`
+	ExpiresAt  int64  `json:"expires_at"`
+	PollToken  string `json:"-"` // 只返回给机顶盒，用于查询状态
+
+	ScannedBy      uint64 `json:"scanned_by,omitempty"` // 扫码的installation_id
+	InstallationId uint64 `json:"installation_id,omitempty"`
+	UserId         uint64 `json:"user_id,omitempty"`
+	BoundAt        int64  `json:"bound_at,omitempty"`
+	BindSign       string `json:"bind_sign,omitempty"` // 绑定结果的签名，机顶盒提交给其他服务校验
+}
+
+// 存储时包含poll_token
+type storedSession struct {
+	Session
+	PollToken string `json:"poll_token"`
+}
+
+func (s *Session) Done() bool {
+	return s.State == StateConfirmed || s.State == StateCancelled || s.State == StateExpired
+}
+
+/*
+	二维码中的内容，token为session_id、device_id和expires的签名
+*/
+type QrPayload struct {
+	Action    string `json:"action"`
+	SessionId string `json:"session_id"`
+	DeviceId  string `json:"device_id"`
+	Expires   int64  `json:"expires"`
+	Token     string `json:"token"`
+}
+
+type Config struct {
+	TTL         int    // 二维码有效期(秒)
+	TokenSecret string // 二维码token的密钥，只在本服务使用
+	BindSecret  string // 绑定结果的密钥，和校验绑定结果的服务共用，不能和app签名的密钥相同
+	// 机顶盒密钥的根密钥，每台机顶盒的密钥为DeviceSecret(根密钥, device_id)，出厂时写入机顶盒
+	DeviceSecret string
+}
+
+type Manager struct {
+	store  Store
+	config Config
+
+	lock    sync.Mutex
+	waiters map[string][]chan struct{}
+}
+
+func NewManager(store Store, config Config) *Manager {
+	return &Manager{store: store, config: config, waiters: make(map[string][]chan struct{})}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hmacHex(secret string, fields ...string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for i, f := range fields {
+		if i > 0 {
+			mac.Write([]byte{'|'})
+		}
+		mac.Write([]byte(f))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (m *Manager) tokenSign(sessionId, deviceId string, expires int64) string {
+	return hmacHex(m.config.TokenSecret, sessionId, deviceId, strconv.FormatInt(expires, 10))
+}
+
+/*
+	绑定结果的签名，其他服务用同样的密钥和参数校验，
+	签名内容为device_id|installation_id|user_id|bound_at
+*/
+func BindSign(secret, deviceId string, installationId, userId uint64, boundAt int64) string {
+	return hmacHex(secret, deviceId, strconv.FormatUint(installationId, 10), strconv.FormatUint(userId, 10), strconv.FormatInt(boundAt, 10))
+}
+
+/*
+	校验consumer收到的bind_sign，bound_at超过maxAge秒(0为不限制)的签名不再接受
+*/
+func VerifyBindSign(secret, deviceId string, installationId, userId uint64, boundAt int64, sign string, maxAge int, now time.Time) error {
+	if secret == "" || sign == "" {
+		return ErrInvalidBindSign
+	}
+	if subtle.ConstantTimeCompare([]byte(sign), []byte(BindSign(secret, deviceId, installationId, userId, boundAt))) != 1 {
+		return ErrInvalidBindSign
+	}
+	if maxAge > 0 && now.Unix()-boundAt > int64(maxAge) {
+		return ErrBindSignExpired
+	}
+	return nil
+}
+
+/*
+	机顶盒的密钥，由根密钥和device_id生成，服务端不需要保存每台机顶盒的密钥
+*/
+func DeviceSecret(rootSecret, deviceId string) string {
+	return hmacHex(rootSecret, "device", deviceId)
+}
+
+/*
+	机顶盒请求的签名，和middleware.MakeSignature的方式相同：
+	参数(不含sign)按key排序拼成k=v&k=v，用机顶盒密钥HMAC-SHA1后转为大写hex
+*/
+func DeviceSign(deviceSecret string, params map[string]string) string {
+	var keys []string
+	for k := range params {
+		if k != "sign" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var p []string
+	for _, k := range keys {
+		p = append(p, k+"="+params[k])
+	}
+	mac := hmac.New(sha1.New, []byte(deviceSecret))
+	mac.Write([]byte(strings.Join(p, "&")))
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil)))
+}
+
+/*
+	校验机顶盒创建会话的请求，参数中需要有device_id和timestamp，
+	timestamp和服务器时间相差不超过10分钟，同一个签名只能使用一次
+*/
+func (m *Manager) VerifyDevice(params map[string]string, sign string, now time.Time) error {
+	deviceId := params["device_id"]
+	if m.config.DeviceSecret == "" || deviceId == "" || sign == "" {
+		return ErrInvalidDevice
+	}
+	timestamp, err := strconv.ParseInt(params["timestamp"], 10, 64)
+	if err != nil || timestamp < now.Unix()-deviceSignWindow || timestamp > now.Unix()+deviceSignWindow {
+		return ErrInvalidDevice
+	}
+	expected := DeviceSign(DeviceSecret(m.config.DeviceSecret, deviceId), params)
+	if subtle.ConstantTimeCompare([]byte(strings.ToUpper(sign)), []byte(expected)) != 1 {
+		return ErrInvalidDevice
+	}
+	ok, err := m.store.SetNX(devicePrefix+expected, deviceId, deviceSignWindow*2)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidDevice
+	}
+	return nil
+}
+
+func (m *Manager) ttl(s *Session) int {
+	ttl := int(s.ExpiresAt-time.Now().Unix()) + sessionKeep
+	if ttl <= 0 {
+		ttl = 1
+	}
+	return ttl
+}
+
+func encodeSession(s *Session) (string, error) {
+	b, err := json.Marshal(storedSession{Session: *s, PollToken: s.PollToken})
+	return string(b), err
+}
+
+func (m *Manager) save(s *Session) error {
+	value, err := encodeSession(s)
+	if err != nil {
+		return err
+	}
+	return m.store.Set(sessionPrefix+s.Id, value, m.ttl(s))
+}
+
+func (m *Manager) load(id string) (*Session, error) {
+	value, ok, err := m.store.Get(donePrefix + id)
+	if err == nil && !ok {
+		value, ok, err = m.store.Get(sessionPrefix + id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	var stored storedSession
+	if err := json.Unmarshal([]byte(value), &stored); err != nil {
+		return nil, err
+	}
+	s := stored.Session
+	s.PollToken = stored.PollToken
+	if !s.Done() && time.Now().Unix() > s.ExpiresAt {
+		s.State = StateExpired
+	}
+	return &s, nil
+}
+
+/*
+	机顶盒创建配对会话，返回会话和二维码内容
+*/
+func (m *Manager) Create(deviceId, operatorId string) (*Session, *QrPayload, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, nil, err
+	}
+	pollToken, err := randomHex(16)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &Session{
+		Id:         id,
+		DeviceId:   deviceId,
+		OperatorId: operatorId,
+		State:      StatePending,
+		ExpiresAt:  time.Now().Unix() + int64(m.config.TTL),
+		PollToken:  pollToken,
+	}
+	if err := m.save(s); err != nil {
+		return nil, nil, err
+	}
+	payload := &QrPayload{
+		Action:    "pair",
+		SessionId: id,
+		DeviceId:  deviceId,
+		Expires:   s.ExpiresAt,
+		Token:     m.tokenSign(id, deviceId, s.ExpiresAt),
+	}
+	return s, payload, nil
+}
+
+/*
+	校验手机提交的二维码内容，返回对应的会话
+*/
+func (m *Manager) check(p *QrPayload) (*Session, error) {
+	if p.Token == "" || subtle.ConstantTimeCompare([]byte(p.Token), []byte(m.tokenSign(p.SessionId, p.DeviceId, p.Expires))) != 1 {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() > p.Expires {
+		return nil, ErrTokenExpired
+	}
+	s, err := m.load(p.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	if s.DeviceId != p.DeviceId {
+		return nil, ErrInvalidToken
+	}
+	if s.State == StateExpired {
+		return nil, ErrTokenExpired
+	}
+	if s.Done() {
+		return nil, ErrSessionDone
+	}
+	return s, nil
+}
+
+/*
+	手机扫码，记录扫码的设备。同一个二维码只能由一台手机确认
+*/
+func (m *Manager) Scan(p *QrPayload, installationId uint64) (*Session, error) {
+	s, err := m.check(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.claim(s, installationId); err != nil {
+		return nil, err
+	}
+	if s.State == StatePending {
+		s.State = StateScanned
+		s.ScannedBy = installationId
+		if err := m.save(s); err != nil {
+			return nil, err
+		}
+		m.notify(s.Id)
+	}
+	return s, nil
+}
+
+// 第一台扫码的手机占用会话
+func (m *Manager) claim(s *Session, installationId uint64) error {
+	id := strconv.FormatUint(installationId, 10)
+	ok, err := m.store.SetNX(scanPrefix+s.Id, id, m.ttl(s))
+	if err != nil || ok {
+		return err
+	}
+	scanner, _, err := m.store.Get(scanPrefix + s.Id)
+	if err != nil {
+		return err
+	}
+	if scanner != id {
+		return ErrScannedByOther
+	}
+	return nil
+}
+
+// 确认或取消，只有一次能成功
+func (m *Manager) finish(p *QrPayload, installationId uint64, update func(s *Session)) (*Session, error) {
+	s, err := m.check(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.claim(s, installationId); err != nil {
+		return nil, err
+	}
+	update(s)
+	value, err := encodeSession(s)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := m.store.SetNX(donePrefix+s.Id, value, m.ttl(s))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSessionDone
+	}
+	if err := m.save(s); err != nil {
+		return nil, err
+	}
+	m.notify(s.Id)
+	return s, nil
+}
+
+/*
+	手机确认，机顶盒绑定到installation_id和用户
+*/
+func (m *Manager) Confirm(p *QrPayload, installationId, userId uint64) (*Session, error) {
+	return m.finish(p, installationId, func(s *Session) {
+		s.State = StateConfirmed
+		s.ScannedBy = installationId
+		s.InstallationId = installationId
+		s.UserId = userId
+		s.BoundAt = time.Now().Unix()
+		s.BindSign = BindSign(m.config.BindSecret, s.DeviceId, installationId, userId, s.BoundAt)
+	})
+}
+
+func (m *Manager) Cancel(p *QrPayload, installationId uint64) (*Session, error) {
+	return m.finish(p, installationId, func(s *Session) {
+		s.State = StateCancelled
+	})
+}
+
+/*
+	机顶盒查询会话状态
+*/
+func (m *Manager) Get(id, pollToken string) (*Session, error) {
+	s, err := m.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(s.PollToken), []byte(pollToken)) != 1 {
+		return nil, ErrInvalidPoll
+	}
+	return s, nil
+}
+
+func (m *Manager) subscribe(id string) chan struct{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ch := make(chan struct{}, 1)
+	m.waiters[id] = append(m.waiters[id], ch)
+	return ch
+}
+
+func (m *Manager) unsubscribe(id string, ch chan struct{}) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	waiters := m.waiters[id]
+	for i, w := range waiters {
+		if w == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(m.waiters, id)
+	} else {
+		m.waiters[id] = waiters
+	}
+}
+
+func (m *Manager) notify(id string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, ch := range m.waiters[id] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 其他实例修改的状态只能通过轮询存储得到
+const waitPollInterval = time.Second
+
+/*
+	等待状态不再是known，或者到达timeout，或者done关闭(客户端断开)。
+	返回最新的会话，超时时状态可能不变
+*/
+func (m *Manager) Wait(id, pollToken, known string, timeout time.Duration, done <-chan struct{}) (*Session, error) {
+	ch := m.subscribe(id)
+	defer m.unsubscribe(id, ch)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s, err := m.Get(id, pollToken)
+		if err != nil {
+			return nil, err
+		}
+		if s.State != known || s.Done() {
+			return s, nil
+		}
+		select {
+		case <-ch:
+		case <-ticker.C:
+		case <-deadline.C:
+			return s, nil
+		case <-done:
+			return s, nil
+		}
+	}
+}
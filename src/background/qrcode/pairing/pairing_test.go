@@ -0,0 +1,192 @@
+package pairing
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testConfig = Config{TTL: 60, TokenSecret: "token-secret", BindSecret: "bind-secret"}
+
+func newTestManager() *Manager {
+	return NewManager(NewMemoryStore(), testConfig)
+}
+
+func TestPairing(t *testing.T) {
+	m := newTestManager()
+	session, payload, err := m.Create("stb-1", "op-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.State != StatePending || payload.Token == "" {
+		t.Fatalf("session %+v payload %+v", session, payload)
+	}
+
+	if _, err := m.Scan(payload, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Scan(payload, 200); err != ErrScannedByOther {
+		t.Errorf("scan by other %v", err)
+	}
+	if _, err := m.Confirm(payload, 200, 2); err != ErrScannedByOther {
+		t.Errorf("confirm by other %v", err)
+	}
+	if _, err := m.Confirm(payload, 100, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Cancel(payload, 100); err != ErrSessionDone {
+		t.Errorf("cancel after confirm %v", err)
+	}
+
+	s, err := m.Get(session.Id, session.PollToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != StateConfirmed || s.InstallationId != 100 || s.UserId != 1 {
+		t.Errorf("session %+v", s)
+	}
+	if s.BindSign != BindSign("bind-secret", "stb-1", 100, 1, s.BoundAt) {
+		t.Errorf("bind sign %s", s.BindSign)
+	}
+	if _, err := m.Get(session.Id, "wrong"); err != ErrInvalidPoll {
+		t.Errorf("poll token %v", err)
+	}
+}
+
+func TestPairingToken(t *testing.T) {
+	m := newTestManager()
+	_, payload, err := m.Create("stb-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := *payload
+	tampered.DeviceId = "stb-2"
+	if _, err := m.Scan(&tampered, 100); err != ErrInvalidToken {
+		t.Errorf("tampered device %v", err)
+	}
+	tampered = *payload
+	tampered.Expires += 3600
+	if _, err := m.Scan(&tampered, 100); err != ErrInvalidToken {
+		t.Errorf("tampered expires %v", err)
+	}
+	// 其他密钥生成的token无效
+	other := testConfig
+	other.TokenSecret = "other"
+	if _, err := NewManager(m.store, other).Scan(payload, 100); err != ErrInvalidToken {
+		t.Errorf("other secret %v", err)
+	}
+
+	// 会话过期后二维码失效
+	expiredConfig := testConfig
+	expiredConfig.TTL = -1
+	_, expired, err := NewManager(NewMemoryStore(), expiredConfig).Create("stb-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Scan(expired, 100); err != ErrTokenExpired {
+		t.Errorf("expired %v", err)
+	}
+}
+
+func TestPairingWait(t *testing.T) {
+	m := newTestManager()
+	session, payload, err := m.Create("stb-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 状态不变时等到超时
+	start := time.Now()
+	s, err := m.Wait(session.Id, session.PollToken, StatePending, time.Millisecond*50, nil)
+	if err != nil || s.State != StatePending || time.Since(start) < time.Millisecond*50 {
+		t.Errorf("timeout %v %v", s, err)
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		m.Scan(payload, 100)
+	}()
+	start = time.Now()
+	s, err = m.Wait(session.Id, session.PollToken, StatePending, time.Second*5, nil)
+	if err != nil || s.State != StateScanned {
+		t.Fatalf("wait %v %v", s, err)
+	}
+	// 同一进程内的变化立即通知，不需要等轮询间隔
+	if time.Since(start) >= waitPollInterval {
+		t.Errorf("woken after %v", time.Since(start))
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		m.Cancel(payload, 100)
+	}()
+	s, err = m.Wait(session.Id, session.PollToken, StateScanned, time.Second*5, nil)
+	if err != nil || s.State != StateCancelled {
+		t.Errorf("cancel %v %v", s, err)
+	}
+}
+
+func deviceParams(deviceId string, timestamp int64) map[string]string {
+	return map[string]string{"device_id": deviceId, "operator_id": "op-1", "timestamp": strconv.FormatInt(timestamp, 10)}
+}
+
+func TestVerifyDevice(t *testing.T) {
+	config := testConfig
+	config.DeviceSecret = "device-root"
+	m := NewManager(NewMemoryStore(), config)
+	now := time.Now()
+	secret := DeviceSecret("device-root", "stb-1")
+
+	params := deviceParams("stb-1", now.Unix())
+	sign := DeviceSign(secret, params)
+	if err := m.VerifyDevice(params, strings.ToLower(sign), now); err != nil {
+		t.Fatal(err)
+	}
+	// 同一个签名不能重放
+	if err := m.VerifyDevice(params, sign, now); err != ErrInvalidDevice {
+		t.Errorf("replay %v", err)
+	}
+
+	// 用一台机顶盒的密钥不能为其他device_id创建会话
+	other := deviceParams("stb-2", now.Unix())
+	if err := m.VerifyDevice(other, DeviceSign(secret, other), now); err != ErrInvalidDevice {
+		t.Errorf("other device %v", err)
+	}
+	tampered := deviceParams("stb-1", now.Unix()+1)
+	tampered["operator_id"] = "op-2"
+	if err := m.VerifyDevice(tampered, DeviceSign(secret, deviceParams("stb-1", now.Unix()+1)), now); err != ErrInvalidDevice {
+		t.Errorf("tampered operator %v", err)
+	}
+	stale := deviceParams("stb-1", now.Unix()-deviceSignWindow-1)
+	if err := m.VerifyDevice(stale, DeviceSign(secret, stale), now); err != ErrInvalidDevice {
+		t.Errorf("stale timestamp %v", err)
+	}
+	// 没有配置根密钥时拒绝
+	unsigned := NewManager(NewMemoryStore(), testConfig)
+	params = deviceParams("stb-1", now.Unix())
+	if err := unsigned.VerifyDevice(params, DeviceSign(DeviceSecret("", "stb-1"), params), now); err != ErrInvalidDevice {
+		t.Errorf("no device secret %v", err)
+	}
+}
+
+func TestVerifyBindSign(t *testing.T) {
+	now := time.Now()
+	sign := BindSign("bind-secret", "stb-1", 100, 1, now.Unix())
+	if err := VerifyBindSign("bind-secret", "stb-1", 100, 1, now.Unix(), sign, 600, now); err != nil {
+		t.Errorf("valid %v", err)
+	}
+	if err := VerifyBindSign("bind-secret", "stb-2", 100, 1, now.Unix(), sign, 600, now); err != ErrInvalidBindSign {
+		t.Errorf("other device %v", err)
+	}
+	if err := VerifyBindSign("bind-secret", "stb-1", 100, 2, now.Unix(), sign, 600, now); err != ErrInvalidBindSign {
+		t.Errorf("other user %v", err)
+	}
+	if err := VerifyBindSign("", "stb-1", 100, 1, now.Unix(), BindSign("", "stb-1", 100, 1, now.Unix()), 600, now); err != ErrInvalidBindSign {
+		t.Errorf("empty secret %v", err)
+	}
+	if err := VerifyBindSign("bind-secret", "stb-1", 100, 1, now.Unix(), sign, 600, now.Add(time.Second*601)); err != ErrBindSignExpired {
+		t.Errorf("expired %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package pairing
+
+import (
+	"background/common/cache"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	gocache "github.com/patrickmn/go-cache"
+)
+
+/*
+	配对会话的存储，多个实例部署时需要使用RedisStore
+*/
+type Store interface {
+	Set(key, value string, ttl int) error
+	Get(key string) (string, bool, error)
+	// key不存在时才设置，返回是否设置成功
+	SetNX(key, value string, ttl int) (bool, error)
+	Delete(key string) error
+}
+
+type RedisStore struct {
+	pool *redis.Pool
+}
+
+func NewRedisStore(pool *redis.Pool) *RedisStore {
+	return &RedisStore{pool: pool}
+}
+
+func (s *RedisStore) Set(key, value string, ttl int) error {
+	return cache.RedisSetString(key, value, ttl, s.pool)
+}
+
+func (s *RedisStore) Get(key string) (string, bool, error) {
+	value, err := cache.RedisGetString(key, s.pool)
+	if err == redis.ErrNil {
+		return "", false, nil
+	}
+	return value, err == nil, err
+}
+
+func (s *RedisStore) SetNX(key, value string, ttl int) (bool, error) {
+	return cache.RedisSetStringNX(key, value, ttl, s.pool)
+}
+
+func (s *RedisStore) Delete(key string) error {
+	return cache.RedisDelKey(key, s.pool)
+}
+
+/*
+	进程内存储，没有配置redis时使用
+*/
+type MemoryStore struct {
+	mem *gocache.Cache
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{mem: gocache.New(time.Minute*5, time.Minute)}
+}
+
+func (s *MemoryStore) Set(key, value string, ttl int) error {
+	s.mem.Set(key, value, time.Second*time.Duration(ttl))
+	return nil
+}
+
+func (s *MemoryStore) Get(key string) (string, bool, error) {
+	value, ok := s.mem.Get(key)
+	if !ok {
+		return "", false, nil
+	}
+	return value.(string), true, nil
+}
+
+func (s *MemoryStore) SetNX(key, value string, ttl int) (bool, error) {
+	return s.mem.Add(key, value, time.Second*time.Duration(ttl)) == nil, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mem.Delete(key)
+	return nil
+}
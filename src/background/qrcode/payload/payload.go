@@ -0,0 +1,198 @@
+package payload
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingSsid    = errors.New("缺少WiFi名称")
+	ErrInvalidAuth    = errors.New("WiFi加密方式只能是WPA、WEP、nopass")
+	ErrMissingName    = errors.New("缺少名片姓名")
+	ErrInvalidUrl     = errors.New("链接格式错误")
+	ErrMissingSecret  = errors.New("没有配置签名密钥")
+	ErrInvalidSign    = errors.New("签名错误")
+	ErrSignExpired    = errors.New("链接已过期")
+	ErrHostNotAllowed = errors.New("链接域名不允许签名")
+	ErrMissingPayload = errors.New("缺少二维码内容")
+)
+
+type WiFi struct {
+	Ssid     string
+	Password string
+	Auth     string // WPA、WEP、nopass，为空时有密码按WPA
+	Hidden   bool
+}
+
+// WiFi二维码中\;,:"需要转义
+func escapeWiFi(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `:`, `\:`, `"`, `\"`).Replace(s)
+}
+
+/*
+	WIFI:T:WPA;S:ssid;P:password;H:true;;
+*/
+func (w WiFi) Encode() (string, error) {
+	if w.Ssid == "" {
+		return "", ErrMissingSsid
+	}
+	auth := w.Auth
+	if auth == "" {
+		auth = "nopass"
+		if w.Password != "" {
+			auth = "WPA"
+		}
+	}
+	switch strings.ToUpper(auth) {
+	case "WPA", "WEP":
+		auth = strings.ToUpper(auth)
+	case "NOPASS":
+		auth = "nopass"
+	default:
+		return "", ErrInvalidAuth
+	}
+
+	var b strings.Builder
+	b.WriteString("WIFI:T:" + auth + ";S:" + escapeWiFi(w.Ssid) + ";")
+	if auth != "nopass" {
+		b.WriteString("P:" + escapeWiFi(w.Password) + ";")
+	}
+	if w.Hidden {
+		b.WriteString("H:true;")
+	}
+	b.WriteString(";")
+	return b.String(), nil
+}
+
+type VCard struct {
+	Name    string
+	Org     string
+	Title   string
+	Tel     string
+	Email   string
+	Url     string
+	Address string
+	Note    string
+}
+
+func escapeVCard(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+/*
+	vCard 3.0，只填写有值的字段
+*/
+func (v VCard) Encode() (string, error) {
+	if v.Name == "" {
+		return "", ErrMissingName
+	}
+	lines := []string{"BEGIN:VCARD", "VERSION:3.0", "N:" + escapeVCard(v.Name), "FN:" + escapeVCard(v.Name)}
+	for _, field := range []struct{ key, value string }{
+		{"ORG", v.Org},
+		{"TITLE", v.Title},
+		{"TEL", v.Tel},
+		{"EMAIL", v.Email},
+		{"URL", v.Url},
+		{"ADR", v.Address},
+		{"NOTE", v.Note},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if field.key == "ADR" {
+			// 地址按街道填写，其余部分留空
+			lines = append(lines, "ADR:;;"+escapeVCard(field.value)+";;;;")
+			continue
+		}
+		lines = append(lines, field.key+":"+escapeVCard(field.value))
+	}
+	lines = append(lines, "END:VCARD")
+	return strings.Join(lines, "\r\n"), nil
+}
+
+// 签名的内容：host、path和除sign外按参数名排序的参数
+func signContent(u *url.URL) string {
+	query := u.Query()
+	query.Del("sign")
+	return u.Host + u.Path + "?" + query.Encode()
+}
+
+func sign(content, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+	域名是否在列表中，列表中以.开头的项匹配所有子域名
+*/
+func HostAllowed(u *url.URL, hosts []string) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	在链接上加ts(秒)和sign参数，sign为HMAC-SHA256的十六进制。
+	只给hosts中的域名签名，否则任何人都能让服务给自己的链接签名，签名就没有意义
+*/
+func SignUrl(raw, secret string, hosts []string, now time.Time) (string, error) {
+	if secret == "" {
+		return "", ErrMissingSecret
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", ErrInvalidUrl
+	}
+	if !HostAllowed(u, hosts) {
+		return "", ErrHostNotAllowed
+	}
+	query := u.Query()
+	query.Set("ts", strconv.FormatInt(now.Unix(), 10))
+	query.Del("sign")
+	u.RawQuery = query.Encode()
+	query.Set("sign", sign(signContent(u), secret))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+/*
+	校验SignUrl生成的链接，maxAge为0时不检查时间
+*/
+func VerifyUrl(raw, secret string, hosts []string, maxAge time.Duration, now time.Time) error {
+	if secret == "" {
+		return ErrMissingSecret
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ErrInvalidUrl
+	}
+	if !HostAllowed(u, hosts) {
+		return ErrHostNotAllowed
+	}
+	expected := sign(signContent(u), secret)
+	if !hmac.Equal([]byte(expected), []byte(u.Query().Get("sign"))) {
+		return ErrInvalidSign
+	}
+	if maxAge > 0 {
+		ts, err := strconv.ParseInt(u.Query().Get("ts"), 10, 64)
+		if err != nil {
+			return ErrInvalidSign
+		}
+		if now.Sub(time.Unix(ts, 0)) > maxAge {
+			return ErrSignExpired
+		}
+	}
+	return nil
+}
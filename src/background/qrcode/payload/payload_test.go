@@ -0,0 +1,79 @@
+package payload
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWiFi(t *testing.T) {
+	text, err := WiFi{Ssid: `a;b`, Password: `p:"1"`}.Encode()
+	if err != nil || text != `WIFI:T:WPA;S:a\;b;P:p\:\"1\";;` {
+		t.Errorf("%q %v", text, err)
+	}
+	text, err = WiFi{Ssid: "open", Hidden: true}.Encode()
+	if err != nil || text != "WIFI:T:nopass;S:open;H:true;;" {
+		t.Errorf("%q %v", text, err)
+	}
+	if _, err := (WiFi{Ssid: "x", Auth: "WPA3"}).Encode(); err != ErrInvalidAuth {
+		t.Errorf("err %v", err)
+	}
+}
+
+func TestVCard(t *testing.T) {
+	text, err := VCard{Name: "张三", Org: "A,B", Note: "line1\nline2"}.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"FN:张三", `ORG:A\,B`, `NOTE:line1\nline2`, "END:VCARD"} {
+		if !strings.Contains(text, line) {
+			t.Errorf("missing %q in %q", line, text)
+		}
+	}
+}
+
+func TestSignUrl(t *testing.T) {
+	hosts := []string{"example.com", ".example.net"}
+	now := time.Unix(1700000000, 0)
+	signed, err := SignUrl("https://example.com/pair?code=abc", "secret", hosts, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyUrl(signed, "secret", hosts, time.Minute, now.Add(time.Second*30)); err != nil {
+		t.Errorf("verify %v", err)
+	}
+	if err := VerifyUrl(signed, "secret", hosts, time.Minute, now.Add(time.Minute*2)); err != ErrSignExpired {
+		t.Errorf("expired %v", err)
+	}
+	if err := VerifyUrl(signed, "other", hosts, 0, now); err != ErrInvalidSign {
+		t.Errorf("secret %v", err)
+	}
+	tampered := strings.Replace(signed, "code=abc", "code=abd", 1)
+	if err := VerifyUrl(tampered, "secret", hosts, 0, now); err != ErrInvalidSign {
+		t.Errorf("tampered %v", err)
+	}
+	if _, err := SignUrl("/relative", "secret", hosts, now); err != ErrInvalidUrl {
+		t.Errorf("relative %v", err)
+	}
+
+	// 只给允许的域名签名
+	for raw, allowed := range map[string]bool{
+		"https://m.example.com/a":   false,
+		"https://EXAMPLE.com:8080/": true,
+		"https://a.example.net/b":   true,
+		"https://example.net/b":     false,
+		"https://evil.com/b":        false,
+		"https://example.com.evil/": false,
+	} {
+		if _, err := SignUrl(raw, "secret", hosts, now); (err == nil) != allowed {
+			t.Errorf("sign %s: %v", raw, err)
+		}
+	}
+	if _, err := SignUrl("https://example.com/", "secret", nil, now); err != ErrHostNotAllowed {
+		t.Errorf("empty hosts %v", err)
+	}
+	other, _ := SignUrl("https://a.example.net/b", "secret", hosts, now)
+	if err := VerifyUrl(other, "secret", []string{"example.com"}, 0, now); err != ErrHostNotAllowed {
+		t.Errorf("verify other host %v", err)
+	}
+}
@@ -1,26 +1,86 @@
 package main
 
 import (
+	"background/common/cache"
+	"background/qrcode/config"
 	"background/qrcode/controller"
-	"background/qrcode/middleware"
+	"background/qrcode/logger"
+	qmid "background/qrcode/middleware"
+	"background/qrcode/pairing"
+	"flag"
+	"log"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	configPath := flag.String("conf", "../config/config.json", "Config file path")
+	flag.Parse()
+
+	if err := config.LoadConfig(*configPath); err != nil {
+		log.Fatal("Config Failed!!!!", err)
+		return
+	}
+	logger.SetLevel(config.GetLoggerLevel())
+
+	// 密钥都配置后才提供配对接口
+	pairEnabled := config.GetPairSecret() != "" && config.GetBindSecret() != "" && config.GetUserTokenSecret() != "" && config.GetDeviceSecret() != ""
+	if pairEnabled {
+		var store pairing.Store
+		if config.GetRedisAddr() != "" {
+			if err := cache.RedisTest(config.GetRedisAddr(), config.GetRedisPassword()); err != nil {
+				log.Fatal("Redis Failed!!!!", err)
+				return
+			}
+			store = pairing.NewRedisStore(cache.GetRedisPool(config.GetRedisAddr(), config.GetRedisPassword()))
+		} else {
+			logger.Warn("没有配置redis，配对会话保存在内存中，只能单实例部署")
+			store = pairing.NewMemoryStore()
+		}
+		controller.SetPairManager(pairing.NewManager(store, pairing.Config{
+			TTL:         config.GetPairTTL(),
+			TokenSecret: config.GetPairSecret(),
+			BindSecret:  config.GetBindSecret(),
+
+			DeviceSecret: config.GetDeviceSecret(),
+		}))
+	} else {
+		logger.Warn("没有配置pair_secret、bind_secret、user_token_secret或device_secret，不提供配对接口")
+	}
+
 	r := gin.New()
 
-	gin.SetMode(gin.DebugMode)
+	if config.IsProductionEnv() {
+		gin.SetMode(gin.ReleaseMode)
+	} else {
+		gin.SetMode(gin.DebugMode)
+	}
 
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
-	r.Use(middleware.CorsAllowHandler)
+	r.Use(qmid.CorsAllowHandler)
 	r.OPTIONS("*f", func(c *gin.Context) {})
 
 	baseApi := r.Group("")
 	{
 		baseApi.GET("/qrcode", controller.IptvQrcodeHandler)
+		baseApi.POST("/qrcode", controller.IptvQrcodeHandler)
+		baseApi.POST("/qrcode/batch", controller.BatchQrcodeHandler)
+		baseApi.POST("/qrcode/decode", controller.DecodeQrcodeHandler)
+		baseApi.GET("/qrcode/verify", controller.VerifyQrcodeUrlHandler)
+	}
+
+	if pairEnabled {
+		pairApi := r.Group("/pair")
+		// 机顶盒
+		pairApi.POST("/session", controller.CreatePairSessionHandler)
+		pairApi.GET("/session/poll", controller.PollPairSessionHandler)
+		pairApi.GET("/session/events", controller.PairSessionEventsHandler)
+		// 手机app，用户由user_token确定
+		pairApi.POST("/scan", controller.ScanPairHandler)
+		pairApi.POST("/confirm", controller.ConfirmPairHandler)
+		pairApi.POST("/cancel", controller.CancelPairHandler)
 	}
 
-	r.Run(":6600") // listen and server on 0.0.0.0:9000
+	r.Run(config.GetListenAddr())
 }
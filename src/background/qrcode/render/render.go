@@ -0,0 +1,250 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	FormatPng  = "png"
+	FormatJpeg = "jpeg"
+	FormatSvg  = "svg"
+)
+
+var (
+	ErrInvalidLevel  = errors.New("纠错等级只能是L、M、Q、H")
+	ErrInvalidColor  = errors.New("颜色格式为#RRGGBB")
+	ErrInvalidFormat = errors.New("图片格式只能是png、jpeg、svg")
+	ErrLowContrast   = errors.New("前景色需要比背景色深")
+)
+
+type Options struct {
+	Size       int // 图片边长(像素)，小于二维码模块数时按每个模块1像素
+	Level      qrcode.RecoveryLevel
+	Margin     int // 空白边距(模块数)
+	Foreground color.RGBA
+	Background color.RGBA
+	Format     string
+	Logo       image.Image // 中间的logo，连同四周的边不超过二维码区域边长的1/5
+}
+
+// 和原来的接口相同：430像素的jpeg，最高纠错等级
+func DefaultOptions() Options {
+	return Options{
+		Size:       430,
+		Level:      qrcode.Highest,
+		Margin:     4,
+		Foreground: color.RGBA{0, 0, 0, 255},
+		Background: color.RGBA{255, 255, 255, 255},
+		Format:     FormatJpeg,
+	}
+}
+
+func ParseLevel(s string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return qrcode.Low, nil
+	case "M":
+		return qrcode.Medium, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	}
+	return 0, ErrInvalidLevel
+}
+
+// 支持#RGB和#RRGGBB，#可以省略
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, ErrInvalidColor
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, ErrInvalidColor
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+func ParseFormat(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "png":
+		return FormatPng, nil
+	case "jpg", "jpeg":
+		return FormatJpeg, nil
+	case "svg":
+		return FormatSvg, nil
+	}
+	return "", ErrInvalidFormat
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatPng:
+		return "image/png"
+	case FormatSvg:
+		return "image/svg+xml"
+	}
+	return "image/jpeg"
+}
+
+func Extension(format string) string {
+	if format == FormatJpeg {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+func luminance(c color.RGBA) int {
+	return (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+}
+
+/*
+	生成二维码图片，有logo时纠错等级至少为Q
+*/
+func Render(content string, opts Options) ([]byte, error) {
+	if luminance(opts.Foreground) >= luminance(opts.Background) {
+		return nil, ErrLowContrast
+	}
+	if opts.Logo != nil && opts.Level < qrcode.High {
+		opts.Level = qrcode.Highest
+	}
+	q, err := qrcode.New(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	q.DisableBorder = true
+	bitmap := q.Bitmap()
+	if opts.Logo != nil {
+		logo, _ := logoRect(len(bitmap), opts)
+		opts.Logo = imaging.Fit(opts.Logo, logo.Dx(), logo.Dy(), imaging.Lanczos)
+	}
+
+	if opts.Format == FormatSvg {
+		return renderSvg(bitmap, opts)
+	}
+
+	img := renderImage(bitmap, opts)
+	var buf bytes.Buffer
+	if opts.Format == FormatPng {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpeg.DefaultQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 每个模块的像素数和二维码左上角的位置
+func layout(modules int, opts Options) (size, scale, offset int) {
+	total := modules + 2*opts.Margin
+	size = opts.Size
+	if size < total {
+		size = total
+	}
+	scale = size / total
+	offset = (size-total*scale)/2 + opts.Margin*scale
+	return
+}
+
+/*
+	logo区域按二维码模块区域(不含空白边距)计算，连同背景色的边不超过模块区域边长的1/5，
+	只遮挡约4%的模块，在Q、H纠错能力之内
+*/
+func logoRect(modules int, opts Options) (logo, padding image.Rectangle) {
+	_, scale, offset := layout(modules, opts)
+	area := modules * scale
+	outer := area / 5
+	pad := outer / 12
+	side := outer - 2*pad
+	min := offset + (area-side)/2
+	logo = image.Rect(min, min, min+side, min+side)
+	padding = image.Rect(min-pad, min-pad, min+side+pad, min+side+pad)
+	return
+}
+
+func renderImage(bitmap [][]bool, opts Options) image.Image {
+	size, scale, offset := layout(len(bitmap), opts)
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.ZP, draw.Src)
+	fg := image.NewUniform(opts.Foreground)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				r := image.Rect(offset+x*scale, offset+y*scale, offset+(x+1)*scale, offset+(y+1)*scale)
+				draw.Draw(img, r, fg, image.ZP, draw.Src)
+			}
+		}
+	}
+
+	if opts.Logo != nil {
+		logo, padding := logoRect(len(bitmap), opts)
+		draw.Draw(img, padding, image.NewUniform(opts.Background), image.ZP, draw.Src)
+		// 保持比例，居中
+		b := opts.Logo.Bounds()
+		at := logo.Min.Add(image.Pt((logo.Dx()-b.Dx())/2, (logo.Dy()-b.Dy())/2))
+		draw.Draw(img, image.Rectangle{at, at.Add(b.Size())}, opts.Logo, b.Min, draw.Over)
+	}
+	return img
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+/*
+	svg以像素为单位，相邻的深色模块合并为一个矩形，logo以png内嵌
+*/
+func renderSvg(bitmap [][]bool, opts Options) ([]byte, error) {
+	size, scale, offset := layout(len(bitmap), opts)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, size, size)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, size, size, svgColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, svgColor(opts.Foreground))
+	for y, row := range bitmap {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv%dh-%dz", offset+start*scale, offset+y*scale, (x-start)*scale, scale, (x-start)*scale)
+		}
+	}
+	buf.WriteString(`"/>`)
+
+	if opts.Logo != nil {
+		logo, padding := logoRect(len(bitmap), opts)
+		var logoPng bytes.Buffer
+		if err := png.Encode(&logoPng, opts.Logo); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`,
+			padding.Min.X, padding.Min.Y, padding.Dx(), padding.Dy(), svgColor(opts.Background))
+		fmt.Fprintf(&buf, `<image x="%d" y="%d" width="%d" height="%d" href="data:image/png;base64,%s"/>`,
+			logo.Min.X, logo.Min.Y, logo.Dx(), logo.Dy(), base64.StdEncoding.EncodeToString(logoPng.Bytes()))
+	}
+	buf.WriteString("</svg>")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,151 @@
+package backtest
+
+import (
+	"background/stock/model"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	日线数据，价格为前复权价格
+*/
+type Bar struct {
+	Code     string
+	Date     string
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   float64
+	PreClose float64 // 上一个交易日收盘价，用于计算涨跌停，上市首日为0
+}
+
+// Close为0表示当天停牌
+func (b *Bar) Suspended() bool {
+	return b.Close == 0
+}
+
+/*
+	一个交易日所有股票的数据，停牌的股票也有对应的Bar
+*/
+type Day struct {
+	Date string
+	Bars map[string]*Bar
+}
+
+// 按代码排序的股票，策略按此顺序下单，共用资金时回测结果不随map遍历顺序变化
+func (d *Day) Codes() []string {
+	codes := make([]string, 0, len(d.Bars))
+	for code := range d.Bars {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+/*
+	加载codes在[begin, end]内的前复权日线，按日期分组。
+	warmup为begin之前额外加载的交易日数，只作为历史数据不参与交易
+*/
+func LoadBars(db *gorm.DB, codes []string, begin, end string, warmup int) (history map[string][]*Bar, days []*Day, err error) {
+	history = make(map[string][]*Bar)
+	byDate := make(map[string]*Day)
+
+	for _, code := range codes {
+		var pre []*model.StockHistoryDataQ
+		if warmup > 0 {
+			if err = db.Order("date desc").Where("code = ? and date < ?", code, begin).Limit(warmup).Find(&pre).Error; err != nil {
+				return nil, nil, err
+			}
+		}
+		var rows []*model.StockHistoryDataQ
+		if err = db.Order("date asc").Where("code = ? and date >= ? and date <= ?", code, begin, end).Find(&rows).Error; err != nil {
+			return nil, nil, err
+		}
+
+		var preClose float64
+		for i := len(pre) - 1; i >= 0; i-- {
+			bar := newBar(pre[i], preClose)
+			if !bar.Suspended() {
+				preClose = bar.Close
+			}
+			history[code] = append(history[code], bar)
+		}
+		for _, row := range rows {
+			bar := newBar(row, preClose)
+			if !bar.Suspended() {
+				preClose = bar.Close
+			}
+			day, ok := byDate[bar.Date]
+			if !ok {
+				day = &Day{Date: bar.Date, Bars: make(map[string]*Bar)}
+				byDate[bar.Date] = day
+			}
+			day.Bars[code] = bar
+		}
+	}
+
+	for _, day := range byDate {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return history, days, nil
+}
+
+func newBar(row *model.StockHistoryDataQ, preClose float64) *Bar {
+	return &Bar{
+		Code:     row.Code,
+		Date:     row.Date,
+		Open:     row.Open,
+		High:     row.High,
+		Low:      row.Low,
+		Close:    row.Close,
+		Volume:   row.Volume,
+		PreClose: preClose,
+	}
+}
+
+// 之前保存的基准没有交易所前缀，399开头的为深交所指数，其他为上交所指数
+func indexCode(code string) string {
+	if len(code) != 6 {
+		return code
+	}
+	if strings.HasPrefix(code, "399") {
+		return "sz" + code
+	}
+	return "sh" + code
+}
+
+/*
+	加载指数在[begin, end]内的日线，用于基准比较，code带交易所前缀
+*/
+func LoadIndexBars(db *gorm.DB, code, begin, end string) ([]*Bar, error) {
+	code = indexCode(code)
+	var rows []*model.IndexHistoryData
+	if err := db.Order("date asc").Where("code = ? and date >= ? and date <= ?", code, begin, end).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	bars := make([]*Bar, 0, len(rows))
+	var preClose float64
+	for _, row := range rows {
+		bars = append(bars, &Bar{
+			Code:     row.Code,
+			Date:     row.Date,
+			Open:     row.Open,
+			High:     row.High,
+			Low:      row.Low,
+			Close:    row.Close,
+			Volume:   row.Volume,
+			PreClose: preClose,
+		})
+		preClose = row.Close
+	}
+	return bars, nil
+}
@@ -0,0 +1,177 @@
+package backtest
+
+import (
+	"math"
+)
+
+const (
+	SideBuy  = 1
+	SideSell = 2
+)
+
+const (
+	OrderStatusPending  = 0
+	OrderStatusFilled   = 1
+	OrderStatusRejected = 2
+)
+
+const lotSize = 100 // 一手100股
+
+/*
+	委托，当天OnBar中提交，下一个交易日按开盘价撮合，当天未成交的作废。
+	Price为0时为市价单，否则为限价单
+*/
+type Order struct {
+	Id     uint32
+	Code   string
+	Side   uint8 // 参见SideBuy、SideSell
+	Count  int64
+	Price  float64
+	Date   string // 提交日期
+	Status uint8  // 参见OrderStatus*
+	Reason string // 未成交原因
+}
+
+/*
+	成交，Profit为卖出时按持仓均价计算的已实现盈亏，已扣除买卖手续费
+*/
+type Fill struct {
+	OrderId     uint32
+	Code        string
+	Side        uint8
+	Count       int64
+	Price       float64
+	Date        string
+	Commission  float64 // 佣金
+	StampDuty   float64 // 印花税，只在卖出时收取
+	TransferFee float64 // 过户费
+	Profit      float64
+}
+
+func (f *Fill) Fee() float64 {
+	return f.Commission + f.StampDuty + f.TransferFee
+}
+
+/*
+	A股交易规则和费率
+*/
+type BrokerConfig struct {
+	CommissionRate float64 // 佣金费率，双向收取
+	MinCommission  float64 // 最低佣金
+	StampDutyRate  float64 // 印花税率，卖出收取
+	TransferRate   float64 // 过户费率，双向收取
+	PriceLimit     float64 // 涨跌停幅度
+}
+
+var DefaultBrokerConfig = BrokerConfig{
+	CommissionRate: 0.0002,
+	MinCommission:  5,
+	StampDutyRate:  0.001,
+	TransferRate:   0.00002,
+	PriceLimit:     0.1,
+}
+
+type broker struct {
+	config BrokerConfig
+}
+
+func (b *broker) fees(side uint8, price float64, count int64) (commission, stampDuty, transferFee float64) {
+	amount := price * float64(count)
+	commission = math.Max(amount*b.config.CommissionRate, b.config.MinCommission)
+	if side == SideSell {
+		stampDuty = amount * b.config.StampDutyRate
+	}
+	transferFee = amount * b.config.TransferRate
+	return round2(commission), round2(stampDuty), round2(transferFee)
+}
+
+// 涨跌停价，上市首日不限制
+func (b *broker) priceLimits(bar *Bar) (down, up float64) {
+	if bar.PreClose == 0 {
+		return 0, math.MaxFloat64
+	}
+	return round2(bar.PreClose * (1 - b.config.PriceLimit)), round2(bar.PreClose * (1 + b.config.PriceLimit))
+}
+
+/*
+	按当天行情撮合委托，不能成交时设置order的Status和Reason并返回nil
+*/
+func (b *broker) execute(order *Order, bar *Bar, portfolio *Portfolio) *Fill {
+	reject := func(reason string) *Fill {
+		order.Status = OrderStatusRejected
+		order.Reason = reason
+		return nil
+	}
+
+	if bar == nil || bar.Suspended() {
+		return reject("停牌")
+	}
+
+	down, up := b.priceLimits(bar)
+	price := bar.Open
+	if order.Side == SideBuy {
+		// 一字涨停买不进
+		if bar.Low >= up {
+			return reject("涨停")
+		}
+		if order.Price > 0 {
+			if bar.Low > order.Price {
+				return reject("未到委托价")
+			}
+			price = math.Min(bar.Open, order.Price)
+		}
+	} else {
+		if bar.High <= down {
+			return reject("跌停")
+		}
+		if order.Price > 0 {
+			if bar.High < order.Price {
+				return reject("未到委托价")
+			}
+			price = math.Max(bar.Open, order.Price)
+		}
+	}
+	price = round2(math.Min(math.Max(price, down), up))
+
+	count := order.Count
+	if order.Side == SideBuy {
+		count = count / lotSize * lotSize
+		for count > 0 {
+			commission, stampDuty, transferFee := b.fees(SideBuy, price, count)
+			if price*float64(count)+commission+stampDuty+transferFee <= portfolio.Cash {
+				break
+			}
+			count -= lotSize
+		}
+		if count <= 0 {
+			return reject("资金不足")
+		}
+	} else {
+		pos := portfolio.Position(order.Code)
+		if pos == nil || pos.Available == 0 {
+			return reject("没有可卖持仓")
+		}
+		if count > pos.Available {
+			count = pos.Available
+		}
+		// 零股只能在全部卖出时一次卖出
+		if count < pos.Count {
+			count = count / lotSize * lotSize
+		}
+		if count <= 0 {
+			return reject("不足一手")
+		}
+	}
+
+	fill := &Fill{
+		OrderId: order.Id,
+		Code:    order.Code,
+		Side:    order.Side,
+		Count:   count,
+		Price:   price,
+		Date:    bar.Date,
+	}
+	fill.Commission, fill.StampDuty, fill.TransferFee = b.fees(order.Side, price, count)
+	order.Status = OrderStatusFilled
+	return fill
+}
@@ -0,0 +1,245 @@
+package backtest
+
+import (
+	"testing"
+)
+
+// 按日期执行预先设定的下单操作
+type scriptStrategy struct {
+	actions map[string]func(ctx *Context)
+	fills   []*Fill
+}
+
+func (s *scriptStrategy) OnBar(ctx *Context, day *Day) {
+	if action, ok := s.actions[day.Date]; ok {
+		action(ctx)
+	}
+}
+
+func (s *scriptStrategy) OnFill(ctx *Context, fill *Fill) {
+	s.fills = append(s.fills, fill)
+}
+
+func bar(date string, preClose, open, high, low, close float64) *Bar {
+	return &Bar{Code: "600000", Date: date, Open: open, High: high, Low: low, Close: close, Volume: 1000, PreClose: preClose}
+}
+
+func days(bars ...*Bar) []*Day {
+	var result []*Day
+	for _, b := range bars {
+		result = append(result, &Day{Date: b.Date, Bars: map[string]*Bar{b.Code: b}})
+	}
+	return result
+}
+
+func run(t *testing.T, cash float64, bars []*Bar, actions map[string]func(ctx *Context)) (*Result, *scriptStrategy) {
+	strategy := &scriptStrategy{actions: actions}
+	result, err := RunBars(Config{Cash: cash}, nil, days(bars...), strategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, strategy
+}
+
+func TestBrokerFees(t *testing.T) {
+	result, strategy := run(t, 100000, []*Bar{
+		bar("2020-01-02", 10, 10, 10, 10, 10),
+		bar("2020-01-03", 10, 10, 10.5, 9.5, 10),
+		bar("2020-01-06", 10, 11, 11, 11, 11),
+		bar("2020-01-07", 11, 11, 11, 11, 11),
+	}, map[string]func(ctx *Context){
+		"2020-01-02": func(ctx *Context) { ctx.Buy("600000", 1000, 0) },
+		"2020-01-03": func(ctx *Context) { ctx.Sell("600000", 1000, 0) },
+	})
+
+	if len(strategy.fills) != 2 {
+		t.Fatalf("fills %d", len(strategy.fills))
+	}
+	buy, sell := strategy.fills[0], strategy.fills[1]
+	// 佣金不足5元按5元，过户费双向，印花税只在卖出时收取
+	if buy.Date != "2020-01-03" || buy.Price != 10 || buy.Commission != 5 || buy.StampDuty != 0 || buy.TransferFee != 0.2 {
+		t.Errorf("buy %+v", buy)
+	}
+	if sell.Date != "2020-01-06" || sell.Price != 11 || sell.Commission != 5 || sell.StampDuty != 11 || sell.TransferFee != 0.22 {
+		t.Errorf("sell %+v", sell)
+	}
+	if profit := 11000 - sell.Fee() - (10000 + buy.Fee()); !near(sell.Profit, profit) {
+		t.Errorf("profit %v, want %v", sell.Profit, profit)
+	}
+	if cash := 100000 - 10000 - buy.Fee() + 11000 - sell.Fee(); !near(result.Portfolio.Cash, cash) {
+		t.Errorf("cash %v, want %v", result.Portfolio.Cash, cash)
+	}
+	if len(result.Portfolio.Positions) != 0 {
+		t.Errorf("positions %v", result.Portfolio.Positions)
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 1e-6 && b-a < 1e-6
+}
+
+// 当天买入的股票当天不能卖出
+func TestBrokerT1(t *testing.T) {
+	result, strategy := run(t, 100000, []*Bar{
+		bar("2020-01-02", 10, 10, 10, 10, 10),
+		bar("2020-01-03", 10, 10, 10, 10, 10),
+		bar("2020-01-06", 10, 10, 10, 10, 10),
+		bar("2020-01-07", 10, 10, 10, 10, 10),
+	}, map[string]func(ctx *Context){
+		"2020-01-02": func(ctx *Context) {
+			ctx.Buy("600000", 1000, 0)
+			ctx.Sell("600000", 1000, 0)
+		},
+		"2020-01-03": func(ctx *Context) {
+			if pos := ctx.Position("600000"); pos == nil || pos.Available != 0 || pos.Count != 1000 {
+				t.Errorf("position on buy day %+v", pos)
+			}
+			ctx.Sell("600000", 1000, 0)
+		},
+		"2020-01-06": func(ctx *Context) {
+			if pos := ctx.Position("600000"); pos != nil {
+				t.Errorf("position after sell %+v", pos)
+			}
+		},
+	})
+
+	if len(strategy.fills) != 2 || strategy.fills[1].Date != "2020-01-06" {
+		t.Fatalf("fills %v", strategy.fills)
+	}
+	if order := result.Orders[1]; order.Status != OrderStatusRejected || order.Reason != "没有可卖持仓" {
+		t.Errorf("sell on buy day %+v", order)
+	}
+}
+
+func TestBrokerLots(t *testing.T) {
+	_, strategy := run(t, 100000, []*Bar{
+		bar("2020-01-02", 10, 10, 10, 10, 10),
+		bar("2020-01-03", 10, 10, 10, 10, 10),
+		bar("2020-01-06", 10, 10, 10, 10, 10),
+		bar("2020-01-07", 10, 10, 10, 10, 10),
+		bar("2020-01-08", 10, 10, 10, 10, 10),
+	}, map[string]func(ctx *Context){
+		// 买入取整手
+		"2020-01-02": func(ctx *Context) { ctx.Buy("600000", 250, 0) },
+		// 部分卖出取整手，剩余100股
+		"2020-01-03": func(ctx *Context) { ctx.Sell("600000", 150, 0) },
+		// 超过持仓按持仓全部卖出
+		"2020-01-06": func(ctx *Context) { ctx.Sell("600000", 1000, 0) },
+	})
+
+	var counts []int64
+	for _, fill := range strategy.fills {
+		counts = append(counts, fill.Count)
+	}
+	if len(counts) != 3 || counts[0] != 200 || counts[1] != 100 || counts[2] != 100 {
+		t.Errorf("fill counts %v", counts)
+	}
+}
+
+// 资金不足时减少手数，一手都买不起时拒绝
+func TestBrokerCash(t *testing.T) {
+	result, strategy := run(t, 10000, []*Bar{
+		bar("2020-01-02", 10, 10, 10, 10, 10),
+		bar("2020-01-03", 10, 10, 10, 10, 10),
+		bar("2020-01-06", 10, 10, 10, 10, 10),
+	}, map[string]func(ctx *Context){
+		"2020-01-02": func(ctx *Context) { ctx.Buy("600000", 1000, 0) },
+		"2020-01-03": func(ctx *Context) { ctx.Buy("600000", 100, 0) },
+	})
+
+	if len(strategy.fills) != 1 || strategy.fills[0].Count != 900 {
+		t.Fatalf("fills %v", strategy.fills)
+	}
+	if order := result.Orders[1]; order.Status != OrderStatusRejected || order.Reason != "资金不足" {
+		t.Errorf("second buy %+v", order)
+	}
+}
+
+func TestBrokerPriceLimit(t *testing.T) {
+	result, strategy := run(t, 100000, []*Bar{
+		bar("2020-01-02", 10, 10, 10, 10, 10),
+		bar("2020-01-03", 10, 10, 10, 10, 10),
+		// 一字涨停
+		bar("2020-01-06", 10, 11, 11, 11, 11),
+		// 一字跌停
+		bar("2020-01-07", 11, 9.9, 9.9, 9.9, 9.9),
+		// 开盘价低于跌停价时按跌停价成交
+		bar("2020-01-08", 9.9, 8.9, 9.5, 8.8, 9),
+		bar("2020-01-09", 9, 9, 9, 9, 9),
+	}, map[string]func(ctx *Context){
+		"2020-01-02": func(ctx *Context) { ctx.Buy("600000", 100, 0) },
+		"2020-01-03": func(ctx *Context) { ctx.Buy("600000", 100, 0) },
+		"2020-01-06": func(ctx *Context) { ctx.Sell("600000", 100, 0) },
+		"2020-01-07": func(ctx *Context) { ctx.Sell("600000", 100, 0) },
+	})
+
+	if order := result.Orders[1]; order.Status != OrderStatusRejected || order.Reason != "涨停" {
+		t.Errorf("buy at limit up %+v", order)
+	}
+	if order := result.Orders[2]; order.Status != OrderStatusRejected || order.Reason != "跌停" {
+		t.Errorf("sell at limit down %+v", order)
+	}
+	if len(strategy.fills) != 2 || strategy.fills[1].Price != 8.91 {
+		t.Errorf("fills %v", strategy.fills)
+	}
+}
+
+func TestBrokerLimitOrder(t *testing.T) {
+	result, strategy := run(t, 100000, []*Bar{
+		bar("2020-01-02", 10, 10, 10, 10, 10),
+		bar("2020-01-03", 10, 10, 10.2, 9.8, 10),
+		bar("2020-01-06", 10, 10.1, 10.3, 9.9, 10),
+		bar("2020-01-07", 10, 10, 10, 10, 10),
+	}, map[string]func(ctx *Context){
+		"2020-01-02": func(ctx *Context) {
+			ctx.Buy("600000", 100, 9.5)
+			ctx.Buy("600000", 100, 9.9)
+		},
+		"2020-01-03": func(ctx *Context) { ctx.Sell("600000", 100, 10.2) },
+	})
+
+	if order := result.Orders[0]; order.Status != OrderStatusRejected || order.Reason != "未到委托价" {
+		t.Errorf("buy below low %+v", order)
+	}
+	if len(strategy.fills) != 2 || strategy.fills[0].Price != 9.9 || strategy.fills[1].Price != 10.2 {
+		t.Errorf("fills %v", strategy.fills)
+	}
+}
+
+// 停牌日的委托不能成交，持仓按停牌前的价格计算市值
+func TestBrokerSuspended(t *testing.T) {
+	result, strategy := run(t, 100000, []*Bar{
+		bar("2020-01-02", 10, 10, 10, 10, 10),
+		bar("2020-01-03", 10, 10, 10, 10, 10),
+		{Code: "600000", Date: "2020-01-06", PreClose: 10},
+		bar("2020-01-07", 10, 10.5, 10.5, 10.5, 10.5),
+	}, map[string]func(ctx *Context){
+		"2020-01-02": func(ctx *Context) { ctx.Buy("600000", 100, 0) },
+		"2020-01-03": func(ctx *Context) { ctx.Sell("600000", 100, 0) },
+		"2020-01-06": func(ctx *Context) { ctx.Buy("600001", 100, 0) },
+	})
+
+	if order := result.Orders[1]; order.Status != OrderStatusRejected || order.Reason != "停牌" {
+		t.Errorf("sell on suspended day %+v", order)
+	}
+	// 没有行情的股票按停牌处理
+	if order := result.Orders[2]; order.Status != OrderStatusRejected || order.Reason != "停牌" {
+		t.Errorf("buy without bar %+v", order)
+	}
+	if len(strategy.fills) != 1 {
+		t.Errorf("fills %v", strategy.fills)
+	}
+	if equity := result.Equity[2]; equity.MarketValue != 1000 {
+		t.Errorf("market value on suspended day %v", equity.MarketValue)
+	}
+}
+
+func TestDayCodes(t *testing.T) {
+	day := &Day{Bars: map[string]*Bar{"600001": {}, "000001": {}, "300001": {}, "600000": {}}}
+	for i := 0; i < 10; i++ {
+		codes := day.Codes()
+		if len(codes) != 4 || codes[0] != "000001" || codes[1] != "300001" || codes[2] != "600000" || codes[3] != "600001" {
+			t.Fatalf("codes %v", codes)
+		}
+	}
+}
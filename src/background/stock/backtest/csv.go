@@ -0,0 +1,55 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// 每日资产导出为csv
+func WriteEquityCsv(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"date", "cash", "market_value", "total", "drawdown", "benchmark"})
+	for _, point := range report.Equity {
+		writer.Write([]string{
+			point.Date,
+			formatFloat(point.Cash),
+			formatFloat(point.MarketValue),
+			formatFloat(point.Total),
+			formatFloat(point.Drawdown),
+			formatFloat(point.Benchmark),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// 成交记录导出为csv
+func WriteTradesCsv(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"date", "code", "side", "count", "price", "amount", "fee", "profit", "hold_days"})
+	for _, trade := range report.Trades {
+		side := "buy"
+		if trade.Side == SideSell {
+			side = "sell"
+		}
+		writer.Write([]string{
+			trade.Date,
+			trade.Code,
+			side,
+			fmt.Sprint(trade.Count),
+			formatFloat(trade.Price),
+			formatFloat(trade.Amount),
+			formatFloat(trade.Fee),
+			formatFloat(trade.Profit),
+			formatFloat(trade.HoldDays),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
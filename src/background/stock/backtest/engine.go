@@ -0,0 +1,198 @@
+package backtest
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	回测策略。OnBar在每个交易日收盘后调用，可以提交委托，委托在下一个交易日撮合；
+	OnFill在委托成交后调用
+*/
+type Strategy interface {
+	OnBar(ctx *Context, day *Day)
+	OnFill(ctx *Context, fill *Fill)
+}
+
+// 需要历史行情的策略实现，返回计算指标需要的交易日数(包含当天)
+type WarmupStrategy interface {
+	Warmup() int
+}
+
+type Config struct {
+	Codes     []string
+	Begin     string // 开始日期，格式2006-01-02
+	End       string
+	Cash      float64 // 初始资金
+	Warmup    int     // begin之前加载的历史交易日数，供策略计算指标
+	Benchmark string  // 基准指数代码，只用于报告
+	Broker    BrokerConfig
+}
+
+// 每日收盘后的资产
+type EquityPoint struct {
+	Date        string
+	Cash        float64
+	MarketValue float64
+	Total       float64
+}
+
+type Result struct {
+	Config    Config
+	Orders    []*Order
+	Fills     []*Fill
+	Equity    []*EquityPoint
+	Portfolio *Portfolio // 回测结束时的持仓
+}
+
+/*
+	策略的运行环境，提供行情历史、持仓和下单接口
+*/
+type Context struct {
+	Date      string
+	portfolio *Portfolio
+	history   map[string][]*Bar
+	orders    []*Order
+	pending   []*Order
+	nextId    uint32
+}
+
+func (ctx *Context) Portfolio() *Portfolio {
+	return ctx.portfolio
+}
+
+func (ctx *Context) Position(code string) *Position {
+	return ctx.portfolio.Position(code)
+}
+
+/*
+	code截止到当前交易日最近n个交易日的数据(包含停牌日)，按日期升序，n<=0时返回全部
+*/
+func (ctx *Context) History(code string, n int) []*Bar {
+	bars := ctx.history[code]
+	if n > 0 && len(bars) > n {
+		return bars[len(bars)-n:]
+	}
+	return bars
+}
+
+// 买入count股，price为0时按下一个交易日开盘价成交
+func (ctx *Context) Buy(code string, count int64, price float64) *Order {
+	return ctx.submit(code, SideBuy, count, price)
+}
+
+func (ctx *Context) Sell(code string, count int64, price float64) *Order {
+	return ctx.submit(code, SideSell, count, price)
+}
+
+/*
+	按金额买入，以refPrice估算股数并取整手，refPrice一般为当天收盘价
+*/
+func (ctx *Context) BuyValue(code string, money, refPrice, price float64) *Order {
+	if refPrice <= 0 {
+		return nil
+	}
+	count := int64(money/refPrice) / lotSize * lotSize
+	if count <= 0 {
+		return nil
+	}
+	return ctx.Buy(code, count, price)
+}
+
+func (ctx *Context) submit(code string, side uint8, count int64, price float64) *Order {
+	if count <= 0 {
+		return nil
+	}
+	ctx.nextId++
+	order := &Order{
+		Id:    ctx.nextId,
+		Code:  code,
+		Side:  side,
+		Count: count,
+		Price: price,
+		Date:  ctx.Date,
+	}
+	ctx.orders = append(ctx.orders, order)
+	ctx.pending = append(ctx.pending, order)
+	return order
+}
+
+/*
+	从数据库加载行情并回测
+*/
+func Run(db *gorm.DB, config Config, strategy Strategy) (*Result, error) {
+	if len(config.Codes) == 0 {
+		return nil, errors.New("no stock code to backtest")
+	}
+	if s, ok := strategy.(WarmupStrategy); ok && s.Warmup() > config.Warmup {
+		config.Warmup = s.Warmup()
+	}
+	history, days, err := LoadBars(db, config.Codes, config.Begin, config.End, config.Warmup)
+	if err != nil {
+		return nil, err
+	}
+	return RunBars(config, history, days, strategy)
+}
+
+/*
+	按交易日顺序回测：先撮合前一天的委托，再按收盘价计算资产，最后调用策略OnBar
+*/
+func RunBars(config Config, history map[string][]*Bar, days []*Day, strategy Strategy) (*Result, error) {
+	if config.Cash <= 0 {
+		return nil, errors.New("initial cash must be positive")
+	}
+	if config.Broker == (BrokerConfig{}) {
+		config.Broker = DefaultBrokerConfig
+	}
+
+	b := &broker{config: config.Broker}
+	ctx := &Context{
+		portfolio: NewPortfolio(config.Cash),
+		history:   make(map[string][]*Bar),
+	}
+	for code, bars := range history {
+		ctx.history[code] = append([]*Bar(nil), bars...)
+	}
+
+	result := &Result{Config: config}
+	for _, day := range days {
+		ctx.Date = day.Date
+		ctx.portfolio.startDay()
+
+		pending := ctx.pending
+		ctx.pending = nil
+		for _, order := range pending {
+			fill := b.execute(order, day.Bars[order.Code], ctx.portfolio)
+			if fill == nil {
+				continue
+			}
+			ctx.portfolio.applyFill(fill)
+			result.Fills = append(result.Fills, fill)
+			strategy.OnFill(ctx, fill)
+		}
+
+		for code, bar := range day.Bars {
+			ctx.history[code] = append(ctx.history[code], bar)
+		}
+		ctx.portfolio.markToMarket(day)
+		result.Equity = append(result.Equity, &EquityPoint{
+			Date:        day.Date,
+			Cash:        ctx.portfolio.Cash,
+			MarketValue: ctx.portfolio.MarketValue(),
+			Total:       ctx.portfolio.Total(),
+		})
+
+		strategy.OnBar(ctx, day)
+	}
+
+	// 最后一天提交的委托没有机会成交
+	for _, order := range ctx.pending {
+		order.Status = OrderStatusRejected
+		order.Reason = "回测结束"
+	}
+
+	result.Orders = ctx.orders
+	result.Portfolio = ctx.portfolio
+	return result, nil
+}
@@ -0,0 +1,390 @@
+package backtest
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 参数网格，键为参数名，值为要遍历的取值
+type ParamGrid map[string][]float64
+
+// 一组参数
+type Params map[string]float64
+
+func (p Params) String() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+/*
+	网格中所有参数组合，按参数名排序后依次展开，结果顺序固定
+*/
+func (g ParamGrid) Combinations() []Params {
+	var names []string
+	for name := range g {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	combinations := []Params{{}}
+	for _, name := range names {
+		var next []Params
+		for _, base := range combinations {
+			for _, value := range g[name] {
+				params := make(Params)
+				for k, v := range base {
+					params[k] = v
+				}
+				params[name] = value
+				next = append(next, params)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// 根据参数创建策略，cash为单只股票回测的初始资金
+type StrategyFactory func(cash float64, params Params) (Strategy, error)
+
+type OptimizeConfig struct {
+	Strategy        string
+	Grid            ParamGrid
+	Codes           []string // 股票池，每只股票单独回测
+	Begin           string
+	End             string
+	Cash            float64
+	Warmup          int // 至少加载的历史交易日数，策略需要更多时按参数组合中最大的值
+	InSampleMonths  int // 为0时不做walk-forward，整个区间作为样本内
+	OutSampleMonths int // 样本外月数，也是窗口滚动的步长
+	Workers         int // 同时回测的股票数
+	RiskFree        float64
+	Broker          BrokerConfig
+	Progress        func(done, total int) // 每完成一只股票调用一次，可以为空
+}
+
+/*
+	walk-forward窗口，没有样本外区间时OutBegin、OutEnd为空
+*/
+type Window struct {
+	InBegin  string `json:"in_begin"`
+	InEnd    string `json:"in_end"`
+	OutBegin string `json:"out_begin"`
+	OutEnd   string `json:"out_end"`
+}
+
+// 一组参数在一个样本区间内所有股票的平均表现
+type SampleScore struct {
+	CodeCount    int     `json:"code_count"`
+	Return       float64 `json:"return"`
+	AnnualReturn float64 `json:"annual_return"`
+	Sharpe       float64 `json:"sharpe"`
+	MaxDrawdown  float64 `json:"max_drawdown"`
+	WinRate      float64 `json:"win_rate"` // 收益为正的股票占比
+	TradeCount   int     `json:"trade_count"`
+	Score        float64 `json:"score"` // 排名依据，取平均夏普比率
+}
+
+type ParamResult struct {
+	Window int         `json:"window"`
+	Params Params      `json:"params"`
+	Rank   int         `json:"rank"` // 窗口内按样本内得分的排名，从1开始
+	In     SampleScore `json:"in"`
+	Out    SampleScore `json:"out"`
+}
+
+type OptimizeResult struct {
+	Config  OptimizeConfig
+	Windows []Window
+	Results []*ParamResult // 按窗口、排名排序
+}
+
+// 每个窗口排名第一的参数
+func (r *OptimizeResult) Best() []*ParamResult {
+	var best []*ParamResult
+	for _, result := range r.Results {
+		if result.Rank == 1 {
+			best = append(best, result)
+		}
+	}
+	return best
+}
+
+/*
+	从begin开始按月切分窗口，样本内inMonths个月，紧接着样本外outMonths个月，
+	每次向后滚动outMonths个月，样本外超出end时结束
+*/
+func Windows(begin, end string, inMonths, outMonths int) ([]Window, error) {
+	const layout = "2006-01-02"
+	if inMonths == 0 {
+		return []Window{{InBegin: begin, InEnd: end}}, nil
+	}
+	if inMonths < 0 || outMonths <= 0 {
+		return nil, errors.New("invalid walk-forward months")
+	}
+	start, err := time.Parse(layout, begin)
+	if err != nil {
+		return nil, err
+	}
+
+	var windows []Window
+	for i := 0; ; i++ {
+		inBegin := start.AddDate(0, i*outMonths, 0)
+		outBegin := inBegin.AddDate(0, inMonths, 0)
+		outEnd := outBegin.AddDate(0, outMonths, -1).Format(layout)
+		if outEnd > end {
+			break
+		}
+		windows = append(windows, Window{
+			InBegin:  inBegin.Format(layout),
+			InEnd:    outBegin.AddDate(0, 0, -1).Format(layout),
+			OutBegin: outBegin.Format(layout),
+			OutEnd:   outEnd,
+		})
+	}
+	if len(windows) == 0 {
+		return nil, errors.New("date range is shorter than one walk-forward window")
+	}
+	return windows, nil
+}
+
+/*
+	从数据库加载股票池行情，遍历参数网格并做walk-forward
+*/
+func Optimize(db *gorm.DB, config OptimizeConfig, factory StrategyFactory) (*OptimizeResult, error) {
+	return OptimizeBars(config, func(code string, begin, end string, warmup int) (map[string][]*Bar, []*Day, error) {
+		return LoadBars(db, []string{code}, begin, end, warmup)
+	}, factory)
+}
+
+type scoreSum struct {
+	codes        int
+	wins         int
+	trades       int
+	totalReturn  float64
+	annualReturn float64
+	sharpe       float64
+	maxDrawdown  float64
+}
+
+func (s *scoreSum) add(m *Metrics) {
+	s.codes++
+	if m.TotalReturn > 0 {
+		s.wins++
+	}
+	s.trades += m.TradeCount
+	s.totalReturn += m.TotalReturn
+	s.annualReturn += m.AnnualReturn
+	s.sharpe += m.Sharpe
+	s.maxDrawdown += m.MaxDrawdown
+}
+
+func (s *scoreSum) score() SampleScore {
+	if s.codes == 0 {
+		return SampleScore{}
+	}
+	n := float64(s.codes)
+	return SampleScore{
+		CodeCount:    s.codes,
+		Return:       s.totalReturn / n,
+		AnnualReturn: s.annualReturn / n,
+		Sharpe:       s.sharpe / n,
+		MaxDrawdown:  s.maxDrawdown / n,
+		WinRate:      float64(s.wins) / n,
+		TradeCount:   s.trades,
+		Score:        s.sharpe / n,
+	}
+}
+
+/*
+	load返回单只股票[begin, end]内的行情(包含begin之前warmup个交易日)。
+	每只股票用每组参数在每个窗口的样本内、样本外分别回测一次，最多同时处理Workers只股票
+*/
+func OptimizeBars(config OptimizeConfig, load func(code, begin, end string, warmup int) (map[string][]*Bar, []*Day, error), factory StrategyFactory) (*OptimizeResult, error) {
+	if config.Cash <= 0 {
+		return nil, errors.New("initial cash must be positive")
+	}
+	combinations := config.Grid.Combinations()
+	for _, params := range combinations {
+		strategy, err := factory(config.Cash, params)
+		if err != nil {
+			return nil, err
+		}
+		// 历史不够时指标按截断的行情计算，结果没有意义
+		if s, ok := strategy.(WarmupStrategy); ok && s.Warmup() > config.Warmup {
+			config.Warmup = s.Warmup()
+		}
+	}
+	windows, err := Windows(config.Begin, config.End, config.InSampleMonths, config.OutSampleMonths)
+	if err != nil {
+		return nil, err
+	}
+	workers := config.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	last := windows[len(windows)-1]
+	end := last.InEnd
+	if last.OutEnd != "" {
+		end = last.OutEnd
+	}
+
+	// sums[窗口][参数组合][0样本内/1样本外]
+	sums := make([][][2]scoreSum, len(windows))
+	for i := range sums {
+		sums[i] = make([][2]scoreSum, len(combinations))
+	}
+
+	var (
+		lock     sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		done     int
+	)
+	codes := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for code := range codes {
+				lock.Lock()
+				failed := firstErr != nil
+				lock.Unlock()
+				if failed {
+					continue
+				}
+
+				history, days, err := load(code, config.Begin, end, config.Warmup)
+				var metrics [][][2]*Metrics
+				if err == nil {
+					metrics, err = optimizeCode(config, code, history[code], days, windows, combinations, factory)
+				}
+
+				lock.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					for w := range metrics {
+						for c := range metrics[w] {
+							for sample, m := range metrics[w][c] {
+								if m != nil {
+									sums[w][c][sample].add(m)
+								}
+							}
+						}
+					}
+				}
+				done++
+				if config.Progress != nil {
+					config.Progress(done, len(config.Codes))
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, code := range config.Codes {
+		codes <- code
+	}
+	close(codes)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	result := &OptimizeResult{Config: config, Windows: windows}
+	for w := range windows {
+		var ranked []*ParamResult
+		for c, params := range combinations {
+			if sums[w][c][0].codes == 0 {
+				continue
+			}
+			ranked = append(ranked, &ParamResult{
+				Window: w,
+				Params: params,
+				In:     sums[w][c][0].score(),
+				Out:    sums[w][c][1].score(),
+			})
+		}
+		sort.SliceStable(ranked, func(i, j int) bool {
+			if ranked[i].In.Score != ranked[j].In.Score {
+				return ranked[i].In.Score > ranked[j].In.Score
+			}
+			return ranked[i].In.Return > ranked[j].In.Return
+		})
+		for i, r := range ranked {
+			r.Rank = i + 1
+		}
+		result.Results = append(result.Results, ranked...)
+	}
+	return result, nil
+}
+
+/*
+	单只股票在各窗口、各参数下的回测指标，区间内没有行情的样本为nil
+*/
+func optimizeCode(config OptimizeConfig, code string, warmup []*Bar, days []*Day, windows []Window, combinations []Params, factory StrategyFactory) ([][][2]*Metrics, error) {
+	run := func(params Params, begin, end string) (*Metrics, error) {
+		if begin == "" {
+			return nil, nil
+		}
+		from := sort.Search(len(days), func(i int) bool { return days[i].Date >= begin })
+		to := sort.Search(len(days), func(i int) bool { return days[i].Date > end })
+		if from >= to {
+			return nil, nil
+		}
+
+		// 区间之前的行情都作为历史，只保留最近warmup个交易日
+		history := append([]*Bar(nil), warmup...)
+		for _, day := range days[:from] {
+			if bar, ok := day.Bars[code]; ok {
+				history = append(history, bar)
+			}
+		}
+		if len(history) > config.Warmup {
+			history = history[len(history)-config.Warmup:]
+		}
+
+		strategy, err := factory(config.Cash, params)
+		if err != nil {
+			return nil, err
+		}
+		btConfig := Config{
+			Codes:  []string{code},
+			Begin:  begin,
+			End:    end,
+			Cash:   config.Cash,
+			Warmup: config.Warmup,
+			Broker: config.Broker,
+		}
+		result, err := RunBars(btConfig, map[string][]*Bar{code: history}, days[from:to], strategy)
+		if err != nil {
+			return nil, err
+		}
+		report := BuildReport(config.Cash, result.Equity, TradesFromFills(result.Fills), nil, config.RiskFree)
+		return &report.Metrics, nil
+	}
+
+	metrics := make([][][2]*Metrics, len(windows))
+	for w, window := range windows {
+		metrics[w] = make([][2]*Metrics, len(combinations))
+		for c, params := range combinations {
+			in, err := run(params, window.InBegin, window.InEnd)
+			if err != nil {
+				return nil, err
+			}
+			out, err := run(params, window.OutBegin, window.OutEnd)
+			if err != nil {
+				return nil, err
+			}
+			metrics[w][c] = [2]*Metrics{in, out}
+		}
+	}
+	return metrics, nil
+}
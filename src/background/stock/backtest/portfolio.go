@@ -0,0 +1,107 @@
+package backtest
+
+import (
+	"sort"
+)
+
+/*
+	单只股票持仓，Available为当天可卖数量(T+1，当天买入的不可卖)
+*/
+type Position struct {
+	Code      string
+	Count     int64
+	Available int64
+	Cost      float64 // 持仓成本，包含买入手续费，卖出时按均价扣减
+	LastPrice float64 // 最近一个未停牌交易日的收盘价
+}
+
+func (p *Position) AvgPrice() float64 {
+	if p.Count == 0 {
+		return 0
+	}
+	return p.Cost / float64(p.Count)
+}
+
+func (p *Position) MarketValue() float64 {
+	return p.LastPrice * float64(p.Count)
+}
+
+/*
+	组合，所有股票共用一份资金
+*/
+type Portfolio struct {
+	Cash      float64
+	Positions map[string]*Position
+}
+
+func NewPortfolio(cash float64) *Portfolio {
+	return &Portfolio{Cash: cash, Positions: make(map[string]*Position)}
+}
+
+func (p *Portfolio) Position(code string) *Position {
+	return p.Positions[code]
+}
+
+func (p *Portfolio) MarketValue() float64 {
+	var value float64
+	for _, pos := range p.Positions {
+		value += pos.MarketValue()
+	}
+	return value
+}
+
+func (p *Portfolio) Total() float64 {
+	return p.Cash + p.MarketValue()
+}
+
+// 按代码排序的持仓
+func (p *Portfolio) SortedPositions() []*Position {
+	var positions []*Position
+	for _, pos := range p.Positions {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Code < positions[j].Code })
+	return positions
+}
+
+// 新交易日开始，前一天买入的股票变为可卖
+func (p *Portfolio) startDay() {
+	for _, pos := range p.Positions {
+		pos.Available = pos.Count
+	}
+}
+
+// 用当天收盘价更新持仓市值，停牌的沿用之前的价格
+func (p *Portfolio) markToMarket(day *Day) {
+	for code, pos := range p.Positions {
+		if bar, ok := day.Bars[code]; ok && !bar.Suspended() {
+			pos.LastPrice = bar.Close
+		}
+	}
+}
+
+func (p *Portfolio) applyFill(fill *Fill) {
+	pos, ok := p.Positions[fill.Code]
+	if !ok {
+		pos = &Position{Code: fill.Code}
+		p.Positions[fill.Code] = pos
+	}
+
+	amount := fill.Price * float64(fill.Count)
+	if fill.Side == SideBuy {
+		p.Cash -= amount + fill.Fee()
+		pos.Count += fill.Count
+		pos.Cost += amount + fill.Fee()
+	} else {
+		cost := pos.AvgPrice() * float64(fill.Count)
+		p.Cash += amount - fill.Fee()
+		fill.Profit = amount - fill.Fee() - cost
+		pos.Count -= fill.Count
+		pos.Available -= fill.Count
+		pos.Cost -= cost
+		if pos.Count == 0 {
+			delete(p.Positions, fill.Code)
+		}
+	}
+	pos.LastPrice = fill.Price
+}
@@ -0,0 +1,317 @@
+package backtest
+
+import (
+	"math"
+	"sort"
+)
+
+const tradingDaysPerYear = 242 // A股每年交易日数，用于年化
+
+/*
+	成交记录，HoldDays为卖出股票按先进先出计算的平均持有交易日数
+*/
+type Trade struct {
+	Date     string  `json:"date"`
+	Code     string  `json:"code"`
+	Side     uint8   `json:"side"`
+	Count    int64   `json:"count"`
+	Price    float64 `json:"price"`
+	Amount   float64 `json:"amount"`
+	Fee      float64 `json:"fee"`
+	Profit   float64 `json:"profit"`
+	HoldDays float64 `json:"hold_days"`
+}
+
+type ReportEquity struct {
+	Date        string  `json:"date"`
+	Cash        float64 `json:"cash"`
+	MarketValue float64 `json:"market_value"`
+	Total       float64 `json:"total"`
+	Drawdown    float64 `json:"drawdown"`  // 相对之前最高点的回撤
+	Benchmark   float64 `json:"benchmark"` // 同样资金买入基准指数的市值，没有基准时为0
+}
+
+type Metrics struct {
+	TotalReturn         float64 `json:"total_return"`
+	AnnualReturn        float64 `json:"annual_return"`
+	AnnualVolatility    float64 `json:"annual_volatility"`
+	Sharpe              float64 `json:"sharpe"`
+	Sortino             float64 `json:"sortino"`
+	MaxDrawdown         float64 `json:"max_drawdown"`
+	MaxDrawdownPeak     string  `json:"max_drawdown_peak"`     // 最大回撤开始的最高点日期
+	MaxDrawdownTrough   string  `json:"max_drawdown_trough"`   // 最大回撤的最低点日期
+	MaxDrawdownDuration int     `json:"max_drawdown_duration"` // 最长的低于前高的交易日数
+	TradeCount          int     `json:"trade_count"`
+	WinRate             float64 `json:"win_rate"` // 盈利的卖出笔数占比
+	AvgHoldDays         float64 `json:"avg_hold_days"`
+
+	Benchmark             string  `json:"benchmark"`
+	BenchmarkMissing      string  `json:"benchmark_missing,omitempty"` // 设置了基准但没有行情时的说明
+	BenchmarkReturn       float64 `json:"benchmark_return"`
+	BenchmarkAnnualReturn float64 `json:"benchmark_annual_return"`
+	ExcessReturn          float64 `json:"excess_return"` // 年化收益减基准年化收益
+	Alpha                 float64 `json:"alpha"`
+	Beta                  float64 `json:"beta"`
+}
+
+type Report struct {
+	Metrics Metrics         `json:"metrics"`
+	Equity  []*ReportEquity `json:"equity"`
+	Trades  []*Trade        `json:"trades"`
+}
+
+/*
+	根据每日资产和成交记录计算绩效指标。
+	initialCash为初始资金，benchmark为基准指数日线(可以为空)，riskFree为年化无风险利率
+*/
+func BuildReport(initialCash float64, equity []*EquityPoint, trades []*Trade, benchmark []*Bar, riskFree float64) *Report {
+	report := &Report{Trades: trades}
+	if len(equity) == 0 || initialCash <= 0 {
+		return report
+	}
+
+	dayIndex := make(map[string]int)
+	for i, point := range equity {
+		dayIndex[point.Date] = i
+	}
+	benchmarkValues := alignBenchmark(initialCash, equity, benchmark)
+
+	var peak float64 = initialCash
+	var peakDate string
+	var underwater int
+	m := &report.Metrics
+	for i, point := range equity {
+		if point.Total >= peak {
+			peak = point.Total
+			peakDate = point.Date
+			underwater = 0
+		} else {
+			underwater++
+		}
+		if underwater > m.MaxDrawdownDuration {
+			m.MaxDrawdownDuration = underwater
+		}
+
+		drawdown := 1 - point.Total/peak
+		if drawdown > m.MaxDrawdown {
+			m.MaxDrawdown = drawdown
+			m.MaxDrawdownPeak = peakDate
+			m.MaxDrawdownTrough = point.Date
+		}
+		report.Equity = append(report.Equity, &ReportEquity{
+			Date:        point.Date,
+			Cash:        point.Cash,
+			MarketValue: point.MarketValue,
+			Total:       point.Total,
+			Drawdown:    drawdown,
+			Benchmark:   benchmarkValues[i],
+		})
+	}
+
+	returns := dailyReturns(initialCash, equity, func(p *EquityPoint) float64 { return p.Total })
+	m.TotalReturn = equity[len(equity)-1].Total/initialCash - 1
+	m.AnnualReturn = annualize(m.TotalReturn, len(equity))
+	dailyRiskFree := riskFree / tradingDaysPerYear
+	mean, std := meanStd(returns)
+	m.AnnualVolatility = std * math.Sqrt(tradingDaysPerYear)
+	if std > 0 {
+		m.Sharpe = (mean - dailyRiskFree) / std * math.Sqrt(tradingDaysPerYear)
+	}
+	var downside float64
+	for _, r := range returns {
+		if d := r - dailyRiskFree; d < 0 {
+			downside += d * d
+		}
+	}
+	if downside > 0 {
+		downside = math.Sqrt(downside / float64(len(returns)))
+		m.Sortino = (mean - dailyRiskFree) / downside * math.Sqrt(tradingDaysPerYear)
+	}
+
+	fillHoldDays(trades, dayIndex)
+	var sells, wins int
+	var holdDays float64
+	for _, trade := range trades {
+		if trade.Side != SideSell {
+			continue
+		}
+		sells++
+		if trade.Profit > 0 {
+			wins++
+		}
+		holdDays += trade.HoldDays
+	}
+	m.TradeCount = len(trades)
+	if sells > 0 {
+		m.WinRate = float64(wins) / float64(sells)
+		m.AvgHoldDays = holdDays / float64(sells)
+	}
+
+	if len(benchmark) > 0 && benchmarkValues[len(benchmarkValues)-1] > 0 {
+		m.Benchmark = benchmark[0].Code
+		m.BenchmarkReturn = benchmarkValues[len(benchmarkValues)-1]/initialCash - 1
+		m.BenchmarkAnnualReturn = annualize(m.BenchmarkReturn, len(equity))
+		m.ExcessReturn = m.AnnualReturn - m.BenchmarkAnnualReturn
+
+		benchmarkReturns := make([]float64, len(equity))
+		for i := range equity {
+			prev := initialCash
+			if i > 0 {
+				prev = benchmarkValues[i-1]
+			}
+			if prev > 0 {
+				benchmarkReturns[i] = benchmarkValues[i]/prev - 1
+			}
+		}
+		benchmarkMean, benchmarkStd := meanStd(benchmarkReturns)
+		if benchmarkStd > 0 {
+			m.Beta = covariance(returns, benchmarkReturns, mean, benchmarkMean) / (benchmarkStd * benchmarkStd)
+		}
+		m.Alpha = (mean - dailyRiskFree - m.Beta*(benchmarkMean-dailyRiskFree)) * tradingDaysPerYear
+	}
+	return report
+}
+
+/*
+	按回测日期对齐基准指数，第一天以收盘价买入initialCash的基准，
+	基准停牌或缺少数据的日期沿用前一天的值
+*/
+func alignBenchmark(initialCash float64, equity []*EquityPoint, benchmark []*Bar) []float64 {
+	values := make([]float64, len(equity))
+	if len(benchmark) == 0 {
+		return values
+	}
+
+	closes := make(map[string]float64)
+	for _, bar := range benchmark {
+		if !bar.Suspended() {
+			closes[bar.Date] = bar.Close
+		}
+	}
+	var base, last float64
+	for i, point := range equity {
+		if price, ok := closes[point.Date]; ok {
+			if base == 0 {
+				base = price
+			}
+			last = price
+		}
+		if base > 0 {
+			values[i] = initialCash * last / base
+		} else {
+			values[i] = initialCash
+		}
+	}
+	return values
+}
+
+func dailyReturns(initialCash float64, equity []*EquityPoint, value func(*EquityPoint) float64) []float64 {
+	returns := make([]float64, len(equity))
+	prev := initialCash
+	for i, point := range equity {
+		if prev > 0 {
+			returns[i] = value(point)/prev - 1
+		}
+		prev = value(point)
+	}
+	return returns
+}
+
+func annualize(totalReturn float64, days int) float64 {
+	if days == 0 || totalReturn <= -1 {
+		return totalReturn
+	}
+	return math.Pow(1+totalReturn, float64(tradingDaysPerYear)/float64(days)) - 1
+}
+
+// 均值和样本标准差
+func meanStd(values []float64) (mean, std float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	for _, v := range values {
+		std += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(values)-1))
+}
+
+func covariance(a, b []float64, meanA, meanB float64) float64 {
+	if len(a) < 2 || len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += (a[i] - meanA) * (b[i] - meanB)
+	}
+	return sum / float64(len(a)-1)
+}
+
+/*
+	按先进先出匹配买入和卖出，计算每笔卖出的平均持有交易日数
+*/
+func fillHoldDays(trades []*Trade, dayIndex map[string]int) {
+	type lot struct {
+		day   int
+		count int64
+	}
+	lots := make(map[string][]*lot)
+
+	sorted := make([]*Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date < sorted[j].Date })
+
+	for _, trade := range sorted {
+		day := dayIndex[trade.Date]
+		if trade.Side == SideBuy {
+			lots[trade.Code] = append(lots[trade.Code], &lot{day, trade.Count})
+			continue
+		}
+
+		var matched int64
+		var days float64
+		queue := lots[trade.Code]
+		for matched < trade.Count && len(queue) > 0 {
+			n := trade.Count - matched
+			if queue[0].count < n {
+				n = queue[0].count
+			}
+			days += float64(n) * float64(day-queue[0].day)
+			matched += n
+			queue[0].count -= n
+			if queue[0].count == 0 {
+				queue = queue[1:]
+			}
+		}
+		lots[trade.Code] = queue
+		if matched > 0 {
+			trade.HoldDays = days / float64(matched)
+		}
+	}
+}
+
+/*
+	由回测结果生成成交记录
+*/
+func TradesFromFills(fills []*Fill) []*Trade {
+	var trades []*Trade
+	for _, fill := range fills {
+		trades = append(trades, &Trade{
+			Date:   fill.Date,
+			Code:   fill.Code,
+			Side:   fill.Side,
+			Count:  fill.Count,
+			Price:  fill.Price,
+			Amount: fill.Price * float64(fill.Count),
+			Fee:    fill.Fee(),
+			Profit: fill.Profit,
+		})
+	}
+	return trades
+}
@@ -0,0 +1,256 @@
+package backtest
+
+import (
+	"background/stock/model"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	保存回测结果，新建一条simulation记录，成交、结束持仓和每日资产通过simulation_id关联。
+	params为策略参数，以json保存在simulation.value中
+*/
+func Save(db *gorm.DB, name string, params interface{}, result *Result) (*model.Simulation, error) {
+	value, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var simulation model.Simulation
+	simulation.Value = string(value)
+	simulation.Strategy = name
+	simulation.Codes = strings.Join(result.Config.Codes, ",")
+	simulation.BeginDate = result.Config.Begin
+	simulation.EndDate = result.Config.End
+	simulation.Benchmark = result.Config.Benchmark
+	simulation.InitialCash = result.Config.Cash
+	simulation.FinalEquity = result.Config.Cash
+	if len(result.Equity) > 0 {
+		simulation.FinalEquity = result.Equity[len(result.Equity)-1].Total
+	}
+	simulation.ReturnRate = simulation.FinalEquity/simulation.InitialCash - 1
+
+	tx := db.Begin()
+	if err := saveResult(tx, &simulation, result); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &simulation, nil
+}
+
+func saveResult(tx *gorm.DB, simulation *model.Simulation, result *Result) error {
+	if err := tx.Create(simulation).Error; err != nil {
+		return err
+	}
+
+	fees := make(map[string]float64)
+	for _, fill := range result.Fills {
+		var trans model.TransStockInfo
+		trans.Code = fill.Code
+		trans.Price = fill.Price
+		trans.Count = fill.Count
+		trans.Date = fill.Date
+		trans.Fee = fill.Fee()
+		trans.Cost = fill.Price * float64(fill.Count)
+		trans.Profit = fill.Profit
+		trans.TransType = "buy"
+		if fill.Side == SideSell {
+			trans.TransType = "sell"
+		}
+		trans.SimulationId = simulation.Id
+		if err := tx.Create(&trans).Error; err != nil {
+			return err
+		}
+		fees[fill.Code] += fill.Fee()
+	}
+
+	var lastDate string
+	if len(result.Equity) > 0 {
+		lastDate = result.Equity[len(result.Equity)-1].Date
+	}
+	// 成本已包含买入手续费，fee只作记录
+	for _, pos := range result.Portfolio.SortedPositions() {
+		var hold model.HoldStockInfo
+		hold.Code = pos.Code
+		hold.Price = pos.LastPrice
+		hold.Date = lastDate
+		hold.AvgPrice = pos.AvgPrice()
+		hold.AllCount = pos.Count
+		hold.HoldMoney = pos.MarketValue()
+		hold.Fee = fees[pos.Code]
+		hold.Cost = pos.Cost
+		hold.FloatProfit = hold.HoldMoney - hold.Cost
+		if hold.HoldMoney > 0 {
+			hold.FloatProfitRate = hold.FloatProfit / hold.HoldMoney * 100
+		}
+		hold.TransType = "total"
+		hold.SimulationId = simulation.Id
+		if err := tx.Create(&hold).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, point := range result.Equity {
+		var equity model.SimulationEquity
+		equity.SimulationId = simulation.Id
+		equity.Date = point.Date
+		equity.Cash = point.Cash
+		equity.MarketValue = point.MarketValue
+		equity.Total = point.Total
+		if err := tx.Create(&equity).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+	从数据库加载回测结果生成报告，benchmark为空时使用回测时设置的基准。
+	基准从指数日线表加载，没有导入的指数在报告中说明缺少数据
+*/
+func LoadReport(db *gorm.DB, simulationId uint32, benchmark string, riskFree float64) (*model.Simulation, *Report, error) {
+	var simulation model.Simulation
+	if err := db.Where("id = ?", simulationId).First(&simulation).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var rows []model.SimulationEquity
+	if err := db.Where("simulation_id = ?", simulationId).Order("date asc").Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	var equity []*EquityPoint
+	for _, row := range rows {
+		equity = append(equity, &EquityPoint{Date: row.Date, Cash: row.Cash, MarketValue: row.MarketValue, Total: row.Total})
+	}
+
+	var transList []model.TransStockInfo
+	if err := db.Where("simulation_id = ?", simulationId).Order("id asc").Find(&transList).Error; err != nil {
+		return nil, nil, err
+	}
+	var trades []*Trade
+	for _, trans := range transList {
+		trade := &Trade{
+			Date:   trans.Date,
+			Code:   trans.Code,
+			Side:   SideBuy,
+			Count:  trans.Count,
+			Price:  trans.Price,
+			Amount: trans.Cost,
+			Fee:    trans.Fee,
+			Profit: trans.Profit,
+		}
+		if trans.TransType == "sell" {
+			trade.Side = SideSell
+		}
+		trades = append(trades, trade)
+	}
+
+	if benchmark == "" {
+		benchmark = simulation.Benchmark
+	}
+	var benchmarkBars []*Bar
+	if benchmark != "" && len(equity) > 0 {
+		bars, err := LoadIndexBars(db, benchmark, equity[0].Date, equity[len(equity)-1].Date)
+		if err != nil {
+			return nil, nil, err
+		}
+		benchmarkBars = bars
+	}
+
+	report := BuildReport(simulation.InitialCash, equity, trades, benchmarkBars, riskFree)
+	if benchmark != "" && report.Metrics.Benchmark == "" {
+		report.Metrics.Benchmark = benchmark
+		report.Metrics.BenchmarkMissing = fmt.Sprintf("指数%s在回测区间没有日线数据，需要先用tools/importindex.go导入", benchmark)
+	}
+	return &simulation, report, nil
+}
+
+/*
+	新建一条运行中的参数优化记录，结果通过FinishOptimization保存
+*/
+func StartOptimization(db *gorm.DB, config OptimizeConfig) (*model.Optimization, error) {
+	grid, err := json.Marshal(config.Grid)
+	if err != nil {
+		return nil, err
+	}
+
+	var optimization model.Optimization
+	optimization.Strategy = config.Strategy
+	optimization.Grid = string(grid)
+	optimization.CodeCount = len(config.Codes)
+	optimization.BeginDate = config.Begin
+	optimization.EndDate = config.End
+	optimization.InSampleMonths = config.InSampleMonths
+	optimization.OutSampleMonths = config.OutSampleMonths
+	optimization.Status = model.OptimizationStatusRunning
+	if err := db.Create(&optimization).Error; err != nil {
+		return nil, err
+	}
+	return &optimization, nil
+}
+
+/*
+	保存参数优化结果，runErr不为空时只把记录标记为失败
+*/
+func FinishOptimization(db *gorm.DB, optimization *model.Optimization, result *OptimizeResult, runErr error) error {
+	if runErr != nil {
+		optimization.Status = model.OptimizationStatusFailed
+		return db.Save(optimization).Error
+	}
+
+	best := result.Best()
+	if len(best) > 0 {
+		for _, r := range best {
+			optimization.InSampleScore += r.In.Score
+			optimization.OutSampleScore += r.Out.Score
+		}
+		optimization.InSampleScore /= float64(len(best))
+		optimization.OutSampleScore /= float64(len(best))
+		optimization.BestParams = best[len(best)-1].Params.String()
+	}
+	optimization.Status = model.OptimizationStatusFinished
+
+	tx := db.Begin()
+	for _, r := range result.Results {
+		window := result.Windows[r.Window]
+		var row model.OptimizationResult
+		row.OptimizationId = optimization.Id
+		row.Window = r.Window
+		row.InSampleBegin = window.InBegin
+		row.InSampleEnd = window.InEnd
+		row.OutSampleBegin = window.OutBegin
+		row.OutSampleEnd = window.OutEnd
+		row.Params = r.Params.String()
+		row.Rank = r.Rank
+		row.CodeCount = r.In.CodeCount
+		row.Return = r.In.Return
+		row.AnnualReturn = r.In.AnnualReturn
+		row.Sharpe = r.In.Sharpe
+		row.MaxDrawdown = r.In.MaxDrawdown
+		row.WinRate = r.In.WinRate
+		row.TradeCount = r.In.TradeCount
+		row.Score = r.In.Score
+		row.OutReturn = r.Out.Return
+		row.OutAnnualReturn = r.Out.AnnualReturn
+		row.OutSharpe = r.Out.Sharpe
+		row.OutMaxDrawdown = r.Out.MaxDrawdown
+		row.OutWinRate = r.Out.WinRate
+		row.OutTradeCount = r.Out.TradeCount
+		row.OutScore = r.Out.Score
+		if err := tx.Create(&row).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Save(optimization).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,226 @@
+package calendar
+
+import (
+	"background/common/logger"
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// 交易时段
+type Phase int
+
+const (
+	PhaseClosed     Phase = iota // 非交易日，或交易日9:15之前
+	PhaseAuction                 // 9:15-9:30 开盘集合竞价
+	PhaseMorning                 // 9:30-11:30
+	PhaseLunchBreak              // 11:30-13:00
+	PhaseAfternoon               // 13:00-15:00
+	PhaseAfterClose              // 交易日15:00之后
+)
+
+// 时段开始的时间，按一天中的分钟数
+const (
+	auctionMinute   = 9*60 + 15
+	openMinute      = 9*60 + 30
+	lunchMinute     = 11*60 + 30
+	afternoonMinute = 13 * 60
+	closeMinute     = 15 * 60
+)
+
+/*
+	A股交易日历，周六、周日和节假日文件中的日期休市。
+	节假日文件每行一个日期，#开头为注释
+*/
+type Calendar struct {
+	location *time.Location
+	holidays map[string]bool
+	lastYear int // 节假日文件覆盖的最后一年
+	warned   sync.Once
+}
+
+func New(holidays []string) (*Calendar, error) {
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		location = time.FixedZone("CST", 8*3600)
+	}
+	c := &Calendar{location: location, holidays: make(map[string]bool)}
+	for _, date := range holidays {
+		t, err := time.Parse(dateLayout, date)
+		if err != nil {
+			return nil, errors.New("invalid holiday " + date)
+		}
+		c.holidays[date] = true
+		if t.Year() > c.lastYear {
+			c.lastYear = t.Year()
+		}
+	}
+	return c, nil
+}
+
+func Load(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var holidays []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		holidays = append(holidays, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return New(holidays)
+}
+
+var defaultCalendar, _ = New(nil)
+
+/*
+	加载默认日历，没有加载时只按周末判断休市
+*/
+func Init(path string) error {
+	c, err := Load(path)
+	if err != nil {
+		return err
+	}
+	defaultCalendar = c
+	return nil
+}
+
+func Default() *Calendar {
+	return defaultCalendar
+}
+
+func (c *Calendar) In(t time.Time) time.Time {
+	return t.In(c.location)
+}
+
+// 交易所所在时区(Asia/Shanghai)，行情和K线的时间按此时区
+func (c *Calendar) Location() *time.Location {
+	return c.location
+}
+
+// 节假日文件是否包含year年的数据
+func (c *Calendar) Covers(year int) bool {
+	return year <= c.lastYear
+}
+
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	t = c.In(t)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	if t.Year() > c.lastYear {
+		c.warned.Do(func() {
+			logger.Error("节假日文件没有", t.Year(), "年的数据，只按周末判断休市")
+		})
+	}
+	return !c.holidays[t.Format(dateLayout)]
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+func (c *Calendar) Phase(t time.Time) Phase {
+	t = c.In(t)
+	if !c.IsTradingDay(t) {
+		return PhaseClosed
+	}
+	switch m := minuteOfDay(t); {
+	case m < auctionMinute:
+		return PhaseClosed
+	case m < openMinute:
+		return PhaseAuction
+	case m < lunchMinute:
+		return PhaseMorning
+	case m < afternoonMinute:
+		return PhaseLunchBreak
+	case m < closeMinute:
+		return PhaseAfternoon
+	}
+	return PhaseAfterClose
+}
+
+// 是否处于连续竞价时段
+func (c *Calendar) IsTradingTime(t time.Time) bool {
+	phase := c.Phase(t)
+	return phase == PhaseMorning || phase == PhaseAfternoon
+}
+
+/*
+	当天连续竞价已进行的分钟数，上午、下午各120分钟，非交易日为0
+*/
+func (c *Calendar) TradedMinutes(t time.Time) float64 {
+	t = c.In(t)
+	if !c.IsTradingDay(t) {
+		return 0
+	}
+	minutes := float64(minuteOfDay(t)) + float64(t.Second())/60
+	switch {
+	case minutes < openMinute:
+		return 0
+	case minutes < lunchMinute:
+		return minutes - openMinute
+	case minutes < afternoonMinute:
+		return 120
+	case minutes < closeMinute:
+		return 120 + minutes - afternoonMinute
+	}
+	return 240
+}
+
+// t之后(不含t当天)的第一个交易日
+func (c *Calendar) NextTradingDay(t time.Time) time.Time {
+	t = c.In(t)
+	for {
+		t = t.AddDate(0, 0, 1)
+		if c.IsTradingDay(t) {
+			return t
+		}
+	}
+}
+
+// t之前(不含t当天)的最后一个交易日
+func (c *Calendar) PrevTradingDay(t time.Time) time.Time {
+	t = c.In(t)
+	for {
+		t = t.AddDate(0, 0, -1)
+		if c.IsTradingDay(t) {
+			return t
+		}
+	}
+}
+
+/*
+	[begin, end]内的交易日，日期格式为2006-01-02
+*/
+func (c *Calendar) TradingDays(begin, end string) ([]string, error) {
+	from, err := time.ParseInLocation(dateLayout, begin, c.location)
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.ParseInLocation(dateLayout, end, c.location)
+	if err != nil {
+		return nil, err
+	}
+	var days []string
+	for t := from; !t.After(to); t = t.AddDate(0, 0, 1) {
+		if c.IsTradingDay(t) {
+			days = append(days, t.Format(dateLayout))
+		}
+	}
+	return days, nil
+}
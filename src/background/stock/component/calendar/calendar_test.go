@@ -0,0 +1,32 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHolidayFile(t *testing.T) {
+	c, err := Load("../../config/holiday.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for year := 2018; year <= 2026; year++ {
+		if !c.Covers(year) {
+			t.Errorf("holiday file has no data for %d", year)
+		}
+	}
+
+	for date, trading := range map[string]bool{
+		"2022-10-07": false, // 国庆
+		"2024-02-09": false, // 春节
+		"2025-06-02": false, // 端午
+		"2026-02-23": false, // 春节
+		"2026-02-24": true,
+		"2026-10-17": false, // 周六
+	} {
+		day, _ := time.ParseInLocation(dateLayout, date, c.Location())
+		if c.IsTradingDay(day) != trading {
+			t.Errorf("%s trading day %v, want %v", date, !trading, trading)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package calendar
+
+import (
+	"background/common/logger"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 交易日内的时段事件
+type Event int
+
+const (
+	EventPreOpen       Event = iota // 9:15 开盘集合竞价开始
+	EventOpen                       // 9:30 连续竞价开始
+	EventLunchBreak                 // 11:30 午间休市
+	EventAfternoonOpen              // 13:00 下午开盘
+	EventClose                      // 15:00 收盘
+	EventAfterClose                 // 15:30 收盘后，行情数据已稳定
+)
+
+var eventMinutes = map[Event]int{
+	EventPreOpen:       auctionMinute,
+	EventOpen:          openMinute,
+	EventLunchBreak:    lunchMinute,
+	EventAfternoonOpen: afternoonMinute,
+	EventClose:         closeMinute,
+	EventAfterClose:    closeMinute + 30,
+}
+
+type job struct {
+	name    string
+	run     func()
+	running bool
+}
+
+type intervalJob struct {
+	job
+	interval time.Duration
+}
+
+/*
+	按交易时段调度任务：On注册的任务在每个交易日的时段事件触发，
+	Every注册的任务只在连续竞价时段内按间隔执行。同一个任务上一次没有结束时跳过本次
+*/
+type Scheduler struct {
+	calendar *Calendar
+	lock     sync.Mutex
+	events   map[Event][]*job
+	every    []*intervalJob
+}
+
+func NewScheduler(calendar *Calendar) *Scheduler {
+	return &Scheduler{calendar: calendar, events: make(map[Event][]*job)}
+}
+
+func (s *Scheduler) On(event Event, name string, run func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.events[event] = append(s.events[event], &job{name: name, run: run})
+}
+
+func (s *Scheduler) Every(interval time.Duration, name string, run func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.every = append(s.every, &intervalJob{job: job{name: name, run: run}, interval: interval})
+}
+
+func (s *Scheduler) start(j *job) {
+	s.lock.Lock()
+	if j.running {
+		s.lock.Unlock()
+		logger.Debug("任务", j.name, "上次还没有结束，跳过")
+		return
+	}
+	j.running = true
+	s.lock.Unlock()
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Error("任务", j.name, "异常:", err)
+			}
+			s.lock.Lock()
+			j.running = false
+			s.lock.Unlock()
+		}()
+		j.run()
+	}()
+}
+
+/*
+	now之后最近的时段事件，now所在交易日的事件都已过去时从下一个交易日找
+*/
+func (s *Scheduler) next(now time.Time) (time.Time, Event) {
+	var events []Event
+	for event := range eventMinutes {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool { return eventMinutes[events[i]] < eventMinutes[events[j]] })
+
+	now = s.calendar.In(now)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if !s.calendar.IsTradingDay(day) {
+		day = s.calendar.NextTradingDay(day)
+	}
+	for {
+		for _, event := range events {
+			at := day.Add(time.Minute * time.Duration(eventMinutes[event]))
+			if at.After(now) {
+				return at, event
+			}
+		}
+		day = s.calendar.NextTradingDay(day)
+	}
+}
+
+/*
+	阻塞运行
+*/
+func (s *Scheduler) Run() {
+	s.lock.Lock()
+	every := s.every
+	s.lock.Unlock()
+	for _, j := range every {
+		go func(j *intervalJob) {
+			for {
+				if s.calendar.IsTradingTime(time.Now()) {
+					s.start(&j.job)
+				}
+				time.Sleep(j.interval)
+			}
+		}(j)
+	}
+
+	for {
+		at, event := s.next(time.Now())
+		time.Sleep(time.Until(at))
+
+		s.lock.Lock()
+		jobs := s.events[event]
+		s.lock.Unlock()
+		for _, j := range jobs {
+			logger.Debug("时段事件", int(event), "执行任务", j.name)
+			s.start(j)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 type config struct {
@@ -18,9 +19,33 @@ type config struct {
 	EnableHttpLog bool   `json:"enable_http_log"`
 	TmplRoot      string `json:"tmpl_root"`
 	StaticRoot    string `json:"static_root"`
+
+	RiskFreeRate      float64 `json:"risk_free_rate"`     // 年化无风险利率，用于计算夏普比率
+	BacktestBenchmark string  `json:"backtest_benchmark"` // 回测默认的基准指数代码，带交易所前缀，从指数日线表加载
+
+	LowBuyHighSellLookback int     `json:"low_buy_high_sell_lookback"`  // 低买高卖计算最高、最低价的交易日数
+	LowBuyHighSellMinRange float64 `json:"low_buy_high_sell_min_range"` // 低买高卖最高价与最低价的最小差幅
+	FreqWaveSwing          float64 `json:"freq_wave_swing"`             // 寻找波动频繁股票时一次波动的最小幅度，也是优化freq_wave的默认值
+	OptimizeWorkers        int     `json:"optimize_workers"`            // 参数优化同时回测的股票数
+	IndicatorCacheSize     int     `json:"indicator_cache_size"`        // 缓存技术指标的股票数
+
+	AlertPollInterval  int     `json:"alert_poll_interval"`  // 价格提醒获取行情的间隔秒数
+	AlertCooldown      int     `json:"alert_cooldown"`       // 同一规则两次提醒的默认最小间隔秒数
+	AlertWebhookUrl    string  `json:"alert_webhook_url"`    // 默认的提醒webhook地址
+	AlertRiseThreshold float64 `json:"alert_rise_threshold"` // trans_prompt的涨幅提醒阈值
+	AlertFallThreshold float64 `json:"alert_fall_threshold"` // trans_prompt的跌幅提醒阈值
+
+	HolidayFile string `json:"holiday_file"` // 交易所休市日文件，相对路径按配置文件所在目录
+
+	IntradayCodes            []string `json:"intraday_codes"`              // 除提醒规则外需要记录分时数据的股票
+	TickRetentionDays        int      `json:"tick_retention_days"`         // 逐笔快照保留天数
+	MinuteBarRetentionMonths int      `json:"minute_bar_retention_months"` // 分钟K线保留月数
+
+	ScreenerWorkers int `json:"screener_workers"` // 选股时同时求值的股票数
 }
 
 var c config
+var configDir = "../config" // 配置文件所在目录
 
 func init() {
 	c.ProductionEnv = false
@@ -33,6 +58,21 @@ func init() {
 	c.EnableHttpLog = true
 	c.TmplRoot = "f:/Git/e94/src/background/stock/tmpl/"
 	c.StaticRoot = "c:/work/code/e94/src/background/stock/html/stock"
+	c.RiskFreeRate = 0.03
+	c.BacktestBenchmark = "sh000300"
+	c.LowBuyHighSellLookback = 180
+	c.LowBuyHighSellMinRange = 0.2
+	c.FreqWaveSwing = 0.15
+	c.OptimizeWorkers = 8
+	c.IndicatorCacheSize = 500
+	c.AlertPollInterval = 3
+	c.AlertCooldown = 600
+	c.AlertRiseThreshold = 0.03
+	c.AlertFallThreshold = -0.02
+	c.HolidayFile = "holiday.txt"
+	c.TickRetentionDays = 3
+	c.MinuteBarRetentionMonths = 12
+	c.ScreenerWorkers = 8
 }
 
 func LoadConfig(path string) error {
@@ -52,6 +92,7 @@ func LoadConfig(path string) error {
 	}
 
 	c = ctmp
+	configDir = filepath.Dir(path)
 	return nil
 }
 
@@ -98,3 +139,84 @@ func GetTmplRoot() string {
 func GetStaticRoot() string {
 	return c.StaticRoot
 }
+
+func GetRiskFreeRate() float64 {
+	return c.RiskFreeRate
+}
+
+func GetBacktestBenchmark() string {
+	if c.BacktestBenchmark == "" {
+		return "sh000300"
+	}
+	return c.BacktestBenchmark
+}
+
+func GetLowBuyHighSellLookback() int {
+	return c.LowBuyHighSellLookback
+}
+
+func GetLowBuyHighSellMinRange() float64 {
+	return c.LowBuyHighSellMinRange
+}
+
+func GetFreqWaveSwing() float64 {
+	if c.FreqWaveSwing <= 0 {
+		return 0.15
+	}
+	return c.FreqWaveSwing
+}
+
+func GetOptimizeWorkers() int {
+	return c.OptimizeWorkers
+}
+
+func GetIndicatorCacheSize() int {
+	return c.IndicatorCacheSize
+}
+
+func GetAlertPollInterval() int {
+	return c.AlertPollInterval
+}
+
+func GetAlertCooldown() int {
+	return c.AlertCooldown
+}
+
+func GetAlertWebhookUrl() string {
+	return c.AlertWebhookUrl
+}
+
+func GetAlertRiseThreshold() float64 {
+	return c.AlertRiseThreshold
+}
+
+func GetAlertFallThreshold() float64 {
+	return c.AlertFallThreshold
+}
+
+func GetHolidayFile() string {
+	file := c.HolidayFile
+	if file == "" {
+		file = "holiday.txt"
+	}
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(configDir, file)
+}
+
+func GetIntradayCodes() []string {
+	return c.IntradayCodes
+}
+
+func GetTickRetentionDays() int {
+	return c.TickRetentionDays
+}
+
+func GetMinuteBarRetentionMonths() int {
+	return c.MinuteBarRetentionMonths
+}
+
+func GetScreenerWorkers() int {
+	return c.ScreenerWorkers
+}
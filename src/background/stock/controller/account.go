@@ -0,0 +1,346 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/stock/model"
+	"background/stock/portfolio"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	GET /cms/account/list
+*/
+func AccountListHandler(c *gin.Context) {
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var accounts []model.Account
+	if err := db.Order("id").Find(&accounts).Error; err != nil {
+		logger.Error("query account err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": accounts})
+}
+
+/*
+	POST /cms/account/save
+	id为0时新建账户
+*/
+func AccountSaveHandler(c *gin.Context) {
+	var account model.Account
+	if err := c.Bind(&account); err != nil {
+		logger.Error(err)
+		return
+	}
+	if account.Name == "" || (account.Type != model.AccountTypeReal && account.Type != model.AccountTypeSimulation) || account.InitialCash < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	if account.Id != 0 {
+		old, ok := loadAccount(c, account.Id)
+		if !ok {
+			return
+		}
+		account.CreatedAt = old.CreatedAt
+		// 修改初始资金后流水要能重放
+		if account.InitialCash != old.InitialCash {
+			trades, err := portfolio.LoadTrades(db, account.Id)
+			if err != nil {
+				logger.Error("query account_trade err!!!,", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			if _, err := portfolio.Replay(account.InitialCash, trades); err != nil {
+				c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+				return
+			}
+		}
+	}
+	if err := db.Save(&account).Error; err != nil {
+		logger.Error("save account err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": account})
+}
+
+func loadAccount(c *gin.Context, id uint32) (*model.Account, bool) {
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var account model.Account
+	if err := db.Where("id = ?", id).First(&account).Error; err == gorm.ErrRecordNotFound {
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		logger.Error("query account err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
+	}
+	return &account, true
+}
+
+// 流水校验不通过时返回错误信息，其他错误返回500
+func abortTradeError(c *gin.Context, err error) {
+	if _, ok := err.(*portfolio.TradeError); ok {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+		return
+	}
+	logger.Error(err)
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+/*
+	GET /cms/account/position
+	当前持仓，按实时行情计算市值和浮动盈亏。closed为1时包含已清仓的股票
+*/
+func AccountPositionHandler(c *gin.Context) {
+	type param struct {
+		AccountId uint32 `form:"account_id" binding:"required"`
+		Closed    int    `form:"closed"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	account, ok := loadAccount(c, p.AccountId)
+	if !ok {
+		return
+	}
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	ledger, err := portfolio.Load(db, account)
+	if err != nil {
+		abortTradeError(c, err)
+		return
+	}
+	if err := portfolio.MarkRealTime(ledger); err != nil {
+		// 行情获取失败时按成本价显示
+		logger.Error("获取股票实时信息失败:", err)
+	}
+
+	positions := make([]gin.H, 0)
+	for _, pos := range ledger.Positions {
+		count := pos.Count()
+		if count == 0 && p.Closed != 1 {
+			continue
+		}
+		var rate float64
+		if cost := pos.Cost(); cost > 0 {
+			rate = pos.Unrealized() / cost
+		}
+		positions = append(positions, gin.H{
+			"code":            pos.Code,
+			"count":           count,
+			"avg_price":       pos.AvgPrice(),
+			"cost":            pos.Cost(),
+			"price":           pos.Price,
+			"market_value":    pos.MarketValue(),
+			"unrealized":      pos.Unrealized(),
+			"unrealized_rate": rate,
+			"realized":        pos.Realized,
+			"dividend":        pos.Dividend,
+			"lots":            pos.Lots,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{
+		"account":      account,
+		"cash":         ledger.Cash,
+		"market_value": ledger.MarketValue(),
+		"equity":       ledger.Equity(),
+		"net_deposit":  ledger.NetDeposit,
+		"cost":         ledger.Cost(),
+		"realized":     ledger.Realized(),
+		"unrealized":   ledger.Unrealized(),
+		"fees":         ledger.Fees,
+		"positions":    positions,
+	}})
+}
+
+/*
+	GET /cms/account/trade/list
+	账户流水，按时间倒序
+*/
+func AccountTradeListHandler(c *gin.Context) {
+	type param struct {
+		AccountId uint32 `form:"account_id" binding:"required"`
+		StockCode string `form:"stock_code"`
+		Limit     int    `form:"limit" binding:"required"`
+		Offset    int    `form:"offset"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	db = db.Where("account_id = ?", p.AccountId)
+	if p.StockCode != "" {
+		db = db.Where("stock_code = ?", p.StockCode)
+	}
+
+	var trades []model.AccountTrade
+	if err := db.Order("date desc, time desc, id desc").Offset(p.Offset).Limit(p.Limit).Find(&trades).Error; err != nil {
+		logger.Error("query account_trade err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": trades})
+}
+
+/*
+	POST /cms/account/trade/save
+	录入一条流水，卖出数量超过持仓等与账本不符时返回err_msg
+*/
+func AccountTradeSaveHandler(c *gin.Context) {
+	var trade model.AccountTrade
+	if err := c.Bind(&trade); err != nil {
+		logger.Error(err)
+		return
+	}
+	if trade.AccountId == 0 || trade.Type < model.AccountTradeBuy || trade.Type > model.AccountTradeWithdraw || len(trade.Date) != 10 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if trade.Type != model.AccountTradeDeposit && trade.Type != model.AccountTradeWithdraw && len(trade.StockCode) != 6 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	account, ok := loadAccount(c, trade.AccountId)
+	if !ok {
+		return
+	}
+	trade.Source = model.AccountTradeSourceApi
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	if _, err := portfolio.AddTrades(db, account, []*model.AccountTrade{&trade}); err != nil {
+		abortTradeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": trade})
+}
+
+/*
+	POST /cms/account/trade/delete
+*/
+func AccountTradeDeleteHandler(c *gin.Context) {
+	type param struct {
+		AccountId uint32 `form:"account_id" json:"account_id" binding:"required"`
+		Id        uint32 `form:"id" json:"id" binding:"required"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	account, ok := loadAccount(c, p.AccountId)
+	if !ok {
+		return
+	}
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	if err := portfolio.DeleteTrade(db, account, p.Id); err == gorm.ErrRecordNotFound {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	} else if err != nil {
+		abortTradeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success})
+}
+
+/*
+	POST /cms/account/trade/import
+	导入券商导出的交割单(表单字段file)，已导入的流水按合同编号跳过
+*/
+func AccountTradeImportHandler(c *gin.Context) {
+	type param struct {
+		AccountId uint32 `form:"account_id" binding:"required"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	account, ok := loadAccount(c, p.AccountId)
+	if !ok {
+		return
+	}
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	trades, skipped, err := portfolio.ParseCsv(file)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	added, err := portfolio.AddTrades(db, account, trades)
+	if err != nil {
+		abortTradeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{
+		"parsed":  len(trades),
+		"added":   added,
+		"skipped": skipped,
+	}})
+}
+
+/*
+	GET /cms/account/snapshot/list
+	每日资产快照，按日期升序
+*/
+func AccountSnapshotListHandler(c *gin.Context) {
+	type param struct {
+		AccountId uint32 `form:"account_id" binding:"required"`
+		Begin     string `form:"begin"`
+		End       string `form:"end"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	db = db.Where("account_id = ?", p.AccountId)
+	if p.Begin != "" {
+		db = db.Where("date >= ?", p.Begin)
+	}
+	if p.End != "" {
+		db = db.Where("date <= ?", p.End)
+	}
+
+	var snapshots []model.AccountSnapshot
+	if err := db.Order("date").Find(&snapshots).Error; err != nil {
+		logger.Error("query account_snapshot err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": snapshots})
+}
@@ -0,0 +1,133 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/stock/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	GET /cms/alert/rule/list
+	价格提醒规则，stock_code为空时返回全部
+*/
+func AlertRuleListHandler(c *gin.Context) {
+	type param struct {
+		StockCode string `form:"stock_code"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	if p.StockCode != "" {
+		db = db.Where("stock_code = ?", p.StockCode)
+	}
+
+	var rules []model.AlertRule
+	if err := db.Order("id desc").Find(&rules).Error; err != nil {
+		logger.Error("query alert_rule err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": rules})
+}
+
+/*
+	POST /cms/alert/rule/save
+	id为0时新建规则
+*/
+func AlertRuleSaveHandler(c *gin.Context) {
+	var rule model.AlertRule
+	if err := c.Bind(&rule); err != nil {
+		logger.Error(err)
+		return
+	}
+	if len(rule.StockCode) != 6 || rule.Type < model.AlertTypePrice || rule.Type > model.AlertTypeIndicatorCross || rule.Channel == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	if rule.Id != 0 {
+		var old model.AlertRule
+		if err := db.Where("id = ?", rule.Id).First(&old).Error; err == gorm.ErrRecordNotFound {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		} else if err != nil {
+			logger.Error("query alert_rule err!!!,", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		rule.CreatedAt = old.CreatedAt
+		rule.LastTriggeredAt = old.LastTriggeredAt
+	}
+	if err := db.Save(&rule).Error; err != nil {
+		logger.Error("save alert_rule err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": rule})
+}
+
+/*
+	POST /cms/alert/rule/delete
+*/
+func AlertRuleDeleteHandler(c *gin.Context) {
+	type param struct {
+		Id uint32 `form:"id" json:"id" binding:"required"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	if err := db.Where("id = ?", p.Id).Delete(&model.AlertRule{}).Error; err != nil {
+		logger.Error("delete alert_rule err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success})
+}
+
+/*
+	GET /cms/alert/log/list
+	已发出的提醒，按时间倒序
+*/
+func AlertLogListHandler(c *gin.Context) {
+	type param struct {
+		RuleId uint32 `form:"rule_id"`
+		Limit  int    `form:"limit" binding:"required"`
+		Offset int    `form:"offset"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	if p.RuleId != 0 {
+		db = db.Where("rule_id = ?", p.RuleId)
+	}
+
+	var logs []model.AlertLog
+	if err := db.Order("id desc").Offset(p.Offset).Limit(p.Limit).Find(&logs).Error; err != nil {
+		logger.Error("query alert_log err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": logs})
+}
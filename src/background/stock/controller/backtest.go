@@ -0,0 +1,122 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/stock/backtest"
+	"background/stock/config"
+	"background/stock/model"
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	GET /chart/backtest/list
+	回测记录，按时间倒序
+*/
+func BacktestListHandler(c *gin.Context) {
+	type param struct {
+		Limit  int `form:"limit" binding:"required"`
+		Offset int `form:"offset"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var simulations []model.Simulation
+	if err := db.Order("id desc").Offset(p.Offset).Limit(p.Limit).Find(&simulations).Error; err != nil {
+		logger.Error("query simulation err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": simulations})
+}
+
+func loadBacktestReport(c *gin.Context, id uint32, benchmark string) (*model.Simulation, *backtest.Report, bool) {
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	simulation, report, err := backtest.LoadReport(db, id, benchmark, config.GetRiskFreeRate())
+	if err == gorm.ErrRecordNotFound {
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, nil, false
+	} else if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return simulation, report, true
+}
+
+/*
+	GET /chart/backtest/report
+	回测报告：绩效指标、每日资产和回撤、成交记录。benchmark为空时使用回测时设置的基准
+*/
+func BacktestReportHandler(c *gin.Context) {
+	type param struct {
+		Id        uint32 `form:"id" binding:"required"`
+		Benchmark string `form:"benchmark"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	simulation, report, ok := loadBacktestReport(c, p.Id, p.Benchmark)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": gin.H{"simulation": simulation, "report": report}})
+}
+
+/*
+	GET /chart/backtest/report/csv
+	导出csv，type为equity(每日资产)或trades(成交记录)
+*/
+func BacktestReportCsvHandler(c *gin.Context) {
+	type param struct {
+		Id        uint32 `form:"id" binding:"required"`
+		Type      string `form:"type" binding:"required"`
+		Benchmark string `form:"benchmark"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+	if p.Type != "equity" && p.Type != "trades" {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	_, report, ok := loadBacktestReport(c, p.Id, p.Benchmark)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if p.Type == "equity" {
+		err = backtest.WriteEquityCsv(&buf, report)
+	} else {
+		err = backtest.WriteTradesCsv(&buf, report)
+	}
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=backtest_%d_%s.csv", p.Id, p.Type))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,47 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/stock/indicator"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	GET /cms/stock/indicator
+	股票的技术指标序列(MA、EMA、MACD、RSI、KDJ、BOLL、ATR、OBV、量比)，按前复权价格计算，
+	start、end为空时不限制
+*/
+func StockIndicatorHandler(c *gin.Context) {
+	type param struct {
+		Code  string `form:"code" binding:"required"`
+		Start string `form:"start"`
+		End   string `form:"end"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	series, err := indicator.GetSeries(db, p.Code)
+	if err != nil {
+		logger.Error("query stock_history_data_q err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	data := make([]*indicator.Values, 0, len(series))
+	for _, v := range series {
+		if (p.Start == "" || v.Date >= p.Start) && (p.End == "" || v.Date <= p.End) {
+			data = append(data, v)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": data})
+}
@@ -0,0 +1,68 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/stock/component/calendar"
+	"background/stock/model"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	GET /chart/stock/bars
+	分钟K线，period为1、5、15、60，start、end为日期(2006-01-02)，包含end当天。
+	日期按交易所时区，和保存的K线时间一致
+*/
+func StockMinuteBarHandler(c *gin.Context) {
+	type param struct {
+		Code   string `form:"code" binding:"required"`
+		Period uint8  `form:"period" binding:"required"`
+		Start  string `form:"start" binding:"required"`
+		End    string `form:"end" binding:"required"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+	if p.Period != 1 && p.Period != 5 && p.Period != 15 && p.Period != 60 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	location := calendar.Default().Location()
+	start, err := time.ParseInLocation("2006-01-02", p.Start, location)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	end, err := time.ParseInLocation("2006-01-02", p.End, location)
+	if err != nil || end.Before(start) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	end = end.AddDate(0, 0, 1)
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	// 按月分表，逐月查询
+	bars := make([]model.StockMinuteBar, 0)
+	for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location()); month.Before(end); month = month.AddDate(0, 1, 0) {
+		table := model.StockMinuteBarTable(month)
+		if !db.HasTable(table) {
+			continue
+		}
+		var monthBars []model.StockMinuteBar
+		if err := db.Table(table).Where("code = ? and period = ? and time >= ? and time < ?", p.Code, p.Period, start, end).Order("time asc").Find(&monthBars).Error; err != nil {
+			logger.Error("query ", table, " err!!!,", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		bars = append(bars, monthBars...)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": bars})
+}
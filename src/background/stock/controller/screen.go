@@ -0,0 +1,195 @@
+package cms
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"background/stock/config"
+	"background/stock/model"
+	"background/stock/screener"
+	"background/stock/task"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	GET /cms/screen/list
+*/
+func ScreenListHandler(c *gin.Context) {
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	var screens []model.Screen
+	if err := db.Order("id desc").Find(&screens).Error; err != nil {
+		logger.Error("query screen err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": screens})
+}
+
+/*
+	POST /cms/screen/save
+	id为0时新建，表达式不正确时返回err_msg
+*/
+func ScreenSaveHandler(c *gin.Context) {
+	var screen model.Screen
+	if err := c.Bind(&screen); err != nil {
+		logger.Error(err)
+		return
+	}
+	if screen.Name == "" {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if _, err := screener.Compile(screen.Expression); err != nil {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	if screen.Id != 0 {
+		var old model.Screen
+		if err := db.Where("id = ?", screen.Id).First(&old).Error; err == gorm.ErrRecordNotFound {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		} else if err != nil {
+			logger.Error("query screen err!!!,", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		screen.CreatedAt = old.CreatedAt
+		screen.LastRunAt = old.LastRunAt
+		screen.LastHitCount = old.LastHitCount
+	}
+	if err := db.Save(&screen).Error; err != nil {
+		logger.Error("save screen err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": screen})
+}
+
+/*
+	POST /cms/screen/delete
+	同时删除选股结果
+*/
+func ScreenDeleteHandler(c *gin.Context) {
+	type param struct {
+		Id uint32 `form:"id" json:"id" binding:"required"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+	if err := db.Where("screen_id = ?", p.Id).Delete(&model.ScreenHit{}).Error; err != nil {
+		logger.Error("delete screen_hit err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := db.Where("id = ?", p.Id).Delete(&model.Screen{}).Error; err != nil {
+		logger.Error("delete screen err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success})
+}
+
+/*
+	POST /cms/screen/run
+	id不为0时运行保存的选股条件并保存结果，否则对expression求值，不保存
+*/
+func ScreenRunHandler(c *gin.Context) {
+	type param struct {
+		Id         uint32 `form:"id" json:"id"`
+		Expression string `form:"expression" json:"expression"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	if p.Id != 0 {
+		var screen model.Screen
+		if err := db.Where("id = ?", p.Id).First(&screen).Error; err == gorm.ErrRecordNotFound {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		} else if err != nil {
+			logger.Error("query screen err!!!,", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		hits, err := task.RunScreen(db, &screen)
+		if err != nil {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": hits})
+		return
+	}
+
+	expr, err := screener.Compile(p.Expression)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+		return
+	}
+	hits, err := screener.Run(db, expr, config.GetScreenerWorkers())
+	if err != nil {
+		logger.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": hits})
+}
+
+/*
+	GET /cms/screen/hit/list
+	选股结果，date为空时返回最近一次运行的结果
+*/
+func ScreenHitListHandler(c *gin.Context) {
+	type param struct {
+		ScreenId uint32 `form:"screen_id" binding:"required"`
+		Date     string `form:"date"`
+	}
+	var p param
+	if err := c.Bind(&p); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db := c.MustGet(constant.ContextDb).(*gorm.DB)
+
+	if p.Date == "" {
+		var last model.ScreenHit
+		if err := db.Where("screen_id = ?", p.ScreenId).Order("date desc").First(&last).Error; err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": []model.ScreenHit{}})
+			return
+		} else if err != nil {
+			logger.Error("query screen_hit err!!!,", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		p.Date = last.Date
+	}
+
+	var hits []model.ScreenHit
+	if err := db.Where("screen_id = ? and date = ?", p.ScreenId, p.Date).Order("stock_code").Find(&hits).Error; err != nil {
+		logger.Error("query screen_hit err!!!,", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"err_code": constant.Success, "data": hits})
+}
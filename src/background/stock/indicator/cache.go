@@ -0,0 +1,104 @@
+package indicator
+
+import (
+	"background/common/cache"
+	"background/stock/model"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type entry struct {
+	calc       *Calculator
+	values     []*Values
+	lastDate   string  // 已计算的最后一行数据的日期，包括停牌日
+	checkDate  string  // 最后一个有结果的交易日
+	checkClose float64 // checkDate的收盘价，除权除息后前复权价格会整体重算，与数据库不一致时重新计算
+	usedAt     time.Time
+}
+
+/*
+	按股票代码缓存指标序列，再次获取时只计算新增的交易日，
+	超过size只股票时淘汰最久未使用的
+*/
+type Cache struct {
+	lock    sync.Mutex
+	size    int
+	entries map[string]*entry
+	flight  cache.FlightGroup
+}
+
+func NewCache(size int) *Cache {
+	return &Cache{size: size, entries: make(map[string]*entry)}
+}
+
+var defaultCache = NewCache(500)
+
+func InitCache(size int) {
+	defaultCache = NewCache(size)
+}
+
+// 使用默认缓存获取指标序列
+func GetSeries(db *gorm.DB, code string) ([]*Values, error) {
+	return defaultCache.Get(db, code)
+}
+
+func (c *Cache) Get(db *gorm.DB, code string) ([]*Values, error) {
+	values, err := c.flight.Do(code, func() (interface{}, error) {
+		return c.refresh(db, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values.([]*Values), nil
+}
+
+func (c *Cache) refresh(db *gorm.DB, code string) ([]*Values, error) {
+	c.lock.Lock()
+	e := c.entries[code]
+	c.lock.Unlock()
+
+	if e != nil && e.checkDate != "" {
+		var rows []*model.StockHistoryDataQ
+		if err := db.Where("code = ? and date = ?", code, e.checkDate).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 || rows[0].Close != e.checkClose {
+			e = nil
+		}
+	}
+	if e == nil {
+		e = &entry{calc: NewCalculator()}
+	}
+
+	var rows []*model.StockHistoryDataQ
+	if err := db.Where("code = ? and date > ?", code, e.lastDate).Order("date asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, point := range FromHistoryDataQ(rows) {
+		if v := e.calc.Update(point); v != nil {
+			e.values = append(e.values, v)
+			e.checkDate = v.Date
+			e.checkClose = v.Close
+		}
+		e.lastDate = point.Date
+	}
+	e.usedAt = time.Now()
+
+	c.lock.Lock()
+	c.entries[code] = e
+	for len(c.entries) > c.size {
+		var oldest string
+		for k, v := range c.entries {
+			if oldest == "" || v.usedAt.Before(c.entries[oldest].usedAt) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.lock.Unlock()
+
+	// 之后的刷新只会追加，返回固定长度的切片
+	return e.values[:len(e.values):len(e.values)], nil
+}
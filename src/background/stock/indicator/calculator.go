@@ -0,0 +1,211 @@
+package indicator
+
+import (
+	"background/stock/backtest"
+	"background/stock/model"
+	"math"
+)
+
+// 一个交易日的前复权行情
+type Point struct {
+	Date   string
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// 收盘价为0表示停牌
+func (p *Point) Suspended() bool {
+	return p.Close == 0
+}
+
+func FromHistoryDataQ(rows []*model.StockHistoryDataQ) []*Point {
+	points := make([]*Point, 0, len(rows))
+	for _, row := range rows {
+		points = append(points, &Point{Date: row.Date, Open: row.Open, High: row.High, Low: row.Low, Close: row.Close, Volume: row.Volume})
+	}
+	return points
+}
+
+func FromBars(bars []*backtest.Bar) []*Point {
+	points := make([]*Point, 0, len(bars))
+	for _, bar := range bars {
+		points = append(points, &Point{Date: bar.Date, Open: bar.Open, High: bar.High, Low: bar.Low, Close: bar.Close, Volume: bar.Volume})
+	}
+	return points
+}
+
+/*
+	一个交易日的各项指标，参数与常用行情软件默认值相同，数据不足一个周期的指标为0
+*/
+type Values struct {
+	Date        string  `json:"date"`
+	Close       float64 `json:"close"`
+	MA5         float64 `json:"ma5"`
+	MA10        float64 `json:"ma10"`
+	MA20        float64 `json:"ma20"`
+	MA60        float64 `json:"ma60"`
+	EMA12       float64 `json:"ema12"`
+	EMA26       float64 `json:"ema26"`
+	DIF         float64 `json:"dif"` // MACD(12,26,9)
+	DEA         float64 `json:"dea"`
+	MACD        float64 `json:"macd"` // 2*(DIF-DEA)
+	RSI6        float64 `json:"rsi6"`
+	RSI12       float64 `json:"rsi12"`
+	RSI24       float64 `json:"rsi24"`
+	K           float64 `json:"k"` // KDJ(9,3,3)
+	D           float64 `json:"d"`
+	J           float64 `json:"j"`
+	BollMid     float64 `json:"boll_mid"` // BOLL(20,2)
+	BollUpper   float64 `json:"boll_upper"`
+	BollLower   float64 `json:"boll_lower"`
+	ATR         float64 `json:"atr"` // ATR(14)，真实波幅的简单移动平均
+	OBV         float64 `json:"obv"`
+	VolumeRatio float64 `json:"volume_ratio"` // 成交量与前5日平均成交量之比
+}
+
+type rsi struct {
+	up  *SMA
+	abs *SMA
+}
+
+func newRsi(n int) *rsi {
+	return &rsi{up: NewSMA(n, 1, 0), abs: NewSMA(n, 1, 0)}
+}
+
+func (r *rsi) update(change float64) float64 {
+	up := r.up.Update(math.Max(change, 0))
+	abs := r.abs.Update(math.Abs(change))
+	if !r.up.Ready() || abs == 0 {
+		return 0
+	}
+	return up / abs * 100
+}
+
+/*
+	指标计算器，保存各指标的中间状态，新的交易日只需要调用一次Update。
+	停牌日不更新状态，也不产生结果
+*/
+type Calculator struct {
+	ma5, ma10, ma20, ma60 *MA
+	ema12, ema26, dea     *EMA
+	rsi6, rsi12, rsi24    *rsi
+	kdjRange              *HighLow
+	k, d                  *SMA
+	atr                   *MA
+	volume5               *MA
+	obv                   float64
+	preClose              float64
+	count                 int
+}
+
+func NewCalculator() *Calculator {
+	return &Calculator{
+		ma5:      NewMA(5),
+		ma10:     NewMA(10),
+		ma20:     NewMA(20),
+		ma60:     NewMA(60),
+		ema12:    NewEMA(12),
+		ema26:    NewEMA(26),
+		dea:      NewEMA(9),
+		rsi6:     newRsi(6),
+		rsi12:    newRsi(12),
+		rsi24:    newRsi(24),
+		kdjRange: NewHighLow(9),
+		k:        NewSMA(3, 1, 50),
+		d:        NewSMA(3, 1, 50),
+		atr:      NewMA(14),
+		volume5:  NewMA(5),
+	}
+}
+
+func ready(v float64, ok bool) float64 {
+	if !ok {
+		return 0
+	}
+	return v
+}
+
+func (c *Calculator) Update(p *Point) *Values {
+	if p.Suspended() {
+		return nil
+	}
+	v := &Values{Date: p.Date, Close: p.Close}
+
+	v.MA5 = ready(c.ma5.Update(p.Close), c.ma5.Ready())
+	v.MA10 = ready(c.ma10.Update(p.Close), c.ma10.Ready())
+	v.MA20 = ready(c.ma20.Update(p.Close), c.ma20.Ready())
+	v.MA60 = ready(c.ma60.Update(p.Close), c.ma60.Ready())
+	if c.ma20.Ready() {
+		std := c.ma20.Std()
+		v.BollMid = v.MA20
+		v.BollUpper = v.MA20 + 2*std
+		v.BollLower = v.MA20 - 2*std
+	}
+
+	c.ema12.Update(p.Close)
+	c.ema26.Update(p.Close)
+	dif := c.ema12.Value() - c.ema26.Value()
+	dea := c.dea.Update(dif)
+	if c.ema26.Ready() {
+		v.EMA12 = c.ema12.Value()
+		v.EMA26 = c.ema26.Value()
+		v.DIF = dif
+		v.DEA = dea
+		v.MACD = 2 * (dif - dea)
+	}
+
+	high, low := c.kdjRange.Update(p.High, p.Low)
+	rsv := 50.0
+	if high > low {
+		rsv = (p.Close - low) / (high - low) * 100
+	}
+	k := c.k.Update(rsv)
+	d := c.d.Update(k)
+	if c.kdjRange.Ready() {
+		v.K = k
+		v.D = d
+		v.J = 3*k - 2*d
+	}
+
+	// 成交量比较的是前5日，先取值再更新
+	if c.volume5.Ready() && c.volume5.Value() > 0 {
+		v.VolumeRatio = p.Volume / c.volume5.Value()
+	}
+	c.volume5.Update(p.Volume)
+
+	if c.count > 0 {
+		change := p.Close - c.preClose
+		v.RSI6 = c.rsi6.update(change)
+		v.RSI12 = c.rsi12.update(change)
+		v.RSI24 = c.rsi24.update(change)
+
+		tr := math.Max(p.High-p.Low, math.Max(math.Abs(p.High-c.preClose), math.Abs(p.Low-c.preClose)))
+		v.ATR = ready(c.atr.Update(tr), c.atr.Ready())
+
+		if change > 0 {
+			c.obv += p.Volume
+		} else if change < 0 {
+			c.obv -= p.Volume
+		}
+	}
+	v.OBV = c.obv
+
+	c.preClose = p.Close
+	c.count++
+	return v
+}
+
+// 计算整个序列的指标，跳过停牌日
+func Calculate(points []*Point) []*Values {
+	c := NewCalculator()
+	values := make([]*Values, 0, len(points))
+	for _, p := range points {
+		if v := c.Update(p); v != nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
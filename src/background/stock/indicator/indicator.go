@@ -0,0 +1,156 @@
+package indicator
+
+import (
+	"math"
+)
+
+/*
+	增量计算的基础指标，每次Update传入一个新值并返回最新结果，
+	数据不足一个周期时Ready返回false
+*/
+
+// 简单移动平均
+type MA struct {
+	n      int
+	values []float64
+	pos    int
+	count  int
+	sum    float64
+}
+
+func NewMA(n int) *MA {
+	return &MA{n: n, values: make([]float64, n)}
+}
+
+func (m *MA) Update(v float64) float64 {
+	if m.count == m.n {
+		m.sum -= m.values[m.pos]
+	} else {
+		m.count++
+	}
+	m.values[m.pos] = v
+	m.sum += v
+	m.pos = (m.pos + 1) % m.n
+	return m.Value()
+}
+
+func (m *MA) Value() float64 {
+	if m.count == 0 {
+		return 0
+	}
+	return m.sum / float64(m.count)
+}
+
+func (m *MA) Ready() bool {
+	return m.count == m.n
+}
+
+// 窗口内的总体标准差，与MA使用相同的窗口
+func (m *MA) Std() float64 {
+	if m.count == 0 {
+		return 0
+	}
+	mean := m.Value()
+	var sum float64
+	for i := 0; i < m.count; i++ {
+		sum += (m.values[i] - mean) * (m.values[i] - mean)
+	}
+	return math.Sqrt(sum / float64(m.count))
+}
+
+// 指数移动平均，第一个值作为初始值
+type EMA struct {
+	n     int
+	alpha float64
+	value float64
+	count int
+}
+
+func NewEMA(n int) *EMA {
+	return &EMA{n: n, alpha: 2 / float64(n+1)}
+}
+
+func (e *EMA) Update(v float64) float64 {
+	if e.count == 0 {
+		e.value = v
+	} else {
+		e.value = e.alpha*v + (1-e.alpha)*e.value
+	}
+	e.count++
+	return e.value
+}
+
+func (e *EMA) Value() float64 {
+	return e.value
+}
+
+func (e *EMA) Ready() bool {
+	return e.count >= e.n
+}
+
+/*
+	通达信、同花顺的SMA(X,N,M)：Y = (M*X + (N-M)*Y') / N，init为第一次计算前的Y'
+*/
+type SMA struct {
+	n     int
+	m     int
+	value float64
+	count int
+}
+
+func NewSMA(n, m int, init float64) *SMA {
+	return &SMA{n: n, m: m, value: init}
+}
+
+func (s *SMA) Update(v float64) float64 {
+	s.value = (float64(s.m)*v + float64(s.n-s.m)*s.value) / float64(s.n)
+	s.count++
+	return s.value
+}
+
+func (s *SMA) Value() float64 {
+	return s.value
+}
+
+func (s *SMA) Ready() bool {
+	return s.count >= s.n
+}
+
+// 最近n个值的最高、最低值
+type HighLow struct {
+	n     int
+	highs []float64
+	lows  []float64
+	pos   int
+	count int
+}
+
+func NewHighLow(n int) *HighLow {
+	return &HighLow{n: n, highs: make([]float64, n), lows: make([]float64, n)}
+}
+
+func (h *HighLow) Update(high, low float64) (float64, float64) {
+	h.highs[h.pos] = high
+	h.lows[h.pos] = low
+	h.pos = (h.pos + 1) % h.n
+	if h.count < h.n {
+		h.count++
+	}
+	return h.Value()
+}
+
+func (h *HighLow) Value() (high, low float64) {
+	for i := 0; i < h.count; i++ {
+		if i == 0 || h.highs[i] > high {
+			high = h.highs[i]
+		}
+		if i == 0 || h.lows[i] < low {
+			low = h.lows[i]
+		}
+	}
+	return high, low
+}
+
+func (h *HighLow) Ready() bool {
+	return h.count == h.n
+}
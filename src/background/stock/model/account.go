@@ -0,0 +1,45 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+/*
+资金账户，持仓和盈亏由account_trade按时间顺序重放得到
+*/
+type Account struct {
+	Id          uint32    `gorm:"primary_key" json:"id"`
+	Name        string    `gorm:"size:64" json:"name"`
+	Broker      string    `gorm:"size:64" json:"broker"` //券商
+	Type        uint8     `json:"type"`                  //参见AccountType*
+	InitialCash float64   `json:"initial_cash"`          //初始资金，之后的资金变动记为转入、转出
+	Status      uint8     `json:"status"`                //1启用 0停用
+	Remark      string    `gorm:"size:256" json:"remark"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const (
+	AccountTypeReal       = 1 //实盘
+	AccountTypeSimulation = 2 //模拟盘
+)
+
+func (Account) TableName() string {
+	return "account"
+}
+
+func initAccount(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&Account{}) {
+		err = db.AutoMigrate(&Account{}).Error
+	} else {
+		err = db.CreateTable(&Account{}).Error
+	}
+	return err
+}
+
+func dropAccount(db *gorm.DB) {
+	db.DropTableIfExists(&Account{})
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+/*
+账户每日收盘后的资产快照
+*/
+type AccountSnapshot struct {
+	Id               uint32    `gorm:"primary_key" json:"id"`
+	AccountId        uint32    `gorm:"unique_index:idx_account_date" json:"account_id"`
+	Date             string    `gorm:"size:10;unique_index:idx_account_date" json:"date"`
+	Cash             float64   `json:"cash"`
+	MarketValue      float64   `json:"market_value"`
+	Equity           float64   `json:"equity"`            //现金+市值
+	NetDeposit       float64   `json:"net_deposit"`       //初始资金+累计转入-累计转出
+	Cost             float64   `json:"cost"`              //持仓成本
+	RealizedProfit   float64   `json:"realized_profit"`   //累计已实现盈亏，包含分红
+	UnrealizedProfit float64   `json:"unrealized_profit"` //持仓浮动盈亏
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func (AccountSnapshot) TableName() string {
+	return "account_snapshot"
+}
+
+func initAccountSnapshot(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&AccountSnapshot{}) {
+		err = db.AutoMigrate(&AccountSnapshot{}).Error
+	} else {
+		err = db.CreateTable(&AccountSnapshot{}).Error
+	}
+	return err
+}
+
+func dropAccountSnapshot(db *gorm.DB) {
+	db.DropTableIfExists(&AccountSnapshot{})
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+/*
+账户流水：买卖成交、分红送转和资金转入转出。
+导入时按external_id去重，券商没有合同编号时由成交内容生成，接口录入时随机生成
+*/
+type AccountTrade struct {
+	Id         uint32    `gorm:"primary_key" json:"id"`
+	AccountId  uint32    `gorm:"unique_index:idx_account_external" json:"account_id"`
+	StockCode  string    `gorm:"size:6" json:"stock_code"` //转入转出时为空
+	Type       uint8     `json:"type"`                     //参见AccountTrade*
+	Date       string    `gorm:"size:10" json:"date"`      //2006-01-02
+	Time       string    `gorm:"size:8" json:"time"`       //15:04:05，可以为空
+	Price      float64   `json:"price"`                    //成交价
+	Count      int64     `json:"count"`                    //成交股数；送转股时为获得的股数
+	Amount     float64   `json:"amount"`                   //成交金额；分红、转入转出的金额
+	Fee        float64   `json:"fee"`                      //佣金、过户费等
+	Tax        float64   `json:"tax"`                      //印花税、红利税
+	Source     uint8     `json:"source"`                   //参见AccountTradeSource*
+	ExternalId string    `gorm:"size:64;unique_index:idx_account_external" json:"external_id"`
+	Remark     string    `gorm:"size:256" json:"remark"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const (
+	AccountTradeBuy      = 1 //买入
+	AccountTradeSell     = 2 //卖出
+	AccountTradeDividend = 3 //现金分红
+	AccountTradeBonus    = 4 //送股、转增股
+	AccountTradeDeposit  = 5 //资金转入
+	AccountTradeWithdraw = 6 //资金转出
+)
+
+const (
+	AccountTradeSourceApi = 1 //接口录入
+	AccountTradeSourceCsv = 2 //券商对账单导入
+)
+
+func (AccountTrade) TableName() string {
+	return "account_trade"
+}
+
+func initAccountTrade(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&AccountTrade{}) {
+		// 接口录入的旧流水没有external_id，建唯一索引前补上
+		err = db.Model(&AccountTrade{}).Where("external_id = ''").Update("external_id", gorm.Expr("CONCAT('id:', id)")).Error
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&AccountTrade{}).Error
+	} else {
+		err = db.CreateTable(&AccountTrade{}).Error
+	}
+	return err
+}
+
+func dropAccountTrade(db *gorm.DB) {
+	db.DropTableIfExists(&AccountTrade{})
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+/*
+已发出的提醒，rule_id为0表示由trans_prompt生成的规则
+*/
+type AlertLog struct {
+	Id        uint32    `gorm:"primary_key" json:"id"`
+	RuleId    uint32    `gorm:"index" json:"rule_id"`
+	RuleKey   string    `gorm:"size:64" json:"rule_key"`
+	StockCode string    `gorm:"size:6" json:"stock_code"`
+	Type      uint8     `json:"type"`
+	Price     float64   `json:"price"`
+	Change    float64   `json:"change"` //当时的涨跌幅
+	Message   string    `gorm:"size:512" json:"message"`
+	Channel   uint8     `json:"channel"` //发送成功的提醒方式
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AlertLog) TableName() string {
+	return "alert_log"
+}
+
+func initAlertLog(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&AlertLog{}) {
+		err = db.AutoMigrate(&AlertLog{}).Error
+	} else {
+		err = db.CreateTable(&AlertLog{}).Error
+	}
+	return err
+}
+
+func dropAlertLog(db *gorm.DB) {
+	db.DropTableIfExists(&AlertLog{})
+}
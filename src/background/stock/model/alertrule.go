@@ -0,0 +1,64 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+/*
+价格提醒规则，条件从不满足变为满足时提醒一次，之后cooldown秒内不再提醒
+*/
+type AlertRule struct {
+	Id              uint32     `gorm:"primary_key" json:"id"`
+	StockCode       string     `gorm:"size:6;index" json:"stock_code"`
+	Type            uint8      `json:"type"`                        //参见AlertType*
+	Direction       uint8      `json:"direction"`                   //价格、指标穿越的方向，参见AlertDirection*
+	Threshold       float64    `json:"threshold"`                   //价格；涨跌幅(0.03表示涨3%，-0.02表示跌2%)；量比
+	Indicator       string     `gorm:"size:32" json:"indicator"`    //指标穿越时的指标名，如ma20、boll_upper
+	Cooldown        int        `json:"cooldown"`                    //两次提醒的最小间隔秒数，0使用默认值
+	Channel         uint8      `json:"channel"`                     //提醒方式，参见AlertChannel*，可以组合
+	WebhookUrl      string     `gorm:"size:256" json:"webhook_url"` //为空时使用默认的webhook
+	Status          uint8      `json:"status"`                      //1启用 0停用
+	Remark          string     `gorm:"size:256" json:"remark"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+const (
+	AlertTypePrice          = 1 //价格穿越
+	AlertTypeChange         = 2 //涨跌幅
+	AlertTypeVolumeSpike    = 3 //放量，按已交易时间折算的量比
+	AlertTypeLimitUp        = 4 //涨停
+	AlertTypeLimitDown      = 5 //跌停
+	AlertTypeIndicatorCross = 6 //价格穿越日线指标
+)
+
+const (
+	AlertDirectionUp   = 1 //向上穿越
+	AlertDirectionDown = 2 //向下穿越
+)
+
+const (
+	AlertChannelEmail   = 1
+	AlertChannelWebhook = 2
+)
+
+func (AlertRule) TableName() string {
+	return "alert_rule"
+}
+
+func initAlertRule(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&AlertRule{}) {
+		err = db.AutoMigrate(&AlertRule{}).Error
+	} else {
+		err = db.CreateTable(&AlertRule{}).Error
+	}
+	return err
+}
+
+func dropAlertRule(db *gorm.DB) {
+	db.DropTableIfExists(&AlertRule{})
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	指数日线，用于回测的基准比较。代码带交易所前缀，如sh000300，
+	避免与同代码的股票(如sz000001)混淆
+*/
+type IndexHistoryData struct {
+	Id     uint32  `gorm:"primary_key" json:"id"`
+	Code   string  `gorm:"size:16;unique_index:idx_index_code_date" json:"code"` //指数代码
+	Date   string  `gorm:"size:10;unique_index:idx_index_code_date" json:"date"` //交易日期
+	Open   float64 `json:"open"`
+	Close  float64 `json:"close"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Volume float64 `json:"volume"` //成交量(手)
+}
+
+func (IndexHistoryData) TableName() string {
+	return "index_history_data"
+}
+
+func initIndexHistoryData(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&IndexHistoryData{}) {
+		err = db.AutoMigrate(&IndexHistoryData{}).Error
+	} else {
+		err = db.CreateTable(&IndexHistoryData{}).Error
+	}
+	return err
+}
+
+func dropIndexHistoryData(db *gorm.DB) {
+	db.DropTableIfExists(&IndexHistoryData{})
+}
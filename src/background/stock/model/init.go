@@ -1,95 +1,172 @@
-package model
-
-import (
-	"background/common/logger"
-	"github.com/jinzhu/gorm"
-)
-
-func InitModel(db *gorm.DB) error {
-	var err error
-
-	err = initRealTimeStock(db)
-	if err != nil {
-		logger.Fatal("Init db real_time_stock failed, ", err)
-		return err
-	}
-
-	err = initHoldStockInfo(db)
-	if err != nil {
-		logger.Fatal("Init db hold_stock_info failed, ", err)
-		return err
-	}
-
-	err = initStockHistoryDataQ(db)
-	if err != nil {
-		logger.Fatal("Init db stock_history_data_q failed, ", err)
-		return err
-	}
-
-	err = initStockList(db)
-	if err != nil {
-		logger.Fatal("Init db stock_list failed, ", err)
-		return err
-	}
-
-	err = initTransPrompt(db)
-	if err != nil {
-		logger.Fatal("Init db trans_prompt failed, ", err)
-		return err
-	}
-
-	err = initTransStockInfo(db)
-	if err != nil {
-		logger.Fatal("Init db trans_stock_info failed, ", err)
-		return err
-	}
-
-	err = initDeepFallStock(db)
-	if err != nil {
-		logger.Fatal("Init db deep_fall_stock failed, ", err)
-		return err
-	}
-
-	err = initSimulation(db)
-	if err != nil {
-		logger.Fatal("Init db simulation failed, ", err)
-		return err
-	}
-
-	err = initTonghuashunSuggestion(db)
-	if err != nil {
-		logger.Fatal("Init db tonghuashun_suggestion failed, ", err)
-		return err
-	}
-
-	err = initTonghuashunMainForceControl(db)
-	if err != nil {
-		logger.Fatal("Init db tonghuashun_main_force_control failed, ", err)
-		return err
-	}
-
-	err = initStockTask(db)
-	if err != nil {
-		logger.Fatal("Init db stock_task failed, ", err)
-		return err
-	}
-
-	return err
-}
-
-// Do not call this method!!!!
-func rebuildModel(db *gorm.DB) {
-	dropRealTimeStock(db)
-	dropHoldStockInfo(db)
-	dropStockHistoryDataQ(db)
-	dropStockList(db)
-	dropTransPrompt(db)
-	dropTransStockInfo(db)
-	dropDeepFallStock(db)
-	dropSimulation(db)
-	dropTonghuashunSuggestion(db)
-	dropTonghuashunMainForceControl(db)
-	dropStockTask(db)
-
-	InitModel(db)
-}
+package model
+
+import (
+	"background/common/logger"
+	"github.com/jinzhu/gorm"
+)
+
+func InitModel(db *gorm.DB) error {
+	var err error
+
+	err = initRealTimeStock(db)
+	if err != nil {
+		logger.Fatal("Init db real_time_stock failed, ", err)
+		return err
+	}
+
+	err = initHoldStockInfo(db)
+	if err != nil {
+		logger.Fatal("Init db hold_stock_info failed, ", err)
+		return err
+	}
+
+	err = initStockHistoryDataQ(db)
+	if err != nil {
+		logger.Fatal("Init db stock_history_data_q failed, ", err)
+		return err
+	}
+
+	err = initStockList(db)
+	if err != nil {
+		logger.Fatal("Init db stock_list failed, ", err)
+		return err
+	}
+
+	err = initTransPrompt(db)
+	if err != nil {
+		logger.Fatal("Init db trans_prompt failed, ", err)
+		return err
+	}
+
+	err = initTransStockInfo(db)
+	if err != nil {
+		logger.Fatal("Init db trans_stock_info failed, ", err)
+		return err
+	}
+
+	err = initDeepFallStock(db)
+	if err != nil {
+		logger.Fatal("Init db deep_fall_stock failed, ", err)
+		return err
+	}
+
+	err = initSimulation(db)
+	if err != nil {
+		logger.Fatal("Init db simulation failed, ", err)
+		return err
+	}
+
+	err = initTonghuashunSuggestion(db)
+	if err != nil {
+		logger.Fatal("Init db tonghuashun_suggestion failed, ", err)
+		return err
+	}
+
+	err = initTonghuashunMainForceControl(db)
+	if err != nil {
+		logger.Fatal("Init db tonghuashun_main_force_control failed, ", err)
+		return err
+	}
+
+	err = initStockTask(db)
+	if err != nil {
+		logger.Fatal("Init db stock_task failed, ", err)
+		return err
+	}
+
+	err = initSimulationEquity(db)
+	if err != nil {
+		logger.Fatal("Init db simulation_equity failed, ", err)
+		return err
+	}
+
+	err = initOptimization(db)
+	if err != nil {
+		logger.Fatal("Init db optimization failed, ", err)
+		return err
+	}
+
+	err = initOptimizationResult(db)
+	if err != nil {
+		logger.Fatal("Init db optimization_result failed, ", err)
+		return err
+	}
+
+	err = initAlertRule(db)
+	if err != nil {
+		logger.Fatal("Init db alert_rule failed, ", err)
+		return err
+	}
+
+	err = initAlertLog(db)
+	if err != nil {
+		logger.Fatal("Init db alert_log failed, ", err)
+		return err
+	}
+
+	err = initAccount(db)
+	if err != nil {
+		logger.Fatal("Init db account failed, ", err)
+		return err
+	}
+
+	err = initAccountTrade(db)
+	if err != nil {
+		logger.Fatal("Init db account_trade failed, ", err)
+		return err
+	}
+
+	err = initAccountSnapshot(db)
+	if err != nil {
+		logger.Fatal("Init db account_snapshot failed, ", err)
+		return err
+	}
+
+	err = initScreen(db)
+	if err != nil {
+		logger.Fatal("Init db screen failed, ", err)
+		return err
+	}
+
+	err = initScreenHit(db)
+	if err != nil {
+		logger.Fatal("Init db screen_hit failed, ", err)
+		return err
+	}
+
+	err = initIndexHistoryData(db)
+	if err != nil {
+		logger.Fatal("Init db index_history_data failed, ", err)
+		return err
+	}
+
+	return err
+}
+
+// Do not call this method!!!!
+func rebuildModel(db *gorm.DB) {
+	dropRealTimeStock(db)
+	dropHoldStockInfo(db)
+	dropStockHistoryDataQ(db)
+	dropStockList(db)
+	dropTransPrompt(db)
+	dropTransStockInfo(db)
+	dropDeepFallStock(db)
+	dropSimulation(db)
+	dropTonghuashunSuggestion(db)
+	dropTonghuashunMainForceControl(db)
+	dropStockTask(db)
+	dropSimulationEquity(db)
+	dropOptimization(db)
+	dropOptimizationResult(db)
+	dropAlertRule(db)
+	dropAlertLog(db)
+	dropAccount(db)
+	dropAccountTrade(db)
+	dropAccountSnapshot(db)
+	dropScreen(db)
+	dropScreenHit(db)
+	dropIndexHistoryData(db)
+
+	InitModel(db)
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+/*
+策略参数优化，grid为参数网格，in_sample_months为0时只在整个区间内遍历参数，
+否则按滚动窗口做walk-forward，每个窗口用样本内数据排名，再用随后的样本外数据检验
+*/
+type Optimization struct {
+	Id              uint32    `gorm:"primary_key" json:"id"`
+	Strategy        string    `gorm:"size:64" json:"strategy"` //策略名称
+	Grid            string    `gorm:"type:text" json:"grid"`   //参数网格，json格式
+	CodeCount       int       `json:"code_count"`              //股票数量
+	BeginDate       string    `gorm:"size:10" json:"begin_date"`
+	EndDate         string    `gorm:"size:10" json:"end_date"`
+	InSampleMonths  int       `json:"in_sample_months"`             //样本内月数
+	OutSampleMonths int       `json:"out_sample_months"`            //样本外月数，也是窗口滚动的步长
+	BestParams      string    `gorm:"type:text" json:"best_params"` //最后一个窗口样本内排名第一的参数
+	InSampleScore   float64   `json:"in_sample_score"`              //各窗口排名第一的参数样本内得分均值
+	OutSampleScore  float64   `json:"out_sample_score"`             //各窗口排名第一的参数样本外得分均值，远低于样本内时说明过拟合
+	Status          uint8     `json:"status"`                       //参见OptimizationStatus*
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+const (
+	OptimizationStatusRunning  = 1
+	OptimizationStatusFinished = 2
+	OptimizationStatusFailed   = 3
+)
+
+func (Optimization) TableName() string {
+	return "optimization"
+}
+
+func initOptimization(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&Optimization{}) {
+		err = db.AutoMigrate(&Optimization{}).Error
+	} else {
+		err = db.CreateTable(&Optimization{}).Error
+	}
+	return err
+}
+
+func dropOptimization(db *gorm.DB) {
+	db.DropTableIfExists(&Optimization{})
+}
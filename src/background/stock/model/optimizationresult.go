@@ -0,0 +1,56 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+/*
+一个窗口内一组参数的结果，各指标为所有股票单独回测的平均值，rank为样本内得分排名
+*/
+type OptimizationResult struct {
+	Id             uint32 `gorm:"primary_key" json:"id"`
+	OptimizationId uint32 `gorm:"index" json:"optimization_id"`
+	Window         int    `json:"window"` //窗口序号，从0开始
+	InSampleBegin  string `gorm:"size:10" json:"in_sample_begin"`
+	InSampleEnd    string `gorm:"size:10" json:"in_sample_end"`
+	OutSampleBegin string `gorm:"size:10" json:"out_sample_begin"` //没有样本外区间时为空
+	OutSampleEnd   string `gorm:"size:10" json:"out_sample_end"`
+	Params         string `gorm:"type:text" json:"params"` //参数，json格式
+	Rank           int    `json:"rank"`
+	CodeCount      int    `json:"code_count"`
+
+	Return       float64 `json:"return"` //样本内平均收益率
+	AnnualReturn float64 `json:"annual_return"`
+	Sharpe       float64 `json:"sharpe"`
+	MaxDrawdown  float64 `json:"max_drawdown"`
+	WinRate      float64 `json:"win_rate"` //收益为正的股票占比
+	TradeCount   int     `json:"trade_count"`
+	Score        float64 `json:"score"`
+
+	OutReturn       float64 `json:"out_return"` //样本外平均收益率
+	OutAnnualReturn float64 `json:"out_annual_return"`
+	OutSharpe       float64 `json:"out_sharpe"`
+	OutMaxDrawdown  float64 `json:"out_max_drawdown"`
+	OutWinRate      float64 `json:"out_win_rate"`
+	OutTradeCount   int     `json:"out_trade_count"`
+	OutScore        float64 `json:"out_score"`
+}
+
+func (OptimizationResult) TableName() string {
+	return "optimization_result"
+}
+
+func initOptimizationResult(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&OptimizationResult{}) {
+		err = db.AutoMigrate(&OptimizationResult{}).Error
+	} else {
+		err = db.CreateTable(&OptimizationResult{}).Error
+	}
+	return err
+}
+
+func dropOptimizationResult(db *gorm.DB) {
+	db.DropTableIfExists(&OptimizationResult{})
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+按时间分表的表，表名为前缀加日期，第一次写入时建表，过期时整表删除
+*/
+var partitionTables = struct {
+	sync.Mutex
+	created map[string]bool
+}{created: make(map[string]bool)}
+
+// 确保分表存在，value为表对应的模型
+func EnsurePartitionTable(db *gorm.DB, table string, value interface{}) error {
+	partitionTables.Lock()
+	defer partitionTables.Unlock()
+
+	if partitionTables.created[table] {
+		return nil
+	}
+	if !db.HasTable(table) {
+		if err := db.Table(table).CreateTable(value).Error; err != nil {
+			return err
+		}
+	}
+	partitionTables.created[table] = true
+	return nil
+}
+
+// 前缀为prefix的所有分表
+func ListPartitionTables(db *gorm.DB, prefix string) ([]string, error) {
+	rows, err := db.Raw("show tables like ?", strings.Replace(prefix, "_", "\\_", -1)+"%").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func DropPartitionTable(db *gorm.DB, table string) error {
+	partitionTables.Lock()
+	delete(partitionTables.created, table)
+	partitionTables.Unlock()
+	return db.DropTableIfExists(table).Error
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+/*
+保存的选股条件，启用的每个交易日收盘后运行，结果保存在screen_hit
+*/
+type Screen struct {
+	Id           uint32     `gorm:"primary_key" json:"id"`
+	Name         string     `gorm:"size:64" json:"name"`
+	Expression   string     `gorm:"size:1024" json:"expression"` //选股条件表达式，参见screener.Compile
+	Status       uint8      `json:"status"`                      //1启用 0停用
+	Remark       string     `gorm:"size:256" json:"remark"`
+	LastRunAt    *time.Time `json:"last_run_at"`
+	LastHitCount int        `json:"last_hit_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (Screen) TableName() string {
+	return "screen"
+}
+
+func initScreen(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&Screen{}) {
+		err = db.AutoMigrate(&Screen{}).Error
+	} else {
+		err = db.CreateTable(&Screen{}).Error
+	}
+	return err
+}
+
+func dropScreen(db *gorm.DB) {
+	db.DropTableIfExists(&Screen{})
+}
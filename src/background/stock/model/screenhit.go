@@ -0,0 +1,36 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+/*
+选股结果，date为运行的日期
+*/
+type ScreenHit struct {
+	Id        uint32  `gorm:"primary_key" json:"id"`
+	ScreenId  uint32  `gorm:"unique_index:idx_screen_date_code" json:"screen_id"`
+	Date      string  `gorm:"size:10;unique_index:idx_screen_date_code" json:"date"`
+	StockCode string  `gorm:"size:6;unique_index:idx_screen_date_code" json:"stock_code"`
+	StockName string  `gorm:"size:32" json:"stock_name"`
+	Close     float64 `json:"close"` //最近一个交易日的收盘价
+}
+
+func (ScreenHit) TableName() string {
+	return "screen_hit"
+}
+
+func initScreenHit(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&ScreenHit{}) {
+		err = db.AutoMigrate(&ScreenHit{}).Error
+	} else {
+		err = db.CreateTable(&ScreenHit{}).Error
+	}
+	return err
+}
+
+func dropScreenHit(db *gorm.DB) {
+	db.DropTableIfExists(&ScreenHit{})
+}
@@ -2,14 +2,25 @@ package model
 
 import (
 	"github.com/jinzhu/gorm"
+	"time"
 )
 
 /*
-模拟信息
+模拟信息，回测的交易记录保存在trans_stock_info，结束时的持仓保存在hold_stock_info，
+每日资产保存在simulation_equity，均通过simulation_id关联
 */
 type Simulation struct {
 	Id                     uint32     `gorm:"primary_key" json:"id"`
-	Value                  string     `gorm:"value" json:"value"`
+	Value                  string     `gorm:"type:text" json:"value"`                      //策略参数，json格式
+	Strategy               string     `gorm:"size:64" json:"strategy"`                     //策略名称
+	Codes                  string     `gorm:"type:text" json:"codes"`                      //回测股票代码，逗号分隔
+	BeginDate              string     `gorm:"size:10" json:"begin_date"`                   //回测开始日期
+	EndDate                string     `gorm:"size:10" json:"end_date"`                     //回测结束日期
+	Benchmark              string     `gorm:"size:16" json:"benchmark"`                    //基准指数代码
+	InitialCash            float64    `json:"initial_cash"`                                //初始资金
+	FinalEquity            float64    `json:"final_equity"`                                //结束时总资产
+	ReturnRate             float64    `json:"return_rate"`                                 //收益率
+	CreatedAt              time.Time  `json:"created_at"`
 }
 
 func (Simulation) TableName() string {
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+/*
+回测每日收盘后的资产
+*/
+type SimulationEquity struct {
+	Id           uint32  `gorm:"primary_key" json:"id"`
+	SimulationId uint32  `gorm:"index" json:"simulation_id"` //模拟ID
+	Date         string  `gorm:"size:10" json:"date"`        //交易日期
+	Cash         float64 `json:"cash"`                       //可用资金
+	MarketValue  float64 `json:"market_value"`               //持仓市值
+	Total        float64 `json:"total"`                      //总资产
+}
+
+func (SimulationEquity) TableName() string {
+	return "simulation_equity"
+}
+
+func initSimulationEquity(db *gorm.DB) error {
+	var err error
+
+	if db.HasTable(&SimulationEquity{}) {
+		err = db.AutoMigrate(&SimulationEquity{}).Error
+	} else {
+		err = db.CreateTable(&SimulationEquity{}).Error
+	}
+	return err
+}
+
+func dropSimulationEquity(db *gorm.DB) {
+	db.DropTableIfExists(&SimulationEquity{})
+}
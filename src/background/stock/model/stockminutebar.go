@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+/*
+分钟K线，按月分表stock_minute_bar_200601，time为K线开始时间
+*/
+type StockMinuteBar struct {
+	Id     uint64    `gorm:"primary_key" json:"id"`
+	Code   string    `gorm:"size:6;unique_index:idx_code_period_time" json:"code"`
+	Period uint8     `gorm:"unique_index:idx_code_period_time" json:"period"` //分钟数，1、5、15、60
+	Time   time.Time `gorm:"unique_index:idx_code_period_time" json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume int64     `json:"volume"` //成交量(股)
+	Amount float64   `json:"amount"` //成交金额(元)
+}
+
+const StockMinuteBarTablePrefix = "stock_minute_bar_"
+
+func StockMinuteBarTable(t time.Time) string {
+	return StockMinuteBarTablePrefix + t.Format("200601")
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+/*
+实时行情的逐笔快照，按天分表stock_tick_20060102，volume、amount为当天累计值
+*/
+type StockTick struct {
+	Id     uint64    `gorm:"primary_key" json:"id"`
+	Code   string    `gorm:"size:6;index:idx_code_time" json:"code"`
+	Time   time.Time `gorm:"index:idx_code_time" json:"time"`
+	Price  float64   `json:"price"`
+	Volume int64     `json:"volume"` //累计成交量(股)
+	Amount float64   `json:"amount"` //累计成交金额(元)
+}
+
+const StockTickTablePrefix = "stock_tick_"
+
+func StockTickTable(t time.Time) string {
+	return StockTickTablePrefix + t.Format("20060102")
+}
@@ -0,0 +1,213 @@
+package portfolio
+
+import (
+	"background/stock/model"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/axgle/mahonia"
+)
+
+// 券商导出的交割单各列可能的列名
+var csvColumns = map[string][]string{
+	"date":   {"成交日期", "发生日期", "交收日期", "日期"},
+	"time":   {"成交时间", "时间"},
+	"code":   {"证券代码", "股票代码", "代码"},
+	"op":     {"操作", "业务名称", "摘要", "买卖标志", "委托类别"},
+	"count":  {"成交数量", "发生数量", "成交股数", "数量"},
+	"price":  {"成交均价", "成交价格", "成交价"},
+	"amount": {"成交金额"},
+	"net":    {"发生金额", "清算金额"}, // 资金变动，分红和转账没有成交金额
+	"fee":    {"手续费", "佣金"},
+	"fee2":   {"过户费", "其他杂费", "交易规费"},
+	"tax":    {"印花税"},
+	"id":     {"合同编号", "成交编号", "委托编号"},
+}
+
+// 操作名称对应的流水类型，按顺序匹配
+var csvOperations = []struct {
+	keyword string
+	typ     uint8
+}{
+	{"红利", model.AccountTradeDividend},
+	{"股息", model.AccountTradeDividend},
+	{"红股", model.AccountTradeBonus},
+	{"送股", model.AccountTradeBonus},
+	{"转增", model.AccountTradeBonus},
+	{"银行转", model.AccountTradeDeposit},
+	{"转入", model.AccountTradeDeposit},
+	{"转银行", model.AccountTradeWithdraw},
+	{"转出", model.AccountTradeWithdraw},
+	{"买", model.AccountTradeBuy},
+	{"卖", model.AccountTradeSell},
+}
+
+func csvOperation(op string) (uint8, bool) {
+	for _, o := range csvOperations {
+		if strings.Contains(op, o.keyword) {
+			return o.typ, true
+		}
+	}
+	return 0, false
+}
+
+// 去掉excel导出时加的="..."和空白
+func csvValue(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	v := strings.TrimSpace(record[i])
+	v = strings.TrimPrefix(v, "=")
+	return strings.TrimSpace(strings.Trim(v, "\"'"))
+}
+
+func csvFloat(record []string, i int) float64 {
+	v, _ := strconv.ParseFloat(strings.Replace(csvValue(record, i), ",", "", -1), 64)
+	return v
+}
+
+func csvDate(v string) (string, bool) {
+	v = strings.Replace(strings.Replace(v, "-", "", -1), "/", "", -1)
+	if len(v) != 8 {
+		return "", false
+	}
+	if _, err := strconv.Atoi(v); err != nil {
+		return "", false
+	}
+	return v[0:4] + "-" + v[4:6] + "-" + v[6:8], true
+}
+
+func csvTime(v string) string {
+	v = strings.Replace(v, ":", "", -1)
+	if len(v) == 5 {
+		v = "0" + v
+	}
+	if len(v) != 6 {
+		return ""
+	}
+	return v[0:2] + ":" + v[2:4] + ":" + v[4:6]
+}
+
+/*
+	解析券商导出的交割单，支持逗号或tab分隔、GBK或UTF-8编码。
+	按列名识别各列，无法识别的操作(如新股申购、利息归本)跳过，返回跳过的行
+*/
+func ParseCsv(r io.Reader) ([]*model.AccountTrade, []string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		data = []byte(mahonia.NewDecoder("gbk").ConvertString(string(data)))
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	firstLine := string(data)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if strings.Contains(firstLine, "\t") && !strings.Contains(firstLine, ",") {
+		reader.Comma = '\t'
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]int)
+	for name, aliases := range csvColumns {
+		columns[name] = -1
+	FIND:
+		for _, alias := range aliases {
+			for i := range header {
+				if csvValue(header, i) == alias {
+					columns[name] = i
+					break FIND
+				}
+			}
+		}
+	}
+	for _, name := range []string{"date", "code", "op"} {
+		if columns[name] < 0 {
+			return nil, nil, fmt.Errorf("交割单缺少%s列", csvColumns[name][0])
+		}
+	}
+
+	var trades []*model.AccountTrade
+	var skipped []string
+	// 成交内容相同的流水出现的次数
+	occurrences := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if len(record) == 0 || strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		op := csvValue(record, columns["op"])
+		typ, ok := csvOperation(op)
+		date, dateOk := csvDate(csvValue(record, columns["date"]))
+		if !ok || !dateOk {
+			skipped = append(skipped, fmt.Sprintf("第%d行 %s", line, strings.Join(record, ",")))
+			continue
+		}
+
+		var t model.AccountTrade
+		t.Type = typ
+		t.Date = date
+		t.Time = csvTime(csvValue(record, columns["time"]))
+		t.Price = math.Abs(csvFloat(record, columns["price"]))
+		t.Count = int64(math.Abs(csvFloat(record, columns["count"])))
+		t.Amount = math.Abs(csvFloat(record, columns["amount"]))
+		if t.Amount == 0 {
+			t.Amount = math.Abs(csvFloat(record, columns["net"]))
+		}
+		t.Fee = math.Abs(csvFloat(record, columns["fee"])) + math.Abs(csvFloat(record, columns["fee2"]))
+		t.Tax = math.Abs(csvFloat(record, columns["tax"]))
+		if typ == model.AccountTradeDividend && strings.Contains(op, "税") {
+			// 红利税补缴
+			t.Tax, t.Amount, t.Price = t.Amount, 0, 0
+		}
+		t.Source = model.AccountTradeSourceCsv
+		t.Remark = op
+
+		code := csvValue(record, columns["code"])
+		if typ != model.AccountTradeDeposit && typ != model.AccountTradeWithdraw {
+			if len(code) < 6 {
+				code = strings.Repeat("0", 6-len(code)) + code
+			}
+			code = code[len(code)-6:]
+			if _, err := strconv.Atoi(code); err != nil {
+				skipped = append(skipped, fmt.Sprintf("第%d行 %s", line, strings.Join(record, ",")))
+				continue
+			}
+			t.StockCode = code
+		}
+
+		// 没有合同编号时用成交内容去重，同一天内容相同的多笔成交按出现次序区分
+		t.ExternalId = csvValue(record, columns["id"])
+		if t.ExternalId == "" {
+			t.ExternalId = fmt.Sprintf("%s %s %s %d %d %v %v", t.Date, t.Time, t.StockCode, t.Type, t.Count, t.Price, t.Amount)
+			occurrences[t.ExternalId]++
+			if n := occurrences[t.ExternalId]; n > 1 {
+				t.ExternalId = fmt.Sprintf("%s #%d", t.ExternalId, n)
+			}
+		}
+		trades = append(trades, &t)
+	}
+	return trades, skipped, nil
+}
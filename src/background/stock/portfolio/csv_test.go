@@ -0,0 +1,95 @@
+package portfolio
+
+import (
+	"background/stock/model"
+	"strings"
+	"testing"
+)
+
+func TestParseCsv(t *testing.T) {
+	data := "成交日期,证券代码,操作,成交数量,成交均价,成交金额,手续费,印花税,合同编号\n" +
+		"20200102,=\"600000\",证券买入,1000,10.00,10000.00,5.00,0,A001\n" +
+		"20200103,600000,证券卖出,-500,11.00,5500.00,5.00,5.50,A002\n" +
+		"20200104,,银行转证券,0,0,0,0,0,\n" +
+		"20200105,732001,新股申购,1000,10.00,0,0,0,A003\n" +
+		"20200106,1,红利入账,0,0,120.00,0,0,A004\n"
+	trades, skipped, err := ParseCsv(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 4 || len(skipped) != 1 || !strings.HasPrefix(skipped[0], "第5行") {
+		t.Fatalf("trades %d, skipped %v", len(trades), skipped)
+	}
+	buy, sell, deposit, dividend := trades[0], trades[1], trades[2], trades[3]
+	if buy.Type != model.AccountTradeBuy || buy.Date != "2020-01-02" || buy.StockCode != "600000" || buy.Count != 1000 || buy.Amount != 10000 || buy.ExternalId != "A001" {
+		t.Errorf("buy %+v", buy)
+	}
+	if sell.Type != model.AccountTradeSell || sell.Count != 500 || sell.Fee != 5 || sell.Tax != 5.5 {
+		t.Errorf("sell %+v", sell)
+	}
+	if deposit.Type != model.AccountTradeDeposit || deposit.StockCode != "" {
+		t.Errorf("deposit %+v", deposit)
+	}
+	if dividend.Type != model.AccountTradeDividend || dividend.StockCode != "000001" || dividend.Amount != 120 {
+		t.Errorf("dividend %+v", dividend)
+	}
+
+	if _, _, err := ParseCsv(strings.NewReader("日期,操作\n20200102,买入\n")); err == nil {
+		t.Error("missing code column")
+	}
+}
+
+// 没有合同编号和成交时间时，同一天内容相同的成交不能被当成重复
+func TestParseCsvIdenticalFills(t *testing.T) {
+	data := "成交日期\t证券代码\t操作\t成交数量\t成交均价\n" +
+		"20200102\t600000\t买入\t100\t10.00\n" +
+		"20200102\t600000\t买入\t100\t10.00\n" +
+		"20200102\t600000\t买入\t200\t10.00\n" +
+		"20200102\t600000\t买入\t100\t10.00\n"
+	trades, _, err := ParseCsv(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]bool)
+	for _, trade := range trades {
+		ids[trade.ExternalId] = true
+	}
+	if len(trades) != 4 || len(ids) != 4 {
+		t.Fatalf("trades %d, ids %v", len(trades), ids)
+	}
+
+	// 重新导入同一份交割单时生成相同的external_id
+	again, _, _ := ParseCsv(strings.NewReader(data))
+	for i := range trades {
+		if again[i].ExternalId != trades[i].ExternalId {
+			t.Errorf("external id %q, again %q", trades[i].ExternalId, again[i].ExternalId)
+		}
+	}
+	if added := mergeTrades(1, trades, again); len(added) != 0 {
+		t.Errorf("reimport added %d", len(added))
+	}
+}
+
+func TestMergeTrades(t *testing.T) {
+	existing := []*model.AccountTrade{{Id: 1, ExternalId: "A001"}, {Id: 2, ExternalId: "api:1"}}
+	trades := []*model.AccountTrade{
+		{Id: 9, ExternalId: "A001"},
+		{Id: 9, ExternalId: "A002"},
+		{Id: 9, ExternalId: "A002"},
+		{Id: 9},
+		{Id: 9},
+	}
+	added := mergeTrades(7, existing, trades)
+	if len(added) != 3 || added[0].ExternalId != "A002" {
+		t.Fatalf("added %v", added)
+	}
+	// 接口录入的流水各自生成不同的external_id
+	if added[1].ExternalId == "" || added[1].ExternalId == added[2].ExternalId || !strings.HasPrefix(added[1].ExternalId, "api:") {
+		t.Errorf("api external ids %q %q", added[1].ExternalId, added[2].ExternalId)
+	}
+	for _, trade := range added {
+		if trade.Id != 0 || trade.AccountId != 7 {
+			t.Errorf("trade %+v", trade)
+		}
+	}
+}
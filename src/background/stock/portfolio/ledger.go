@@ -0,0 +1,282 @@
+package portfolio
+
+import (
+	"background/stock/model"
+	"fmt"
+	"sort"
+)
+
+// 一笔买入形成的持仓批次，卖出时按先进先出扣减
+type Lot struct {
+	Date  string  `json:"date"`
+	Count int64   `json:"count"`
+	Cost  float64 `json:"cost"` // 剩余股数的成本，包含买入费用
+}
+
+type Position struct {
+	Code     string  `json:"code"`
+	Lots     []*Lot  `json:"lots"`
+	Realized float64 `json:"realized"` // 已实现盈亏，包含分红
+	Dividend float64 `json:"dividend"` // 累计税后分红
+	Price    float64 `json:"price"`    // 最新价，Mark之前为0
+}
+
+func (p *Position) Count() int64 {
+	var count int64
+	for _, lot := range p.Lots {
+		count += lot.Count
+	}
+	return count
+}
+
+func (p *Position) Cost() float64 {
+	var cost float64
+	for _, lot := range p.Lots {
+		cost += lot.Cost
+	}
+	return cost
+}
+
+func (p *Position) AvgPrice() float64 {
+	if count := p.Count(); count > 0 {
+		return p.Cost() / float64(count)
+	}
+	return 0
+}
+
+func (p *Position) MarketValue() float64 {
+	return p.Price * float64(p.Count())
+}
+
+func (p *Position) Unrealized() float64 {
+	if p.Count() == 0 {
+		return 0
+	}
+	return p.MarketValue() - p.Cost()
+}
+
+/*
+	账户账本：按时间顺序应用流水，得到现金、持仓批次和已实现盈亏。
+	已清仓的股票保留在Positions中，Count为0
+*/
+type Ledger struct {
+	Cash       float64
+	NetDeposit float64 // 初始资金+累计转入-累计转出
+	Fees       float64 // 累计费用和税
+	Positions  map[string]*Position
+}
+
+func NewLedger(cash float64) *Ledger {
+	return &Ledger{Cash: cash, NetDeposit: cash, Positions: make(map[string]*Position)}
+}
+
+func (l *Ledger) position(code string) *Position {
+	pos, ok := l.Positions[code]
+	if !ok {
+		pos = &Position{Code: code}
+		l.Positions[code] = pos
+	}
+	return pos
+}
+
+// 成交金额，没有填写时按价格和数量计算
+func tradeAmount(t *model.AccountTrade) float64 {
+	if t.Amount != 0 {
+		return t.Amount
+	}
+	return t.Price * float64(t.Count)
+}
+
+// 流水和账本状态不符，如卖出数量超过持仓
+type TradeError struct {
+	Trade  *model.AccountTrade
+	Reason string
+}
+
+func (e *TradeError) Error() string {
+	return e.Trade.Date + " " + e.Trade.StockCode + " " + e.Reason
+}
+
+func (l *Ledger) Apply(t *model.AccountTrade) error {
+	switch t.Type {
+	case model.AccountTradeBuy:
+		if t.StockCode == "" || t.Count <= 0 {
+			return &TradeError{t, fmt.Sprintf("买入数量%d不正确", t.Count)}
+		}
+		cost := tradeAmount(t) + t.Fee + t.Tax
+		l.Cash -= cost
+		l.Fees += t.Fee + t.Tax
+		pos := l.position(t.StockCode)
+		pos.Lots = append(pos.Lots, &Lot{Date: t.Date, Count: t.Count, Cost: cost})
+	case model.AccountTradeSell:
+		pos := l.Positions[t.StockCode]
+		if t.Count <= 0 || pos == nil || pos.Count() < t.Count {
+			return &TradeError{t, fmt.Sprintf("卖出数量%d超过持仓", t.Count)}
+		}
+		proceeds := tradeAmount(t) - t.Fee - t.Tax
+		var basis float64
+		remain := t.Count
+		for remain > 0 {
+			lot := pos.Lots[0]
+			n := lot.Count
+			if n > remain {
+				n = remain
+			}
+			cost := lot.Cost * float64(n) / float64(lot.Count)
+			basis += cost
+			lot.Cost -= cost
+			lot.Count -= n
+			remain -= n
+			if lot.Count == 0 {
+				pos.Lots = pos.Lots[1:]
+			}
+		}
+		l.Cash += proceeds
+		l.Fees += t.Fee + t.Tax
+		pos.Realized += proceeds - basis
+	case model.AccountTradeDividend:
+		pos := l.Positions[t.StockCode]
+		if pos == nil {
+			return &TradeError{t, "没有持仓，不能分红"}
+		}
+		// 没有填写金额时Price为每股分红
+		amount := t.Amount
+		if amount == 0 {
+			amount = t.Price * float64(pos.Count())
+		}
+		net := amount - t.Fee - t.Tax
+		l.Cash += net
+		l.Fees += t.Fee + t.Tax
+		pos.Realized += net
+		pos.Dividend += net
+	case model.AccountTradeBonus:
+		pos := l.Positions[t.StockCode]
+		if t.Count <= 0 || pos == nil || pos.Count() == 0 {
+			return &TradeError{t, "没有持仓，不能送转股"}
+		}
+		// 送转的股数按比例分到每个批次，成本不变
+		held := pos.Count()
+		var added int64
+		for i, lot := range pos.Lots {
+			n := lot.Count * t.Count / held
+			if i == len(pos.Lots)-1 {
+				n = t.Count - added
+			}
+			lot.Count += n
+			added += n
+		}
+		l.Cash -= t.Fee + t.Tax
+		l.Fees += t.Fee + t.Tax
+		pos.Realized -= t.Fee + t.Tax
+	case model.AccountTradeDeposit:
+		if t.Amount <= 0 {
+			return &TradeError{t, "转入金额不正确"}
+		}
+		l.Cash += t.Amount
+		l.NetDeposit += t.Amount
+	case model.AccountTradeWithdraw:
+		if t.Amount <= 0 {
+			return &TradeError{t, "转出金额不正确"}
+		}
+		l.Cash -= t.Amount
+		l.NetDeposit -= t.Amount
+	default:
+		return &TradeError{t, fmt.Sprintf("未知的流水类型%d", t.Type)}
+	}
+	return nil
+}
+
+// 按日期、时间排序流水，同一时间按录入顺序
+func SortTrades(trades []*model.AccountTrade) {
+	sort.SliceStable(trades, func(i, j int) bool {
+		if trades[i].Date != trades[j].Date {
+			return trades[i].Date < trades[j].Date
+		}
+		if trades[i].Time != trades[j].Time {
+			return trades[i].Time < trades[j].Time
+		}
+		return trades[i].Id < trades[j].Id
+	})
+}
+
+/*
+	从初始资金开始重放全部流水
+*/
+func Replay(cash float64, trades []*model.AccountTrade) (*Ledger, error) {
+	SortTrades(trades)
+	l := NewLedger(cash)
+	for _, t := range trades {
+		if err := l.Apply(t); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// 用最新价更新持仓，没有价格的股票按持仓均价计算
+func (l *Ledger) Mark(prices map[string]float64) {
+	for code, pos := range l.Positions {
+		if price, ok := prices[code]; ok && price > 0 {
+			pos.Price = price
+		} else if pos.Price == 0 {
+			pos.Price = pos.AvgPrice()
+		}
+	}
+}
+
+// 当前持仓(不含已清仓)，按代码排序
+func (l *Ledger) Holdings() []*Position {
+	var positions []*Position
+	for _, pos := range l.Positions {
+		if pos.Count() > 0 {
+			positions = append(positions, pos)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Code < positions[j].Code })
+	return positions
+}
+
+// 持仓股票代码
+func (l *Ledger) Codes() []string {
+	var codes []string
+	for _, pos := range l.Holdings() {
+		codes = append(codes, pos.Code)
+	}
+	return codes
+}
+
+func (l *Ledger) MarketValue() float64 {
+	var value float64
+	for _, pos := range l.Positions {
+		value += pos.MarketValue()
+	}
+	return value
+}
+
+func (l *Ledger) Cost() float64 {
+	var cost float64
+	for _, pos := range l.Positions {
+		cost += pos.Cost()
+	}
+	return cost
+}
+
+func (l *Ledger) Realized() float64 {
+	var realized float64
+	for _, pos := range l.Positions {
+		realized += pos.Realized
+	}
+	return realized
+}
+
+func (l *Ledger) Unrealized() float64 {
+	var unrealized float64
+	for _, pos := range l.Positions {
+		unrealized += pos.Unrealized()
+	}
+	return unrealized
+}
+
+func (l *Ledger) Equity() float64 {
+	return l.Cash + l.MarketValue()
+}
@@ -0,0 +1,95 @@
+package portfolio
+
+import (
+	"background/stock/model"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return a-b < 1e-6 && b-a < 1e-6
+}
+
+func trade(date string, typ uint8, code string, count int64, price, fee float64) *model.AccountTrade {
+	return &model.AccountTrade{Date: date, Type: typ, StockCode: code, Count: count, Price: price, Fee: fee}
+}
+
+func TestReplay(t *testing.T) {
+	trades := []*model.AccountTrade{
+		// 乱序录入，按日期重放
+		trade("2020-01-03", model.AccountTradeBuy, "600000", 1000, 12, 5),
+		trade("2020-01-02", model.AccountTradeBuy, "600000", 1000, 10, 5),
+		trade("2020-01-06", model.AccountTradeSell, "600000", 1500, 13, 10),
+		trade("2020-01-07", model.AccountTradeDividend, "600000", 0, 0.5, 0),
+		trade("2020-01-08", model.AccountTradeBonus, "600000", 500, 0, 0),
+		{Date: "2020-01-09", Type: model.AccountTradeDeposit, Amount: 10000},
+		{Date: "2020-01-10", Type: model.AccountTradeWithdraw, Amount: 3000},
+	}
+	l, err := Replay(100000, trades)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 先进先出：卖出第一批1000股和第二批500股
+	basis := 10005 + 12005.0/2
+	realized := 13*1500 - 10 - basis
+	// 剩余500股每股分红0.5
+	realized += 250
+	if pos := l.Positions["600000"]; !near(pos.Realized, realized) || pos.Dividend != 250 || pos.Count() != 1000 || !near(pos.Cost(), 12005.0/2) {
+		t.Errorf("position %+v, realized %v", pos, realized)
+	}
+	if cash := 100000 - 10005 - 12005 + 13*1500 - 10 + 250 + 10000 - 3000.0; !near(l.Cash, cash) {
+		t.Errorf("cash %v, want %v", l.Cash, cash)
+	}
+	if l.NetDeposit != 107000 || l.Fees != 20 {
+		t.Errorf("net deposit %v, fees %v", l.NetDeposit, l.Fees)
+	}
+
+	l.Mark(map[string]float64{"600000": 7})
+	if !near(l.Unrealized(), 7000-12005.0/2) || !near(l.Equity(), l.Cash+7000) {
+		t.Errorf("unrealized %v, equity %v", l.Unrealized(), l.Equity())
+	}
+	if codes := l.Codes(); len(codes) != 1 || codes[0] != "600000" {
+		t.Errorf("codes %v", codes)
+	}
+}
+
+func TestReplayInvalid(t *testing.T) {
+	for name, trades := range map[string][]*model.AccountTrade{
+		"sell more than held": {
+			trade("2020-01-02", model.AccountTradeBuy, "600000", 100, 10, 0),
+			trade("2020-01-03", model.AccountTradeSell, "600000", 200, 10, 0),
+		},
+		"sell before buy": {
+			trade("2020-01-03", model.AccountTradeBuy, "600000", 100, 10, 0),
+			trade("2020-01-02", model.AccountTradeSell, "600000", 100, 10, 0),
+		},
+		"dividend without position": {
+			trade("2020-01-02", model.AccountTradeDividend, "600000", 0, 0.5, 0),
+		},
+		"empty withdraw": {
+			{Date: "2020-01-02", Type: model.AccountTradeWithdraw},
+		},
+	} {
+		if _, err := Replay(100000, trades); err == nil {
+			t.Errorf("%s: no error", name)
+		} else if _, ok := err.(*TradeError); !ok {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// 同一时间的流水按录入顺序
+func TestSortTrades(t *testing.T) {
+	trades := []*model.AccountTrade{
+		{Id: 3, Date: "2020-01-02", Time: "10:00:00"},
+		{Id: 2, Date: "2020-01-02", Time: "09:30:00"},
+		{Id: 1, Date: "2020-01-02", Time: "10:00:00"},
+		{Id: 4, Date: "2020-01-01", Time: "14:00:00"},
+	}
+	SortTrades(trades)
+	for i, id := range []uint32{4, 2, 1, 3} {
+		if trades[i].Id != id {
+			t.Fatalf("order %d: %d, want %d", i, trades[i].Id, id)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package portfolio
+
+import (
+	"background/common/uuid"
+	"background/stock/model"
+	"background/stock/service"
+	"background/stock/tools/util"
+
+	"github.com/jinzhu/gorm"
+)
+
+func LoadTrades(db *gorm.DB, accountId uint32) ([]*model.AccountTrade, error) {
+	var trades []*model.AccountTrade
+	if err := db.Where("account_id = ?", accountId).Find(&trades).Error; err != nil {
+		return nil, err
+	}
+	SortTrades(trades)
+	return trades, nil
+}
+
+func Load(db *gorm.DB, account *model.Account) (*Ledger, error) {
+	trades, err := LoadTrades(db, account.Id)
+	if err != nil {
+		return nil, err
+	}
+	return Replay(account.InitialCash, trades)
+}
+
+/*
+	合并新流水，已存在相同external_id的跳过，没有external_id的(接口录入)生成一个
+*/
+func mergeTrades(accountId uint32, existing, trades []*model.AccountTrade) []*model.AccountTrade {
+	seen := make(map[string]bool)
+	for _, t := range existing {
+		seen[t.ExternalId] = true
+	}
+
+	var adding []*model.AccountTrade
+	for _, t := range trades {
+		if t.ExternalId == "" {
+			t.ExternalId = "api:" + uuid.New()
+		}
+		if seen[t.ExternalId] {
+			continue
+		}
+		seen[t.ExternalId] = true
+		t.Id = 0
+		t.AccountId = accountId
+		adding = append(adding, t)
+	}
+	return adding
+}
+
+/*
+	锁定账户，同一账户的导入、录入和删除依次进行
+*/
+func lockAccount(tx *gorm.DB, account *model.Account) error {
+	var locked model.Account
+	return tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", account.Id).First(&locked).Error
+}
+
+/*
+	添加流水，已存在相同external_id的跳过。加上新流水后重放校验，不通过时返回*TradeError，不保存任何流水
+*/
+func AddTrades(db *gorm.DB, account *model.Account, trades []*model.AccountTrade) (added int, err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = lockAccount(tx, account); err != nil {
+		return 0, err
+	}
+	existing, err := LoadTrades(tx, account.Id)
+	if err != nil {
+		return 0, err
+	}
+	adding := mergeTrades(account.Id, existing, trades)
+	if len(adding) == 0 {
+		tx.Rollback()
+		return 0, nil
+	}
+	if _, err = Replay(account.InitialCash, append(existing, adding...)); err != nil {
+		return 0, err
+	}
+
+	for _, t := range adding {
+		if err = tx.Create(t).Error; err != nil {
+			return 0, err
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return len(adding), nil
+}
+
+/*
+	删除流水，删除后账本不正确(如之后的卖出没有了对应的买入)时返回*TradeError
+*/
+func DeleteTrade(db *gorm.DB, account *model.Account, id uint32) (err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = lockAccount(tx, account); err != nil {
+		return err
+	}
+	trades, err := LoadTrades(tx, account.Id)
+	if err != nil {
+		return err
+	}
+	var remain []*model.AccountTrade
+	for _, t := range trades {
+		if t.Id != id {
+			remain = append(remain, t)
+		}
+	}
+	if len(remain) == len(trades) {
+		return gorm.ErrRecordNotFound
+	}
+	if _, err = Replay(account.InitialCash, remain); err != nil {
+		return err
+	}
+	if err = tx.Where("id = ? and account_id = ?", id, account.Id).Delete(&model.AccountTrade{}).Error; err != nil {
+		return err
+	}
+	return tx.Commit().Error
+}
+
+/*
+	用实时行情更新持仓价格，收盘后为当天收盘价
+*/
+func MarkRealTime(l *Ledger) error {
+	var codes []string
+	for _, code := range l.Codes() {
+		if jysCode := util.GetJysCodeByStockCode(code); jysCode != "" {
+			codes = append(codes, jysCode+code)
+		}
+	}
+	prices := make(map[string]float64)
+	if len(codes) > 0 {
+		quotes, err := service.GetRealTimeStocks(codes)
+		if err != nil {
+			return err
+		}
+		for code, quote := range quotes {
+			// 停牌时使用昨收价
+			prices[code] = quote.NowPrice
+			if prices[code] == 0 {
+				prices[code] = quote.YestdayClosePrice
+			}
+		}
+	}
+	l.Mark(prices)
+	return nil
+}
+
+func NewSnapshot(account *model.Account, date string, l *Ledger) *model.AccountSnapshot {
+	var snapshot model.AccountSnapshot
+	snapshot.AccountId = account.Id
+	snapshot.Date = date
+	snapshot.Cash = l.Cash
+	snapshot.MarketValue = l.MarketValue()
+	snapshot.Equity = l.Equity()
+	snapshot.NetDeposit = l.NetDeposit
+	snapshot.Cost = l.Cost()
+	snapshot.RealizedProfit = l.Realized()
+	snapshot.UnrealizedProfit = l.Unrealized()
+	return &snapshot
+}
+
+/*
+	保存账户当天的资产快照，已存在时覆盖
+*/
+func SaveSnapshot(db *gorm.DB, account *model.Account, date string) (*model.AccountSnapshot, error) {
+	l, err := Load(db, account)
+	if err != nil {
+		return nil, err
+	}
+	if err := MarkRealTime(l); err != nil {
+		return nil, err
+	}
+	snapshot := NewSnapshot(account, date, l)
+
+	var old model.AccountSnapshot
+	if err := db.Where("account_id = ? and date = ?", account.Id, date).First(&old).Error; err == nil {
+		snapshot.Id = old.Id
+		snapshot.CreatedAt = old.CreatedAt
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if err := db.Save(snapshot).Error; err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,134 @@
+package screener
+
+import (
+	"background/stock/indicator"
+	"background/stock/model"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 计算日线指标需要的历史数据，保证MACD等指数平均收敛
+const indicatorLookback = 250
+
+/*
+	一只股票的求值环境，Points为前复权日线(不含停牌日)，按日期升序
+*/
+type Env struct {
+	Stock  *model.StockList
+	Points []*indicator.Point
+	Now    time.Time
+	values *indicator.Values
+	days   int // 计算指标用到的交易日数
+}
+
+// 往前第offset个交易日的行情，0为最近一个交易日
+func (e *Env) point(offset int) *indicator.Point {
+	i := len(e.Points) - 1 - offset
+	if i < 0 {
+		return nil
+	}
+	return e.Points[i]
+}
+
+// 最近n个交易日的行情，不足n个时返回nil
+func (e *Env) last(n int) []*indicator.Point {
+	if n <= 0 || len(e.Points) < n {
+		return nil
+	}
+	return e.Points[len(e.Points)-n:]
+}
+
+// 最近一个交易日的指标，没有行情时为nil
+func (e *Env) Values() *indicator.Values {
+	if e.values == nil && len(e.Points) > 0 {
+		series := indicator.Calculate(e.Points)
+		if len(series) > 0 {
+			e.values = series[len(series)-1]
+			e.days = len(series)
+		}
+	}
+	return e.values
+}
+
+// stock_list中的基本面数据，字符串保存，空或"-"为缺失
+var fundamentals = map[string]func(s *model.StockList) string{
+	"pe":                 func(s *model.StockList) string { return s.Pe },
+	"pb":                 func(s *model.StockList) string { return s.Pb },
+	"esp":                func(s *model.StockList) string { return s.Esp },
+	"eps":                func(s *model.StockList) string { return s.Esp },
+	"bvps":               func(s *model.StockList) string { return s.Bvps },
+	"outstanding":        func(s *model.StockList) string { return s.Outstanding },
+	"totals":             func(s *model.StockList) string { return s.Totals },
+	"total_assets":       func(s *model.StockList) string { return s.TotalAssets },
+	"liquid_assets":      func(s *model.StockList) string { return s.LiquidAssets },
+	"fixed_assets":       func(s *model.StockList) string { return s.FixedAssets },
+	"reserved":           func(s *model.StockList) string { return s.Reserved },
+	"reserved_per_share": func(s *model.StockList) string { return s.ReservedPerShare },
+	"undp":               func(s *model.StockList) string { return s.Undp },
+	"perundp":            func(s *model.StockList) string { return s.Perundp },
+	"rev":                func(s *model.StockList) string { return s.Rev },
+	"profit":             func(s *model.StockList) string { return s.Profit },
+	"gpr":                func(s *model.StockList) string { return s.Gpr },
+	"npr":                func(s *model.StockList) string { return s.Npr },
+	"holders":            func(s *model.StockList) string { return s.Holders },
+}
+
+type fundamentalNode struct {
+	field func(s *model.StockList) string
+}
+
+func (n *fundamentalNode) num(env *Env) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(n.field(env.Stock)), 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return v
+}
+
+type indicatorField struct {
+	value func(v *indicator.Values) float64
+	days  int // 指标有效需要的交易日数，与indicator.Calculator的周期一致
+}
+
+/*
+	最近一个交易日的日线指标，参见indicator.Values。
+	数据不足时Values中的指标为0，这里作为缺失处理
+*/
+var indicatorFields = map[string]indicatorField{
+	"ma5":          {func(v *indicator.Values) float64 { return v.MA5 }, 5},
+	"ma10":         {func(v *indicator.Values) float64 { return v.MA10 }, 10},
+	"ma20":         {func(v *indicator.Values) float64 { return v.MA20 }, 20},
+	"ma60":         {func(v *indicator.Values) float64 { return v.MA60 }, 60},
+	"ema12":        {func(v *indicator.Values) float64 { return v.EMA12 }, 26},
+	"ema26":        {func(v *indicator.Values) float64 { return v.EMA26 }, 26},
+	"dif":          {func(v *indicator.Values) float64 { return v.DIF }, 26},
+	"dea":          {func(v *indicator.Values) float64 { return v.DEA }, 26},
+	"macd":         {func(v *indicator.Values) float64 { return v.MACD }, 26},
+	"rsi6":         {func(v *indicator.Values) float64 { return v.RSI6 }, 7},
+	"rsi12":        {func(v *indicator.Values) float64 { return v.RSI12 }, 13},
+	"rsi24":        {func(v *indicator.Values) float64 { return v.RSI24 }, 25},
+	"k":            {func(v *indicator.Values) float64 { return v.K }, 9},
+	"d":            {func(v *indicator.Values) float64 { return v.D }, 9},
+	"j":            {func(v *indicator.Values) float64 { return v.J }, 9},
+	"boll_upper":   {func(v *indicator.Values) float64 { return v.BollUpper }, 20},
+	"boll_mid":     {func(v *indicator.Values) float64 { return v.BollMid }, 20},
+	"boll_lower":   {func(v *indicator.Values) float64 { return v.BollLower }, 20},
+	"atr":          {func(v *indicator.Values) float64 { return v.ATR }, 15},
+	"obv":          {func(v *indicator.Values) float64 { return v.OBV }, 1},
+	"volume_ratio": {func(v *indicator.Values) float64 { return v.VolumeRatio }, 6},
+}
+
+type indicatorNode struct {
+	name string
+}
+
+func (n *indicatorNode) num(env *Env) float64 {
+	values := env.Values()
+	field := indicatorFields[n.name]
+	if values == nil || env.days < field.days {
+		return math.NaN()
+	}
+	return field.value(values)
+}
@@ -0,0 +1,518 @@
+package screener
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+	选股条件表达式，例如 pe < 20 and close > ma(60) and drop_from_high(180) > 0.5
+	支持 and/or/not(也可以写作&&、||、!)、比较运算、四则运算和括号，
+	函数参数必须是数字常量。数据缺失(如没有市盈率、历史数据不足)时比较结果未知，
+	not之后仍然未知，整个条件结果未知时不匹配
+*/
+type Expr struct {
+	Source   string
+	root     boolNode
+	lookback int // 需要的历史交易日数，0表示只用到基本面数据
+}
+
+type numNode interface {
+	num(env *Env) float64
+}
+
+// known为false表示数据缺失，结果未知
+type boolNode interface {
+	test(env *Env) (match, known bool)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+	pos   int
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			value, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("第%d个字符: 数字%s不正确", start+1, src[start:i])
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], value: value, pos: start})
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] >= 'a' && src[i] <= 'z' || src[i] >= 'A' && src[i] <= 'Z' || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			text := strings.ToLower(src[start:i])
+			switch text {
+			case "and", "or", "not":
+				tokens = append(tokens, token{kind: tokenOp, text: text, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokenIdent, text: text, pos: start})
+			}
+		default:
+			op := ""
+			for _, candidate := range []string{"<=", ">=", "==", "!=", "&&", "||", "<", ">", "=", "!", "+", "-", "*", "/", "(", ")", ","} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("第%d个字符: 不能识别的字符%q", i+1, src[i:i+1])
+			}
+			text := op
+			switch op {
+			case "&&":
+				text = "and"
+			case "||":
+				text = "or"
+			case "!":
+				text = "not"
+			case "=":
+				text = "=="
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: text, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	lookback int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(texts ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("第%d个字符: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+/*
+	解析并检查表达式，结果必须是条件(比较或逻辑运算)
+*/
+func Compile(src string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "多余的%s", t.text)
+	}
+	cond, ok := root.(boolNode)
+	if !ok {
+		return nil, fmt.Errorf("表达式的结果必须是条件，如 close > ma(20)")
+	}
+	return &Expr{Source: src, root: cond, lookback: p.lookback}, nil
+}
+
+// 条件的运算对象
+func (p *parser) expectBool(node interface{}, t token) (boolNode, error) {
+	if b, ok := node.(boolNode); ok {
+		return b, nil
+	}
+	return nil, p.errorf(t, "%s的运算对象必须是条件", t.text)
+}
+
+func (p *parser) expectNum(node interface{}, t token) (numNode, error) {
+	if n, ok := node.(numNode); ok {
+		return n, nil
+	}
+	return nil, p.errorf(t, "%s的运算对象必须是数值", t.text)
+}
+
+func (p *parser) parseOr() (interface{}, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("or") {
+		op := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l, err := p.expectBool(left, op)
+		if err != nil {
+			return nil, err
+		}
+		r, err := p.expectBool(right, op)
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{l, r}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (interface{}, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("and") {
+		op := p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l, err := p.expectBool(left, op)
+		if err != nil {
+			return nil, err
+		}
+		r, err := p.expectBool(right, op)
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{l, r}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (interface{}, error) {
+	if p.isOp("not") {
+		op := p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.expectBool(operand, op)
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{b}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (interface{}, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("<", "<=", ">", ">=", "==", "!=") {
+		return left, nil
+	}
+	op := p.next()
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	l, err := p.expectNum(left, op)
+	if err != nil {
+		return nil, err
+	}
+	r, err := p.expectNum(right, op)
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("<", "<=", ">", ">=", "==", "!=") {
+		return nil, p.errorf(p.peek(), "比较运算不能连写")
+	}
+	return &compareNode{op.text, l, r}, nil
+}
+
+func (p *parser) parseSum() (interface{}, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		op := p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		l, err := p.expectNum(left, op)
+		if err != nil {
+			return nil, err
+		}
+		r, err := p.expectNum(right, op)
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op.text, l, r}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (interface{}, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/") {
+		op := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l, err := p.expectNum(left, op)
+		if err != nil {
+			return nil, err
+		}
+		r, err := p.expectNum(right, op)
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op.text, l, r}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (interface{}, error) {
+	if p.isOp("-") {
+		op := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		n, err := p.expectNum(operand, op)
+		if err != nil {
+			return nil, err
+		}
+		return &arithNode{"-", constNode(0), n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (interface{}, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenNumber:
+		return constNode(t.value), nil
+	case t.kind == tokenOp && t.text == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.errorf(p.peek(), "缺少)")
+		}
+		p.next()
+		return node, nil
+	case t.kind == tokenIdent:
+		var args []float64
+		if p.isOp("(") {
+			p.next()
+			for !p.isOp(")") {
+				if len(args) > 0 {
+					if !p.isOp(",") {
+						return nil, p.errorf(p.peek(), "缺少,或)")
+					}
+					p.next()
+				}
+				negative := false
+				if p.isOp("-") {
+					p.next()
+					negative = true
+				}
+				arg := p.next()
+				if arg.kind != tokenNumber {
+					return nil, p.errorf(arg, "%s的参数必须是数字", t.text)
+				}
+				if negative {
+					arg.value = -arg.value
+				}
+				args = append(args, arg.value)
+			}
+			p.next()
+		}
+		return p.call(t, args)
+	case t.kind == tokenEOF:
+		return nil, p.errorf(t, "表达式不完整")
+	}
+	return nil, p.errorf(t, "不能识别的%s", t.text)
+}
+
+func (p *parser) call(t token, args []float64) (numNode, error) {
+	if field, ok := fundamentals[t.text]; ok {
+		if len(args) > 0 {
+			return nil, p.errorf(t, "%s没有参数", t.text)
+		}
+		return &fundamentalNode{field}, nil
+	}
+	if _, ok := indicatorFields[t.text]; ok {
+		if len(args) > 0 {
+			return nil, p.errorf(t, "%s没有参数", t.text)
+		}
+		if p.lookback < indicatorLookback {
+			p.lookback = indicatorLookback
+		}
+		return &indicatorNode{t.text}, nil
+	}
+	f, ok := functions[t.text]
+	if !ok {
+		return nil, p.errorf(t, "未知的字段或函数%s", t.text)
+	}
+	if len(args) < f.minArgs || len(args) > f.maxArgs {
+		return nil, p.errorf(t, "%s的参数个数不正确", t.text)
+	}
+	lookback, err := f.check(args)
+	if err != nil {
+		return nil, p.errorf(t, "%s: %s", t.text, err.Error())
+	}
+	if lookback > p.lookback {
+		p.lookback = lookback
+	}
+	return &callNode{f, args}, nil
+}
+
+type constNode float64
+
+func (n constNode) num(env *Env) float64 {
+	return float64(n)
+}
+
+type arithNode struct {
+	op          string
+	left, right numNode
+}
+
+func (n *arithNode) num(env *Env) float64 {
+	l, r := n.left.num(env), n.right.num(env)
+	switch n.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	}
+	if r == 0 {
+		return math.NaN()
+	}
+	return l / r
+}
+
+type compareNode struct {
+	op          string
+	left, right numNode
+}
+
+func (n *compareNode) test(env *Env) (bool, bool) {
+	l, r := n.left.num(env), n.right.num(env)
+	if math.IsNaN(l) || math.IsNaN(r) || math.IsInf(l, 0) || math.IsInf(r, 0) {
+		return false, false
+	}
+	switch n.op {
+	case "<":
+		return l < r, true
+	case "<=":
+		return l <= r, true
+	case ">":
+		return l > r, true
+	case ">=":
+		return l >= r, true
+	case "==":
+		return l == r, true
+	}
+	return l != r, true
+}
+
+type andNode struct {
+	left, right boolNode
+}
+
+// 一边确定为false时结果为false，否则有一边未知时结果未知
+func (n *andNode) test(env *Env) (bool, bool) {
+	l, lKnown := n.left.test(env)
+	if lKnown && !l {
+		return false, true
+	}
+	r, rKnown := n.right.test(env)
+	if rKnown && !r {
+		return false, true
+	}
+	return lKnown && rKnown, lKnown && rKnown
+}
+
+type orNode struct {
+	left, right boolNode
+}
+
+// 一边确定为true时结果为true，否则有一边未知时结果未知
+func (n *orNode) test(env *Env) (bool, bool) {
+	l, lKnown := n.left.test(env)
+	if lKnown && l {
+		return true, true
+	}
+	r, rKnown := n.right.test(env)
+	if rKnown && r {
+		return true, true
+	}
+	return false, lKnown && rKnown
+}
+
+type notNode struct {
+	operand boolNode
+}
+
+func (n *notNode) test(env *Env) (bool, bool) {
+	match, known := n.operand.test(env)
+	return !match && known, known
+}
+
+// 需要的历史交易日数
+func (e *Expr) Lookback() int {
+	return e.lookback
+}
+
+func (e *Expr) Match(env *Env) bool {
+	match, known := e.root.test(env)
+	return match && known
+}
@@ -0,0 +1,149 @@
+package screener
+
+import (
+	"background/stock/indicator"
+	"background/stock/model"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCompile(t *testing.T) {
+	for src, lookback := range map[string]int{
+		"pe < 20":                                    0,
+		"PE < 20 AND pb > 1":                         0,
+		"pe < 20 && !(pb > 1) || listed_days >= 365": 0,
+		"close > ma(60)":                             60,
+		"close(5) > 0":                               6,
+		"change(10) > 0.1":                           11,
+		"swing_count(120, 0.2) >= 3":                 120,
+		"close > ma60":                               indicatorLookback,
+		"-close < -1 * 2":                            1,
+	} {
+		expr, err := Compile(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if expr.Lookback() != lookback {
+			t.Errorf("%s: lookback %d, want %d", src, expr.Lookback(), lookback)
+		}
+	}
+
+	for _, src := range []string{
+		"",
+		"pe",
+		"pe + 1",
+		"pe < 20 and",
+		"pe < 20 and pb",
+		"1 < pe < 20",
+		"(pe < 20",
+		"pe < 20)",
+		"foo > 1",
+		"pe(1) > 1",
+		"ma60(1) > 1",
+		"ma(0) > 1",
+		"ma(1.5) > 1",
+		"ma(close) > 1",
+		"swing_count(10) > 1",
+		"swing_count(10, 0) > 1",
+		"pe < 20 # 1",
+	} {
+		if _, err := Compile(src); err == nil {
+			t.Errorf("%q compiled", src)
+		}
+	}
+}
+
+// n个交易日的行情，收盘价从1开始每天涨0.1
+func risingPoints(n int) []*indicator.Point {
+	points := make([]*indicator.Point, 0, n)
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		close := 1 + float64(i)*0.1
+		points = append(points, &indicator.Point{
+			Date:   day.AddDate(0, 0, i).Format("2006-01-02"),
+			Open:   close,
+			High:   close * 1.01,
+			Low:    close * 0.99,
+			Close:  close,
+			Volume: 1000,
+		})
+	}
+	return points
+}
+
+func match(t *testing.T, src string, env *Env) bool {
+	expr, err := Compile(src)
+	if err != nil {
+		t.Fatalf("%s: %v", src, err)
+	}
+	return expr.Match(env)
+}
+
+func TestMatch(t *testing.T) {
+	stock := &model.StockList{Code: "600000", Pe: "15.5", Pb: "-", TimeToMarket: "20190101"}
+	env := &Env{Stock: stock, Points: risingPoints(100), Now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	for src, want := range map[string]bool{
+		"pe < 20":                  true,
+		"pe >= 20":                 false,
+		"pe = 15.5":                true,
+		"pe * 2 - 1 == 30":         true,
+		"listed_days == 365":       true,
+		"close > ma(60)":           true,
+		"close > ma60":             true,
+		"close(1) < close":         true,
+		"change(10) > 0":           true,
+		"highest(5) == high":       true,
+		"drop_from_high(20) > 0":   true,
+		"close > ma(101)":          false, // 历史数据不足
+		"close(100) > 0":           false,
+		"pe / 0 > 1":               false,
+		"rsi6 > 50":                true,
+		"volume_ratio == 1":        true,
+		"pb > 1":                   false, // 缺失
+		"not pb > 1":               false, // 缺失取反仍然未知
+		"pb > 1 or pe < 20":        true,
+		"pb > 1 and pe > 20":       false,
+		"not (pb > 1 and pe > 20)": true, // 一边确定为false时and为false
+		"not (pb > 1 or pe < 20)":  false,
+		"not (pb > 1 and pe < 20)": false,
+	} {
+		if got := match(t, src, env); got != want {
+			t.Errorf("%s: %v, want %v", src, got, want)
+		}
+	}
+}
+
+// 指标数据不足一个周期时未知，不能当作0比较
+func TestIndicatorNotReady(t *testing.T) {
+	for name, field := range indicatorFields {
+		for _, days := range []int{field.days - 1, field.days} {
+			if days <= 0 {
+				continue
+			}
+			env := &Env{Stock: &model.StockList{}, Points: risingPoints(days)}
+			src := fmt.Sprintf("%s > -1000000000 or %s <= -1000000000", name, name)
+			if got := match(t, src, env); got != (days >= field.days) {
+				t.Errorf("%s with %d days: %v", name, days, got)
+			}
+		}
+	}
+
+	env := &Env{Stock: &model.StockList{}, Points: risingPoints(30)}
+	if match(t, "close > ma60", env) || match(t, "not close <= ma60", env) {
+		t.Error("ma60 with 30 days matched")
+	}
+	if !match(t, "close > ma20", env) {
+		t.Error("ma20 with 30 days")
+	}
+
+	// 没有行情或全部停牌
+	for _, points := range [][]*indicator.Point{nil, {{Date: "2020-01-01"}}} {
+		env := &Env{Stock: &model.StockList{}, Points: points}
+		if match(t, "obv >= 0 or obv < 0", env) {
+			t.Errorf("obv with %d suspended days", len(points))
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package screener
+
+import (
+	"background/stock/indicator"
+	"errors"
+	"math"
+	"time"
+)
+
+type function struct {
+	minArgs, maxArgs int
+	check            func(args []float64) (int, error) // 检查参数，返回需要的历史交易日数
+	eval             func(env *Env, args []float64) float64
+}
+
+type callNode struct {
+	f    *function
+	args []float64
+}
+
+func (n *callNode) num(env *Env) float64 {
+	return n.f.eval(env, n.args)
+}
+
+const maxPeriod = 2500
+
+// 第i个参数为周期，没有时使用def
+func period(args []float64, i, def int) int {
+	if i >= len(args) {
+		return def
+	}
+	return int(args[i])
+}
+
+func checkPeriod(args []float64, i, def, min int) (int, error) {
+	if i < len(args) && args[i] != math.Trunc(args[i]) {
+		return 0, errors.New("周期必须是整数")
+	}
+	n := period(args, i, def)
+	if n < min || n > maxPeriod {
+		return 0, errors.New("周期超出范围")
+	}
+	return n, nil
+}
+
+// 可以带一个偏移参数的日线字段，close(1)为前一个交易日的收盘价
+func barField(field func(p *indicator.Point) float64) *function {
+	return &function{
+		minArgs: 0,
+		maxArgs: 1,
+		check: func(args []float64) (int, error) {
+			offset, err := checkPeriod(args, 0, 0, 0)
+			return offset + 1, err
+		},
+		eval: func(env *Env, args []float64) float64 {
+			p := env.point(period(args, 0, 0))
+			if p == nil {
+				return math.NaN()
+			}
+			return field(p)
+		},
+	}
+}
+
+// 参数为周期n，对最近n个交易日求值
+func window(min int, f func(points []*indicator.Point, args []float64) float64) *function {
+	return &function{
+		minArgs: 1,
+		maxArgs: 1,
+		check: func(args []float64) (int, error) {
+			return checkPeriod(args, 0, 0, min)
+		},
+		eval: func(env *Env, args []float64) float64 {
+			points := env.last(period(args, 0, 0))
+			if points == nil {
+				return math.NaN()
+			}
+			return f(points, args)
+		},
+	}
+}
+
+func highest(points []*indicator.Point) float64 {
+	high := points[0].High
+	for _, p := range points {
+		high = math.Max(high, p.High)
+	}
+	return high
+}
+
+func lowest(points []*indicator.Point) float64 {
+	low := points[0].Low
+	for _, p := range points {
+		low = math.Min(low, p.Low)
+	}
+	return low
+}
+
+/*
+	收盘价的波段次数：从低点上涨超过swing记一次，从高点下跌超过swing再记一次
+*/
+func swingCount(points []*indicator.Point, swing float64) float64 {
+	low := points[0].Close
+	high := low
+	count := 0
+	rising := false
+	for _, p := range points {
+		if (p.Close-low)/low > swing && !rising {
+			count++
+			rising = true
+		}
+		if (high-p.Close)/high > swing && rising {
+			count++
+			rising = false
+		}
+		if p.Close > high {
+			high = p.Close
+			if rising {
+				low = high
+			}
+		}
+		if p.Close < low {
+			low = p.Close
+			if !rising {
+				high = low
+			}
+		}
+	}
+	return float64(count)
+}
+
+var functions = map[string]*function{
+	"close":  barField(func(p *indicator.Point) float64 { return p.Close }),
+	"open":   barField(func(p *indicator.Point) float64 { return p.Open }),
+	"high":   barField(func(p *indicator.Point) float64 { return p.High }),
+	"low":    barField(func(p *indicator.Point) float64 { return p.Low }),
+	"volume": barField(func(p *indicator.Point) float64 { return p.Volume }),
+
+	// 收盘价的n日简单平均
+	"ma": window(1, func(points []*indicator.Point, args []float64) float64 {
+		var sum float64
+		for _, p := range points {
+			sum += p.Close
+		}
+		return sum / float64(len(points))
+	}),
+	// 收盘价的n日指数平均，使用全部历史数据计算
+	"ema": {
+		minArgs: 1,
+		maxArgs: 1,
+		check: func(args []float64) (int, error) {
+			n, err := checkPeriod(args, 0, 0, 1)
+			return n * 4, err
+		},
+		eval: func(env *Env, args []float64) float64 {
+			n := period(args, 0, 0)
+			if len(env.Points) < n {
+				return math.NaN()
+			}
+			ema := indicator.NewEMA(n)
+			for _, p := range env.Points {
+				ema.Update(p.Close)
+			}
+			return ema.Value()
+		},
+	},
+	"highest": window(1, func(points []*indicator.Point, args []float64) float64 {
+		return highest(points)
+	}),
+	"lowest": window(1, func(points []*indicator.Point, args []float64) float64 {
+		return lowest(points)
+	}),
+	"avg_volume": window(1, func(points []*indicator.Point, args []float64) float64 {
+		var sum float64
+		for _, p := range points {
+			sum += p.Volume
+		}
+		return sum / float64(len(points))
+	}),
+	// n个交易日的涨跌幅
+	"change": {
+		minArgs: 1,
+		maxArgs: 1,
+		check: func(args []float64) (int, error) {
+			n, err := checkPeriod(args, 0, 0, 1)
+			return n + 1, err
+		},
+		eval: func(env *Env, args []float64) float64 {
+			now, before := env.point(0), env.point(period(args, 0, 0))
+			if now == nil || before == nil {
+				return math.NaN()
+			}
+			return now.Close/before.Close - 1
+		},
+	},
+	// 收盘价较n日最高价的跌幅
+	"drop_from_high": window(1, func(points []*indicator.Point, args []float64) float64 {
+		high := highest(points)
+		return (high - points[len(points)-1].Close) / high
+	}),
+	// 收盘价较n日最低价的涨幅
+	"rise_from_low": window(1, func(points []*indicator.Point, args []float64) float64 {
+		low := lowest(points)
+		return (points[len(points)-1].Close - low) / low
+	}),
+	// swing_count(n, swing) n日内幅度超过swing的波段次数
+	"swing_count": {
+		minArgs: 2,
+		maxArgs: 2,
+		check: func(args []float64) (int, error) {
+			if args[1] <= 0 {
+				return 0, errors.New("波动幅度必须大于0")
+			}
+			return checkPeriod(args, 0, 0, 2)
+		},
+		eval: func(env *Env, args []float64) float64 {
+			points := env.last(period(args, 0, 0))
+			if points == nil {
+				return math.NaN()
+			}
+			return swingCount(points, args[1])
+		},
+	},
+	// 上市天数(自然日)
+	"listed_days": {
+		minArgs: 0,
+		maxArgs: 0,
+		check: func(args []float64) (int, error) {
+			return 0, nil
+		},
+		eval: func(env *Env, args []float64) float64 {
+			listed, err := time.Parse("20060102", env.Stock.TimeToMarket)
+			if err != nil {
+				return math.NaN()
+			}
+			return math.Floor(env.Now.Sub(listed).Hours() / 24)
+		},
+	},
+}
@@ -0,0 +1,91 @@
+package screener
+
+import (
+	"background/common/logger"
+	"background/stock/indicator"
+	"background/stock/model"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 符合条件的股票
+type Hit struct {
+	Code  string  `json:"code"`
+	Name  string  `json:"name"`
+	Date  string  `json:"date"` // 最近一个交易日，只用到基本面数据时为空
+	Close float64 `json:"close"`
+}
+
+// 最近lookback个交易日的前复权日线，不含停牌日
+func loadPoints(db *gorm.DB, code string, lookback int) ([]*indicator.Point, error) {
+	var rows []*model.StockHistoryDataQ
+	if err := db.Where("code = ? and close > 0", code).Order("date desc").Limit(lookback).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+	return indicator.FromHistoryDataQ(rows), nil
+}
+
+/*
+	对stock_list中的全部股票求值，最多同时处理workers只股票，结果按代码排序。
+	单只股票加载数据失败时记录日志并跳过
+*/
+func Run(db *gorm.DB, expr *Expr, workers int) ([]*Hit, error) {
+	var stocks []*model.StockList
+	if err := db.Find(&stocks).Error; err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+		hits []*Hit
+	)
+	now := time.Now()
+	queue := make(chan *model.StockList)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for stock := range queue {
+				env := &Env{Stock: stock, Now: now}
+				if expr.Lookback() > 0 {
+					points, err := loadPoints(db, stock.Code, expr.Lookback())
+					if err != nil {
+						logger.Error("query stock_history_data_q err!!!,", stock.Code, err)
+						continue
+					}
+					env.Points = points
+				}
+				if !expr.Match(env) {
+					continue
+				}
+
+				hit := &Hit{Code: stock.Code, Name: stock.Name}
+				if p := env.point(0); p != nil {
+					hit.Date = p.Date
+					hit.Close = p.Close
+				}
+				lock.Lock()
+				hits = append(hits, hit)
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, stock := range stocks {
+		queue <- stock
+	}
+	close(queue)
+	wg.Wait()
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Code < hits[j].Code })
+	return hits, nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"background/stock/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const TENCENT_KLINE_URL = "http://web.ifzq.gtimg.cn/appstock/app/fqkline/get"
+
+// 每次请求的最大条数，按自然年请求不会超过
+const tencentKlineLimit = 640
+
+var klineClient = &http.Client{Timeout: time.Second * 10}
+
+/*
+	腾讯日K线 http://web.ifzq.gtimg.cn/appstock/app/fqkline/get?param=sh000300,day,2020-01-01,2020-12-31,640,
+	返回 {"code":0,"data":{"sh000300":{"day":[["2020-01-02","4121.35","4152.24","4172.66","4121.35","1819843920.00"],...]}}}
+	每条为日期、开盘、收盘、最高、最低、成交量，指数不复权
+*/
+func ParseTencentKline(code string, data []byte) ([]*model.IndexHistoryData, error) {
+	var resp struct {
+		Code int                                   `json:"code"`
+		Msg  string                                `json:"msg"`
+		Data map[string]map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, errors.New("tencent kline: " + resp.Msg)
+	}
+	series, ok := resp.Data[code]
+	if !ok {
+		return nil, fmt.Errorf("tencent kline: %s not found", code)
+	}
+	raw, ok := series["day"]
+	if !ok {
+		raw, ok = series["qfqday"]
+	}
+	// 区间内没有交易日时没有day
+	if !ok {
+		return nil, nil
+	}
+
+	// 股票的K线在除权日多一个对象元素，只取前6个字符串
+	var days [][]interface{}
+	if err := json.Unmarshal(raw, &days); err != nil {
+		return nil, err
+	}
+	rows := make([]*model.IndexHistoryData, 0, len(days))
+	for _, day := range days {
+		if len(day) < 6 {
+			return nil, fmt.Errorf("tencent kline: invalid day %v", day)
+		}
+		var fields [6]string
+		for i := range fields {
+			s, ok := day[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("tencent kline: invalid day %v", day)
+			}
+			fields[i] = s
+		}
+		if _, err := time.Parse("2006-01-02", fields[0]); err != nil {
+			return nil, fmt.Errorf("tencent kline: invalid date %s", fields[0])
+		}
+		var values [5]float64
+		for i := range values {
+			v, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("tencent kline: invalid value %s", fields[i+1])
+			}
+			values[i] = v
+		}
+		rows = append(rows, &model.IndexHistoryData{
+			Code:   code,
+			Date:   fields[0],
+			Open:   values[0],
+			Close:  values[1],
+			High:   values[2],
+			Low:    values[3],
+			Volume: values[4],
+		})
+	}
+	return rows, nil
+}
+
+/*
+	获取指数[begin, end]内的日线，code带交易所前缀，按自然年分批请求
+*/
+func FetchIndexHistory(code, begin, end string) ([]*model.IndexHistoryData, error) {
+	if _, _, ok := splitCode(code); !ok {
+		return nil, errors.New("index code must have sh/sz prefix: " + code)
+	}
+	from, err := time.Parse("2006-01-02", begin)
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*model.IndexHistoryData
+	for year := from.Year(); year <= to.Year(); year++ {
+		yearBegin, yearEnd := fmt.Sprintf("%d-01-01", year), fmt.Sprintf("%d-12-31", year)
+		if year == from.Year() {
+			yearBegin = begin
+		}
+		if year == to.Year() {
+			yearEnd = end
+		}
+		url := fmt.Sprintf("%s?param=%s,day,%s,%s,%d,", TENCENT_KLINE_URL, code, yearBegin, yearEnd, tencentKlineLimit)
+		data, err := httpGet(klineClient, url, "")
+		if err != nil {
+			return nil, err
+		}
+		yearRows, err := ParseTencentKline(code, data)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, yearRows...)
+	}
+	return rows, nil
+}
+
+/*
+	保存指数日线，已有的日期更新为新数据
+*/
+func SaveIndexHistory(db *gorm.DB, rows []*model.IndexHistoryData) error {
+	tx := db.Set("gorm:insert_option", "ON DUPLICATE KEY UPDATE open = VALUES(open), close = VALUES(close), high = VALUES(high), low = VALUES(low), volume = VALUES(volume)")
+	for _, row := range rows {
+		if err := tx.Create(row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
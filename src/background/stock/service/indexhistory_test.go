@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestParseTencentKline(t *testing.T) {
+	data := `{"code":0,"msg":"","data":{"sh000300":{"day":[` +
+		`["2020-01-02","4121.35","4152.24","4172.66","4121.35","1819843920.00"],` +
+		`["2020-01-03","4161.22","4144.96","4164.30","4131.86","1581051590.00",{"nd":"2019"}]` +
+		`],"qt":{}}}}`
+	rows, err := ParseTencentKline("sh000300", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rows %d", len(rows))
+	}
+	r := rows[0]
+	if r.Code != "sh000300" || r.Date != "2020-01-02" || r.Open != 4121.35 || r.Close != 4152.24 ||
+		r.High != 4172.66 || r.Low != 4121.35 || r.Volume != 1819843920 {
+		t.Errorf("row %+v", r)
+	}
+
+	// 区间内没有交易日
+	rows, err = ParseTencentKline("sh000300", []byte(`{"code":0,"msg":"","data":{"sh000300":{"qt":{}}}}`))
+	if err != nil || len(rows) != 0 {
+		t.Errorf("empty %v %v", rows, err)
+	}
+
+	for _, bad := range []string{
+		`{"code":-1,"msg":"param error","data":[]}`,
+		`{"code":0,"msg":"","data":{"sz399001":{"day":[]}}}`,
+		`{"code":0,"msg":"","data":{"sh000300":{"day":[["2020-01-02","4121.35"]]}}}`,
+		`{"code":0,"msg":"","data":{"sh000300":{"day":[["20200102","1","1","1","1","1"]]}}}`,
+		`{"code":0,"msg":"","data":{"sh000300":{"day":[["2020-01-02","1","x","1","1","1"]]}}}`,
+	} {
+		if _, err := ParseTencentKline("sh000300", []byte(bad)); err == nil {
+			t.Errorf("%s parsed", bad)
+		}
+	}
+}
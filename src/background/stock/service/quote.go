@@ -0,0 +1,21 @@
+package service
+
+import (
+	"background/stock/model"
+)
+
+/*
+	批量获取实时行情，codes为带交易所前缀的代码(如sh600000)，数据源失败时自动切换。
+	返回按代码(不含前缀)索引的行情，不存在的股票不在结果中
+*/
+func GetRealTimeStocks(codes []string) (map[string]*model.RealTimeStock, error) {
+	quotes, err := DefaultQuoteProvider.Fetch(codes)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*model.RealTimeStock, len(quotes))
+	for _, quote := range quotes {
+		result[quote.StockCode] = quote
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"background/stock/model"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const NETEASE_REALTIME_STOCK_URL = "http://api.money.126.net/data/feed/"
+
+/*
+	网易行情 http://api.money.126.net/data/feed/0600000,1000001,money.api
+	代码前缀0为上海、1为深圳，返回_ntes_quote_callback({...});包裹的json，成交量单位为股
+*/
+type NeteaseQuoteProvider struct {
+	Url    string
+	client *http.Client
+}
+
+func NewNeteaseQuoteProvider() *NeteaseQuoteProvider {
+	return &NeteaseQuoteProvider{Url: NETEASE_REALTIME_STOCK_URL, client: quoteClient}
+}
+
+func (p *NeteaseQuoteProvider) Name() string {
+	return "netease"
+}
+
+func (p *NeteaseQuoteProvider) Fetch(codes []string) (map[string]*model.RealTimeStock, error) {
+	return fetchBatches(codes, 100, func(batch []string) (map[string]*model.RealTimeStock, error) {
+		data, err := httpGet(p.client, p.Url+joinCodes(batch, func(jysCode, stockCode string) string {
+			if jysCode == "sh" {
+				return "0" + stockCode
+			}
+			return "1" + stockCode
+		})+",money.api", "")
+		if err != nil {
+			return nil, err
+		}
+		quotes, err := ParseNeteaseQuotes(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return filterQuotes(p.Name(), quotes), nil
+	})
+}
+
+type neteaseQuote struct {
+	Symbol    string  `json:"symbol"`
+	Type      string  `json:"type"` // SH、SZ
+	Price     float64 `json:"price"`
+	YestClose float64 `json:"yestclose"`
+	Open      float64 `json:"open"`
+	High      float64 `json:"high"`
+	Low       float64 `json:"low"`
+	Volume    float64 `json:"volume"`
+	Turnover  float64 `json:"turnover"`
+	Time      string  `json:"time"` // 2006/01/02 15:04:05
+	Bid1      float64 `json:"bid1"`
+	Bid2      float64 `json:"bid2"`
+	Bid3      float64 `json:"bid3"`
+	Bid4      float64 `json:"bid4"`
+	Bid5      float64 `json:"bid5"`
+	BidVol1   float64 `json:"bidvol1"`
+	BidVol2   float64 `json:"bidvol2"`
+	BidVol3   float64 `json:"bidvol3"`
+	BidVol4   float64 `json:"bidvol4"`
+	BidVol5   float64 `json:"bidvol5"`
+	Ask1      float64 `json:"ask1"`
+	Ask2      float64 `json:"ask2"`
+	Ask3      float64 `json:"ask3"`
+	Ask4      float64 `json:"ask4"`
+	Ask5      float64 `json:"ask5"`
+	AskVol1   float64 `json:"askvol1"`
+	AskVol2   float64 `json:"askvol2"`
+	AskVol3   float64 `json:"askvol3"`
+	AskVol4   float64 `json:"askvol4"`
+	AskVol5   float64 `json:"askvol5"`
+}
+
+/*
+	解析网易行情，代码不存在时不在json中
+*/
+func ParseNeteaseQuotes(data string) (map[string]*model.RealTimeStock, error) {
+	start := strings.Index(data, "(")
+	end := strings.LastIndex(data, ")")
+	if start < 0 || end < start {
+		return nil, ErrNoQuoteData
+	}
+	var items map[string]*neteaseQuote
+	if err := json.Unmarshal([]byte(data[start+1:end]), &items); err != nil {
+		return nil, err
+	}
+
+	quotes := make(map[string]*model.RealTimeStock)
+	for _, item := range items {
+		jysCode := strings.ToLower(item.Type)
+		if _, _, ok := splitCode(jysCode + item.Symbol); !ok || len(item.Time) != 19 {
+			continue
+		}
+
+		var q model.RealTimeStock
+		q.JysCode = jysCode
+		q.StockCode = item.Symbol
+		q.NowPrice = item.Price
+		q.YestdayClosePrice = item.YestClose
+		q.TodayOpenPrice = item.Open
+		q.TodayHighPrice = item.High
+		q.TodayLowPrice = item.Low
+		q.DealCount = int(item.Volume)
+		q.DealMoney = item.Turnover
+		q.BuyPrice1, q.BuyCount1 = item.Bid1, int(item.BidVol1)
+		q.BuyPrice2, q.BuyCount2 = item.Bid2, int(item.BidVol2)
+		q.BuyPrice3, q.BuyCount3 = item.Bid3, int(item.BidVol3)
+		q.BuyPrice4, q.BuyCount4 = item.Bid4, int(item.BidVol4)
+		q.BuyPrice5, q.BuyCount5 = item.Bid5, int(item.BidVol5)
+		q.SellPrice1, q.SellCount1 = item.Ask1, int(item.AskVol1)
+		q.SellPrice2, q.SellCount2 = item.Ask2, int(item.AskVol2)
+		q.SellPrice3, q.SellCount3 = item.Ask3, int(item.AskVol3)
+		q.SellPrice4, q.SellCount4 = item.Ask4, int(item.AskVol4)
+		q.SellPrice5, q.SellCount5 = item.Ask5, int(item.AskVol5)
+		q.BuyPrice = q.BuyPrice1
+		q.SellPrice = q.SellPrice1
+		q.DealDate = strings.Replace(item.Time[:10], "/", "-", -1)
+		q.DealTime = item.Time[11:]
+		quotes[jysCode+q.StockCode] = &q
+	}
+	return quotes, nil
+}
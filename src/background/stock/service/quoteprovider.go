@@ -0,0 +1,223 @@
+package service
+
+import (
+	"background/common/logger"
+	"background/stock/model"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	实时行情数据源。codes为带交易所前缀的代码(如sh600000)，
+	返回按带前缀代码索引的行情，不存在的股票不在结果中，停牌股票的当前价为0
+*/
+type QuoteProvider interface {
+	Name() string
+	Fetch(codes []string) (map[string]*model.RealTimeStock, error)
+}
+
+var ErrNoQuoteData = errors.New("no quote data in response")
+
+var quoteClient = &http.Client{Timeout: time.Second * 5}
+
+func httpGet(client *http.Client, url, referer string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("quote status " + resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// 按交易所前缀拆分代码
+func splitCode(code string) (jysCode, stockCode string, ok bool) {
+	if len(code) != 8 || (code[:2] != "sh" && code[:2] != "sz") {
+		return "", "", false
+	}
+	return code[:2], code[2:], true
+}
+
+/*
+	检查解析出的行情，停牌(当前价为0)时只检查昨收价和日期
+*/
+func validateQuote(q *model.RealTimeStock) error {
+	if q.YestdayClosePrice <= 0 {
+		return fmt.Errorf("%s%s invalid pre close %v", q.JysCode, q.StockCode, q.YestdayClosePrice)
+	}
+	if _, err := time.Parse("2006-01-02 15:04:05", q.DealDate+" "+q.DealTime); err != nil {
+		return fmt.Errorf("%s%s invalid deal time %s %s", q.JysCode, q.StockCode, q.DealDate, q.DealTime)
+	}
+	if q.NowPrice == 0 {
+		return nil
+	}
+	if q.NowPrice < 0 || q.TodayLowPrice <= 0 || q.TodayHighPrice < q.TodayLowPrice ||
+		q.NowPrice > q.TodayHighPrice || q.NowPrice < q.TodayLowPrice {
+		return fmt.Errorf("%s%s invalid price now %v high %v low %v", q.JysCode, q.StockCode, q.NowPrice, q.TodayHighPrice, q.TodayLowPrice)
+	}
+	if q.DealCount < 0 || q.DealMoney < 0 {
+		return fmt.Errorf("%s%s invalid volume %v amount %v", q.JysCode, q.StockCode, q.DealCount, q.DealMoney)
+	}
+	return nil
+}
+
+// 去掉校验不通过的行情
+func filterQuotes(provider string, quotes map[string]*model.RealTimeStock) map[string]*model.RealTimeStock {
+	for code, q := range quotes {
+		if err := validateQuote(q); err != nil {
+			logger.Error(provider, "行情数据错误:", err)
+			delete(quotes, code)
+		}
+	}
+	return quotes
+}
+
+const (
+	quoteMaxFailures     = 3                // 连续失败次数达到后暂停使用该数据源
+	quoteDisableDuration = time.Second * 30 // 第一次暂停的时间，之后每次加倍
+	quoteMaxDisable      = time.Minute * 5
+)
+
+type QuoteProviderStatus struct {
+	Name          string    `json:"name"`
+	Requests      int       `json:"requests"`
+	Errors        int       `json:"errors"`
+	Failures      int       `json:"failures"` // 连续失败次数
+	LastError     string    `json:"last_error"`
+	DisabledUntil time.Time `json:"disabled_until"`
+}
+
+/*
+	按顺序使用多个数据源，请求失败时换下一个。
+	连续失败的数据源暂停使用一段时间，全部暂停时仍按顺序尝试
+*/
+type FailoverQuoteProvider struct {
+	lock      sync.Mutex
+	providers []QuoteProvider
+	status    []*QuoteProviderStatus
+}
+
+func NewFailoverQuoteProvider(providers ...QuoteProvider) *FailoverQuoteProvider {
+	f := &FailoverQuoteProvider{providers: providers}
+	for _, p := range providers {
+		f.status = append(f.status, &QuoteProviderStatus{Name: p.Name()})
+	}
+	return f
+}
+
+func (f *FailoverQuoteProvider) Name() string {
+	return "failover"
+}
+
+func (f *FailoverQuoteProvider) order(now time.Time) []int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var healthy, disabled []int
+	for i, s := range f.status {
+		if now.Before(s.DisabledUntil) {
+			disabled = append(disabled, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+	return append(healthy, disabled...)
+}
+
+func (f *FailoverQuoteProvider) report(i int, err error, now time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	s := f.status[i]
+	s.Requests++
+	if err == nil {
+		s.Failures = 0
+		s.DisabledUntil = time.Time{}
+		return
+	}
+	s.Errors++
+	s.Failures++
+	s.LastError = err.Error()
+	if s.Failures >= quoteMaxFailures {
+		d := quoteDisableDuration << uint(s.Failures-quoteMaxFailures)
+		if d > quoteMaxDisable || d <= 0 {
+			d = quoteMaxDisable
+		}
+		s.DisabledUntil = now.Add(d)
+		logger.Error("行情数据源", s.Name, "连续失败", s.Failures, "次，暂停到", s.DisabledUntil.Format("15:04:05"))
+	}
+}
+
+func (f *FailoverQuoteProvider) Fetch(codes []string) (map[string]*model.RealTimeStock, error) {
+	var lastErr error
+	for _, i := range f.order(time.Now()) {
+		quotes, err := f.providers[i].Fetch(codes)
+		f.report(i, err, time.Now())
+		if err == nil {
+			return quotes, nil
+		}
+		logger.Error("行情数据源", f.providers[i].Name(), "请求失败:", err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no quote provider")
+	}
+	return nil, lastErr
+}
+
+// 各数据源的状态
+func (f *FailoverQuoteProvider) Status() []QuoteProviderStatus {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var status []QuoteProviderStatus
+	for _, s := range f.status {
+		status = append(status, *s)
+	}
+	return status
+}
+
+var DefaultQuoteProvider = NewFailoverQuoteProvider(NewSinaQuoteProvider(), NewTencentQuoteProvider(), NewNeteaseQuoteProvider())
+
+// 按batch个代码分批请求并合并结果
+func fetchBatches(codes []string, batch int, fetch func([]string) (map[string]*model.RealTimeStock, error)) (map[string]*model.RealTimeStock, error) {
+	result := make(map[string]*model.RealTimeStock)
+	for start := 0; start < len(codes); start += batch {
+		end := start + batch
+		if end > len(codes) {
+			end = len(codes)
+		}
+		quotes, err := fetch(codes[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for code, q := range quotes {
+			result[code] = q
+		}
+	}
+	return result, nil
+}
+
+func joinCodes(codes []string, convert func(jysCode, stockCode string) string) string {
+	var converted []string
+	for _, code := range codes {
+		if jysCode, stockCode, ok := splitCode(code); ok {
+			converted = append(converted, convert(jysCode, stockCode))
+		}
+	}
+	return strings.Join(converted, ",")
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"background/stock/model"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFixture(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// 三个数据源的fixture是同一时刻的行情，解析结果应该一致
+func checkQuotes(t *testing.T, provider string, quotes map[string]*model.RealTimeStock) {
+	q, ok := quotes["sh600000"]
+	if !ok {
+		t.Fatalf("%s: sh600000 not found", provider)
+	}
+	if q.JysCode != "sh" || q.StockCode != "600000" {
+		t.Errorf("%s: code %s %s", provider, q.JysCode, q.StockCode)
+	}
+	if q.NowPrice != 10.31 || q.YestdayClosePrice != 10.25 || q.TodayOpenPrice != 10.26 ||
+		q.TodayHighPrice != 10.35 || q.TodayLowPrice != 10.22 {
+		t.Errorf("%s: price %+v", provider, q)
+	}
+	if q.DealCount < 23155400 || q.DealCount > 23155412 {
+		t.Errorf("%s: deal count %d", provider, q.DealCount)
+	}
+	if q.DealMoney < 238345670 || q.DealMoney > 238345680 {
+		t.Errorf("%s: deal money %v", provider, q.DealMoney)
+	}
+	if q.BuyPrice1 != 10.3 || q.BuyCount1 != 25300 || q.SellPrice5 != 10.35 || q.SellCount5 != 61200 {
+		t.Errorf("%s: order book %+v", provider, q)
+	}
+	if q.DealDate != "2020-06-12" || q.DealTime != "15:00:03" {
+		t.Errorf("%s: deal time %s %s", provider, q.DealDate, q.DealTime)
+	}
+
+	q, ok = quotes["sz000001"]
+	if !ok {
+		t.Fatalf("%s: sz000001 not found", provider)
+	}
+	if q.NowPrice != 12.98 || q.YestdayClosePrice != 13.12 || q.BuyCount1 != 100 {
+		t.Errorf("%s: sz000001 %+v", provider, q)
+	}
+}
+
+func TestParseSinaQuotes(t *testing.T) {
+	quotes, err := ParseSinaQuotes(readFixture(t, "sina.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuotes(t, "sina", quotes)
+	if _, ok := quotes["sh601313"]; ok {
+		t.Error("empty quote should be skipped")
+	}
+	// 停牌
+	if q, ok := quotes["sz000029"]; !ok || q.NowPrice != 0 || validateQuote(q) != nil {
+		t.Errorf("suspended quote %+v", q)
+	}
+	if _, err := ParseSinaQuotes("<html>forbidden</html>"); err != ErrNoQuoteData {
+		t.Errorf("unexpected err %v", err)
+	}
+}
+
+func TestParseTencentQuotes(t *testing.T) {
+	quotes, err := ParseTencentQuotes(readFixture(t, "tencent.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuotes(t, "tencent", quotes)
+	if len(quotes) != 2 {
+		t.Errorf("quotes %d", len(quotes))
+	}
+}
+
+func TestParseNeteaseQuotes(t *testing.T) {
+	quotes, err := ParseNeteaseQuotes(readFixture(t, "netease.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuotes(t, "netease", quotes)
+	if _, err := ParseNeteaseQuotes("_ntes_quote_callback({});"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetRealTimeStockObject(t *testing.T) {
+	err, q := GetRealTimeStockObject("sh", "600000", readFixture(t, "sina.txt"))
+	if err != nil || q.NowPrice != 10.31 {
+		t.Fatal(err, q)
+	}
+	// 原来按固定偏移截取，代码前缀长度不同时会出错
+	err, q = GetRealTimeStockObject("sh", "601313", "var hq_str_sh601313=\"\";\n")
+	if err != ErrStockNotExist || q != nil {
+		t.Error(err, q)
+	}
+	if err, _ = GetRealTimeStockObject("sh", "600000", ""); err == nil {
+		t.Error("expect error for empty response")
+	}
+}
+
+func TestValidateQuote(t *testing.T) {
+	quotes, _ := ParseSinaQuotes(readFixture(t, "sina.txt"))
+	q := *quotes["sh600000"]
+	if err := validateQuote(&q); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := q
+	bad.NowPrice = 11
+	if validateQuote(&bad) == nil {
+		t.Error("price above high should be invalid")
+	}
+	bad = q
+	bad.YestdayClosePrice = 0
+	if validateQuote(&bad) == nil {
+		t.Error("zero pre close should be invalid")
+	}
+	bad = q
+	bad.DealTime = "15:00"
+	if validateQuote(&bad) == nil {
+		t.Error("bad deal time should be invalid")
+	}
+
+	filtered := filterQuotes("test", map[string]*model.RealTimeStock{"sh600000": &q, "sh600001": &bad})
+	if len(filtered) != 1 || filtered["sh600000"] == nil {
+		t.Errorf("filtered %v", filtered)
+	}
+}
+
+func TestProvidersWithServer(t *testing.T) {
+	var lastPath, lastReferer string
+	fixture := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastPath = r.URL.Path + "?" + r.URL.RawQuery
+		lastReferer = r.Header.Get("Referer")
+		w.Write([]byte(fixture))
+	}))
+	defer server.Close()
+
+	codes := []string{"sh600000", "sz000001", "bad"}
+
+	fixture = readFixture(t, "sina.txt")
+	sina := &SinaQuoteProvider{Url: server.URL + "/", client: server.Client()}
+	quotes, err := sina.Fetch(codes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkQuotes(t, "sina", quotes)
+	if lastPath != "/list=sh600000,sz000001?" || lastReferer == "" {
+		t.Errorf("sina request %s referer %s", lastPath, lastReferer)
+	}
+
+	fixture = readFixture(t, "tencent.txt")
+	tencent := &TencentQuoteProvider{Url: server.URL + "/", client: server.Client()}
+	if quotes, err = tencent.Fetch(codes); err != nil {
+		t.Fatal(err)
+	}
+	checkQuotes(t, "tencent", quotes)
+	if lastPath != "/q=sh600000,sz000001?" {
+		t.Errorf("tencent request %s", lastPath)
+	}
+
+	fixture = readFixture(t, "netease.txt")
+	netease := &NeteaseQuoteProvider{Url: server.URL + "/", client: server.Client()}
+	if quotes, err = netease.Fetch(codes); err != nil {
+		t.Fatal(err)
+	}
+	checkQuotes(t, "netease", quotes)
+	if lastPath != "/0600000,1000001,money.api?" {
+		t.Errorf("netease request %s", lastPath)
+	}
+}
+
+func TestFetchBatches(t *testing.T) {
+	var codes []string
+	for i := 0; i < 250; i++ {
+		codes = append(codes, "sh600000")
+	}
+	var sizes []int
+	_, err := fetchBatches(codes, 100, func(batch []string) (map[string]*model.RealTimeStock, error) {
+		sizes = append(sizes, len(batch))
+		return nil, nil
+	})
+	if err != nil || len(sizes) != 3 || sizes[2] != 50 {
+		t.Error(err, sizes)
+	}
+}
+
+type fakeProvider struct {
+	name  string
+	price float64 // 用价格区分返回结果的数据源
+	err   error
+	calls int
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Fetch(codes []string) (map[string]*model.RealTimeStock, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return map[string]*model.RealTimeStock{"sh600000": {JysCode: "sh", StockCode: "600000", NowPrice: p.price}}, nil
+}
+
+func TestFailoverQuoteProvider(t *testing.T) {
+	primary := &fakeProvider{name: "primary", price: 1, err: errors.New("timeout")}
+	backup := &fakeProvider{name: "backup", price: 2}
+	f := NewFailoverQuoteProvider(primary, backup)
+
+	for i := 0; i < quoteMaxFailures; i++ {
+		quotes, err := f.Fetch([]string{"sh600000"})
+		if err != nil || quotes["sh600000"].NowPrice != backup.price {
+			t.Fatal(err, quotes)
+		}
+	}
+	status := f.Status()
+	if status[0].Failures != quoteMaxFailures || status[0].DisabledUntil.IsZero() || status[1].Errors != 0 {
+		t.Fatalf("status %+v", status)
+	}
+
+	// 暂停期间先用备用数据源
+	f.Fetch([]string{"sh600000"})
+	if primary.calls != quoteMaxFailures {
+		t.Errorf("disabled provider called %d times", primary.calls)
+	}
+
+	// 暂停时间每次加倍，不超过上限
+	now := time.Now()
+	f.report(0, primary.err, now)
+	if d := f.status[0].DisabledUntil.Sub(now); d != quoteDisableDuration*2 {
+		t.Errorf("disable duration %v", d)
+	}
+	for i := 0; i < 10; i++ {
+		f.report(0, primary.err, now)
+	}
+	if d := f.status[0].DisabledUntil.Sub(now); d != quoteMaxDisable {
+		t.Errorf("max disable duration %v", d)
+	}
+
+	// 暂停结束后恢复
+	if order := f.order(now.Add(quoteMaxDisable)); order[0] != 0 {
+		t.Errorf("order %v", order)
+	}
+	primary.err = nil
+	f.status[0].DisabledUntil = time.Time{}
+	quotes, _ := f.Fetch([]string{"sh600000"})
+	if quotes["sh600000"].NowPrice != primary.price || f.Status()[0].Failures != 0 {
+		t.Errorf("recover %+v", f.Status()[0])
+	}
+
+	// 全部失败时返回最后一个错误
+	primary.err = errors.New("primary down")
+	backup.err = errors.New("backup down")
+	if _, err := f.Fetch([]string{"sh600000"}); err == nil || !strings.Contains(err.Error(), "backup") {
+		t.Errorf("err %v", err)
+	}
+}
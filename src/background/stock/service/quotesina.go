@@ -0,0 +1,112 @@
+package service
+
+import (
+	"background/stock/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/*
+	新浪行情 http://hq.sinajs.cn/list=sh600000,sz000001
+	每只股票一行：var hq_str_sh600000="名称,今开,昨收,当前价,最高,最低,买一,卖一,成交量(股),成交额(元),买一量,买一价,...,卖五价,日期,时间,状态";
+*/
+type SinaQuoteProvider struct {
+	Url    string
+	client *http.Client
+}
+
+func NewSinaQuoteProvider() *SinaQuoteProvider {
+	return &SinaQuoteProvider{Url: SINA_REALTIME_STOCK_URL, client: quoteClient}
+}
+
+func (p *SinaQuoteProvider) Name() string {
+	return "sina"
+}
+
+func (p *SinaQuoteProvider) Fetch(codes []string) (map[string]*model.RealTimeStock, error) {
+	return fetchBatches(codes, 100, func(batch []string) (map[string]*model.RealTimeStock, error) {
+		data, err := httpGet(p.client, p.Url+"list="+joinCodes(batch, func(jysCode, stockCode string) string {
+			return jysCode + stockCode
+		}), "https://finance.sina.com.cn")
+		if err != nil {
+			return nil, err
+		}
+		quotes, err := ParseSinaQuotes(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return filterQuotes(p.Name(), quotes), nil
+	})
+}
+
+/*
+	解析新浪行情，没有数据的股票(代码不存在)跳过。响应中没有任何行情记录时返回ErrNoQuoteData
+*/
+func ParseSinaQuotes(data string) (map[string]*model.RealTimeStock, error) {
+	quotes := make(map[string]*model.RealTimeStock)
+	found := false
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		eq := strings.Index(line, "=\"")
+		if !strings.HasPrefix(line, "var hq_str_") || eq < 0 {
+			continue
+		}
+		jysCode, stockCode, ok := splitCode(line[len("var hq_str_"):eq])
+		if !ok {
+			continue
+		}
+		found = true
+
+		value := line[eq+2:]
+		if end := strings.LastIndex(value, "\""); end >= 0 {
+			value = value[:end]
+		}
+		fields := strings.Split(value, ",")
+		if len(fields) < 32 {
+			continue
+		}
+		quotes[jysCode+stockCode] = sinaQuote(jysCode, stockCode, fields)
+	}
+	if !found {
+		return nil, ErrNoQuoteData
+	}
+	return quotes, nil
+}
+
+func sinaQuote(jysCode, stockCode string, fields []string) *model.RealTimeStock {
+	float := func(i int) float64 {
+		v, _ := strconv.ParseFloat(fields[i], 64)
+		return v
+	}
+	integer := func(i int) int {
+		v, _ := strconv.ParseFloat(fields[i], 64)
+		return int(v)
+	}
+
+	var q model.RealTimeStock
+	q.JysCode = jysCode
+	q.StockCode = stockCode
+	q.TodayOpenPrice = float(1)
+	q.YestdayClosePrice = float(2)
+	q.NowPrice = float(3)
+	q.TodayHighPrice = float(4)
+	q.TodayLowPrice = float(5)
+	q.BuyPrice = float(6)
+	q.SellPrice = float(7)
+	q.DealCount = integer(8)
+	q.DealMoney = float(9)
+	q.BuyCount1, q.BuyPrice1 = integer(10), float(11)
+	q.BuyCount2, q.BuyPrice2 = integer(12), float(13)
+	q.BuyCount3, q.BuyPrice3 = integer(14), float(15)
+	q.BuyCount4, q.BuyPrice4 = integer(16), float(17)
+	q.BuyCount5, q.BuyPrice5 = integer(18), float(19)
+	q.SellCount1, q.SellPrice1 = integer(20), float(21)
+	q.SellCount2, q.SellPrice2 = integer(22), float(23)
+	q.SellCount3, q.SellPrice3 = integer(24), float(25)
+	q.SellCount4, q.SellPrice4 = integer(26), float(27)
+	q.SellCount5, q.SellPrice5 = integer(28), float(29)
+	q.DealDate = fields[30]
+	q.DealTime = fields[31]
+	return &q
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"background/stock/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const TENCENT_REALTIME_STOCK_URL = "http://qt.gtimg.cn/"
+
+/*
+	腾讯行情 http://qt.gtimg.cn/q=sh600000,sz000001
+	每只股票一行：v_sh600000="1~名称~代码~当前价~昨收~今开~成交量(手)~外盘~内盘~买一价~买一量(手)~...~卖五量~最近逐笔~时间(20060102150405)~涨跌~涨跌%~最高~最低~价格/成交量/成交额~成交量(手)~成交额(万)~...";
+*/
+type TencentQuoteProvider struct {
+	Url    string
+	client *http.Client
+}
+
+func NewTencentQuoteProvider() *TencentQuoteProvider {
+	return &TencentQuoteProvider{Url: TENCENT_REALTIME_STOCK_URL, client: quoteClient}
+}
+
+func (p *TencentQuoteProvider) Name() string {
+	return "tencent"
+}
+
+func (p *TencentQuoteProvider) Fetch(codes []string) (map[string]*model.RealTimeStock, error) {
+	return fetchBatches(codes, 60, func(batch []string) (map[string]*model.RealTimeStock, error) {
+		data, err := httpGet(p.client, p.Url+"q="+joinCodes(batch, func(jysCode, stockCode string) string {
+			return jysCode + stockCode
+		}), "")
+		if err != nil {
+			return nil, err
+		}
+		quotes, err := ParseTencentQuotes(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return filterQuotes(p.Name(), quotes), nil
+	})
+}
+
+/*
+	解析腾讯行情，代码不存在时返回v_pv_none_match，跳过
+*/
+func ParseTencentQuotes(data string) (map[string]*model.RealTimeStock, error) {
+	quotes := make(map[string]*model.RealTimeStock)
+	found := false
+	for _, line := range strings.Split(data, ";") {
+		line = strings.TrimSpace(line)
+		eq := strings.Index(line, "=\"")
+		if !strings.HasPrefix(line, "v_") || eq < 0 {
+			continue
+		}
+		found = true
+		jysCode, stockCode, ok := splitCode(line[len("v_"):eq])
+		if !ok {
+			continue
+		}
+
+		value := strings.TrimSuffix(line[eq+2:], "\"")
+		fields := strings.Split(value, "~")
+		if len(fields) < 38 || len(fields[30]) != 14 {
+			continue
+		}
+		quotes[jysCode+stockCode] = tencentQuote(jysCode, stockCode, fields)
+	}
+	if !found {
+		return nil, ErrNoQuoteData
+	}
+	return quotes, nil
+}
+
+func tencentQuote(jysCode, stockCode string, fields []string) *model.RealTimeStock {
+	float := func(i int) float64 {
+		v, _ := strconv.ParseFloat(fields[i], 64)
+		return v
+	}
+	// 腾讯的量以手为单位
+	shares := func(i int) int {
+		v, _ := strconv.ParseFloat(fields[i], 64)
+		return int(v * 100)
+	}
+
+	var q model.RealTimeStock
+	q.JysCode = jysCode
+	q.StockCode = stockCode
+	q.NowPrice = float(3)
+	q.YestdayClosePrice = float(4)
+	q.TodayOpenPrice = float(5)
+	q.DealCount = shares(36)
+	q.DealMoney = float(37) * 10000
+	q.TodayHighPrice = float(33)
+	q.TodayLowPrice = float(34)
+	q.BuyPrice1, q.BuyCount1 = float(9), shares(10)
+	q.BuyPrice2, q.BuyCount2 = float(11), shares(12)
+	q.BuyPrice3, q.BuyCount3 = float(13), shares(14)
+	q.BuyPrice4, q.BuyCount4 = float(15), shares(16)
+	q.BuyPrice5, q.BuyCount5 = float(17), shares(18)
+	q.SellPrice1, q.SellCount1 = float(19), shares(20)
+	q.SellPrice2, q.SellCount2 = float(21), shares(22)
+	q.SellPrice3, q.SellCount3 = float(23), shares(24)
+	q.SellPrice4, q.SellCount4 = float(25), shares(26)
+	q.SellPrice5, q.SellCount5 = float(27), shares(28)
+	q.BuyPrice = q.BuyPrice1
+	q.SellPrice = q.SellPrice1
+	t := fields[30]
+	q.DealDate = t[0:4] + "-" + t[4:6] + "-" + t[6:8]
+	q.DealTime = t[8:10] + ":" + t[10:12] + ":" + t[12:14]
+	return &q
+}
@@ -11,6 +11,7 @@ import (
 	"log"
 	"background/stock/model"
 	"background/stock/config"
+	"background/stock/indicator"
 	"background/common/constant"
 	cc "background/stock/controller"
 
@@ -54,6 +55,7 @@ func main(){
 	db.DB().SetMaxIdleConns(10)
 
 	model.InitModel(db)
+	indicator.InitCache(config.GetIndicatorCacheSize())
 
 	r := gin.New()
 
@@ -70,6 +72,37 @@ func main(){
 	{
 		cms.GET("/stock/price", cc.StockPriceHandler)
 		cms.GET("/stock/list", cc.StockListHandler)
+		cms.GET("/stock/bars", cc.StockMinuteBarHandler)
+
+		cms.GET("/backtest/list", cc.BacktestListHandler)
+		cms.GET("/backtest/report", cc.BacktestReportHandler)
+		cms.GET("/backtest/report/csv", cc.BacktestReportCsvHandler)
+	}
+
+	stockCms := r.Group("cms")
+	stockCms.Use(dbMiddleware)
+	{
+		stockCms.GET("/stock/indicator", cc.StockIndicatorHandler)
+
+		stockCms.GET("/alert/rule/list", cc.AlertRuleListHandler)
+		stockCms.POST("/alert/rule/save", cc.AlertRuleSaveHandler)
+		stockCms.POST("/alert/rule/delete", cc.AlertRuleDeleteHandler)
+		stockCms.GET("/alert/log/list", cc.AlertLogListHandler)
+
+		stockCms.GET("/account/list", cc.AccountListHandler)
+		stockCms.POST("/account/save", cc.AccountSaveHandler)
+		stockCms.GET("/account/position", cc.AccountPositionHandler)
+		stockCms.GET("/account/trade/list", cc.AccountTradeListHandler)
+		stockCms.POST("/account/trade/save", cc.AccountTradeSaveHandler)
+		stockCms.POST("/account/trade/delete", cc.AccountTradeDeleteHandler)
+		stockCms.POST("/account/trade/import", cc.AccountTradeImportHandler)
+		stockCms.GET("/account/snapshot/list", cc.AccountSnapshotListHandler)
+
+		stockCms.GET("/screen/list", cc.ScreenListHandler)
+		stockCms.POST("/screen/save", cc.ScreenSaveHandler)
+		stockCms.POST("/screen/delete", cc.ScreenDeleteHandler)
+		stockCms.POST("/screen/run", cc.ScreenRunHandler)
+		stockCms.GET("/screen/hit/list", cc.ScreenHitListHandler)
 	}
 
 	r.Static("/stock",  config.GetStaticRoot())
@@ -0,0 +1,73 @@
+package strategy
+
+import (
+	"background/stock/backtest"
+)
+
+/*
+	GetFreqWaveStock波动规则的回测版本：收盘价从最低点上涨超过Swing时买入，
+	从最高点回落超过Swing时卖出。每只股票最多使用Money的资金
+*/
+type FreqWaveStrategy struct {
+	Money float64 `json:"money"` // 每只股票的资金上限
+	Swing float64 `json:"swing"` // 一次波动的最小幅度
+
+	waves map[string]*wave
+}
+
+type wave struct {
+	low  float64
+	high float64
+	up   bool // 处于上涨波段
+}
+
+func NewFreqWaveStrategy(money, swing float64) *FreqWaveStrategy {
+	return &FreqWaveStrategy{
+		Money: money,
+		Swing: swing,
+		waves: make(map[string]*wave),
+	}
+}
+
+func (s *FreqWaveStrategy) OnBar(ctx *backtest.Context, day *backtest.Day) {
+	for _, code := range day.Codes() {
+		bar := day.Bars[code]
+		if bar.Suspended() {
+			continue
+		}
+		w, ok := s.waves[code]
+		if !ok {
+			s.waves[code] = &wave{low: bar.Close, high: bar.Close}
+			continue
+		}
+
+		price := bar.Close
+		if !w.up && GetRose(price-w.low, w.low) > s.Swing {
+			w.up = true
+			if ctx.Position(code) == nil {
+				ctx.BuyValue(code, s.Money, price, 0)
+			}
+		} else if w.up && GetRose(w.high-price, w.high) > s.Swing {
+			w.up = false
+			if pos := ctx.Position(code); pos != nil && pos.Available > 0 {
+				ctx.Sell(code, pos.Available, 0)
+			}
+		}
+
+		if price > w.high {
+			w.high = price
+			if w.up {
+				w.low = price
+			}
+		}
+		if price < w.low {
+			w.low = price
+			if !w.up {
+				w.high = price
+			}
+		}
+	}
+}
+
+func (s *FreqWaveStrategy) OnFill(ctx *backtest.Context, fill *backtest.Fill) {
+}
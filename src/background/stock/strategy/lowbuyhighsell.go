@@ -111,7 +111,10 @@ func GetRose(first,second float64)(result float64){
 	return first  / second
 }
 
-func LowBuyHighSell(stockCode ,begin,end string,db *gorm.DB){
+/*
+lookback为计算最高价、最低价的交易日数，minRange为最高价与最低价的最小差幅，小于此值不交易
+*/
+func LowBuyHighSell(stockCode ,begin,end string,lookback int,minRange float64,db *gorm.DB){
 	var err error
 
 	var allMoney,surplusMoney float64;
@@ -148,7 +151,7 @@ func LowBuyHighSell(stockCode ,begin,end string,db *gorm.DB){
 	}
 
 	var beforeStockHistoryDataQs []*model.StockHistoryDataQ
-	if err ,beforeStockHistoryDataQs = GetStockHistoryDataQByDatePre(stockCode,begin,180,db) ; err != nil{
+	if err ,beforeStockHistoryDataQs = GetStockHistoryDataQByDatePre(stockCode,begin,lookback,db) ; err != nil{
 		logger.Error(err)
 		return
 	}
@@ -173,7 +176,7 @@ func LowBuyHighSell(stockCode ,begin,end string,db *gorm.DB){
 	//如果最高价与最低价相差幅度小于50%,直接退出
 	var rose float64
 	rose = GetRose(highStockHistoryDataQ.Close - lowStockHistoryDataQ.Close,highStockHistoryDataQ.Close)
-	if rose < 0.2{
+	if rose < minRange{
 		logger.Debug("最高价与最低价相差幅度太小，不适合做高抛低吸操作")
 		return
 	}
@@ -0,0 +1,135 @@
+package strategy
+
+import (
+	"background/stock/backtest"
+)
+
+/*
+	LowBuyHighSell的回测版本，实现backtest.Strategy，可以同时回测多只股票。
+	每只股票最多使用Money的资金，买卖点规则与LowBuyHighSell相同
+*/
+type LowBuyHighSellStrategy struct {
+	Money    float64 `json:"money"`     // 每只股票的资金上限
+	Lookback int     `json:"lookback"`  // 计算最高价、最低价的交易日数
+	MinRange float64 `json:"min_range"` // 最高价与最低价的最小差幅，小于此值不交易
+
+	lastPrice map[string]float64 // 每只股票上一笔成交价
+}
+
+func NewLowBuyHighSellStrategy(money float64) *LowBuyHighSellStrategy {
+	return &LowBuyHighSellStrategy{
+		Money:     money,
+		Lookback:  180,
+		MinRange:  0.2,
+		lastPrice: make(map[string]float64),
+	}
+}
+
+// 最近Lookback个交易日和当天
+func (s *LowBuyHighSellStrategy) Warmup() int {
+	return s.Lookback + 1
+}
+
+// 最近n个交易日收盘价的最高、最低价，跳过停牌日
+func highLowClose(bars []*backtest.Bar) (high, low float64) {
+	for _, bar := range bars {
+		if bar.Suspended() {
+			continue
+		}
+		if high == 0 || bar.Close > high {
+			high = bar.Close
+		}
+		if low == 0 || bar.Close < low {
+			low = bar.Close
+		}
+	}
+	return high, low
+}
+
+func (s *LowBuyHighSellStrategy) OnBar(ctx *backtest.Context, day *backtest.Day) {
+	for _, code := range day.Codes() {
+		bar := day.Bars[code]
+		if bar.Suspended() {
+			continue
+		}
+		// 不包含当天
+		history := ctx.History(code, s.Lookback+1)
+		if len(history) < 61 {
+			continue
+		}
+		high, low := highLowClose(history[:len(history)-1])
+		if high == low || GetRose(high-low, high) < s.MinRange {
+			continue
+		}
+
+		if !s.buy(ctx, code, bar.Close, high, low) {
+			s.sell(ctx, code, bar.Close, history)
+		}
+	}
+}
+
+func (s *LowBuyHighSellStrategy) buy(ctx *backtest.Context, code string, price, high, low float64) bool {
+	var rate float64
+	if price < low {
+		rate = GetRose(high-low, high-price)
+		if rate < 0.07 {
+			rate = 0.07
+		}
+	} else {
+		rate = GetRose(high-price, high-low)
+	}
+
+	factor := func(levels []float64) float64 {
+		for i, threshold := range []float64{5, 4, 3, 2, 1} {
+			if rate > threshold {
+				return levels[i]
+			}
+		}
+		return levels[5]
+	}
+
+	pos := ctx.Position(code)
+	var money float64
+	if pos == nil {
+		// 首次建仓
+		money = s.Money * 0.5 * factor([]float64{1, 0.9, 0.8, 0.8, 0.7, 0.6})
+	} else if GetRose(s.lastPrice[code]-price, s.lastPrice[code]) > 0.15 {
+		// 比上一笔成交价下跌超过15%时加仓
+		money = s.Money * factor([]float64{0.2, 0.15, 0.12, 0.1, 0.08, 0.07})
+		if used := pos.Cost; used+money > s.Money {
+			money = (s.Money - used) * 0.8
+		}
+	} else {
+		return false
+	}
+	return ctx.BuyValue(code, money, price, 0) != nil
+}
+
+func (s *LowBuyHighSellStrategy) sell(ctx *backtest.Context, code string, price float64, history []*backtest.Bar) {
+	pos := ctx.Position(code)
+	if pos == nil || pos.Available == 0 {
+		return
+	}
+	last := s.lastPrice[code]
+	if price < last || GetRose(price-last, last) < 0.1 {
+		return
+	}
+
+	high, low := highLowClose(history[len(history)-61 : len(history)-1])
+	var count int64
+	if price > high {
+		count = pos.Count / 2
+	} else if price > low {
+		count = int64(float64(pos.Count) * GetRose(price-low, high-low))
+	} else {
+		return
+	}
+	if count < 100 {
+		count = 100
+	}
+	ctx.Sell(code, count, 0)
+}
+
+func (s *LowBuyHighSellStrategy) OnFill(ctx *backtest.Context, fill *backtest.Fill) {
+	s.lastPrice[fill.Code] = fill.Price
+}
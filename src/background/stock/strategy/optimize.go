@@ -0,0 +1,46 @@
+package strategy
+
+import (
+	"background/stock/backtest"
+	"background/stock/config"
+	"fmt"
+)
+
+/*
+	可以做参数优化的回测策略，参数名为策略的json字段名，没有给出的参数使用默认值
+*/
+var BacktestStrategies = map[string]backtest.StrategyFactory{
+	"low_buy_high_sell": func(cash float64, params backtest.Params) (backtest.Strategy, error) {
+		s := NewLowBuyHighSellStrategy(cash)
+		lookback := float64(s.Lookback)
+		if err := applyParams(params, map[string]*float64{"lookback": &lookback, "min_range": &s.MinRange}); err != nil {
+			return nil, err
+		}
+		s.Lookback = int(lookback)
+		if s.Lookback < 60 {
+			return nil, fmt.Errorf("lookback %d is less than 60", s.Lookback)
+		}
+		return s, nil
+	},
+	"freq_wave": func(cash float64, params backtest.Params) (backtest.Strategy, error) {
+		s := NewFreqWaveStrategy(cash, config.GetFreqWaveSwing())
+		if err := applyParams(params, map[string]*float64{"swing": &s.Swing}); err != nil {
+			return nil, err
+		}
+		if s.Swing <= 0 {
+			return nil, fmt.Errorf("swing %v must be positive", s.Swing)
+		}
+		return s, nil
+	},
+}
+
+func applyParams(params backtest.Params, fields map[string]*float64) error {
+	for name, value := range params {
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("unknown strategy param %s", name)
+		}
+		*field = value
+	}
+	return nil
+}
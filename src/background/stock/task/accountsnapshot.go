@@ -0,0 +1,28 @@
+package task
+
+import (
+	"background/common/logger"
+	"background/stock/component/calendar"
+	"background/stock/model"
+	"background/stock/portfolio"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	收盘后保存所有启用账户当天的资产快照
+*/
+func SnapshotAccounts(db *gorm.DB) {
+	var accounts []model.Account
+	if err := db.Where("status = 1").Find(&accounts).Error; err != nil {
+		logger.Error("query account err!!!,", err)
+		return
+	}
+	date := calendar.Default().In(time.Now()).Format("2006-01-02")
+	for i := range accounts {
+		if _, err := portfolio.SaveSnapshot(db, &accounts[i], date); err != nil {
+			logger.Error("保存账户快照失败:", accounts[i].Id, accounts[i].Name, err)
+		}
+	}
+}
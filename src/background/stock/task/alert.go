@@ -0,0 +1,349 @@
+package task
+
+import (
+	"background/common/logger"
+	"background/stock/component/calendar"
+	"background/stock/config"
+	"background/stock/indicator"
+	"background/stock/model"
+	"background/stock/tools/util"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	价格提醒：所有规则涉及的股票合并为批量请求获取行情，每个tick对规则求值，
+	条件从不满足变为满足时提醒一次，并在冷却时间内不再提醒。
+	行情由QuotePoller获取
+*/
+
+const alertRuleReloadInterval = time.Minute
+
+type alertRule struct {
+	key  string // 去重和冷却的键，alert_rule为rule:id，trans_prompt为prompt:id:类型
+	rule model.AlertRule
+}
+
+type alertState struct {
+	active    bool // 上一个tick条件是否满足
+	lastFired time.Time
+}
+
+// 求值需要的日线数据，每个交易日加载一次
+type alertDaily struct {
+	date      string
+	avgVolume float64           // 前5个交易日平均成交量(手)
+	values    *indicator.Values // 最近一个交易日的指标
+}
+
+type AlertEngine struct {
+	db       *gorm.DB
+	rules    []*alertRule
+	loadedAt time.Time
+	states   map[string]*alertState
+	daily    map[string]*alertDaily
+	webhook  *http.Client
+}
+
+func NewAlertEngine(db *gorm.DB) *AlertEngine {
+	return &AlertEngine{
+		db:      db,
+		states:  make(map[string]*alertState),
+		daily:   make(map[string]*alertDaily),
+		webhook: &http.Client{Timeout: time.Second * 5},
+	}
+}
+
+// 涨跌停幅度，创业板、科创板20%，其余10%，不区分ST
+func limitRate(code string) float64 {
+	if strings.HasPrefix(code, "300") || strings.HasPrefix(code, "688") {
+		return 0.2
+	}
+	return 0.1
+}
+
+func round2(v float64) float64 {
+	return math.Floor(v*100+0.5) / 100
+}
+
+func indicatorValue(v *indicator.Values, name string) (float64, bool) {
+	switch name {
+	case "ma5":
+		return v.MA5, v.MA5 > 0
+	case "ma10":
+		return v.MA10, v.MA10 > 0
+	case "ma20":
+		return v.MA20, v.MA20 > 0
+	case "ma60":
+		return v.MA60, v.MA60 > 0
+	case "ema12":
+		return v.EMA12, v.EMA12 > 0
+	case "ema26":
+		return v.EMA26, v.EMA26 > 0
+	case "boll_upper":
+		return v.BollUpper, v.BollUpper > 0
+	case "boll_mid":
+		return v.BollMid, v.BollMid > 0
+	case "boll_lower":
+		return v.BollLower, v.BollLower > 0
+	}
+	return 0, false
+}
+
+func (e *AlertEngine) loadRules() {
+	var rules []model.AlertRule
+	if err := e.db.Where("status = 1").Find(&rules).Error; err != nil {
+		logger.Error("query alert_rule err!!!,", err)
+		return
+	}
+	var transPrompts []model.TransPrompt
+	if err := e.db.Where("status = 1").Find(&transPrompts).Error; err != nil {
+		logger.Error("query trans_prompt err!!!,", err)
+		return
+	}
+
+	var loaded []*alertRule
+	for _, rule := range rules {
+		key := fmt.Sprintf("rule:%d", rule.Id)
+		loaded = append(loaded, &alertRule{key: key, rule: rule})
+		if _, ok := e.states[key]; !ok && rule.LastTriggeredAt != nil {
+			e.states[key] = &alertState{lastFired: *rule.LastTriggeredAt}
+		}
+	}
+
+	// trans_prompt的买入价、卖出价和涨跌幅提醒
+	for _, prompt := range transPrompts {
+		base := model.AlertRule{StockCode: prompt.StockCode, Channel: model.AlertChannelEmail}
+		add := func(name string, rule model.AlertRule) {
+			loaded = append(loaded, &alertRule{key: fmt.Sprintf("prompt:%d:%s", prompt.Id, name), rule: rule})
+		}
+		if prompt.PromptBuyPrice > 0 {
+			rule := base
+			rule.Type, rule.Direction, rule.Threshold = model.AlertTypePrice, model.AlertDirectionDown, prompt.PromptBuyPrice
+			rule.Remark = fmt.Sprintf("到达买入价格，设定的交易量为%d", prompt.PromptBuyCount)
+			add("buy", rule)
+		}
+		if prompt.PromptSellPrice > 0 {
+			rule := base
+			rule.Type, rule.Direction, rule.Threshold = model.AlertTypePrice, model.AlertDirectionUp, prompt.PromptSellPrice
+			rule.Remark = fmt.Sprintf("到达卖出价格，设定的交易量为%d", prompt.PromptSellCount)
+			add("sell", rule)
+		}
+		rule := base
+		rule.Type, rule.Threshold = model.AlertTypeChange, config.GetAlertRiseThreshold()
+		add("rise", rule)
+		rule.Threshold = config.GetAlertFallThreshold()
+		add("fall", rule)
+	}
+
+	e.rules = loaded
+	e.loadedAt = time.Now()
+}
+
+func (e *AlertEngine) loadDaily(code, date string) *alertDaily {
+	if daily, ok := e.daily[code]; ok && daily.date == date {
+		return daily
+	}
+
+	daily := &alertDaily{date: date}
+	var rows []*model.StockHistoryDataQ
+	if err := e.db.Where("code = ? and date < ? and close > 0", code, date).Order("date desc").Limit(5).Find(&rows).Error; err != nil {
+		logger.Error("query stock_history_data_q err!!!,", err)
+	} else if len(rows) > 0 {
+		for _, row := range rows {
+			daily.avgVolume += row.Volume
+		}
+		daily.avgVolume /= float64(len(rows))
+	}
+	if series, err := indicator.GetSeries(e.db, code); err != nil {
+		logger.Error(err)
+	} else {
+		// 收盘后指标已包含当天，只取之前的交易日
+		for i := len(series) - 1; i >= 0; i-- {
+			if series[i].Date < date {
+				daily.values = series[i]
+				break
+			}
+		}
+	}
+	e.daily[code] = daily
+	return daily
+}
+
+// 规则涉及的股票代码
+func (e *AlertEngine) Codes() []string {
+	if time.Since(e.loadedAt) > alertRuleReloadInterval {
+		e.loadRules()
+	}
+	var codes []string
+	for _, r := range e.rules {
+		codes = append(codes, r.rule.StockCode)
+	}
+	return codes
+}
+
+/*
+	对所有规则求值
+*/
+func (e *AlertEngine) OnQuotes(quotes map[string]*model.RealTimeStock, now time.Time) {
+	for _, r := range e.rules {
+		quote, ok := quotes[r.rule.StockCode]
+		if !ok || quote.NowPrice == 0 || quote.YestdayClosePrice == 0 {
+			continue
+		}
+		active, message := e.evaluate(&r.rule, quote, now)
+
+		state, ok := e.states[r.key]
+		if !ok {
+			state = &alertState{}
+			e.states[r.key] = state
+		}
+		fire := active && !state.active
+		state.active = active
+		if !fire {
+			continue
+		}
+		cooldown := r.rule.Cooldown
+		if cooldown <= 0 {
+			cooldown = config.GetAlertCooldown()
+		}
+		if now.Sub(state.lastFired) < time.Second*time.Duration(cooldown) {
+			continue
+		}
+		state.lastFired = now
+
+		go e.deliver(r, quote, message, now)
+	}
+}
+
+/*
+	规则条件是否满足，满足时同时返回提醒内容
+*/
+func (e *AlertEngine) evaluate(rule *model.AlertRule, quote *model.RealTimeStock, now time.Time) (bool, string) {
+	price := quote.NowPrice
+	change := (price - quote.YestdayClosePrice) / quote.YestdayClosePrice
+
+	cross := func(line float64, what string) (bool, string) {
+		if rule.Direction == model.AlertDirectionDown {
+			return price <= line, fmt.Sprintf("价格%v向下跌破%s%.2f", price, what, line)
+		}
+		return price >= line, fmt.Sprintf("价格%v向上突破%s%.2f", price, what, line)
+	}
+
+	switch rule.Type {
+	case model.AlertTypePrice:
+		return cross(rule.Threshold, "")
+	case model.AlertTypeChange:
+		if rule.Threshold >= 0 {
+			return change >= rule.Threshold, fmt.Sprintf("涨幅%.2f%%超过%.2f%%", change*100, rule.Threshold*100)
+		}
+		return change <= rule.Threshold, fmt.Sprintf("跌幅%.2f%%超过%.2f%%", -change*100, -rule.Threshold*100)
+	case model.AlertTypeVolumeSpike:
+		minutes := calendar.Default().TradedMinutes(now)
+		daily := e.loadDaily(rule.StockCode, quote.DealDate)
+		if minutes < 5 || daily.avgVolume == 0 {
+			return false, ""
+		}
+		// 实时行情的成交量单位为股
+		ratio := float64(quote.DealCount) / 100 / minutes / (daily.avgVolume / 240)
+		return ratio >= rule.Threshold, fmt.Sprintf("量比%.2f超过%.2f", ratio, rule.Threshold)
+	case model.AlertTypeLimitUp:
+		limit := round2(quote.YestdayClosePrice * (1 + limitRate(rule.StockCode)))
+		return price >= limit, fmt.Sprintf("涨停，价格%v", price)
+	case model.AlertTypeLimitDown:
+		limit := round2(quote.YestdayClosePrice * (1 - limitRate(rule.StockCode)))
+		return price <= limit, fmt.Sprintf("跌停，价格%v", price)
+	case model.AlertTypeIndicatorCross:
+		daily := e.loadDaily(rule.StockCode, quote.DealDate)
+		if daily.values == nil {
+			return false, ""
+		}
+		line, ok := indicatorValue(daily.values, rule.Indicator)
+		if !ok {
+			return false, ""
+		}
+		return cross(line, rule.Indicator)
+	}
+	return false, ""
+}
+
+/*
+	按规则的提醒方式发送邮件和webhook，并记录到alert_log
+*/
+func (e *AlertEngine) deliver(r *alertRule, quote *model.RealTimeStock, message string, now time.Time) {
+	name := util.GetNameByCode(r.rule.StockCode, e.db)
+	change := (quote.NowPrice - quote.YestdayClosePrice) / quote.YestdayClosePrice
+	if r.rule.Remark != "" {
+		message += "，" + r.rule.Remark
+	}
+
+	var sent uint8
+	if r.rule.Channel&model.AlertChannelEmail != 0 {
+		if util.SendEmail("股票价格提醒",
+			"<div><h2>股票代码:"+r.rule.StockCode+"  股票名称:"+name+"</h2></br>"+
+				"<h4>"+message+"</h4></br>"+
+				"<h4>当前价格为:"+fmt.Sprint(quote.NowPrice)+"</h4></br>"+
+				"<h4>当前涨幅为:"+fmt.Sprintf("%.2f", change*100)+"%</h4></br></div>") {
+			sent |= model.AlertChannelEmail
+		}
+	}
+	if r.rule.Channel&model.AlertChannelWebhook != 0 {
+		url := r.rule.WebhookUrl
+		if url == "" {
+			url = config.GetAlertWebhookUrl()
+		}
+		if url != "" && e.postWebhook(url, r, quote, name, message, change, now) {
+			sent |= model.AlertChannelWebhook
+		}
+	}
+
+	var log model.AlertLog
+	log.RuleId = r.rule.Id
+	log.RuleKey = r.key
+	log.StockCode = r.rule.StockCode
+	log.Type = r.rule.Type
+	log.Price = quote.NowPrice
+	log.Change = change
+	log.Message = message
+	log.Channel = sent
+	if err := e.db.Create(&log).Error; err != nil {
+		logger.Error(err)
+	}
+	if r.rule.Id != 0 {
+		if err := e.db.Model(&model.AlertRule{}).Where("id = ?", r.rule.Id).Update("last_triggered_at", now).Error; err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+func (e *AlertEngine) postWebhook(url string, r *alertRule, quote *model.RealTimeStock, name, message string, change float64, now time.Time) bool {
+	body, _ := json.Marshal(map[string]interface{}{
+		"rule_id":    r.rule.Id,
+		"rule_key":   r.key,
+		"type":       r.rule.Type,
+		"stock_code": r.rule.StockCode,
+		"stock_name": name,
+		"price":      quote.NowPrice,
+		"change":     change,
+		"message":    message,
+		"time":       now.Format("2006-01-02 15:04:05"),
+	})
+	resp, err := e.webhook.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Error("发送提醒webhook失败:", url, err)
+		return false
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		logger.Error("发送提醒webhook失败:", url, resp.Status)
+		return false
+	}
+	return true
+}
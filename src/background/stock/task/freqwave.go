@@ -10,10 +10,11 @@ import (
 )
 
 /*
-寻找波动频繁的股票
+寻找波动频繁的股票，swing为一次波动的最小幅度(config.GetFreqWaveSwing)，
+和freq_wave回测策略的Swing参数含义相同
 */
 
-func GetFreqWaveStock(db *gorm.DB){
+func GetFreqWaveStock(db *gorm.DB,swing float64){
 	var err error
 	var stocks []model.StockList
 	if err = db.Find(&stocks).Error ; err != nil{
@@ -39,11 +40,11 @@ func GetFreqWaveStock(db *gorm.DB){
 		count := 0
 		flag := false
 		for _,stockHistoryDataQNew := range stockHistoryDataQNews{
-			if (stockHistoryDataQNew.Close - low) / low > 0.15 && !flag{
+			if (stockHistoryDataQNew.Close - low) / low > swing && !flag{
 				count++
 				flag = true
 			}
-			if (high - stockHistoryDataQNew.Close) / high > 0.15 && flag{
+			if (high - stockHistoryDataQNew.Close) / high > swing && flag{
 				count++
 				flag = false
 			}
@@ -0,0 +1,44 @@
+package task
+
+import (
+	"background/common/logger"
+	"background/stock/component/calendar"
+	"background/stock/config"
+	"background/stock/model"
+	"background/stock/service"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 没有数据时从沪深300发布日开始获取
+const indexHistoryBegin = "2005-01-01"
+
+/*
+	收盘后更新回测基准指数的日线，从已保存的最后一天开始获取
+*/
+func SyncIndexHistory(db *gorm.DB) {
+	code := config.GetBacktestBenchmark()
+	if code == "" {
+		return
+	}
+
+	begin := indexHistoryBegin
+	var last model.IndexHistoryData
+	if err := db.Where("code = ?", code).Order("date desc").First(&last).Error; err == nil {
+		begin = last.Date
+	} else if err != gorm.ErrRecordNotFound {
+		logger.Error("query index_history_data err!!!,", err)
+		return
+	}
+
+	end := calendar.Default().In(time.Now()).Format("2006-01-02")
+	rows, err := service.FetchIndexHistory(code, begin, end)
+	if err != nil {
+		logger.Error("获取指数日线失败:", code, err)
+		return
+	}
+	if err := service.SaveIndexHistory(db, rows); err != nil {
+		logger.Error("保存指数日线失败:", code, err)
+	}
+}
@@ -0,0 +1,197 @@
+package task
+
+import (
+	"background/common/logger"
+	"background/stock/component/calendar"
+	"background/stock/config"
+	"background/stock/model"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 聚合的分钟K线周期
+var MinuteBarPeriods = []uint8{1, 5, 15, 60}
+
+type tickState struct {
+	dealTime string // 上一个快照的成交时间，相同表示没有新成交
+	volume   int64  // 上一个快照的累计成交量
+	amount   float64
+	bars     map[uint8]*model.StockMinuteBar // 各周期当前的K线
+}
+
+/*
+	分时数据记录：保存每个新的行情快照，并聚合为1、5、15、60分钟K线。
+	K线每收到一个快照就更新到数据库，成交量为相邻快照累计成交量之差。
+	连续竞价、集合竞价和收盘快照由不同的poller调用，时段交界时可能同时执行
+*/
+type IntradayRecorder struct {
+	db     *gorm.DB
+	lock   sync.Mutex
+	states map[string]*tickState
+}
+
+func NewIntradayRecorder(db *gorm.DB) *IntradayRecorder {
+	return &IntradayRecorder{db: db, states: make(map[string]*tickState)}
+}
+
+// 除了其他listener的股票，额外记录配置中的股票
+func (r *IntradayRecorder) Codes() []string {
+	return config.GetIntradayCodes()
+}
+
+/*
+	K线开始时间：按当天连续竞价已进行的分钟数划分，集合竞价的成交计入第一根K线，
+	11:30、15:00收盘时的快照计入前一根K线
+*/
+func barTime(t time.Time, period uint8) time.Time {
+	t = calendar.Default().In(t)
+	minutes := int(calendar.Default().TradedMinutes(t))
+	if minutes >= 240 {
+		minutes = 239
+	} else if minutes >= 120 && t.Hour() < 13 {
+		minutes = 119
+	}
+	minutes = minutes / int(period) * int(period)
+
+	start := time.Date(t.Year(), t.Month(), t.Day(), 9, 30, 0, 0, t.Location())
+	if minutes >= 120 {
+		start = time.Date(t.Year(), t.Month(), t.Day(), 13, 0, 0, 0, t.Location())
+		minutes -= 120
+	}
+	return start.Add(time.Minute * time.Duration(minutes))
+}
+
+func (r *IntradayRecorder) OnQuotes(quotes map[string]*model.RealTimeStock, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now = calendar.Default().In(now)
+	for code, quote := range quotes {
+		if quote.NowPrice == 0 {
+			continue
+		}
+		// 集合竞价开始时行情可能还是上一个交易日的
+		if quote.DealDate != now.Format("2006-01-02") {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", quote.DealDate+" "+quote.DealTime, now.Location())
+		if err != nil {
+			logger.Error("解析成交时间失败:", code, quote.DealDate, quote.DealTime)
+			continue
+		}
+		r.record(code, t, quote)
+	}
+}
+
+func (r *IntradayRecorder) record(code string, t time.Time, quote *model.RealTimeStock) {
+	state, ok := r.states[code]
+	dealTime := quote.DealDate + " " + quote.DealTime
+	if ok && state.dealTime == dealTime {
+		return
+	}
+	volume := int64(quote.DealCount)
+	if !ok || !strings.HasPrefix(state.dealTime, quote.DealDate) {
+		// 新的交易日，盘中重启时之前的成交已计入保存的K线
+		state = &tickState{bars: make(map[uint8]*model.StockMinuteBar)}
+		if ok || calendar.Default().TradedMinutes(t) < 1 {
+			state.volume = 0
+		} else {
+			state.volume = volume
+			state.amount = quote.DealMoney
+		}
+		r.states[code] = state
+	}
+
+	deltaVolume := volume - state.volume
+	deltaAmount := quote.DealMoney - state.amount
+	if deltaVolume < 0 {
+		deltaVolume, deltaAmount = 0, 0
+	}
+	state.dealTime = dealTime
+	state.volume = volume
+	state.amount = quote.DealMoney
+
+	var tick model.StockTick
+	tick.Code = code
+	tick.Time = t
+	tick.Price = quote.NowPrice
+	tick.Volume = volume
+	tick.Amount = quote.DealMoney
+	table := model.StockTickTable(t)
+	if err := model.EnsurePartitionTable(r.db, table, &model.StockTick{}); err != nil {
+		logger.Error(err)
+		return
+	}
+	if err := r.db.Table(table).Create(&tick).Error; err != nil {
+		logger.Error(err)
+	}
+
+	// 集合竞价阶段的快照只记录，成交在开盘后计入第一根K线
+	if calendar.Default().Phase(t) == calendar.PhaseAuction {
+		state.volume, state.amount = 0, 0
+		return
+	}
+
+	table = model.StockMinuteBarTable(t)
+	if err := model.EnsurePartitionTable(r.db, table, &model.StockMinuteBar{}); err != nil {
+		logger.Error(err)
+		return
+	}
+	for _, period := range MinuteBarPeriods {
+		start := barTime(t, period)
+		bar := state.bars[period]
+		if bar == nil || !bar.Time.Equal(start) {
+			bar = &model.StockMinuteBar{Code: code, Period: period, Time: start, Open: quote.NowPrice, High: quote.NowPrice, Low: quote.NowPrice}
+			// 重启后继续更新已保存的K线
+			var saved model.StockMinuteBar
+			if err := r.db.Table(table).Where("code = ? and period = ? and time = ?", code, period, start).First(&saved).Error; err == nil {
+				bar = &saved
+			}
+			state.bars[period] = bar
+		}
+		if quote.NowPrice > bar.High {
+			bar.High = quote.NowPrice
+		}
+		if quote.NowPrice < bar.Low {
+			bar.Low = quote.NowPrice
+		}
+		bar.Close = quote.NowPrice
+		bar.Volume += deltaVolume
+		bar.Amount += deltaAmount
+		if err := r.db.Table(table).Save(bar).Error; err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+/*
+	删除过期的分表，逐笔快照保留tick_retention_days天，分钟K线保留minute_bar_retention_months个月
+*/
+func (r *IntradayRecorder) Cleanup() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	tickBefore := model.StockTickTable(now.AddDate(0, 0, -config.GetTickRetentionDays()))
+	barBefore := model.StockMinuteBarTable(now.AddDate(0, -config.GetMinuteBarRetentionMonths(), 0))
+
+	for prefix, before := range map[string]string{model.StockTickTablePrefix: tickBefore, model.StockMinuteBarTablePrefix: barBefore} {
+		tables, err := model.ListPartitionTables(r.db, prefix)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		for _, table := range tables {
+			// 表名后缀为日期，长度相同时可以直接比较
+			if len(table) == len(before) && table < before {
+				logger.Debug("删除过期分表:", table)
+				if err := model.DropPartitionTable(r.db, table); err != nil {
+					logger.Error(err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package task
+
+import (
+	"background/common/logger"
+	"background/stock/model"
+	"background/stock/service"
+	"background/stock/tools/util"
+	"time"
+)
+
+// 接收实时行情，Codes返回需要获取行情的股票代码(不含交易所前缀)
+type QuoteListener interface {
+	Codes() []string
+	OnQuotes(quotes map[string]*model.RealTimeStock, now time.Time)
+}
+
+/*
+	合并所有listener需要的股票，一次批量获取行情后分发给每个listener
+*/
+type QuotePoller struct {
+	listeners []QuoteListener
+}
+
+func (p *QuotePoller) Add(listener QuoteListener) {
+	p.listeners = append(p.listeners, listener)
+}
+
+func (p *QuotePoller) Poll() {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, listener := range p.listeners {
+		for _, code := range listener.Codes() {
+			if len(code) != 6 || seen[code] {
+				continue
+			}
+			jysCode := util.GetJysCodeByStockCode(code)
+			if jysCode == "" {
+				continue
+			}
+			seen[code] = true
+			codes = append(codes, jysCode+code)
+		}
+	}
+	if len(codes) == 0 {
+		return
+	}
+
+	quotes, err := service.GetRealTimeStocks(codes)
+	if err != nil {
+		logger.Error("获取股票实时信息失败:", err)
+		return
+	}
+
+	now := time.Now()
+	for _, listener := range p.listeners {
+		listener.OnQuotes(quotes, now)
+	}
+}
+
+/*
+	按间隔获取行情，直到keep返回false。用于连续竞价以外需要行情的时段(集合竞价、收盘快照)
+*/
+func (p *QuotePoller) PollWhile(interval time.Duration, keep func(now time.Time) bool) {
+	for keep(time.Now()) {
+		p.Poll()
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,68 @@
+package task
+
+import (
+	"background/common/logger"
+	"background/stock/component/calendar"
+	"background/stock/config"
+	"background/stock/model"
+	"background/stock/screener"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	运行一个保存的选股条件，覆盖当天的结果
+*/
+func RunScreen(db *gorm.DB, screen *model.Screen) ([]*screener.Hit, error) {
+	expr, err := screener.Compile(screen.Expression)
+	if err != nil {
+		return nil, err
+	}
+	hits, err := screener.Run(db, expr, config.GetScreenerWorkers())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	date := calendar.Default().In(now).Format("2006-01-02")
+	tx := db.Begin()
+	if err := tx.Where("screen_id = ? and date = ?", screen.Id, date).Delete(&model.ScreenHit{}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for _, hit := range hits {
+		record := model.ScreenHit{ScreenId: screen.Id, Date: date, StockCode: hit.Code, StockName: hit.Name, Close: hit.Close}
+		if err := tx.Create(&record).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Model(screen).Updates(map[string]interface{}{"last_run_at": now, "last_hit_count": len(hits)}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+/*
+	收盘后运行所有启用的选股条件，需要在当天行情同步之后
+*/
+func RunScreens(db *gorm.DB) {
+	var screens []model.Screen
+	if err := db.Where("status = 1").Find(&screens).Error; err != nil {
+		logger.Error("query screen err!!!,", err)
+		return
+	}
+	for i := range screens {
+		hits, err := RunScreen(db, &screens[i])
+		if err != nil {
+			logger.Error("选股失败:", screens[i].Id, screens[i].Name, err)
+			continue
+		}
+		logger.Debug("选股", screens[i].Name, "共", len(hits), "只")
+	}
+}
@@ -68,7 +68,7 @@ func main(){
 			Count++
 			process.Unlock()
 			sDate := stock.TimeToMarket[0:4] + "-" + stock.TimeToMarket[4:6] + "-" + stock.TimeToMarket[6:8]
-			strategy.LowBuyHighSell(stock.Code,sDate,sNowDate,db)
+			strategy.LowBuyHighSell(stock.Code,sDate,sNowDate,config.GetLowBuyHighSellLookback(),config.GetLowBuyHighSellMinRange(),db)
 			process.Lock()
 			Count--
 			process.Unlock()
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"background/stock/component/calendar"
 	"background/stock/config"
 	"background/stock/model"
 	"background/stock/task"
@@ -12,7 +13,6 @@ import (
 	"github.com/jinzhu/gorm"
 	_ "github.com/go-sql-driver/mysql"
 	//"fmt"
-	"fmt"
 )
 
 func main() {
@@ -39,40 +39,55 @@ func main() {
 	
 	model.InitModel(db)
 
-	go func(){
-		for{
-			var p = time.Now()
-			if (fmt.Sprintf("%02d%02d",p.Hour(),p.Minute()) >= "0930" && fmt.Sprintf("%02d%02d",p.Hour(),p.Minute()) <= "1130") ||
-				(fmt.Sprintf("%02d%02d",p.Hour(),p.Minute()) >= "1300" && fmt.Sprintf("%02d%02d",p.Hour(),p.Minute()) <= "1500"){
-				go task.TransPromptAll(db)
-			}
-			time.Sleep(time.Minute)
-		}
-	}()
-
-	//go func(){
-	//	for{
-	//		var p = time.Now()
-	//		if fmt.Sprintf("%02d%02d",p.Hour(),p.Minute()) > "1500"{
-	//			go task.SyncAllRealTimeStockInfo(db)
-	//		}
-	//		time.Sleep(time.Hour * 3)
-	//	}
-	//}()
-	//
-	//go func(){
-	//	for{
-	//		var p = time.Now()
-	//		if fmt.Sprintf("%02d%02d",p.Hour(),p.Minute()) > "1500"{
-	//			go task.GetTonghuashun(db)
-	//		}
-	//		time.Sleep(time.Hour)
-	//	}
-	//}()
+	// 没有节假日数据时会在休市日执行任务，不能启动
+	if err = calendar.Init(config.GetHolidayFile()); err != nil {
+		logger.Fatal("Load holiday file failed, ", err)
+		return
+	}
+	if year := calendar.Default().In(time.Now()).Year(); !calendar.Default().Covers(year) {
+		logger.Fatal("Holiday file has no data for ", year)
+		return
+	}
+
+	scheduler := calendar.NewScheduler(calendar.Default())
+
+	intradayRecorder := task.NewIntradayRecorder(db)
+	var quotePoller task.QuotePoller
+	quotePoller.Add(task.NewAlertEngine(db))
+	quotePoller.Add(intradayRecorder)
+	scheduler.Every(time.Second * time.Duration(config.GetAlertPollInterval()), "quote", quotePoller.Poll)
+
+	// Every只在连续竞价时段执行，集合竞价和15:00收盘快照单独获取
+	var intradayPoller task.QuotePoller
+	intradayPoller.Add(intradayRecorder)
+	scheduler.On(calendar.EventPreOpen, "intraday_auction", func() {
+		intradayPoller.PollWhile(time.Second * time.Duration(config.GetAlertPollInterval()), func(now time.Time) bool {
+			return calendar.Default().Phase(now) == calendar.PhaseAuction
+		})
+	})
+	scheduler.On(calendar.EventClose, "intraday_close", func() {
+		end := time.Now().Add(time.Minute * 3)
+		intradayPoller.PollWhile(time.Second * time.Duration(config.GetAlertPollInterval()), func(now time.Time) bool {
+			return now.Before(end)
+		})
+	})
+	scheduler.On(calendar.EventAfterClose, "intraday_cleanup", intradayRecorder.Cleanup)
+
+	scheduler.On(calendar.EventAfterClose, "realtimestock", func() {
+		task.SyncAllRealTimeStockInfo(db)
+		task.RunScreens(db)
+	})
+	scheduler.On(calendar.EventAfterClose, "tonghuashun", func() {
+		task.GetTonghuashun(db)
+	})
+	scheduler.On(calendar.EventAfterClose, "account_snapshot", func() {
+		task.SnapshotAccounts(db)
+	})
+	scheduler.On(calendar.EventAfterClose, "index_history", func() {
+		task.SyncIndexHistory(db)
+	})
 
 	//task.GetLargeFallStockInfo(db)
 
-	for {
-		time.Sleep(time.Minute * 5)
-	}
+	scheduler.Run()
 }
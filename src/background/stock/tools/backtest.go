@@ -0,0 +1,80 @@
+package main
+
+import (
+	"background/common/logger"
+	"background/stock/backtest"
+	"background/stock/config"
+	"background/stock/strategy"
+	"flag"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	用LowBuyHighSell策略回测并保存结果
+	go run backtest.go -codes 600000,000001 -begin 2016-01-01 -end 2018-01-01
+*/
+func main() {
+	configPath := flag.String("conf", "../config/config.json", "Config file path")
+	codes := flag.String("codes", "", "Stock codes, separated by comma")
+	begin := flag.String("begin", "", "Begin date")
+	end := flag.String("end", "", "End date")
+	cash := flag.Float64("cash", 100000, "Initial cash")
+	benchmark := flag.String("benchmark", "", "Benchmark index code")
+	flag.Parse()
+
+	err := config.LoadConfig(*configPath)
+	if err != nil {
+		logger.Error("Config Failed!!!!", err)
+		return
+	}
+
+	logger.SetLevel(config.GetLoggerLevel())
+
+	db, err := gorm.Open(config.GetDBName(), config.GetDBSource())
+	if err != nil {
+		logger.Fatal("Open db Failed!!!!", err)
+		return
+	}
+
+	db.LogMode(false)
+
+	var btConfig backtest.Config
+	btConfig.Codes = strings.Split(*codes, ",")
+	btConfig.Begin = *begin
+	btConfig.End = *end
+	btConfig.Cash = *cash
+	btConfig.Warmup = 180
+	btConfig.Benchmark = *benchmark
+	if btConfig.Benchmark == "" {
+		btConfig.Benchmark = config.GetBacktestBenchmark()
+	}
+
+	s := strategy.NewLowBuyHighSellStrategy(*cash / float64(len(btConfig.Codes)))
+	result, err := backtest.Run(db, btConfig, s)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	simulation, err := backtest.Save(db, "low_buy_high_sell", s, result)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	_, report, err := backtest.LoadReport(db, simulation.Id, "", config.GetRiskFreeRate())
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	m := report.Metrics
+	logger.Debug("模拟ID:", simulation.Id, " 成交笔数:", m.TradeCount, " 初始资金:", simulation.InitialCash,
+		" 总资产:", simulation.FinalEquity, " 收益率:", m.TotalReturn*100, "% 年化:", m.AnnualReturn*100,
+		"% 最大回撤:", m.MaxDrawdown*100, "% 夏普:", m.Sharpe, " 基准收益率:", m.BenchmarkReturn*100, "%")
+	if m.BenchmarkMissing != "" {
+		logger.Warn(m.BenchmarkMissing)
+	}
+}
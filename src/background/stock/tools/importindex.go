@@ -0,0 +1,60 @@
+package main
+
+import (
+	"background/common/logger"
+	"background/stock/config"
+	"background/stock/model"
+	"background/stock/service"
+	"flag"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	导入指数日线，用于回测的基准比较
+	go run importindex.go -codes sh000300,sh000001 -begin 2005-01-01
+*/
+func main() {
+	configPath := flag.String("conf", "../config/config.json", "Config file path")
+	codes := flag.String("codes", "", "Index codes with sh/sz prefix, separated by comma")
+	begin := flag.String("begin", "2005-01-01", "Begin date")
+	end := flag.String("end", time.Now().Format("2006-01-02"), "End date")
+	flag.Parse()
+
+	err := config.LoadConfig(*configPath)
+	if err != nil {
+		logger.Error("Config Failed!!!!", err)
+		return
+	}
+
+	logger.SetLevel(config.GetLoggerLevel())
+
+	db, err := gorm.Open(config.GetDBName(), config.GetDBSource())
+	if err != nil {
+		logger.Fatal("Open db Failed!!!!", err)
+		return
+	}
+
+	db.LogMode(false)
+
+	model.InitModel(db)
+
+	if *codes == "" {
+		*codes = config.GetBacktestBenchmark()
+	}
+	for _, code := range strings.Split(*codes, ",") {
+		rows, err := service.FetchIndexHistory(code, *begin, *end)
+		if err != nil {
+			logger.Error(code, err)
+			continue
+		}
+		if err := service.SaveIndexHistory(db, rows); err != nil {
+			logger.Error(code, err)
+			continue
+		}
+		logger.Debug("指数:", code, " 导入日线:", len(rows))
+	}
+}
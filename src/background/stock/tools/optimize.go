@@ -0,0 +1,157 @@
+package main
+
+import (
+	"background/common/logger"
+	"background/stock/backtest"
+	"background/stock/config"
+	"background/stock/model"
+	"background/stock/strategy"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+	在股票池上遍历策略参数并做walk-forward，结果按排名保存到optimization_result
+	go run optimize.go -strategy low_buy_high_sell -grid "lookback=120,180,240;min_range=0.1,0.2,0.3" -begin 2012-01-01 -end 2018-01-01 -in 24 -out 6
+	不指定codes时使用stock_list中begin之前已上市一年的股票
+*/
+func main() {
+	configPath := flag.String("conf", "../config/config.json", "Config file path")
+	name := flag.String("strategy", "low_buy_high_sell", "Strategy name")
+	grid := flag.String("grid", "", "Param grid, e.g. lookback=120,180;min_range=0.1,0.2")
+	codes := flag.String("codes", "", "Stock codes, separated by comma")
+	limit := flag.Int("limit", 0, "Max stock count, 0 for all")
+	begin := flag.String("begin", "", "Begin date")
+	end := flag.String("end", "", "End date")
+	cash := flag.Float64("cash", 100000, "Initial cash of each stock")
+	inMonths := flag.Int("in", 0, "In-sample months, 0 to disable walk-forward")
+	outMonths := flag.Int("out", 0, "Out-of-sample months")
+	workers := flag.Int("workers", 0, "Worker count, 0 to use config")
+	flag.Parse()
+
+	err := config.LoadConfig(*configPath)
+	if err != nil {
+		logger.Error("Config Failed!!!!", err)
+		return
+	}
+
+	logger.SetLevel(config.GetLoggerLevel())
+
+	factory, ok := strategy.BacktestStrategies[*name]
+	if !ok {
+		logger.Error("Unknown strategy ", *name)
+		return
+	}
+	paramGrid, err := parseGrid(*grid)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	db, err := gorm.Open(config.GetDBName(), config.GetDBSource())
+	if err != nil {
+		logger.Fatal("Open db Failed!!!!", err)
+		return
+	}
+
+	db.LogMode(false)
+
+	var opConfig backtest.OptimizeConfig
+	opConfig.Strategy = *name
+	opConfig.Grid = paramGrid
+	opConfig.Begin = *begin
+	opConfig.End = *end
+	opConfig.Cash = *cash
+	opConfig.InSampleMonths = *inMonths
+	opConfig.OutSampleMonths = *outMonths
+	opConfig.Workers = *workers
+	if opConfig.Workers <= 0 {
+		opConfig.Workers = config.GetOptimizeWorkers()
+	}
+	opConfig.RiskFree = config.GetRiskFreeRate()
+	if *codes != "" {
+		opConfig.Codes = strings.Split(*codes, ",")
+	} else if opConfig.Codes, err = getUniverse(db, *begin); err != nil {
+		logger.Error(err)
+		return
+	}
+	if *limit > 0 && len(opConfig.Codes) > *limit {
+		opConfig.Codes = opConfig.Codes[:*limit]
+	}
+	opConfig.Progress = func(done, total int) {
+		if done%50 == 0 || done == total {
+			logger.Debug("参数优化进度:", done, "/", total)
+		}
+	}
+
+	optimization, err := backtest.StartOptimization(db, opConfig)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	result, runErr := backtest.Optimize(db, opConfig, factory)
+	if runErr != nil {
+		logger.Error(runErr)
+	}
+	if err = backtest.FinishOptimization(db, optimization, result, runErr); err != nil {
+		logger.Error(err)
+		return
+	}
+	if runErr != nil {
+		return
+	}
+
+	for _, r := range result.Best() {
+		window := result.Windows[r.Window]
+		logger.Debug("窗口", r.Window, " 样本内:", window.InBegin, "~", window.InEnd, " 样本外:", window.OutBegin, "~", window.OutEnd,
+			" 参数:", r.Params.String(), " 样本内得分:", r.In.Score, " 样本外得分:", r.Out.Score)
+	}
+	logger.Debug("优化ID:", optimization.Id, " 最优参数:", optimization.BestParams,
+		" 样本内平均得分:", optimization.InSampleScore, " 样本外平均得分:", optimization.OutSampleScore)
+}
+
+// 解析name=v1,v2;name2=v3格式的参数网格
+func parseGrid(s string) (backtest.ParamGrid, error) {
+	grid := make(backtest.ParamGrid)
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid grid item %s", item)
+		}
+		for _, v := range strings.Split(kv[1], ",") {
+			value, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid grid value %s", v)
+			}
+			grid[strings.TrimSpace(kv[0])] = append(grid[strings.TrimSpace(kv[0])], value)
+		}
+	}
+	return grid, nil
+}
+
+// begin之前已上市一年的股票
+func getUniverse(db *gorm.DB, begin string) ([]string, error) {
+	t, err := time.Parse("2006-01-02", begin)
+	if err != nil {
+		return nil, err
+	}
+	var stocks []model.StockList
+	if err = db.Where("time_to_market > ? and time_to_market <= ?", "0", t.AddDate(-1, 0, 0).Format("20060102")).Order("code asc").Find(&stocks).Error; err != nil {
+		return nil, err
+	}
+	var codes []string
+	for _, stock := range stocks {
+		codes = append(codes, stock.Code)
+	}
+	return codes, nil
+}
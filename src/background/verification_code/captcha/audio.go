@@ -0,0 +1,174 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+)
+
+/*
+	音频验证码：用录好的数字读音0.wav～9.wav拼接，
+	每个数字随机音量和间隔，并混入白噪声和其他数字的低音量片段
+*/
+type AudioGenerator struct {
+	Length     int
+	sampleRate int
+	digits     [10][]float64
+}
+
+const (
+	audioNoise   = 0.04 // 白噪声幅度
+	audioBabble  = 0.15 // 干扰读音的音量
+	audioBabbles = 3    // 每个数字混入的干扰读音个数
+)
+
+/*
+	加载dir下的0.wav～9.wav，只支持8位或16位的PCM，所有文件的采样率必须相同
+*/
+func NewAudioGenerator(dir string, length int) (*AudioGenerator, error) {
+	g := &AudioGenerator{Length: length}
+	for i := range g.digits {
+		b, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.wav", i)))
+		if err != nil {
+			return nil, err
+		}
+		samples, sampleRate, err := decodeWav(b)
+		if err != nil {
+			return nil, fmt.Errorf("%d.wav: %v", i, err)
+		}
+		if g.sampleRate != 0 && sampleRate != g.sampleRate {
+			return nil, fmt.Errorf("%d.wav: sample rate %d, expected %d", i, sampleRate, g.sampleRate)
+		}
+		g.sampleRate = sampleRate
+		g.digits[i] = samples
+	}
+	return g, nil
+}
+
+func (g *AudioGenerator) silence(min, max float64) int {
+	return int((min + r.Float64()*(max-min)) * float64(g.sampleRate))
+}
+
+func (g *AudioGenerator) Generate(opts Options) (*Challenge, error) {
+	text := RandDigits(g.Length)
+
+	var out []float64
+	out = append(out, make([]float64, g.silence(0.3, 0.6))...)
+	for _, d := range text {
+		start := len(out)
+		gain := 0.8 + r.Float64()*0.3
+		for _, s := range g.digits[d-'0'] {
+			out = append(out, s*gain)
+		}
+		out = append(out, make([]float64, g.silence(0.3, 0.7))...)
+
+		// 在这个数字的范围内叠加其他数字的片段
+		for i := 0; i < audioBabbles; i++ {
+			other := g.digits[r.Intn(10)]
+			offset := start + r.Intn(len(out)-start)
+			for j := 0; j < len(other) && offset+j < len(out); j++ {
+				out[offset+j] += other[j] * audioBabble
+			}
+		}
+	}
+	for i := range out {
+		out[i] += (r.Float64()*2 - 1) * audioNoise
+	}
+
+	return &Challenge{Answer: text, ContentType: "audio/wav", Data: encodeWav(out, g.sampleRate)}, nil
+}
+
+// 生成随机数字
+func RandDigits(num int) string {
+	b := make([]byte, num)
+	for i := range b {
+		b[i] = byte('0' + secureIntn(10))
+	}
+	return string(b)
+}
+
+/*
+	解析wav文件，多声道取平均，返回-1～1之间的采样和采样率
+*/
+func decodeWav(b []byte) ([]float64, int, error) {
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return nil, 0, errors.New("not a wav file")
+	}
+	var channels, bits int
+	var sampleRate int
+	var data []byte
+	for pos := 12; pos+8 <= len(b); {
+		id := string(b[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(b[pos+4 : pos+8]))
+		pos += 8
+		if size < 0 || pos+size > len(b) {
+			size = len(b) - pos
+		}
+		chunk := b[pos : pos+size]
+		switch id {
+		case "fmt ":
+			if len(chunk) < 16 {
+				return nil, 0, errors.New("invalid fmt chunk")
+			}
+			if binary.LittleEndian.Uint16(chunk[0:2]) != 1 {
+				return nil, 0, errors.New("only PCM is supported")
+			}
+			channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			bits = int(binary.LittleEndian.Uint16(chunk[14:16]))
+		case "data":
+			data = chunk
+		}
+		// chunk按2字节对齐
+		pos += size + size%2
+	}
+	if channels == 0 || sampleRate == 0 {
+		return nil, 0, errors.New("missing fmt chunk")
+	}
+	if bits != 8 && bits != 16 {
+		return nil, 0, fmt.Errorf("unsupported bits per sample %d", bits)
+	}
+
+	frame := channels * bits / 8
+	samples := make([]float64, len(data)/frame)
+	for i := range samples {
+		var sum float64
+		for c := 0; c < channels; c++ {
+			p := data[i*frame+c*bits/8:]
+			if bits == 8 {
+				sum += (float64(p[0]) - 128) / 128
+			} else {
+				sum += float64(int16(binary.LittleEndian.Uint16(p))) / 32768
+			}
+		}
+		samples[i] = sum / float64(channels)
+	}
+	return samples, sampleRate, nil
+}
+
+// 编码为单声道16位PCM的wav
+func encodeWav(samples []float64, sampleRate int) []byte {
+	var buf bytes.Buffer
+	size := len(samples) * 2
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+size))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*2))
+	binary.Write(&buf, binary.LittleEndian, uint16(2))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(size))
+	for _, s := range samples {
+		s = math.Max(-1, math.Min(1, s))
+		binary.Write(&buf, binary.LittleEndian, int16(s*32767))
+	}
+	return buf.Bytes()
+}
@@ -23,7 +23,7 @@ import (
 
 var (
 	dpi      = flag.Float64("dpi", 72, "screen resolution in Dots Per Inch")
-	r = rand.New(rand.NewSource(time.Now().UnixNano()));
+	r = rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano())});
 	FontFamily []string = make([]string,0);
 )
 
@@ -372,10 +372,9 @@ func RandColor() color.RGBA{
 func RandText(num int) string {
 	textNum := len(txtChars);
 	text := "";
-	r := rand.New(rand.NewSource(time.Now().UnixNano()));
 
 	for i:=0;i<num ;i++  {
-		text = text + string(txtChars[r.Intn(textNum)]);
+		text = text + string(txtChars[secureIntn(textNum)]);
 	}
 	return text;
 }
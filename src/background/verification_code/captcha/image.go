@@ -0,0 +1,156 @@
+package captcha
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+)
+
+const (
+	defaultWidth  = 80
+	defaultHeight = 30
+)
+
+func (opts Options) size() (int, int) {
+	width, height := opts.Width, opts.Height
+	if width <= 0 || height <= 0 {
+		width, height = defaultWidth, defaultHeight
+	}
+	return width, height
+}
+
+// 底色、文字噪点和文字，不含随机噪点
+func drawTextImage(text string, width, height int) (*CaptchaImage, error) {
+	captchaImage, err := NewCaptchaImage(width, height, RandLightColor())
+	if err != nil {
+		return nil, err
+	}
+	if err := captchaImage.DrawTextNoise(CaptchaComplexHigh); err != nil {
+		return nil, err
+	}
+	if err := captchaImage.DrawText(text); err != nil {
+		return nil, err
+	}
+	captchaImage.DrawBorder(ColorToRGB(0x17A7A7A))
+	captchaImage.DrawHollowLine()
+	return captchaImage, nil
+}
+
+func encodeImage(text string, opts Options) (*Challenge, error) {
+	width, height := opts.size()
+	captchaImage, err := drawTextImage(text, width, height)
+	if err != nil {
+		return nil, err
+	}
+	captchaImage.DrawNoise(CaptchaComplexHigh)
+
+	var buf bytes.Buffer
+	if err := captchaImage.SaveImage(&buf, opts.Format); err != nil {
+		return nil, err
+	}
+	return &Challenge{ContentType: imageContentType(opts.Format), Data: buf.Bytes()}, nil
+}
+
+// 复制图片
+func (captcha *CaptchaImage) Clone() *CaptchaImage {
+	m := image.NewNRGBA(captcha.nrgba.Bounds())
+	draw.Draw(m, m.Bounds(), captcha.nrgba, image.ZP, draw.Src)
+	return &CaptchaImage{nrgba: m, width: captcha.width, height: captcha.height, Complex: captcha.Complex}
+}
+
+func (captcha *CaptchaImage) paletted() *image.Paletted {
+	m := image.NewPaletted(captcha.nrgba.Bounds(), palette.Plan9)
+	draw.Draw(m, m.Bounds(), captcha.nrgba, image.ZP, draw.Src)
+	return m
+}
+
+/*
+	保存为gif动画，delay为每帧的时间(1/100秒)，循环播放
+*/
+func SaveAnimation(frames []*CaptchaImage, delay int) ([]byte, error) {
+	anim := &gif.GIF{}
+	for _, frame := range frames {
+		anim.Image = append(anim.Image, frame.paletted())
+		anim.Delay = append(anim.Delay, delay)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 扭曲文字图片
+type TextGenerator struct {
+	Length int
+}
+
+func (g *TextGenerator) Generate(opts Options) (*Challenge, error) {
+	text := RandText(g.Length)
+	c, err := encodeImage(text, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.Answer = text
+	return c, nil
+}
+
+/*
+	算术题，加减法在20以内且结果不为负数，乘法在10以内
+*/
+type MathGenerator struct{}
+
+func (g *MathGenerator) Generate(opts Options) (*Challenge, error) {
+	var question string
+	var answer int
+	switch secureIntn(3) {
+	case 0:
+		a, b := secureIntn(20)+1, secureIntn(20)+1
+		question, answer = fmt.Sprintf("%d+%d=?", a, b), a+b
+	case 1:
+		a, b := secureIntn(20)+1, secureIntn(20)+1
+		if a < b {
+			a, b = b, a
+		}
+		question, answer = fmt.Sprintf("%d-%d=?", a, b), a-b
+	default:
+		a, b := secureIntn(9)+1, secureIntn(9)+1
+		question, answer = fmt.Sprintf("%dx%d=?", a, b), a*b
+	}
+	c, err := encodeImage(question, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.Answer = fmt.Sprint(answer)
+	return c, nil
+}
+
+/*
+	gif动画，文字不变，每帧重新画随机噪点
+*/
+type GifGenerator struct {
+	Length int
+	Frames int
+	Delay  int // 每帧的时间(1/100秒)
+}
+
+func (g *GifGenerator) Generate(opts Options) (*Challenge, error) {
+	width, height := opts.size()
+	text := RandText(g.Length)
+	base, err := drawTextImage(text, width, height)
+	if err != nil {
+		return nil, err
+	}
+	var frames []*CaptchaImage
+	for i := 0; i < g.Frames; i++ {
+		frames = append(frames, base.Clone().DrawNoise(CaptchaComplexHigh))
+	}
+	data, err := SaveAnimation(frames, g.Delay)
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{Answer: text, ContentType: "image/gif", Data: data}, nil
+}
@@ -0,0 +1,87 @@
+package captcha
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// 验证码类型
+const (
+	ModeText  = "text"  // 扭曲文字图片
+	ModeMath  = "math"  // 算术题图片，答案为计算结果
+	ModeGif   = "gif"   // 噪点逐帧变化的动画
+	ModeAudio = "audio" // 朗读数字的wav音频
+)
+
+// 生成的一道验证码
+type Challenge struct {
+	Answer      string
+	ContentType string
+	Data        []byte
+}
+
+type Options struct {
+	Width  int
+	Height int
+	Format int // 图片格式，只对text、math有效
+}
+
+/*
+	一种验证码类型，每次调用生成新的题目和答案
+*/
+type Generator interface {
+	Generate(opts Options) (*Challenge, error)
+}
+
+var generators = make(map[string]Generator)
+
+var (
+	ErrUnknownMode   = errors.New("不支持的验证码类型")
+	ErrUnknownFormat = errors.New("不支持的图片格式")
+)
+
+// 注册验证码类型，在服务启动时调用
+func Register(mode string, g Generator) {
+	generators[mode] = g
+}
+
+func Generate(mode string, opts Options) (*Challenge, error) {
+	g, ok := generators[mode]
+	if !ok {
+		return nil, ErrUnknownMode
+	}
+	return g.Generate(opts)
+}
+
+// 已注册的验证码类型
+func Modes() []string {
+	var modes []string
+	for mode := range generators {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	return modes
+}
+
+func ParseImageFormat(name string) (int, error) {
+	switch strings.ToLower(name) {
+	case "", "jpg", "jpeg":
+		return ImageFormatJpeg, nil
+	case "png":
+		return ImageFormatPng, nil
+	case "gif":
+		return ImageFormatGif, nil
+	}
+	return 0, ErrUnknownFormat
+}
+
+func imageContentType(imageFormat int) string {
+	switch imageFormat {
+	case ImageFormatPng:
+		return "image/png"
+	case ImageFormatGif:
+		return "image/gif"
+	}
+	return "image/jpeg"
+}
@@ -0,0 +1,38 @@
+package captcha
+
+import (
+	crand "crypto/rand"
+	"math/big"
+	"math/rand"
+	"sync"
+)
+
+/*
+	r只用于噪点、颜色等干扰，多个请求并发使用，需要加锁。
+	验证码答案用crypto/rand生成，不能由时间种子推算
+*/
+type lockedSource struct {
+	lock sync.Mutex
+	src  rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.src.Seed(seed)
+}
+
+// 返回[0, n)之间的随机数
+func secureIntn(n int) int {
+	v, err := crand.Int(crand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(v.Int64())
+}
@@ -0,0 +1,209 @@
+package challenge
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+)
+
+const (
+	challengeKeyPrefix = "captcha_challenge_" // 验证码类型和答案的hash
+	attemptKeyPrefix   = "captcha_attempt_"   // 每个验证码的错误次数
+	ipKeyPrefix        = "captcha_ip_"        // 每个ip在时间窗口内的错误次数，验证前先占用
+	ticketKeyPrefix    = "captcha_ticket_"    // 验证通过后的凭证，值为验证码类型
+)
+
+var (
+	ErrNotFound     = errors.New("验证码不存在或已过期")
+	ErrWrongAnswer  = errors.New("验证码错误")
+	ErrTooManyTries = errors.New("验证码错误次数过多")
+	ErrIpBlocked    = errors.New("验证失败次数过多，请稍后再试")
+)
+
+// 验证不通过的错误，可以直接返回给用户，其他错误为存储错误
+func IsVerifyError(err error) bool {
+	return err == ErrNotFound || err == ErrWrongAnswer || err == ErrTooManyTries || err == ErrIpBlocked
+}
+
+type Config struct {
+	TTL           int // 验证码有效期(秒)
+	MaxAttempts   int // 每个验证码允许的错误次数，达到后作废
+	IpMaxFailures int // 每个ip在IpWindow秒内允许的错误次数
+	IpWindow      int
+	TicketTTL     int // 通过凭证的有效期(秒)
+
+	TrustedProxies []string // 可信的反向代理ip或CIDR，只有来自这些地址的X-Forwarded-For才使用
+
+	Modes []string // Require接受的验证码类型，其他类型(如答案很少的算术题)的凭证不能使用
+}
+
+// 默认不接受答案只有几十种的算术题
+var DefaultModes = []string{"text", "gif", "audio"}
+
+func DefaultConfig() Config {
+	return Config{TTL: 300, MaxAttempts: 3, IpMaxFailures: 20, IpWindow: 600, TicketTTL: 300, Modes: DefaultModes}
+}
+
+// 没有配置(为0)的项使用默认值
+func (c Config) WithDefaults() Config {
+	d := DefaultConfig()
+	if c.TTL <= 0 {
+		c.TTL = d.TTL
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = d.MaxAttempts
+	}
+	if c.IpMaxFailures <= 0 {
+		c.IpMaxFailures = d.IpMaxFailures
+	}
+	if c.IpWindow <= 0 {
+		c.IpWindow = d.IpWindow
+	}
+	if c.TicketTTL <= 0 {
+		c.TicketTTL = d.TicketTTL
+	}
+	if len(c.Modes) == 0 {
+		c.Modes = d.Modes
+	}
+	return c
+}
+
+/*
+	验证码的签发和校验：答案只保存hash，验证码验证一次成功后立即作废。
+	验证通过后签发一次性凭证，其他服务的Require中间件消费凭证
+*/
+type Manager struct {
+	store   Store
+	config  Config
+	proxies []*net.IPNet
+}
+
+func NewManager(store Store, config Config) *Manager {
+	return &Manager{store: store, config: config, proxies: parseProxies(config.TrustedProxies)}
+}
+
+func randomId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// 答案不区分大小写，和id、类型一起hash
+func hashAnswer(id, mode, answer string) string {
+	sum := sha256.Sum256([]byte(id + ":" + mode + ":" + strings.ToLower(strings.TrimSpace(answer))))
+	return hex.EncodeToString(sum[:])
+}
+
+// 保存的值为"类型:hash"
+func splitChallenge(value string) (mode, hash string) {
+	if i := strings.LastIndexByte(value, ':'); i >= 0 {
+		return value[:i], value[i+1:]
+	}
+	return "", value
+}
+
+/*
+	保存验证码类型和答案，返回验证码id
+*/
+func (m *Manager) Issue(mode, answer string) (string, error) {
+	id, err := randomId()
+	if err != nil {
+		return "", err
+	}
+	if err := m.store.Set(challengeKeyPrefix+id, mode+":"+hashAnswer(id, mode, answer), m.config.TTL); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// 是否接受该类型验证码的凭证
+func (m *Manager) Accepts(mode string) bool {
+	for _, accepted := range m.config.Modes {
+		if mode == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	校验答案，正确时作废验证码并返回通过凭证。
+	比较答案前先累加验证码和ip的次数，并发猜测时每次都占用一次机会，超过上限直接拒绝。
+	验证码次数达到上限后作废，答对时退回ip占用的次数
+*/
+func (m *Manager) Verify(id, answer, ip string) (string, error) {
+	limitIp := ip != "" && m.config.IpMaxFailures > 0
+	if limitIp {
+		failures, err := m.store.Incr(ipKeyPrefix+ip, m.config.IpWindow)
+		if err != nil {
+			return "", err
+		}
+		if failures > m.config.IpMaxFailures {
+			return "", ErrIpBlocked
+		}
+	}
+
+	value, ok, err := m.store.Get(challengeKeyPrefix + id)
+	if err != nil {
+		return "", err
+	}
+	if !ok || id == "" {
+		return "", ErrNotFound
+	}
+	mode, hash := splitChallenge(value)
+
+	attempts, err := m.store.Incr(attemptKeyPrefix+id, m.config.TTL)
+	if err != nil {
+		return "", err
+	}
+	if attempts > m.config.MaxAttempts {
+		m.store.Delete(challengeKeyPrefix + id)
+		return "", ErrTooManyTries
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashAnswer(id, mode, answer))) != 1 {
+		if attempts >= m.config.MaxAttempts {
+			m.store.Delete(challengeKeyPrefix + id)
+			return "", ErrTooManyTries
+		}
+		return "", ErrWrongAnswer
+	}
+
+	// 并发验证同一个验证码时只有一个能成功
+	if _, ok, err := m.store.Take(challengeKeyPrefix + id); err != nil {
+		return "", err
+	} else if !ok {
+		return "", ErrNotFound
+	}
+	m.store.Delete(attemptKeyPrefix + id)
+	if limitIp {
+		if err := m.store.Decr(ipKeyPrefix + ip); err != nil {
+			return "", err
+		}
+	}
+
+	ticket, err := randomId()
+	if err != nil {
+		return "", err
+	}
+	if err := m.store.Set(ticketKeyPrefix+ticket, mode, m.config.TicketTTL); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+/*
+	消费通过凭证，每个凭证只能使用一次，返回验证码的类型
+*/
+func (m *Manager) Redeem(ticket string) (string, bool, error) {
+	if ticket == "" {
+		return "", false, nil
+	}
+	return m.store.Take(ticketKeyPrefix + ticket)
+}
@@ -0,0 +1,158 @@
+package challenge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestManager(config Config) *Manager {
+	return NewManager(NewMemoryStore(), config.WithDefaults())
+}
+
+func TestVerify(t *testing.T) {
+	m := newTestManager(Config{MaxAttempts: 3})
+	id, err := m.Issue("text", "AbC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(id, "abd", "1.1.1.1"); err != ErrWrongAnswer {
+		t.Errorf("wrong answer %v", err)
+	}
+	ticket, err := m.Verify(id, " abc ", "1.1.1.1")
+	if err != nil || ticket == "" {
+		t.Fatalf("verify %q %v", ticket, err)
+	}
+	// 验证码和凭证都只能使用一次
+	if _, err := m.Verify(id, "abc", "1.1.1.1"); err != ErrNotFound {
+		t.Errorf("reuse %v", err)
+	}
+	if mode, ok, err := m.Redeem(ticket); !ok || err != nil || mode != "text" {
+		t.Errorf("redeem %q %v %v", mode, ok, err)
+	}
+	if _, ok, _ := m.Redeem(ticket); ok {
+		t.Error("redeem twice")
+	}
+
+	id, _ = m.Issue("text", "abc")
+	for i := 0; i < 2; i++ {
+		if _, err := m.Verify(id, "x", ""); err != ErrWrongAnswer {
+			t.Errorf("attempt %d %v", i, err)
+		}
+	}
+	if _, err := m.Verify(id, "x", ""); err != ErrTooManyTries {
+		t.Errorf("last attempt %v", err)
+	}
+	if _, err := m.Verify(id, "abc", ""); err != ErrNotFound {
+		t.Errorf("after too many tries %v", err)
+	}
+}
+
+// 并发猜测时只有MaxAttempts次会比较答案
+func TestVerifyParallel(t *testing.T) {
+	m := newTestManager(Config{MaxAttempts: 3, IpMaxFailures: 1000})
+	id, _ := m.Issue("gif", "42")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Verify(id, "0", "1.1.1.1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	wrong := 0
+	for err := range errs {
+		if err == ErrWrongAnswer {
+			wrong++
+		} else if err != ErrTooManyTries && err != ErrNotFound {
+			t.Errorf("unexpected %v", err)
+		}
+	}
+	// 前两次答错，第三次答错后作废
+	if wrong != 2 {
+		t.Errorf("%d wrong answers, want 2", wrong)
+	}
+	if _, err := m.Verify(id, "42", "1.1.1.1"); err != ErrNotFound {
+		t.Errorf("after parallel guesses %v", err)
+	}
+}
+
+func TestVerifyIpBlocked(t *testing.T) {
+	m := newTestManager(Config{MaxAttempts: 100, IpMaxFailures: 5})
+	id, _ := m.Issue("gif", "42")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Verify(id, "0", "2.2.2.2")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	wrong := 0
+	for err := range errs {
+		if err == ErrWrongAnswer {
+			wrong++
+		} else if err != ErrIpBlocked {
+			t.Errorf("unexpected %v", err)
+		}
+	}
+	if wrong != 5 {
+		t.Errorf("%d answers compared, want 5", wrong)
+	}
+	// 其他ip不受影响
+	if _, err := m.Verify(id, "42", "3.3.3.3"); err != nil {
+		t.Errorf("other ip %v", err)
+	}
+}
+
+// 凭证记录验证码类型，Require只接受配置的类型
+func TestRequireModes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newTestManager(Config{})
+	r := gin.New()
+	r.GET("/", Require(m), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	for mode, want := range map[string]bool{"text": true, "gif": true, "audio": true, "math": false} {
+		id, _ := m.Issue(mode, "12")
+		ticket, err := m.Verify(id, "12", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/?"+TicketParam+"="+ticket, nil))
+		if got := w.Body.String() == "ok"; got != want {
+			t.Errorf("%s ticket accepted %v, want %v", mode, got, want)
+		}
+	}
+
+	// 同一个答案换了类型也不能通过
+	id, _ := m.Issue("math", "12")
+	value, _, _ := m.store.Get(challengeKeyPrefix + id)
+	_, hash := splitChallenge(value)
+	m.store.Set(challengeKeyPrefix+id, "text:"+hash, 300)
+	if _, err := m.Verify(id, "12", ""); err != ErrWrongAnswer {
+		t.Errorf("changed mode %v", err)
+	}
+
+	only := newTestManager(Config{Modes: []string{"audio"}})
+	id, _ = only.Issue("text", "12")
+	ticket, _ := only.Verify(id, "12", "")
+	if mode, ok, _ := only.Redeem(ticket); !ok || only.Accepts(mode) {
+		t.Errorf("text accepted by audio only manager")
+	}
+}
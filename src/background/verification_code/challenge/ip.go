@@ -0,0 +1,82 @@
+package challenge
+
+import (
+	"background/common/logger"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 解析ip或CIDR，单个ip按/32或/128处理
+func parseProxies(proxies []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				logger.Error("Invalid trusted proxy ", p)
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			logger.Error("Invalid trusted proxy ", p, " ", err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func (m *Manager) trusted(ip net.IP) bool {
+	for _, n := range m.proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	客户端ip，用于按ip限制错误次数。只有直接连接的地址是可信代理时才使用X-Forwarded-For，
+	从右向左跳过可信代理，取第一个不可信的地址；否则使用连接地址，避免伪造header绕过限制
+*/
+func (m *Manager) ClientIP(r *http.Request) string {
+	remote := strings.TrimSpace(r.RemoteAddr)
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	ip := net.ParseIP(remote)
+	if ip == nil || !m.trusted(ip) {
+		return remote
+	}
+
+	if header := r.Header.Get("X-Forwarded-For"); header != "" {
+		forwarded := strings.Split(header, ",")
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			addr := net.ParseIP(strings.TrimSpace(forwarded[i]))
+			if addr == nil {
+				break
+			}
+			if !m.trusted(addr) {
+				return addr.String()
+			}
+			ip = addr
+		}
+		return ip.String()
+	}
+	if real := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); real != nil {
+		return real.String()
+	}
+	return remote
+}
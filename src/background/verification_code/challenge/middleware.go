@@ -0,0 +1,56 @@
+package challenge
+
+import (
+	"background/common/constant"
+	"background/common/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TicketParam  = "captcha_ticket"
+	TicketHeader = "X-Captcha-Ticket"
+	IdParam      = "captcha_id"
+	AnswerParam  = "captcha_code"
+)
+
+/*
+	要求请求带有验证通过的凭证(参数captcha_ticket或header X-Captcha-Ticket)，
+	也可以直接带captcha_id和captcha_code在请求时验证。凭证使用后作废，
+	验证码类型不在Config.Modes中的凭证不能使用
+*/
+func Require(m *Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket := c.Request.Header.Get(TicketHeader)
+		if ticket == "" {
+			ticket = c.Request.FormValue(TicketParam)
+		}
+		if ticket == "" {
+			if id := c.Request.FormValue(IdParam); id != "" {
+				var err error
+				ticket, err = m.Verify(id, c.Request.FormValue(AnswerParam), m.ClientIP(c.Request))
+				if IsVerifyError(err) {
+					c.AbortWithStatusJSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": err.Error()})
+					return
+				} else if err != nil {
+					logger.Error(err)
+					c.AbortWithStatus(http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		mode, ok, err := m.Redeem(ticket)
+		if err != nil {
+			logger.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !ok || !m.Accepts(mode) {
+			c.AbortWithStatusJSON(http.StatusOK, gin.H{"err_code": constant.Failure, "err_msg": "请先完成验证码"})
+			return
+		}
+		c.Next()
+	}
+}
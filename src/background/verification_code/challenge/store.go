@@ -0,0 +1,137 @@
+package challenge
+
+import (
+	"background/common/cache"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	gocache "github.com/patrickmn/go-cache"
+)
+
+/*
+	验证码答案和通过凭证的存储，多个服务共用时需要使用RedisStore
+*/
+type Store interface {
+	Set(key, value string, ttl int) error
+	Get(key string) (string, bool, error)
+	// 读取并删除，同一个key只有一次调用能读到
+	Take(key string) (string, bool, error)
+	Delete(key string) error
+	// 计数加1，第一次计数时设置过期时间
+	Incr(key string, ttl int) (int, error)
+	// 计数减1，key不存在时忽略
+	Decr(key string) error
+}
+
+type RedisStore struct {
+	pool *redis.Pool
+}
+
+func NewRedisStore(pool *redis.Pool) *RedisStore {
+	return &RedisStore{pool: pool}
+}
+
+func (s *RedisStore) Set(key, value string, ttl int) error {
+	return cache.RedisSetString(key, value, ttl, s.pool)
+}
+
+func (s *RedisStore) Get(key string) (string, bool, error) {
+	value, err := cache.RedisGetString(key, s.pool)
+	if err == redis.ErrNil {
+		return "", false, nil
+	}
+	return value, err == nil, err
+}
+
+func (s *RedisStore) Take(key string) (string, bool, error) {
+	value, err := cache.RedisTakeString(key, s.pool)
+	if err == redis.ErrNil {
+		return "", false, nil
+	}
+	return value, err == nil, err
+}
+
+func (s *RedisStore) Delete(key string) error {
+	return cache.RedisDelKey(key, s.pool)
+}
+
+func (s *RedisStore) Incr(key string, ttl int) (int, error) {
+	return cache.RedisIncrCount(key, ttl, s.pool)
+}
+
+func (s *RedisStore) Decr(key string) error {
+	// key过期后DECR会生成一个没有过期时间的-1，先确认存在
+	if _, ok, err := s.Get(key); err != nil || !ok {
+		return err
+	}
+	return cache.RedisDecrCount(key, s.pool)
+}
+
+/*
+	进程内存储，没有配置redis时使用，只能在签发验证码的进程内校验
+*/
+type MemoryStore struct {
+	lock sync.Mutex
+	mem  *gocache.Cache
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{mem: gocache.New(time.Minute*5, time.Minute)}
+}
+
+func (s *MemoryStore) Set(key, value string, ttl int) error {
+	s.mem.Set(key, value, time.Second*time.Duration(ttl))
+	return nil
+}
+
+func (s *MemoryStore) Get(key string) (string, bool, error) {
+	value, ok := s.mem.Get(key)
+	if !ok {
+		return "", false, nil
+	}
+	// Incr保存的计数
+	if n, ok := value.(int); ok {
+		return strconv.Itoa(n), true, nil
+	}
+	return value.(string), true, nil
+}
+
+func (s *MemoryStore) Take(key string) (string, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	value, ok := s.mem.Get(key)
+	if !ok {
+		return "", false, nil
+	}
+	s.mem.Delete(key)
+	return value.(string), true, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mem.Delete(key)
+	return nil
+}
+
+func (s *MemoryStore) Incr(key string, ttl int) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.mem.Add(key, 1, time.Second*time.Duration(ttl)); err == nil {
+		return 1, nil
+	}
+	return s.mem.IncrementInt(key, 1)
+}
+
+func (s *MemoryStore) Decr(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.mem.Get(key); !ok {
+		return nil
+	}
+	_, err := s.mem.DecrementInt(key, 1)
+	return err
+}
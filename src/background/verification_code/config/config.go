@@ -22,6 +22,23 @@ type config struct {
 	FontsDir      string `json:"fonts_dir"`
 	IndexHtml     string `json:"index_html"`
 
+	RedisAddr            string `json:"redis_addr"` // 为空时验证码保存在内存中，其他服务不能校验
+	RedisPassword        string `json:"redis_password"`
+	CaptchaTTL           int    `json:"captcha_ttl"`             // 验证码有效期(秒)
+	CaptchaMaxAttempts   int    `json:"captcha_max_attempts"`    // 每个验证码允许的错误次数
+	CaptchaIpMaxFailures int    `json:"captcha_ip_max_failures"` // 每个ip在captcha_ip_window秒内允许的错误次数
+	CaptchaIpWindow      int    `json:"captcha_ip_window"`
+	CaptchaTicketTTL     int    `json:"captcha_ticket_ttl"`   // 验证通过凭证的有效期(秒)
+	CaptchaDefaultMode   string `json:"captcha_default_mode"` // 没有配置consumer时的验证码类型
+	CaptchaLength        int    `json:"captcha_length"`       // 文字和音频验证码的字符数
+	CaptchaSoundsDir     string `json:"captcha_sounds_dir"`   // 数字读音0.wav～9.wav所在目录，为空时不支持音频验证码
+	CaptchaGifFrames     int    `json:"captcha_gif_frames"`
+	CaptchaGifDelay      int    `json:"captcha_gif_delay"` // 每帧的时间(1/100秒)
+
+	CaptchaTrustedProxies []string `json:"captcha_trusted_proxies"` // 反向代理的ip或CIDR，只信任这些地址转发的X-Forwarded-For
+
+	CaptchaConsumers map[string][]string `json:"captcha_consumers"` // 各接入服务允许的验证码类型，第一个为默认类型
+
 }
 
 var c config
@@ -40,6 +57,15 @@ func init() {
 	c.StaticRoot = "/root/data/storage/"
 	c.FontsDir = "/root/Git/e94/src/background/verification_code/fonts"
 	c.IndexHtml = "/root/Git/e94/src/background/verification_code/tpl/index.html"
+	c.CaptchaTTL = 300
+	c.CaptchaMaxAttempts = 3
+	c.CaptchaIpMaxFailures = 20
+	c.CaptchaIpWindow = 600
+	c.CaptchaTicketTTL = 300
+	c.CaptchaDefaultMode = "text"
+	c.CaptchaLength = 4
+	c.CaptchaGifFrames = 8
+	c.CaptchaGifDelay = 20
 
 }
 
@@ -117,4 +143,66 @@ func GetFontsDir() string {
 
 func GetIndexHtml() string {
 	return c.IndexHtml
-}
\ No newline at end of file
+}
+
+func GetRedisAddr() string {
+	return c.RedisAddr
+}
+
+func GetRedisPassword() string {
+	return c.RedisPassword
+}
+
+func GetCaptchaTTL() int {
+	return c.CaptchaTTL
+}
+
+func GetCaptchaMaxAttempts() int {
+	return c.CaptchaMaxAttempts
+}
+
+func GetCaptchaIpMaxFailures() int {
+	return c.CaptchaIpMaxFailures
+}
+
+func GetCaptchaIpWindow() int {
+	return c.CaptchaIpWindow
+}
+
+func GetCaptchaTicketTTL() int {
+	return c.CaptchaTicketTTL
+}
+
+func GetCaptchaDefaultMode() string {
+	return c.CaptchaDefaultMode
+}
+
+/*
+	接入服务允许的验证码类型，没有配置的consumer只能使用默认类型
+*/
+func GetCaptchaModes(consumer string) []string {
+	if modes := c.CaptchaConsumers[consumer]; len(modes) > 0 {
+		return modes
+	}
+	return []string{c.CaptchaDefaultMode}
+}
+
+func GetCaptchaLength() int {
+	return c.CaptchaLength
+}
+
+func GetCaptchaSoundsDir() string {
+	return c.CaptchaSoundsDir
+}
+
+func GetCaptchaGifFrames() int {
+	return c.CaptchaGifFrames
+}
+
+func GetCaptchaGifDelay() int {
+	return c.CaptchaGifDelay
+}
+
+func GetCaptchaTrustedProxies() []string {
+	return c.CaptchaTrustedProxies
+}
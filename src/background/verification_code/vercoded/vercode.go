@@ -1,23 +1,32 @@
 package main
 
 import (
+	"background/common/cache"
+	"background/common/constant"
+	"background/common/logger"
 	"background/verification_code/captcha"
+	"background/verification_code/challenge"
 	"background/verification_code/config"
-	"background/common/logger"
-	"os"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
+	"html/template"
 	"io/ioutil"
-	"strings"
 	"log"
-	"html/template"
-	"flag"
+	"net/http"
+	"os"
+	"strings"
 )
+
 const (
 	dx = 80
 	dy = 30
 )
 
+var manager *challenge.Manager
+
 func main() {
 
 	configPath := flag.String("conf", "../config/config.json", "Config file path")
@@ -29,17 +38,41 @@ func main() {
 		return
 	}
 
-	fontFils,err := ListDir(config.GetFontsDir(),".ttf");
-	if(err != nil){
+	fontFils, err := ListDir(config.GetFontsDir(), ".ttf")
+	if err != nil {
 		logger.Error(err)
-		return ;
+		return
 	}
 
-	captcha.SetFontFamily(fontFils...);
+	captcha.SetFontFamily(fontFils...)
+	registerModes()
+
+	var store challenge.Store
+	if config.GetRedisAddr() != "" {
+		if err := cache.RedisTest(config.GetRedisAddr(), config.GetRedisPassword()); err != nil {
+			logger.Error(err)
+			return
+		}
+		store = challenge.NewRedisStore(cache.GetRedisPool(config.GetRedisAddr(), config.GetRedisPassword()))
+	} else {
+		logger.Warn("没有配置redis，验证码保存在内存中，其他服务不能校验")
+		store = challenge.NewMemoryStore()
+	}
+	manager = challenge.NewManager(store, challenge.Config{
+		TTL:           config.GetCaptchaTTL(),
+		MaxAttempts:   config.GetCaptchaMaxAttempts(),
+		IpMaxFailures: config.GetCaptchaIpMaxFailures(),
+		IpWindow:      config.GetCaptchaIpWindow(),
+		TicketTTL:     config.GetCaptchaTicketTTL(),
+
+		TrustedProxies: config.GetCaptchaTrustedProxies(),
+	}.WithDefaults())
 
 	http.HandleFunc("/", Index)
 	http.HandleFunc("/get/", Get)
-	fmt.Println("服务已启动...");
+	http.HandleFunc("/challenge", Challenge)
+	http.HandleFunc("/verify", Verify)
+	fmt.Println("服务已启动...")
 	err = http.ListenAndServe(":8000", nil)
 	if err != nil {
 		logger.Error(err)
@@ -53,26 +86,148 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	}
 	t.Execute(w, nil)
 }
+
+func registerModes() {
+	captcha.Register(captcha.ModeText, &captcha.TextGenerator{Length: config.GetCaptchaLength()})
+	captcha.Register(captcha.ModeMath, &captcha.MathGenerator{})
+	captcha.Register(captcha.ModeGif, &captcha.GifGenerator{
+		Length: config.GetCaptchaLength(),
+		Frames: config.GetCaptchaGifFrames(),
+		Delay:  config.GetCaptchaGifDelay(),
+	})
+	if config.GetCaptchaSoundsDir() == "" {
+		return
+	}
+	audio, err := captcha.NewAudioGenerator(config.GetCaptchaSoundsDir(), config.GetCaptchaLength())
+	if err != nil {
+		logger.Error("加载数字读音失败，不支持音频验证码:", err)
+		return
+	}
+	captcha.Register(captcha.ModeAudio, audio)
+}
+
+var errModeNotAllowed = errors.New("该服务不允许此验证码类型")
+
+/*
+	按接入服务consumer允许的类型生成验证码，mode为空时使用第一个允许的类型。
+	类型由服务端配置决定并记录在验证码中，接入服务只接受自己允许类型的凭证。
+	format为图片格式jpeg、png，只对text、math有效
+*/
+func issue(r *http.Request) (string, *captcha.Challenge, error) {
+	modes := config.GetCaptchaModes(r.FormValue("consumer"))
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = modes[0]
+	} else if !allowed(modes, mode) {
+		return "", nil, errModeNotAllowed
+	}
+	format, err := captcha.ParseImageFormat(r.FormValue("format"))
+	if err != nil {
+		return "", nil, err
+	}
+	c, err := captcha.Generate(mode, captcha.Options{Width: dx, Height: dy, Format: format})
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := manager.Issue(mode, c.Answer)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, c, nil
+}
+
+func allowed(modes []string, mode string) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// 参数错误时返回400
+func writeIssueError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == captcha.ErrUnknownMode || err == captcha.ErrUnknownFormat || err == errModeNotAllowed {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJson(w, map[string]interface{}{"err_code": constant.Failure, "err_msg": err.Error(), "modes": config.GetCaptchaModes(r.FormValue("consumer"))})
+		return
+	}
+	logger.Error(err)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// 直接返回图片或音频，验证码id在header X-Captcha-Id中
 func Get(w http.ResponseWriter, r *http.Request) {
-	captchaImage,err := captcha.NewCaptchaImage(dx,dy,captcha.RandLightColor());
-	captchaImage.DrawNoise(captcha.CaptchaComplexHigh);
-	captchaImage.DrawTextNoise(captcha.CaptchaComplexHigh);
-	captchaImage.DrawText(captcha.RandText(4));
-	//captchaImage.Drawline(3);
-	captchaImage.DrawBorder(captcha.ColorToRGB(0x17A7A7A));
-	captchaImage.DrawHollowLine();
+	id, c, err := issue(r)
+	if err != nil {
+		writeIssueError(w, r, err)
+		return
+	}
+	w.Header().Set("X-Captcha-Id", id)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", c.ContentType)
+	w.Write(c.Data)
+}
+
+func writeJson(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		logger.Error(err)
+	}
+}
+
+/*
+	GET /challenge?consumer=doctor_comment&mode=text|math|gif|audio&format=jpeg|png
+	返回验证码id和base64编码的图片，音频验证码在audio中
+*/
+func Challenge(w http.ResponseWriter, r *http.Request) {
+	id, c, err := issue(r)
 	if err != nil {
+		writeIssueError(w, r, err)
+		return
+	}
+	key := "image"
+	if strings.HasPrefix(c.ContentType, "audio/") {
+		key = "audio"
+	}
+	writeJson(w, map[string]interface{}{"err_code": constant.Success, "data": map[string]interface{}{
+		"id":         id,
+		key:          "data:" + c.ContentType + ";base64," + base64.StdEncoding.EncodeToString(c.Data),
+		"expires_in": config.GetCaptchaTTL(),
+	}})
+}
+
+/*
+	POST /verify
+	参数id、code，验证通过时返回一次性凭证ticket，提交到需要验证码的接口
+*/
+func Verify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ticket, err := manager.Verify(r.FormValue("id"), r.FormValue("code"), manager.ClientIP(r))
+	if challenge.IsVerifyError(err) {
+		writeJson(w, map[string]interface{}{"err_code": constant.Failure, "err_msg": err.Error()})
+		return
+	} else if err != nil {
 		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
-	captchaImage.SaveImage(w,captcha.ImageFormatJpeg);
+	writeJson(w, map[string]interface{}{"err_code": constant.Success, "data": map[string]interface{}{
+		"ticket":     ticket,
+		"expires_in": config.GetCaptchaTicketTTL(),
+	}})
 }
 
-//获取指定目录下的所有文件，不进入下一级目录搜索，可以匹配后缀过滤。
+// 获取指定目录下的所有文件，不进入下一级目录搜索，可以匹配后缀过滤。
 func ListDir(dirPth string, suffix string) (files []string, err error) {
 	files = make([]string, 0, 10)
 	dir, err := ioutil.ReadDir(dirPth)
 	if err != nil {
-		logger.Error(err,dirPth)
+		logger.Error(err, dirPth)
 		return nil, err
 	}
 	PthSep := string(os.PathSeparator)
@@ -86,4 +241,4 @@ func ListDir(dirPth string, suffix string) (files []string, err error) {
 		}
 	}
 	return files, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,4 @@
+package sourcemap
+type Consumer struct{}
+func Parse(string, []byte) (*Consumer, error) { return nil, nil }
+func (c *Consumer) Source(genLine, genCol int) (source, name string, line, col int, ok bool) { return }